@@ -2,14 +2,19 @@ package backend
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/OpenSlides/openslides-autoupdate-service/pkg/environment"
+	"github.com/OpenSlides/openslides-vote-service/backend/file"
 	"github.com/OpenSlides/openslides-vote-service/backend/memory"
 	"github.com/OpenSlides/openslides-vote-service/backend/postgres"
 	"github.com/OpenSlides/openslides-vote-service/backend/redis"
+	"github.com/OpenSlides/openslides-vote-service/log"
 	"github.com/OpenSlides/openslides-vote-service/vote"
 )
 
@@ -22,18 +27,41 @@ var (
 	envPostgresUser         = environment.NewVariable("VOTE_DATABASE_USER", "openslides", "Databasename of the postgres database used for long polls.")
 	envPostgresDatabase     = environment.NewVariable("VOTE_DATABASE_NAME", "openslides", "Name of the database to save long running polls.")
 	envPostgresPasswordFile = environment.NewVariable("VOTE_DATABASE_PASSWORD_FILE", "/run/secrets/postgres_password", "Password of the postgres database used for long polls.")
+	envPostgresMaxConns     = environment.NewVariable("VOTE_DATABASE_MAX_CONNS", "", "Maximum number of connections to the postgres database. Empty uses the default of the driver, the greater of 4 and the number of CPUs.")
+	envPostgresMinConns     = environment.NewVariable("VOTE_DATABASE_MIN_CONNS", "", "Minimum number of connections to the postgres database, that are kept open. Empty uses the default of the driver, that is 0.")
+	envPostgresReplicaHost  = environment.NewVariable("VOTE_DATABASE_REPLICA_HOST", "", "Host of a read replica of the postgres database. If set, read only queries like the voted users are send to the replica. Because of the replication lag, the returned data can be outdated. The other connection settings are the same as for the primary database.")
 
 	envSingleInstance = environment.NewVariable("VOTE_SINGLE_INSTANCE", "false", "More performance if the serice is not scalled horizontally.")
+
+	envMemorySnapshotFile = environment.NewVariable("VOTE_MEMORY_SNAPSHOT_FILE", "", "File to periodically save the data of the memory backend. It is loaded on startup, if it exists. Only used with VOTE_SINGLE_INSTANCE. Empty disables snapshots.")
+
+	envFileBackendDir = environment.NewVariable("VOTE_FILE_BACKEND_DIR", "", "Directory for the file backend. If set, long polls are saved in append only files in this directory instead of postgres. The files can be used as audit trail.")
 )
 
+// snapshotInterval is the time between two snapshots of the memory backend.
+const snapshotInterval = 10 * time.Second
+
 // Build builds a fast and a long backends from the environment.
 func Build(lookup environment.Environmenter) (fast, long func(context.Context) (vote.Backend, error), singleInstance bool, err error) {
 	// All environment variables have to be called in this function and not in a
 	// sub function. In other case they will not be included in the generated
 	// file environment.md.
 
-	buildMemory := func(_ context.Context) (vote.Backend, error) {
-		return memory.New(), nil
+	// The snapshot is restored, before the backend is returned. So vote.New
+	// loads the users, that have voted, from the restored data.
+	snapshotFile := envMemorySnapshotFile.Value(lookup)
+	buildMemory := func(ctx context.Context) (vote.Backend, error) {
+		m := memory.New()
+		if snapshotFile == "" {
+			return m, nil
+		}
+
+		if err := restoreMemory(m, snapshotFile); err != nil {
+			return nil, fmt.Errorf("restore memory backend: %w", err)
+		}
+
+		go snapshotMemoryLoop(ctx, m, snapshotFile)
+		return m, nil
 	}
 
 	redisAddr := envRedisHost.Value(lookup) + ":" + envRedisPort.Value(lookup)
@@ -61,8 +89,31 @@ func Build(lookup environment.Environmenter) (fast, long func(context.Context) (
 		encodePostgresConfig(envPostgresDatabase.Value(lookup)),
 	)
 
+	maxConns, err := parsePoolSize(envPostgresMaxConns.Value(lookup))
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("invalid value for `%s`: %w", envPostgresMaxConns.Key, err)
+	}
+
+	minConns, err := parsePoolSize(envPostgresMinConns.Value(lookup))
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("invalid value for `%s`: %w", envPostgresMinConns.Key, err)
+	}
+
+	postgresOptions := []postgres.Option{postgres.WithPoolSize(minConns, maxConns)}
+	if replicaHost := envPostgresReplicaHost.Value(lookup); replicaHost != "" {
+		replicaAddr := fmt.Sprintf(
+			`user='%s' password='%s' host='%s' port='%s' dbname='%s'`,
+			encodePostgresConfig(envPostgresUser.Value(lookup)),
+			dbPassword,
+			encodePostgresConfig(replicaHost),
+			encodePostgresConfig(envPostgresPort.Value(lookup)),
+			encodePostgresConfig(envPostgresDatabase.Value(lookup)),
+		)
+		postgresOptions = append(postgresOptions, postgres.WithReplica(replicaAddr))
+	}
+
 	buildPostgres := func(ctx context.Context) (vote.Backend, error) {
-		p, err := postgres.New(ctx, postgresAddr)
+		p, err := postgres.New(ctx, postgresAddr, postgresOptions...)
 		if err != nil {
 			return nil, fmt.Errorf("creating postgres connection pool: %w", err)
 		}
@@ -74,7 +125,20 @@ func Build(lookup environment.Environmenter) (fast, long func(context.Context) (
 		return p, nil
 	}
 
+	fileDir := envFileBackendDir.Value(lookup)
+	buildFile := func(ctx context.Context) (vote.Backend, error) {
+		f, err := file.New(fileDir)
+		if err != nil {
+			return nil, fmt.Errorf("creating file backend: %w", err)
+		}
+		return f, nil
+	}
+
 	long = buildPostgres
+	if fileDir != "" {
+		long = buildFile
+	}
+
 	fast = buildRedis
 	singleInstace, _ := strconv.ParseBool(envSingleInstance.Value(lookup))
 	if singleInstace {
@@ -84,6 +148,94 @@ func Build(lookup environment.Environmenter) (fast, long func(context.Context) (
 	return fast, long, singleInstace, nil
 }
 
+// parsePoolSize parses the size of a connection pool. An empty value returns
+// 0, which means the default.
+func parsePoolSize(value string) (int32, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("expected positive int got %s: %w", value, err)
+	}
+
+	if n <= 0 {
+		return 0, fmt.Errorf("expected positive int got %s", value)
+	}
+
+	return int32(n), nil
+}
+
+// restoreMemory loads the snapshot file into the memory backend. Does nothing,
+// if the file does not exist.
+func restoreMemory(m *memory.Backend, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("open snapshot file: %w", err)
+	}
+	defer f.Close()
+
+	if err := m.Restore(f); err != nil {
+		return fmt.Errorf("restoring snapshot from %s: %w", file, err)
+	}
+
+	log.Info("Restored memory backend from %s", file)
+	return nil
+}
+
+// snapshotMemory writes a snapshot of the memory backend to the file.
+//
+// The snapshot is first written to a temporary file, so a crash while writing
+// does not destroy the last snapshot.
+func snapshotMemory(m *memory.Backend, file string) error {
+	tmpFile := file + ".tmp"
+	f, err := os.Create(tmpFile)
+	if err != nil {
+		return fmt.Errorf("creating snapshot file: %w", err)
+	}
+
+	if err := m.Snapshot(f); err != nil {
+		f.Close()
+		return fmt.Errorf("writing snapshot: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing snapshot file: %w", err)
+	}
+
+	if err := os.Rename(tmpFile, file); err != nil {
+		return fmt.Errorf("replacing snapshot file: %w", err)
+	}
+
+	return nil
+}
+
+// snapshotMemoryLoop writes a snapshot of the memory backend periodically and
+// a last time, when the context is done.
+func snapshotMemoryLoop(ctx context.Context, m *memory.Backend, file string) {
+	ticker := time.NewTicker(snapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := snapshotMemory(m, file); err != nil {
+				log.Info("Error: last snapshot of memory backend: %v", err)
+			}
+			return
+
+		case <-ticker.C:
+			if err := snapshotMemory(m, file); err != nil {
+				log.Info("Error: snapshot of memory backend: %v", err)
+			}
+		}
+	}
+}
+
 // encodePostgresConfig encodes a string to be used in the postgres key value style.
 //
 // See: https://www.postgresql.org/docs/current/libpq-connect.html#LIBPQ-CONNSTRING
@@ -0,0 +1,823 @@
+// Package file implements the vote.Backend interface.
+//
+// All data are saved in files in a directory. Each poll has a state file, an
+// append only log file and, if needed, a file with its times as json. Each
+// entry of the log file contains the user id, the nonce and the vote object of
+// one vote, so it is written at once. The log files can be used as audit trail.
+//
+// A retracted vote is not removed from the log file. Instead, an entry with the
+// negative user id and without a vote object is appended.
+package file
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	stateStarted = "started"
+	stateStopped = "stopped"
+)
+
+// Backend is a vote backend that writes the data to files.
+type Backend struct {
+	dir string
+
+	// clearMu is held for writing while all files are removed. All other
+	// methods hold it for reading.
+	clearMu sync.RWMutex
+
+	locksMu sync.Mutex
+	locks   map[int]*sync.Mutex
+}
+
+// New initializes a new file.Backend that saves its files in dir.
+//
+// The directory is created, if it does not exist.
+func New(dir string) (*Backend, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating directory %s: %w", dir, err)
+	}
+
+	b := Backend{
+		dir:   dir,
+		locks: make(map[int]*sync.Mutex),
+	}
+	return &b, nil
+}
+
+func (b *Backend) String() string {
+	return "file"
+}
+
+// Ping checks, that the directory is accessible.
+func (b *Backend) Ping(ctx context.Context) error {
+	if _, err := os.Stat(b.dir); err != nil {
+		return fmt.Errorf("checking directory: %w", err)
+	}
+	return nil
+}
+
+// lockPoll locks the poll with the given id. The returned function unlocks
+// it.
+func (b *Backend) lockPoll(pollID int) func() {
+	b.clearMu.RLock()
+
+	b.locksMu.Lock()
+	mu, ok := b.locks[pollID]
+	if !ok {
+		mu = new(sync.Mutex)
+		b.locks[pollID] = mu
+	}
+	b.locksMu.Unlock()
+
+	mu.Lock()
+	return func() {
+		mu.Unlock()
+		b.clearMu.RUnlock()
+	}
+}
+
+func (b *Backend) path(pollID int, ext string) string {
+	return filepath.Join(b.dir, fmt.Sprintf("poll-%d.%s", pollID, ext))
+}
+
+// state returns the state of a poll or an empty string, if the poll does not
+// exist.
+func (b *Backend) state(pollID int) (string, error) {
+	data, err := os.ReadFile(b.path(pollID, "state"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", fmt.Errorf("reading state file: %w", err)
+	}
+	return string(data), nil
+}
+
+// setState writes the state of a poll.
+func (b *Backend) setState(pollID int, state string) error {
+	if err := replaceFile(b.path(pollID, "state"), []byte(state)); err != nil {
+		return fmt.Errorf("writing state file: %w", err)
+	}
+	return nil
+}
+
+// replaceFile writes data to a file.
+//
+// The data is first written to a temporary file, so a crash while writing
+// does not destroy the old content.
+func replaceFile(file string, data []byte) error {
+	tmpFile := file + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0o600); err != nil {
+		return fmt.Errorf("writing temporary file: %w", err)
+	}
+
+	if err := os.Rename(tmpFile, file); err != nil {
+		return fmt.Errorf("replacing file: %w", err)
+	}
+	return nil
+}
+
+// Start opens opens a poll.
+func (b *Backend) Start(ctx context.Context, pollID int) error {
+	unlock := b.lockPoll(pollID)
+	defer unlock()
+
+	state, err := b.state(pollID)
+	if err != nil {
+		return fmt.Errorf("fetching state: %w", err)
+	}
+
+	if state != "" {
+		return nil
+	}
+
+	if err := b.setState(pollID, stateStarted); err != nil {
+		return fmt.Errorf("starting poll: %w", err)
+	}
+	return nil
+}
+
+// Stop stopps a poll.
+func (b *Backend) Stop(ctx context.Context, pollID int) ([][]byte, []int, error) {
+	unlock := b.lockPoll(pollID)
+	defer unlock()
+
+	state, err := b.state(pollID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fetching state: %w", err)
+	}
+
+	if state == "" {
+		return nil, nil, doesNotExistError{fmt.Errorf("Poll does not exist")}
+	}
+
+	if state != stateStopped {
+		if err := b.setState(pollID, stateStopped); err != nil {
+			return nil, nil, fmt.Errorf("stopping poll: %w", err)
+		}
+	}
+
+	objects, userIDs, err := b.readVotes(pollID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading votes: %w", err)
+	}
+
+	sort.Ints(userIDs)
+	return objects, userIDs, nil
+}
+
+// Result returns the vote objects and user ids of a stopped poll like Stop,
+// but does not change the poll.
+func (b *Backend) Result(ctx context.Context, pollID int) ([][]byte, []int, error) {
+	unlock := b.lockPoll(pollID)
+	defer unlock()
+
+	state, err := b.state(pollID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fetching state: %w", err)
+	}
+
+	if state == "" {
+		return nil, nil, doesNotExistError{fmt.Errorf("Poll does not exist")}
+	}
+
+	if state != stateStopped {
+		return nil, nil, startedError{fmt.Errorf("poll is not stopped")}
+	}
+
+	objects, userIDs, err := b.readVotes(pollID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading votes: %w", err)
+	}
+
+	sort.Ints(userIDs)
+	return objects, userIDs, nil
+}
+
+// Reopen starts a stopped poll again.
+func (b *Backend) Reopen(ctx context.Context, pollID int) error {
+	unlock := b.lockPoll(pollID)
+	defer unlock()
+
+	state, err := b.state(pollID)
+	if err != nil {
+		return fmt.Errorf("fetching state: %w", err)
+	}
+
+	if state == "" {
+		return doesNotExistError{fmt.Errorf("Poll does not exist")}
+	}
+
+	if err := b.setState(pollID, stateStarted); err != nil {
+		return fmt.Errorf("reopening poll: %w", err)
+	}
+	return nil
+}
+
+// Vote saves a vote.
+//
+// The vote object is appended to the log file of the poll together with the
+// user id.
+func (b *Backend) Vote(ctx context.Context, pollID int, userID int, object []byte) error {
+	return b.VoteWithNonce(ctx, pollID, userID, "", object)
+}
+
+// VoteWithNonce saves a vote like Vote, but only if the nonce was not used
+// before on the poll. The nonce is written in the same log entry as the vote,
+// so it is only used, if the vote is saved.
+func (b *Backend) VoteWithNonce(ctx context.Context, pollID int, userID int, nonce string, object []byte) error {
+	unlock := b.lockPoll(pollID)
+	defer unlock()
+
+	state, err := b.state(pollID)
+	if err != nil {
+		return fmt.Errorf("fetching state: %w", err)
+	}
+
+	if state == "" {
+		return doesNotExistError{fmt.Errorf("poll is not started")}
+	}
+
+	if state == stateStopped {
+		return stoppedError{fmt.Errorf("poll is stopped")}
+	}
+
+	entries, err := b.readLog(pollID)
+	if err != nil {
+		return fmt.Errorf("reading log file: %w", err)
+	}
+
+	for _, id := range userIDsOf(entries) {
+		if id == userID {
+			return doubleVoteError{fmt.Errorf("user has already voted")}
+		}
+	}
+
+	if nonce != "" {
+		for _, entry := range entries {
+			if entry.nonce == nonce {
+				return nonceUsedError{fmt.Errorf("nonce was already used")}
+			}
+		}
+	}
+
+	entry := logEntry{userID: userID, nonce: nonce, object: object}
+	if err := appendFile(b.path(pollID, "log"), entry.encode()); err != nil {
+		return fmt.Errorf("writing vote: %w", err)
+	}
+
+	return nil
+}
+
+// RetractVote removes the vote of a user.
+//
+// The retraction is appended to the log file. The vote object stays in the
+// sequence of VotesSince.
+func (b *Backend) RetractVote(ctx context.Context, pollID int, userID int) error {
+	unlock := b.lockPoll(pollID)
+	defer unlock()
+
+	state, err := b.state(pollID)
+	if err != nil {
+		return fmt.Errorf("fetching state: %w", err)
+	}
+
+	if state == "" {
+		return doesNotExistError{fmt.Errorf("Poll does not exist")}
+	}
+
+	if state == stateStopped {
+		return stoppedError{fmt.Errorf("poll is stopped")}
+	}
+
+	entries, err := b.readLog(pollID)
+	if err != nil {
+		return fmt.Errorf("reading log file: %w", err)
+	}
+
+	voted := false
+	for _, id := range userIDsOf(entries) {
+		if id == userID {
+			voted = true
+			break
+		}
+	}
+
+	if !voted {
+		return nil
+	}
+
+	entry := logEntry{userID: -userID}
+	if err := appendFile(b.path(pollID, "log"), entry.encode()); err != nil {
+		return fmt.Errorf("writing retraction: %w", err)
+	}
+
+	return nil
+}
+
+// Clear removes all data for a poll.
+func (b *Backend) Clear(ctx context.Context, pollID int) error {
+	unlock := b.lockPoll(pollID)
+	defer unlock()
+
+	for _, ext := range []string{"state", "log", "times"} {
+		if err := os.Remove(b.path(pollID, ext)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("removing %s file: %w", ext, err)
+		}
+	}
+	return nil
+}
+
+// ClearAll removes all data for all polls.
+func (b *Backend) ClearAll(ctx context.Context) error {
+	b.clearMu.Lock()
+	defer b.clearMu.Unlock()
+
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return fmt.Errorf("reading directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(b.dir, entry.Name())); err != nil {
+			return fmt.Errorf("removing %s: %w", entry.Name(), err)
+		}
+	}
+	return nil
+}
+
+// Voted returns for all polls, which users have voted.
+func (b *Backend) Voted(ctx context.Context) (map[int][]int, error) {
+	b.clearMu.RLock()
+	entries, err := os.ReadDir(b.dir)
+	b.clearMu.RUnlock()
+	if err != nil {
+		return nil, fmt.Errorf("reading directory: %w", err)
+	}
+
+	out := make(map[int][]int)
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".log")
+		if !ok {
+			continue
+		}
+
+		pollID, err := strconv.Atoi(strings.TrimPrefix(name, "poll-"))
+		if err != nil {
+			continue
+		}
+
+		unlock := b.lockPoll(pollID)
+		entries, err := b.readLog(pollID)
+		unlock()
+		if err != nil {
+			return nil, fmt.Errorf("reading log file of poll %d: %w", pollID, err)
+		}
+
+		userIDs := userIDsOf(entries)
+		if len(userIDs) == 0 {
+			continue
+		}
+
+		sort.Ints(userIDs)
+		out[pollID] = userIDs
+	}
+
+	return out, nil
+}
+
+// Polls returns all polls with a state file. The value is true, if the poll
+// is stopped.
+func (b *Backend) Polls(ctx context.Context) (map[int]bool, error) {
+	b.clearMu.RLock()
+	entries, err := os.ReadDir(b.dir)
+	b.clearMu.RUnlock()
+	if err != nil {
+		return nil, fmt.Errorf("reading directory: %w", err)
+	}
+
+	out := make(map[int]bool)
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".state")
+		if !ok {
+			continue
+		}
+
+		pollID, err := strconv.Atoi(strings.TrimPrefix(name, "poll-"))
+		if err != nil {
+			continue
+		}
+
+		unlock := b.lockPoll(pollID)
+		state, err := b.state(pollID)
+		unlock()
+		if err != nil {
+			return nil, fmt.Errorf("reading state of poll %d: %w", pollID, err)
+		}
+
+		if state == "" {
+			continue
+		}
+
+		out[pollID] = state == stateStopped
+	}
+
+	return out, nil
+}
+
+// SetTime saves a time with a name for a poll.
+//
+// The times are saved as unix time in nanoseconds.
+func (b *Backend) SetTime(ctx context.Context, pollID int, name string, t time.Time, overwrite bool) error {
+	unlock := b.lockPoll(pollID)
+	defer unlock()
+
+	state, err := b.state(pollID)
+	if err != nil {
+		return fmt.Errorf("fetching state: %w", err)
+	}
+
+	if state == "" {
+		return doesNotExistError{fmt.Errorf("Poll does not exist")}
+	}
+
+	times, err := b.readTimes(pollID)
+	if err != nil {
+		return fmt.Errorf("reading times: %w", err)
+	}
+
+	if t.IsZero() {
+		delete(times, name)
+	} else {
+		if _, ok := times[name]; ok && !overwrite {
+			return nil
+		}
+		times[name] = t.UnixNano()
+	}
+
+	data, err := json.Marshal(times)
+	if err != nil {
+		return fmt.Errorf("encoding times: %w", err)
+	}
+
+	if err := replaceFile(b.path(pollID, "times"), data); err != nil {
+		return fmt.Errorf("writing times file: %w", err)
+	}
+	return nil
+}
+
+// Times returns for all polls the time with the name.
+func (b *Backend) Times(ctx context.Context, name string) (map[int]time.Time, error) {
+	b.clearMu.RLock()
+	entries, err := os.ReadDir(b.dir)
+	b.clearMu.RUnlock()
+	if err != nil {
+		return nil, fmt.Errorf("reading directory: %w", err)
+	}
+
+	out := make(map[int]time.Time)
+	for _, entry := range entries {
+		fileName, ok := strings.CutSuffix(entry.Name(), ".times")
+		if !ok {
+			continue
+		}
+
+		pollID, err := strconv.Atoi(strings.TrimPrefix(fileName, "poll-"))
+		if err != nil {
+			continue
+		}
+
+		unlock := b.lockPoll(pollID)
+		times, err := b.readTimes(pollID)
+		unlock()
+		if err != nil {
+			return nil, fmt.Errorf("reading times of poll %d: %w", pollID, err)
+		}
+
+		if t, ok := times[name]; ok {
+			out[pollID] = time.Unix(0, t)
+		}
+	}
+
+	return out, nil
+}
+
+// readTimes reads the times of a poll as unix time in nanoseconds.
+func (b *Backend) readTimes(pollID int) (map[string]int64, error) {
+	times := make(map[string]int64)
+	data, err := os.ReadFile(b.path(pollID, "times"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return times, nil
+		}
+		return nil, fmt.Errorf("reading times file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &times); err != nil {
+		return nil, fmt.Errorf("decoding times file: %w", err)
+	}
+	return times, nil
+}
+
+// VotesSince returns all vote objects that were saved after afterSeq.
+//
+// The sequence number of a vote object is its position in the log file.
+func (b *Backend) VotesSince(ctx context.Context, pollID int, afterSeq int) ([][]byte, int, error) {
+	unlock := b.lockPoll(pollID)
+	defer unlock()
+
+	state, err := b.state(pollID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("fetching state: %w", err)
+	}
+
+	if state == "" {
+		return nil, 0, doesNotExistError{fmt.Errorf("Poll does not exist")}
+	}
+
+	entries, err := b.readLog(pollID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading log file: %w", err)
+	}
+
+	if afterSeq < 0 {
+		afterSeq = 0
+	}
+
+	if afterSeq >= len(entries) {
+		return nil, len(entries), nil
+	}
+
+	out := make([][]byte, 0, len(entries)-afterSeq)
+	for _, entry := range entries[afterSeq:] {
+		if entry.userID < 0 {
+			// Marker of a retracted vote.
+			continue
+		}
+		out = append(out, entry.object)
+	}
+	return out, len(entries), nil
+}
+
+// VotedObject returns the vote object of a user.
+func (b *Backend) VotedObject(ctx context.Context, pollID int, userID int) ([]byte, bool, error) {
+	unlock := b.lockPoll(pollID)
+	defer unlock()
+
+	state, err := b.state(pollID)
+	if err != nil {
+		return nil, false, fmt.Errorf("fetching state: %w", err)
+	}
+
+	if state == "" {
+		return nil, false, doesNotExistError{fmt.Errorf("Poll does not exist")}
+	}
+
+	objects, userIDs, err := b.readVotes(pollID)
+	if err != nil {
+		return nil, false, fmt.Errorf("reading votes: %w", err)
+	}
+
+	for i, id := range userIDs {
+		if id == userID {
+			return objects[i], true, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
+// readVotes reads the vote objects and the user ids of a poll in the order
+// they have voted. The n-th user id belongs to the n-th vote object. Retracted
+// votes are skipped.
+func (b *Backend) readVotes(pollID int) ([][]byte, []int, error) {
+	entries, err := b.readLog(pollID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading log file: %w", err)
+	}
+
+	votes := validEntries(entries)
+	objects := make([][]byte, len(votes))
+	userIDs := make([]int, len(votes))
+	for i, entry := range votes {
+		objects[i] = entry.object
+		userIDs[i] = entry.userID
+	}
+	return objects, userIDs, nil
+}
+
+// userIDsOf returns the ids of the users, that have voted and not retracted
+// their vote, in the order they have voted.
+func userIDsOf(entries []logEntry) []int {
+	votes := validEntries(entries)
+	userIDs := make([]int, len(votes))
+	for i, entry := range votes {
+		userIDs[i] = entry.userID
+	}
+	return userIDs
+}
+
+// validEntries returns the entries of all votes, that were not retracted.
+func validEntries(entries []logEntry) []logEntry {
+	var votes []logEntry
+	for _, entry := range entries {
+		if entry.userID >= 0 {
+			votes = append(votes, entry)
+			continue
+		}
+
+		for i, vote := range votes {
+			if vote.userID == -entry.userID {
+				votes = append(votes[:i], votes[i+1:]...)
+				break
+			}
+		}
+	}
+	return votes
+}
+
+// logEntry is one entry of the log file of a poll. A negative user id marks
+// the retraction of the vote of the user.
+type logEntry struct {
+	userID int
+	nonce  string
+	object []byte
+}
+
+// encode returns the entry as it is written to the log file.
+//
+// The entry is the user id, the length of the nonce, the nonce, the length of
+// the vote object and the vote object, each separated by a space, followed by a
+// newline.
+func (e logEntry) encode() []byte {
+	record := make([]byte, 0, len(e.nonce)+len(e.object)+32)
+	record = strconv.AppendInt(record, int64(e.userID), 10)
+	record = append(record, ' ')
+	record = strconv.AppendInt(record, int64(len(e.nonce)), 10)
+	record = append(record, ' ')
+	record = append(record, e.nonce...)
+	record = append(record, ' ')
+	record = strconv.AppendInt(record, int64(len(e.object)), 10)
+	record = append(record, ' ')
+	record = append(record, e.object...)
+	record = append(record, '\n')
+	return record
+}
+
+// readLog reads all entries from the log file of a poll.
+func (b *Backend) readLog(pollID int) ([]logEntry, error) {
+	f, err := os.Open(b.path(pollID, "log"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("open log file: %w", err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var entries []logEntry
+	for {
+		if _, err := r.Peek(1); errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+
+		entry, err := readLogEntry(r)
+		if err != nil {
+			return nil, fmt.Errorf("reading entry %d: %w", len(entries)+1, err)
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// readLogEntry reads one entry, that was written by logEntry.encode.
+func readLogEntry(r *bufio.Reader) (logEntry, error) {
+	userID, err := readNumber(r)
+	if err != nil {
+		return logEntry{}, fmt.Errorf("reading user id: %w", err)
+	}
+
+	nonce, err := readField(r, ' ')
+	if err != nil {
+		return logEntry{}, fmt.Errorf("reading nonce: %w", err)
+	}
+
+	object, err := readField(r, '\n')
+	if err != nil {
+		return logEntry{}, fmt.Errorf("reading vote object: %w", err)
+	}
+
+	return logEntry{userID: userID, nonce: string(nonce), object: object}, nil
+}
+
+// readNumber reads a number, that is terminated by a space.
+func readNumber(r *bufio.Reader) (int, error) {
+	prefix, err := r.ReadString(' ')
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(strings.TrimSuffix(prefix, " "))
+}
+
+// readField reads the length of a field, the field and the terminator after
+// it.
+func readField(r *bufio.Reader, terminator byte) ([]byte, error) {
+	size, err := readNumber(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading length: %w", err)
+	}
+
+	if size < 0 {
+		return nil, fmt.Errorf("invalid length %d", size)
+	}
+
+	data := make([]byte, size+1)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	if data[size] != terminator {
+		return nil, fmt.Errorf("field is not terminated by %q", terminator)
+	}
+
+	return data[:size], nil
+}
+
+// appendFile appends data to a file and syncs it to the disk. The file is
+// created, if it does not exist.
+//
+// If the data can not be written, the file is truncated to its old size, so
+// no partial entry is left.
+func appendFile(file string, data []byte) error {
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open file: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("reading file size: %w", err)
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Truncate(info.Size())
+		f.Close()
+		return fmt.Errorf("writing: %w", err)
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Truncate(info.Size())
+		f.Close()
+		return fmt.Errorf("syncing: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing: %w", err)
+	}
+	return nil
+}
+
+type doesNotExistError struct {
+	error
+}
+
+func (doesNotExistError) DoesNotExist() {}
+
+type doubleVoteError struct {
+	error
+}
+
+func (doubleVoteError) DoubleVote() {}
+
+type stoppedError struct {
+	error
+}
+
+func (stoppedError) Stopped() {}
+
+type nonceUsedError struct {
+	error
+}
+
+func (nonceUsedError) NonceUsed() {}
+
+type startedError struct {
+	error
+}
+
+func (startedError) Started() {}
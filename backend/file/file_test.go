@@ -0,0 +1,72 @@
+package file_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/OpenSlides/openslides-vote-service/backend/file"
+	"github.com/OpenSlides/openslides-vote-service/backend/test"
+)
+
+func TestBackend(t *testing.T) {
+	f, err := file.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	test.Backend(t, f)
+}
+
+func TestLogFile(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	f, err := file.New(dir)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	f.Start(ctx, 1)
+	f.Vote(ctx, 1, 5, []byte("first\nvote"))
+	f.Vote(ctx, 1, 6, []byte(`"second"`))
+
+	got, err := os.ReadFile(filepath.Join(dir, "poll-1.log"))
+	if err != nil {
+		t.Fatalf("reading log file: %v", err)
+	}
+
+	expect := "5 0  10 first\nvote\n6 0  8 \"second\"\n"
+	if string(got) != expect {
+		t.Errorf("got log file:\n%s\nexpected:\n%s", got, expect)
+	}
+
+	t.Run("new instance reads the files", func(t *testing.T) {
+		f2, err := file.New(dir)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+
+		object, found, err := f2.VotedObject(ctx, 1, 5)
+		if err != nil {
+			t.Fatalf("VotedObject: %v", err)
+		}
+
+		if !found || string(object) != "first\nvote" {
+			t.Errorf("VotedObject returned %q, %v, expected %q, true", object, found, "first\nvote")
+		}
+	})
+	t.Run("log file with a partial entry", func(t *testing.T) {
+		if err := os.WriteFile(filepath.Join(dir, "poll-2.state"), []byte("started"), 0o600); err != nil {
+			t.Fatalf("writing state file: %v", err)
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, "poll-2.log"), []byte("5 0  10 first\nvote\n6 0  8 \"sec"), 0o600); err != nil {
+			t.Fatalf("writing log file: %v", err)
+		}
+
+		if _, _, err := f.VotedObject(ctx, 2, 5); err == nil {
+			t.Errorf("VotedObject returned no error for a corrupted log file")
+		}
+	})
+}
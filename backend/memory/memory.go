@@ -5,10 +5,13 @@ package memory
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"sort"
 	"sync"
 	"testing"
+	"time"
 )
 
 const (
@@ -20,17 +23,21 @@ const (
 // Backend is a vote backend that holds the data in memory.
 type Backend struct {
 	mu      sync.Mutex
-	voted   map[int]map[int]struct{}
+	voted   map[int]map[int]int // voted maps for each poll the user id to the index of the vote object.
 	objects map[int][][]byte
 	state   map[int]int
+	nonces  map[int]map[string]struct{}
+	times   map[int]map[string]time.Time
 }
 
 // New initializes a new memory.Backend.
 func New() *Backend {
 	b := Backend{
-		voted:   make(map[int]map[int]struct{}),
+		voted:   make(map[int]map[int]int),
 		objects: make(map[int][][]byte),
 		state:   make(map[int]int),
+		nonces:  make(map[int]map[string]struct{}),
+		times:   make(map[int]map[string]time.Time),
 	}
 	return &b
 }
@@ -39,6 +46,11 @@ func (b *Backend) String() string {
 	return "memory"
 }
 
+// Ping does nothing. The memory is always reachable.
+func (b *Backend) Ping(ctx context.Context) error {
+	return nil
+}
+
 // Start opens opens a poll.
 func (b *Backend) Start(ctx context.Context, pollID int) error {
 	b.mu.Lock()
@@ -61,17 +73,73 @@ func (b *Backend) Stop(ctx context.Context, pollID int) ([][]byte, []int, error)
 	}
 
 	b.state[pollID] = pollStateStopped
+	objects, userIDs := b.result(pollID)
+	return objects, userIDs, nil
+}
+
+// Result returns the vote objects and user ids of a stopped poll like Stop,
+// but does not change the poll.
+func (b *Backend) Result(ctx context.Context, pollID int) ([][]byte, []int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state[pollID] {
+	case pollStateUnknown:
+		return nil, nil, doesNotExistError{fmt.Errorf("Poll does not exist")}
+	case pollStateStarted:
+		return nil, nil, startedError{fmt.Errorf("poll is not stopped")}
+	}
 
+	objects, userIDs := b.result(pollID)
+	return objects, userIDs, nil
+}
+
+// result returns the vote objects and user ids of a poll. b.mu has to be
+// locked.
+func (b *Backend) result(pollID int) ([][]byte, []int) {
 	userIDs := make([]int, 0, len(b.voted[pollID]))
-	for id := range b.voted[pollID] {
+	indexes := make([]int, 0, len(b.voted[pollID]))
+	for id, idx := range b.voted[pollID] {
 		userIDs = append(userIDs, id)
+		indexes = append(indexes, idx)
 	}
 	sort.Ints(userIDs)
-	return b.objects[pollID], userIDs, nil
+
+	if len(indexes) == len(b.objects[pollID]) {
+		return b.objects[pollID], userIDs
+	}
+
+	// Some votes were retracted. Only return the objects, that still belong to
+	// a user, in the order they were saved.
+	sort.Ints(indexes)
+	objects := make([][]byte, len(indexes))
+	for i, idx := range indexes {
+		objects[i] = b.objects[pollID][idx]
+	}
+	return objects, userIDs
+}
+
+// Reopen starts a stopped poll again.
+func (b *Backend) Reopen(ctx context.Context, pollID int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state[pollID] == pollStateUnknown {
+		return doesNotExistError{fmt.Errorf("Poll does not exist")}
+	}
+
+	b.state[pollID] = pollStateStarted
+	return nil
 }
 
 // Vote saves a vote.
 func (b *Backend) Vote(ctx context.Context, pollID int, userID int, object []byte) error {
+	return b.VoteWithNonce(ctx, pollID, userID, "", object)
+}
+
+// VoteWithNonce saves a vote like Vote, but only if the nonce was not used
+// before on the poll.
+func (b *Backend) VoteWithNonce(ctx context.Context, pollID int, userID int, nonce string, object []byte) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
@@ -84,18 +152,48 @@ func (b *Backend) Vote(ctx context.Context, pollID int, userID int, object []byt
 	}
 
 	if b.voted[pollID] == nil {
-		b.voted[pollID] = make(map[int]struct{})
+		b.voted[pollID] = make(map[int]int)
 	}
 
 	if _, ok := b.voted[pollID][userID]; ok {
 		return doubleVoteError{fmt.Errorf("user has already voted")}
 	}
 
-	b.voted[pollID][userID] = struct{}{}
+	if nonce != "" {
+		if _, ok := b.nonces[pollID][nonce]; ok {
+			return nonceUsedError{fmt.Errorf("nonce was already used")}
+		}
+
+		if b.nonces[pollID] == nil {
+			b.nonces[pollID] = make(map[string]struct{})
+		}
+		b.nonces[pollID][nonce] = struct{}{}
+	}
+
+	b.voted[pollID][userID] = len(b.objects[pollID])
 	b.objects[pollID] = append(b.objects[pollID], object)
 	return nil
 }
 
+// RetractVote removes the vote of a user.
+//
+// The vote object stays in the sequence of VotesSince.
+func (b *Backend) RetractVote(ctx context.Context, pollID int, userID int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state[pollID] == pollStateUnknown {
+		return doesNotExistError{fmt.Errorf("Poll does not exist")}
+	}
+
+	if b.state[pollID] == pollStateStopped {
+		return stoppedError{fmt.Errorf("poll is stopped")}
+	}
+
+	delete(b.voted[pollID], userID)
+	return nil
+}
+
 // Clear removes all data for a poll.
 func (b *Backend) Clear(ctx context.Context, pollID int) error {
 	b.mu.Lock()
@@ -104,6 +202,8 @@ func (b *Backend) Clear(ctx context.Context, pollID int) error {
 	delete(b.voted, pollID)
 	delete(b.objects, pollID)
 	delete(b.state, pollID)
+	delete(b.nonces, pollID)
+	delete(b.times, pollID)
 	return nil
 }
 
@@ -112,9 +212,11 @@ func (b *Backend) ClearAll(ctx context.Context) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	b.voted = make(map[int]map[int]struct{})
+	b.voted = make(map[int]map[int]int)
 	b.objects = make(map[int][][]byte)
 	b.state = make(map[int]int)
+	b.nonces = make(map[int]map[string]struct{})
+	b.times = make(map[int]map[string]time.Time)
 	return nil
 }
 
@@ -136,6 +238,180 @@ func (b *Backend) Voted(ctx context.Context) (map[int][]int, error) {
 	return out, nil
 }
 
+// Polls returns all known polls. The value is true, if the poll is stopped.
+func (b *Backend) Polls(ctx context.Context) (map[int]bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	out := make(map[int]bool, len(b.state))
+	for pid, state := range b.state {
+		if state == pollStateUnknown {
+			continue
+		}
+		out[pid] = state == pollStateStopped
+	}
+
+	return out, nil
+}
+
+// SetTime saves a time with a name for a poll.
+func (b *Backend) SetTime(ctx context.Context, pollID int, name string, t time.Time, overwrite bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state[pollID] == pollStateUnknown {
+		return doesNotExistError{fmt.Errorf("Poll does not exist")}
+	}
+
+	if t.IsZero() {
+		delete(b.times[pollID], name)
+		return nil
+	}
+
+	if _, ok := b.times[pollID][name]; ok && !overwrite {
+		return nil
+	}
+
+	if b.times[pollID] == nil {
+		b.times[pollID] = make(map[string]time.Time)
+	}
+	b.times[pollID][name] = t
+	return nil
+}
+
+// Times returns for all polls the time with the name.
+func (b *Backend) Times(ctx context.Context, name string) (map[int]time.Time, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	out := make(map[int]time.Time)
+	for pid, times := range b.times {
+		if t, ok := times[name]; ok {
+			out[pid] = t
+		}
+	}
+	return out, nil
+}
+
+// VotesSince returns all vote objects that were saved after afterSeq.
+//
+// The sequence number of a vote object is its position in the list of votes.
+func (b *Backend) VotesSince(ctx context.Context, pollID int, afterSeq int) ([][]byte, int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state[pollID] == pollStateUnknown {
+		return nil, 0, doesNotExistError{fmt.Errorf("Poll does not exist")}
+	}
+
+	objects := b.objects[pollID]
+	if afterSeq < 0 {
+		afterSeq = 0
+	}
+
+	if afterSeq >= len(objects) {
+		return nil, len(objects), nil
+	}
+
+	out := make([][]byte, len(objects)-afterSeq)
+	copy(out, objects[afterSeq:])
+	return out, len(objects), nil
+}
+
+// VotedObject returns the vote object of a user.
+func (b *Backend) VotedObject(ctx context.Context, pollID int, userID int) ([]byte, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state[pollID] == pollStateUnknown {
+		return nil, false, doesNotExistError{fmt.Errorf("Poll does not exist")}
+	}
+
+	idx, ok := b.voted[pollID][userID]
+	if !ok {
+		return nil, false, nil
+	}
+
+	return b.objects[pollID][idx], true, nil
+}
+
+// snapshot is the format that is used by Snapshot and Restore.
+type snapshot struct {
+	State   map[int]int                  `json:"state"`
+	Voted   map[int]map[int]int          `json:"voted"`
+	Objects map[int][][]byte             `json:"objects"`
+	Nonces  map[int]map[string]struct{}  `json:"nonces,omitempty"`
+	Times   map[int]map[string]time.Time `json:"times,omitempty"`
+}
+
+// Snapshot writes all data of the backend as json to w.
+func (b *Backend) Snapshot(w io.Writer) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	data := snapshot{
+		State:   b.state,
+		Voted:   b.voted,
+		Objects: b.objects,
+		Nonces:  b.nonces,
+		Times:   b.times,
+	}
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		return fmt.Errorf("encoding snapshot: %w", err)
+	}
+	return nil
+}
+
+// Restore replaces all data of the backend with a snapshot created by
+// Snapshot.
+func (b *Backend) Restore(r io.Reader) error {
+	var data snapshot
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return fmt.Errorf("decoding snapshot: %w", err)
+	}
+
+	if data.Voted == nil {
+		data.Voted = make(map[int]map[int]int)
+	}
+
+	if data.Objects == nil {
+		data.Objects = make(map[int][][]byte)
+	}
+
+	if data.State == nil {
+		data.State = make(map[int]int)
+	}
+
+	if data.Nonces == nil {
+		data.Nonces = make(map[int]map[string]struct{})
+	}
+
+	if data.Times == nil {
+		data.Times = make(map[int]map[string]time.Time)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.voted = data.Voted
+	b.objects = data.Objects
+	b.state = data.State
+	b.nonces = data.Nonces
+	b.times = data.Times
+	return nil
+}
+
+// IsStopped tells, if the poll exists and if it is stopped. It is meant for
+// tests.
+func (b *Backend) IsStopped(pollID int) (exists bool, stopped bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := b.state[pollID]
+	return state != pollStateUnknown, state == pollStateStopped
+}
+
 // AssertUserHasVoted is a method for the tests to check, if a user has voted.
 func (b *Backend) AssertUserHasVoted(t *testing.T, pollID, userID int) {
 	t.Helper()
@@ -165,3 +441,15 @@ type stoppedError struct {
 }
 
 func (stoppedError) Stopped() {}
+
+type nonceUsedError struct {
+	error
+}
+
+func (nonceUsedError) NonceUsed() {}
+
+type startedError struct {
+	error
+}
+
+func (startedError) Started() {}
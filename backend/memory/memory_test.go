@@ -1,6 +1,10 @@
 package memory_test
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
 	"testing"
 
 	"github.com/OpenSlides/openslides-vote-service/backend/memory"
@@ -12,3 +16,97 @@ func TestBackend(t *testing.T) {
 
 	test.Backend(t, m)
 }
+
+func TestSnapshotRestore(t *testing.T) {
+	ctx := context.Background()
+	m := memory.New()
+
+	m.Start(ctx, 1)
+	m.Vote(ctx, 1, 5, []byte("vote in stopped poll"))
+	m.Stop(ctx, 1)
+
+	m.Start(ctx, 2)
+	m.Vote(ctx, 2, 5, []byte("vote in started poll"))
+
+	buf := new(bytes.Buffer)
+	if err := m.Snapshot(buf); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	restored := memory.New()
+	if err := restored.Restore(buf); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	t.Run("voted users", func(t *testing.T) {
+		got, err := restored.Voted(ctx)
+		if err != nil {
+			t.Fatalf("Voted: %v", err)
+		}
+
+		expect := map[int][]int{1: {5}, 2: {5}}
+		if !reflect.DeepEqual(got, expect) {
+			t.Errorf("Voted returned %v, expected %v", got, expect)
+		}
+	})
+
+	t.Run("stopped poll", func(t *testing.T) {
+		err := restored.Vote(ctx, 1, 6, []byte("vote"))
+
+		var errStopped interface{ Stopped() }
+		if !errors.As(err, &errStopped) {
+			t.Errorf("Vote on restored stopped poll has to return an error with method Stopped(), got: %v", err)
+		}
+	})
+
+	t.Run("started poll", func(t *testing.T) {
+		err := restored.Vote(ctx, 2, 5, []byte("vote"))
+
+		var errDoubleVote interface{ DoubleVote() }
+		if !errors.As(err, &errDoubleVote) {
+			t.Errorf("Second vote on restored poll has to return an error with method DoubleVote(), got: %v", err)
+		}
+
+		if err := restored.Vote(ctx, 2, 6, []byte("second vote")); err != nil {
+			t.Fatalf("Vote on restored started poll: %v", err)
+		}
+
+		objects, _, err := restored.Stop(ctx, 2)
+		if err != nil {
+			t.Fatalf("Stop: %v", err)
+		}
+
+		expect := [][]byte{[]byte("vote in started poll"), []byte("second vote")}
+		if !reflect.DeepEqual(objects, expect) {
+			t.Errorf("Stop returned %q, expected %q", objects, expect)
+		}
+	})
+}
+
+func TestIsStopped(t *testing.T) {
+	ctx := context.Background()
+	m := memory.New()
+
+	m.Start(ctx, 2)
+	m.Start(ctx, 3)
+	m.Stop(ctx, 3)
+
+	for _, tt := range []struct {
+		name          string
+		pollID        int
+		expectExists  bool
+		expectStopped bool
+	}{
+		{"unknown", 1, false, false},
+		{"started", 2, true, false},
+		{"stopped", 3, true, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			exists, stopped := m.IsStopped(tt.pollID)
+
+			if exists != tt.expectExists || stopped != tt.expectStopped {
+				t.Errorf("IsStopped returned (%v, %v), expected (%v, %v)", exists, stopped, tt.expectExists, tt.expectStopped)
+			}
+		})
+	}
+}
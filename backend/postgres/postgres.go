@@ -24,10 +24,72 @@ var schema string
 // Has to be initializes with New().
 type Backend struct {
 	pool *pgxpool.Pool
+
+	// replica is used for read only queries. It is the same as pool, if no
+	// replica is configured.
+	replica *pgxpool.Pool
+}
+
+// Option is an optional argument for New.
+type Option func(*config)
+
+type config struct {
+	replicaConnString string
+	minConns          int32
+	maxConns          int32
+}
+
+// WithReplica configures a read replica. Read only queries like Voted are send
+// to the replica, all other queries to the primary database.
+//
+// Since the replica lags behind the primary, the read methods can return
+// outdated data, for example a vote count that does not contain the latest
+// votes.
+func WithReplica(connString string) Option {
+	return func(c *config) {
+		c.replicaConnString = connString
+	}
+}
+
+// WithPoolSize sets the minimum and maximum number of connections of the
+// connection pools. Zero keeps the default of pgxpool.
+func WithPoolSize(minConns, maxConns int32) Option {
+	return func(c *config) {
+		c.minConns = minConns
+		c.maxConns = maxConns
+	}
 }
 
 // New creates a new connection pool.
-func New(ctx context.Context, connString string) (*Backend, error) {
+func New(ctx context.Context, connString string, options ...Option) (*Backend, error) {
+	var cfg config
+	for _, o := range options {
+		o(&cfg)
+	}
+
+	pool, err := newPool(ctx, connString, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	b := Backend{
+		pool:    pool,
+		replica: pool,
+	}
+
+	if cfg.replicaConnString != "" {
+		replica, err := newPool(ctx, cfg.replicaConnString, cfg)
+		if err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("replica: %w", err)
+		}
+		b.replica = replica
+	}
+
+	return &b, nil
+}
+
+func newPool(ctx context.Context, connString string, cfg config) (*pgxpool.Pool, error) {
 	conf, err := pgxpool.ParseConfig(connString)
 	if err != nil {
 		return nil, fmt.Errorf("invalid connection url: %w", err)
@@ -40,16 +102,26 @@ func New(ctx context.Context, connString string) (*Backend, error) {
 	// See https://github.com/OpenSlides/openslides-vote-service/pull/66
 	conf.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol
 
+	if cfg.maxConns > 0 {
+		conf.MaxConns = cfg.maxConns
+	}
+
+	if cfg.minConns > 0 {
+		conf.MinConns = cfg.minConns
+	}
+
+	if conf.MinConns > conf.MaxConns {
+		return nil, fmt.Errorf("minimum pool size %d is bigger then the maximum %d", conf.MinConns, conf.MaxConns)
+	}
+
+	log.Info("Postgres connection pool to %s: min %d, max %d connections", conf.ConnConfig.Host, conf.MinConns, conf.MaxConns)
+
 	pool, err := pgxpool.NewWithConfig(ctx, conf)
 	if err != nil {
 		return nil, fmt.Errorf("creating connection pool: %w", err)
 	}
 
-	b := Backend{
-		pool: pool,
-	}
-
-	return &b, nil
+	return pool, nil
 }
 
 func (b *Backend) String() string {
@@ -60,6 +132,9 @@ func (b *Backend) String() string {
 func (b *Backend) Wait(ctx context.Context) {
 	for ctx.Err() == nil {
 		err := b.pool.Ping(ctx)
+		if err == nil && b.replica != b.pool {
+			err = b.replica.Ping(ctx)
+		}
 		if err == nil {
 			return
 		}
@@ -68,6 +143,20 @@ func (b *Backend) Wait(ctx context.Context) {
 	}
 }
 
+// Ping checks, that postgres and the replica are reachable.
+func (b *Backend) Ping(ctx context.Context) error {
+	if err := b.pool.Ping(ctx); err != nil {
+		return fmt.Errorf("ping postgres: %w", err)
+	}
+
+	if b.replica != b.pool {
+		if err := b.replica.Ping(ctx); err != nil {
+			return fmt.Errorf("ping postgres replica: %w", err)
+		}
+	}
+	return nil
+}
+
 // Migrate creates the database schema.
 func (b *Backend) Migrate(ctx context.Context) error {
 	if _, err := b.pool.Exec(ctx, schema); err != nil {
@@ -78,6 +167,9 @@ func (b *Backend) Migrate(ctx context.Context) error {
 
 // Close closes all connections. It blocks, until all connection are closed.
 func (b *Backend) Close() {
+	if b.replica != b.pool {
+		b.replica.Close()
+	}
 	b.pool.Close()
 }
 
@@ -97,13 +189,19 @@ func (b *Backend) Start(ctx context.Context, pollID int) error {
 // If an transaction error happens, the vote is saved again. This is done until
 // either the vote is saved or the given context is canceled.
 func (b *Backend) Vote(ctx context.Context, pollID int, userID int, object []byte) error {
+	return b.VoteWithNonce(ctx, pollID, userID, "", object)
+}
+
+// VoteWithNonce adds a vote like Vote. The nonce is saved in the same
+// transaction as the vote.
+func (b *Backend) VoteWithNonce(ctx context.Context, pollID int, userID int, nonce string, object []byte) error {
 	return continueOnTransactionError(ctx, func() error {
-		return b.voteOnce(ctx, pollID, userID, object)
+		return b.voteOnce(ctx, pollID, userID, nonce, object)
 	})
 }
 
 // voteOnce tries to add the vote once.
-func (b *Backend) voteOnce(ctx context.Context, pollID int, userID int, object []byte) (err error) {
+func (b *Backend) voteOnce(ctx context.Context, pollID int, userID int, nonce string, object []byte) (err error) {
 	log.Debug("SQL: Begin transaction for vote")
 	defer func() {
 		log.Debug("SQL: End transaction for vote with error: %v", err)
@@ -146,6 +244,19 @@ func (b *Backend) voteOnce(ctx context.Context, pollID int, userID int, object [
 				return fmt.Errorf("converting user ids to bytes: %w", err)
 			}
 
+			if nonce != "" {
+				sql = "INSERT INTO vote.nonce (poll_id, nonce) VALUES ($1, $2) ON CONFLICT DO NOTHING;"
+				log.Debug("SQL: `%s` (values: %d, [nonce])", sql, pollID)
+				result, err := tx.Exec(ctx, sql, pollID, nonce)
+				if err != nil {
+					return fmt.Errorf("writing nonce: %w", err)
+				}
+
+				if result.RowsAffected() == 0 {
+					return nonceUsedError{fmt.Errorf("nonce was already used")}
+				}
+			}
+
 			sql = "UPDATE vote.poll SET user_ids = $1 WHERE id = $2;"
 			log.Debug("SQL: `%s` (values: [user_ids]), %d", sql, pollID)
 			if _, err := tx.Exec(ctx, sql, uIDsRaw, pollID); err != nil {
@@ -167,6 +278,98 @@ func (b *Backend) voteOnce(ctx context.Context, pollID int, userID int, object [
 	return nil
 }
 
+// RetractVote removes the vote of a user.
+//
+// Postgres does not save, which vote object belongs to which user. So only
+// votes can be retracted, that contain the user as vote_user_id. This is only
+// the case on named polls. On other polls, an error is returned.
+//
+// If an transaction error happens, the vote is retracted again. This is done
+// until either the vote is retracted or the given context is canceled.
+func (b *Backend) RetractVote(ctx context.Context, pollID int, userID int) error {
+	return continueOnTransactionError(ctx, func() error {
+		return b.retractOnce(ctx, pollID, userID)
+	})
+}
+
+// retractOnce tries to remove the vote once.
+func (b *Backend) retractOnce(ctx context.Context, pollID int, userID int) (err error) {
+	log.Debug("SQL: Begin transaction for retract")
+	defer func() {
+		log.Debug("SQL: End transaction for retract with error: %v", err)
+	}()
+
+	err = pgx.BeginTxFunc(
+		ctx,
+		b.pool,
+		pgx.TxOptions{
+			IsoLevel: "REPEATABLE READ",
+		},
+		func(tx pgx.Tx) error {
+			sql := `SELECT stopped, user_ids FROM vote.poll WHERE id = $1;`
+			log.Debug("SQL: `%s` (values: %d)", sql, pollID)
+
+			var stopped bool
+			var uIDsRaw []byte
+			if err := tx.QueryRow(ctx, sql, pollID).Scan(&stopped, &uIDsRaw); err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					return doesNotExistError{fmt.Errorf("unknown poll")}
+				}
+				return fmt.Errorf("fetching poll data: %w", err)
+			}
+
+			if stopped {
+				return stoppedError{fmt.Errorf("poll is stopped")}
+			}
+
+			uIDs, err := userIDListFromBytes(uIDsRaw)
+			if err != nil {
+				return fmt.Errorf("parsing user ids: %w", err)
+			}
+
+			if !uIDs.remove(int32(userID)) {
+				return nil
+			}
+
+			uIDsRaw, err = uIDs.toBytes()
+			if err != nil {
+				return fmt.Errorf("converting user ids to bytes: %w", err)
+			}
+
+			sql = `
+			DELETE FROM vote.objects
+			WHERE id = (
+				SELECT id
+				FROM vote.objects
+				WHERE poll_id = $1 AND convert_from(vote, 'UTF8')::jsonb->>'vote_user_id' = $2::text
+				LIMIT 1
+			);
+			`
+			log.Debug("SQL: `%s` (values: %d, %d)", sql, pollID, userID)
+			result, err := tx.Exec(ctx, sql, pollID, userID)
+			if err != nil {
+				return fmt.Errorf("deleting vote object: %w", err)
+			}
+
+			if result.RowsAffected() == 0 {
+				return notRetractableError{fmt.Errorf("vote object of user %d not found, only votes with a vote_user_id can be retracted", userID)}
+			}
+
+			sql = "UPDATE vote.poll SET user_ids = $1 WHERE id = $2;"
+			log.Debug("SQL: `%s` (values: [user_ids]), %d", sql, pollID)
+			if _, err := tx.Exec(ctx, sql, uIDsRaw, pollID); err != nil {
+				return fmt.Errorf("writing user ids: %w", err)
+			}
+
+			return nil
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("running transaction: %w", err)
+	}
+	return nil
+}
+
 // Stop ends a poll and returns all vote objects and users who have voted.
 //
 // If an transaction error happens, the poll is stopped again. This is done
@@ -218,54 +421,118 @@ func (b *Backend) stopOnce(ctx context.Context, pollID int) (objects [][]byte, u
 				return fmt.Errorf("setting poll %d to stopped: %w", pollID, err)
 			}
 
-			sql = `
-			SELECT Obj.vote
-			FROM vote.poll Poll
-			LEFT JOIN vote.objects Obj ON Obj.poll_id = Poll.id
-			WHERE Poll.id = $1;
-			`
-			log.Debug("SQL: `%s` (values: %d", sql, pollID)
-			rows, err := tx.Query(ctx, sql, pollID)
-			if err != nil {
-				return fmt.Errorf("fetching vote objects: %w", err)
-			}
+			var err error
+			objects, users, err = readResult(ctx, tx, pollID)
+			return err
+		},
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("running transaction: %w", err)
+	}
+	return objects, users, nil
+}
 
-			for rows.Next() {
-				var bs []byte
-				err = rows.Scan(&bs)
-				if err != nil {
-					return fmt.Errorf("parsind row: %w", err)
-				}
-				if len(bs) == 0 {
-					continue
-				}
-				objects = append(objects, bs)
-			}
+// readResult reads the vote objects and user ids of a poll inside a
+// transaction.
+func readResult(ctx context.Context, tx pgx.Tx, pollID int) (objects [][]byte, users []int, err error) {
+	sql := `
+	SELECT Obj.vote
+	FROM vote.poll Poll
+	LEFT JOIN vote.objects Obj ON Obj.poll_id = Poll.id
+	WHERE Poll.id = $1;
+	`
+	log.Debug("SQL: `%s` (values: %d", sql, pollID)
+	rows, err := tx.Query(ctx, sql, pollID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fetching vote objects: %w", err)
+	}
 
-			if err := rows.Err(); err != nil {
-				return fmt.Errorf("parsing query rows: %w", err)
-			}
+	for rows.Next() {
+		var bs []byte
+		err = rows.Scan(&bs)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parsind row: %w", err)
+		}
+		if len(bs) == 0 {
+			continue
+		}
+		objects = append(objects, bs)
+	}
 
-			sql = `
-			SELECT user_ids
-			FROM vote.poll
-			WHERE poll.id = $1;
-			`
-			var rawUserIDs []byte
-			if err := tx.QueryRow(ctx, sql, pollID).Scan(&rawUserIDs); err != nil {
-				return fmt.Errorf("fetching poll data: %w", err)
-			}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("parsing query rows: %w", err)
+	}
 
-			uIDs, err := userIDListFromBytes(rawUserIDs)
-			if err != nil {
-				return fmt.Errorf("parsing user ids: %w", err)
+	sql = `
+	SELECT user_ids
+	FROM vote.poll
+	WHERE poll.id = $1;
+	`
+	var rawUserIDs []byte
+	if err := tx.QueryRow(ctx, sql, pollID).Scan(&rawUserIDs); err != nil {
+		return nil, nil, fmt.Errorf("fetching poll data: %w", err)
+	}
+
+	uIDs, err := userIDListFromBytes(rawUserIDs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing user ids: %w", err)
+	}
+
+	for _, id := range uIDs {
+		users = append(users, int(id))
+	}
+
+	return objects, users, nil
+}
+
+// Result returns the vote objects and user ids of a stopped poll like Stop,
+// but does not change the poll.
+//
+// If an transaction error happens, the data is read again.
+func (b *Backend) Result(ctx context.Context, pollID int) ([][]byte, []int, error) {
+	var objs [][]byte
+	var userIDs []int
+	err := continueOnTransactionError(ctx, func() error {
+		o, uids, err := b.resultOnce(ctx, pollID)
+		if err != nil {
+			return err
+		}
+		objs = o
+		userIDs = uids
+		return nil
+	})
+
+	return objs, userIDs, err
+}
+
+// resultOnce reads the result of a stopped poll once.
+func (b *Backend) resultOnce(ctx context.Context, pollID int) (objects [][]byte, users []int, err error) {
+	err = pgx.BeginTxFunc(
+		ctx,
+		b.pool,
+		pgx.TxOptions{
+			IsoLevel:   "REPEATABLE READ",
+			AccessMode: pgx.ReadOnly,
+		},
+		func(tx pgx.Tx) error {
+			sql := "SELECT stopped FROM vote.poll WHERE id = $1;"
+			log.Debug("SQL: `%s` (values: %d)", sql, pollID)
+
+			var stopped bool
+			if err := tx.QueryRow(ctx, sql, pollID).Scan(&stopped); err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					return doesNotExistError{fmt.Errorf("Poll does not exist")}
+				}
+				return fmt.Errorf("fetching poll state: %w", err)
 			}
 
-			for _, id := range uIDs {
-				users = append(users, int(id))
+			if !stopped {
+				return startedError{fmt.Errorf("poll is not stopped")}
 			}
 
-			return nil
+			var err error
+			objects, users, err = readResult(ctx, tx, pollID)
+			return err
 		},
 	)
 	if err != nil {
@@ -274,6 +541,23 @@ func (b *Backend) stopOnce(ctx context.Context, pollID int) (objects [][]byte, u
 	return objects, users, nil
 }
 
+// Reopen starts a stopped poll again.
+//
+// The vote objects and the user ids are not touched.
+func (b *Backend) Reopen(ctx context.Context, pollID int) error {
+	sql := "UPDATE vote.poll SET stopped = false WHERE id = $1;"
+	log.Debug("SQL: `%s` (values: %d)", sql, pollID)
+	result, err := b.pool.Exec(ctx, sql, pollID)
+	if err != nil {
+		return fmt.Errorf("setting poll %d to started: %w", pollID, err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return doesNotExistError{fmt.Errorf("Poll does not exist")}
+	}
+	return nil
+}
+
 // Clear removes all data about a poll from the database.
 func (b *Backend) Clear(ctx context.Context, pollID int) error {
 	sql := "DELETE FROM vote.poll WHERE id = $1"
@@ -306,11 +590,13 @@ func (b *Backend) ClearAll(ctx context.Context) error {
 }
 
 // Voted returns for all polls the userIDs, that have voted.
+//
+// The data is read from the replica, if one is configured.
 func (b *Backend) Voted(ctx context.Context) (map[int][]int, error) {
 	sql := `SELECT id, user_ids	FROM vote.poll;`
 
 	log.Debug("SQL: `%s`", sql)
-	rows, err := b.pool.Query(ctx, sql)
+	rows, err := b.replica.Query(ctx, sql)
 	if err != nil {
 		return nil, fmt.Errorf("fetching user_ids from all poll objects: %w", err)
 	}
@@ -337,6 +623,223 @@ func (b *Backend) Voted(ctx context.Context) (map[int][]int, error) {
 	return out, nil
 }
 
+// Polls returns all known polls. The value is true, if the poll is stopped.
+//
+// The data is read from the replica, if one is configured.
+func (b *Backend) Polls(ctx context.Context) (map[int]bool, error) {
+	sql := `SELECT id, stopped FROM vote.poll;`
+
+	log.Debug("SQL: `%s`", sql)
+	rows, err := b.replica.Query(ctx, sql)
+	if err != nil {
+		return nil, fmt.Errorf("fetching all polls: %w", err)
+	}
+	defer rows.Close()
+
+	out := make(map[int]bool)
+	for rows.Next() {
+		var pid int
+		var stopped bool
+		if err := rows.Scan(&pid, &stopped); err != nil {
+			return nil, fmt.Errorf("parsing row: %w", err)
+		}
+		out[pid] = stopped
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading polls: %w", err)
+	}
+
+	return out, nil
+}
+
+// SetTime saves a time with a name for a poll.
+func (b *Backend) SetTime(ctx context.Context, pollID int, name string, t time.Time, overwrite bool) error {
+	if t.IsZero() {
+		return b.removeTime(ctx, pollID, name)
+	}
+
+	sql := `INSERT INTO vote.poll_time (poll_id, name, time) VALUES ($1, $2, $3)
+	ON CONFLICT (poll_id, name) DO NOTHING;`
+	if overwrite {
+		sql = `INSERT INTO vote.poll_time (poll_id, name, time) VALUES ($1, $2, $3)
+		ON CONFLICT (poll_id, name) DO UPDATE SET time = EXCLUDED.time;`
+	}
+
+	log.Debug("SQL: `%s` (values: %d, %s, %d)", sql, pollID, name, t.UnixNano())
+	if _, err := b.pool.Exec(ctx, sql, pollID, name, t.UnixNano()); err != nil {
+		var perr *pgconn.PgError
+		// The error code is returned, if the poll does not exist.
+		if errors.As(err, &perr) && perr.Code == "23503" {
+			return doesNotExistError{fmt.Errorf("Poll does not exist")}
+		}
+		return fmt.Errorf("saving time %s of poll %d: %w", name, pollID, err)
+	}
+	return nil
+}
+
+func (b *Backend) removeTime(ctx context.Context, pollID int, name string) error {
+	err := pgx.BeginTxFunc(
+		ctx,
+		b.pool,
+		pgx.TxOptions{},
+		func(tx pgx.Tx) error {
+			sql := "SELECT EXISTS(SELECT 1 FROM vote.poll WHERE id = $1);"
+			log.Debug("SQL: `%s` (values: %d)", sql, pollID)
+			var exists bool
+			if err := tx.QueryRow(ctx, sql, pollID).Scan(&exists); err != nil {
+				return fmt.Errorf("checking poll existence: %w", err)
+			}
+
+			if !exists {
+				return doesNotExistError{fmt.Errorf("Poll does not exist")}
+			}
+
+			sql = "DELETE FROM vote.poll_time WHERE poll_id = $1 AND name = $2;"
+			log.Debug("SQL: `%s` (values: %d, %s)", sql, pollID, name)
+			if _, err := tx.Exec(ctx, sql, pollID, name); err != nil {
+				return fmt.Errorf("deleting time: %w", err)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("removing time %s of poll %d: %w", name, pollID, err)
+	}
+	return nil
+}
+
+// Times returns for all polls the time with the name.
+//
+// The data is read from the replica, if one is configured.
+func (b *Backend) Times(ctx context.Context, name string) (map[int]time.Time, error) {
+	sql := `SELECT poll_id, time FROM vote.poll_time WHERE name = $1;`
+
+	log.Debug("SQL: `%s` (values: %s)", sql, name)
+	rows, err := b.replica.Query(ctx, sql, name)
+	if err != nil {
+		return nil, fmt.Errorf("fetching times: %w", err)
+	}
+	defer rows.Close()
+
+	out := make(map[int]time.Time)
+	for rows.Next() {
+		var pid int
+		var nanos int64
+		if err := rows.Scan(&pid, &nanos); err != nil {
+			return nil, fmt.Errorf("parsing row: %w", err)
+		}
+		out[pid] = time.Unix(0, nanos)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading times: %w", err)
+	}
+
+	return out, nil
+}
+
+// VotesSince returns all vote objects of a poll that were saved after afterSeq.
+//
+// The sequence number of a vote object is its row id. It increases with each
+// vote, but is not continuous for one poll.
+//
+// The data is read from the replica, if one is configured.
+func (b *Backend) VotesSince(ctx context.Context, pollID int, afterSeq int) ([][]byte, int, error) {
+	var objects [][]byte
+	var latest int
+
+	err := pgx.BeginTxFunc(
+		ctx,
+		b.replica,
+		pgx.TxOptions{
+			IsoLevel:   "REPEATABLE READ",
+			AccessMode: pgx.ReadOnly,
+		},
+		func(tx pgx.Tx) error {
+			sql := "SELECT EXISTS(SELECT 1 FROM vote.poll WHERE id = $1);"
+			log.Debug("SQL: `%s` (values: %d)", sql, pollID)
+
+			var exists bool
+			if err := tx.QueryRow(ctx, sql, pollID).Scan(&exists); err != nil {
+				return fmt.Errorf("fetching poll exists: %w", err)
+			}
+
+			if !exists {
+				return doesNotExistError{fmt.Errorf("Poll does not exist")}
+			}
+
+			sql = "SELECT COALESCE(MAX(id), 0) FROM vote.objects WHERE poll_id = $1;"
+			log.Debug("SQL: `%s` (values: %d)", sql, pollID)
+			if err := tx.QueryRow(ctx, sql, pollID).Scan(&latest); err != nil {
+				return fmt.Errorf("fetching latest sequence number: %w", err)
+			}
+
+			sql = "SELECT vote FROM vote.objects WHERE poll_id = $1 AND id > $2 ORDER BY id;"
+			log.Debug("SQL: `%s` (values: %d, %d)", sql, pollID, afterSeq)
+			rows, err := tx.Query(ctx, sql, pollID, afterSeq)
+			if err != nil {
+				return fmt.Errorf("fetching vote objects: %w", err)
+			}
+
+			for rows.Next() {
+				var bs []byte
+				if err := rows.Scan(&bs); err != nil {
+					return fmt.Errorf("parsing row: %w", err)
+				}
+				objects = append(objects, bs)
+			}
+
+			if err := rows.Err(); err != nil {
+				return fmt.Errorf("parsing query rows: %w", err)
+			}
+
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("running transaction: %w", err)
+	}
+	return objects, latest, nil
+}
+
+// VotedObject returns the vote object of a user.
+//
+// Postgres does not save, which vote object belongs to which user. So only
+// vote objects can be found, that contain the user as vote_user_id. This is
+// only the case on named polls.
+func (b *Backend) VotedObject(ctx context.Context, pollID int, userID int) ([]byte, bool, error) {
+	sql := "SELECT EXISTS(SELECT 1 FROM vote.poll WHERE id = $1);"
+	log.Debug("SQL: `%s` (values: %d)", sql, pollID)
+
+	var exists bool
+	if err := b.replica.QueryRow(ctx, sql, pollID).Scan(&exists); err != nil {
+		return nil, false, fmt.Errorf("fetching poll exists: %w", err)
+	}
+
+	if !exists {
+		return nil, false, doesNotExistError{fmt.Errorf("Poll does not exist")}
+	}
+
+	sql = `
+	SELECT vote
+	FROM vote.objects
+	WHERE poll_id = $1 AND convert_from(vote, 'UTF8')::jsonb->>'vote_user_id' = $2::text
+	LIMIT 1;
+	`
+	log.Debug("SQL: `%s` (values: %d, %d)", sql, pollID, userID)
+
+	var object []byte
+	if err := b.replica.QueryRow(ctx, sql, pollID, userID).Scan(&object); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("fetching vote object: %w", err)
+	}
+
+	return object, true, nil
+}
+
 // ContinueOnTransactionError runs the given many times until is does not return
 // an transaction error. Also stopes, when the given context is canceled.
 func continueOnTransactionError(ctx context.Context, f func() error) error {
@@ -396,6 +899,19 @@ func (u *userIDList) add(userID int32) error {
 	return nil
 }
 
+// remove removes the userID from the userIDs. It returns false, if the userID
+// is not in the list.
+func (u *userIDList) remove(userID int32) bool {
+	ints := []int32(*u)
+	idx := sort.Search(len(ints), func(i int) bool { return ints[i] >= userID })
+	if idx >= len(ints) || ints[idx] != userID {
+		return false
+	}
+
+	*u = append(ints[:idx], ints[idx+1:]...)
+	return true
+}
+
 // contains returns true if the userID is contains the list of userIDs.
 func (u *userIDList) contains(userID int32) bool {
 	ints := []int32(*u)
@@ -424,3 +940,21 @@ type stoppedError struct {
 }
 
 func (stoppedError) Stopped() {}
+
+type notRetractableError struct {
+	error
+}
+
+func (notRetractableError) NotRetractable() {}
+
+type nonceUsedError struct {
+	error
+}
+
+func (nonceUsedError) NonceUsed() {}
+
+type startedError struct {
+	error
+}
+
+func (startedError) Started() {}
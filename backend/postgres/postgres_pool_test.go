@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+)
+
+// poolConfig returns the minimum and maximum size of the connection pool.
+func (b *Backend) poolConfig() (int32, int32) {
+	conf := b.pool.Config()
+	return conf.MinConns, conf.MaxConns
+}
+
+func TestPoolSize(t *testing.T) {
+	ctx := context.Background()
+
+	// The pool connects lazily, so no database is needed.
+	addr := `user=postgres password='password' host=localhost port=1 dbname=database`
+
+	t.Run("configured", func(t *testing.T) {
+		b, err := New(ctx, addr, WithPoolSize(2, 50))
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		defer b.Close()
+
+		minConns, maxConns := b.poolConfig()
+		if minConns != 2 || maxConns != 50 {
+			t.Errorf("Got pool size min %d, max %d, expected min 2, max 50", minConns, maxConns)
+		}
+	})
+
+	t.Run("default", func(t *testing.T) {
+		b, err := New(ctx, addr, WithPoolSize(0, 0))
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		defer b.Close()
+
+		minConns, maxConns := b.poolConfig()
+		if minConns != 0 || maxConns < 4 {
+			t.Errorf("Got pool size min %d, max %d, expected the defaults", minConns, maxConns)
+		}
+	})
+
+	t.Run("min bigger then max", func(t *testing.T) {
+		if _, err := New(ctx, addr, WithPoolSize(10, 5)); err == nil {
+			t.Errorf("New did not return an error")
+		}
+	})
+}
@@ -2,11 +2,13 @@ package postgres_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 
 	"github.com/OpenSlides/openslides-vote-service/backend/postgres"
 	"github.com/OpenSlides/openslides-vote-service/backend/test"
+	"github.com/jackc/pgx/v5"
 	"github.com/ory/dockertest/v3"
 )
 
@@ -61,3 +63,87 @@ func TestImplementBackendInterface(t *testing.T) {
 
 	test.Backend(t, p)
 }
+
+func TestReplica(t *testing.T) {
+	ctx := context.Background()
+	port, close := startPostgres(t)
+	defer close()
+
+	addr := fmt.Sprintf(`user=postgres password='password' host=localhost port=%s dbname=database`, port)
+	replicaAddr := fmt.Sprintf(`user=postgres password='password' host=localhost port=%s dbname=replica`, port)
+
+	// Use a second database as "replica". Since there is no replication, data
+	// that is read from it, has to be empty.
+	primary, err := postgres.New(ctx, addr)
+	if err != nil {
+		t.Fatalf("Creating postgres backend returned: %v", err)
+	}
+	primary.Wait(ctx)
+
+	conn, err := pgx.Connect(ctx, addr)
+	if err != nil {
+		t.Fatalf("Connecting to postgres: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	if _, err := conn.Exec(ctx, "CREATE DATABASE replica"); err != nil {
+		t.Fatalf("Creating replica database: %v", err)
+	}
+	primary.Close()
+
+	replica, err := postgres.New(ctx, replicaAddr)
+	if err != nil {
+		t.Fatalf("Creating replica backend returned: %v", err)
+	}
+	if err := replica.Migrate(ctx); err != nil {
+		t.Fatalf("Creating db schema on replica: %v", err)
+	}
+	replica.Close()
+
+	p, err := postgres.New(ctx, addr, postgres.WithReplica(replicaAddr))
+	if err != nil {
+		t.Fatalf("Creating postgres backend with replica returned: %v", err)
+	}
+	defer p.Close()
+
+	p.Wait(ctx)
+	if err := p.Migrate(ctx); err != nil {
+		t.Fatalf("Creating db schema: %v", err)
+	}
+
+	if err := p.Start(ctx, 1); err != nil {
+		t.Fatalf("Start returned: %v", err)
+	}
+
+	if err := p.Vote(ctx, 1, 5, []byte("my vote")); err != nil {
+		t.Fatalf("Vote returned: %v", err)
+	}
+
+	t.Run("read methods use the replica", func(t *testing.T) {
+		voted, err := p.Voted(ctx)
+		if err != nil {
+			t.Fatalf("Voted returned: %v", err)
+		}
+
+		if len(voted) != 0 {
+			t.Errorf("Voted returned %v, expected the empty data from the replica", voted)
+		}
+
+		_, _, err = p.VotesSince(ctx, 1, 0)
+		var errDoesNotExist interface{ DoesNotExist() }
+		if !errors.As(err, &errDoesNotExist) {
+			t.Errorf("VotesSince has to return DoesNotExist() from the replica, got: %v", err)
+		}
+	})
+
+	t.Run("write methods use the primary", func(t *testing.T) {
+		objects, userIDs, err := p.Stop(ctx, 1)
+		if err != nil {
+			t.Fatalf("Stop returned: %v", err)
+		}
+
+		if len(objects) != 1 || len(userIDs) != 1 {
+			t.Errorf("Stop returned %d objects and %d users, expected one of each from the primary", len(objects), len(userIDs))
+		}
+	})
+}
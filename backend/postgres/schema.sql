@@ -19,3 +19,24 @@ CREATE TABLE IF NOT EXISTS vote.objects (
     -- The vote object.
     vote BYTEA
 );
+
+CREATE TABLE IF NOT EXISTS vote.nonce (
+    poll_id INTEGER NOT NULL REFERENCES vote.poll(id) ON DELETE CASCADE,
+
+    -- The nonce of a ballot. Each nonce can only be used once per poll.
+    nonce TEXT NOT NULL,
+
+    PRIMARY KEY (poll_id, nonce)
+);
+
+CREATE TABLE IF NOT EXISTS vote.poll_time (
+    poll_id INTEGER NOT NULL REFERENCES vote.poll(id) ON DELETE CASCADE,
+
+    -- The name of the time, for example the deadline of the poll.
+    name TEXT NOT NULL,
+
+    -- The time as unix time in nanoseconds.
+    time BIGINT NOT NULL,
+
+    PRIMARY KEY (poll_id, name)
+);
@@ -6,7 +6,8 @@
 // access to the redis database can see the vote results and how each user has
 // voted.
 //
-// It uses the keys `vote_state_X`, `vote_data_X` and `vote_polls` where X is a
+// It uses the keys `vote_state_X`, `vote_data_X`, `vote_sequence_X`,
+// `vote_nonce_X`, `vote_times_X`, `vote_polls` and `vote_count` where X is a
 // pollID.
 //
 // The key `vote_state_X` has type int. It is a number that tells the current
@@ -15,11 +16,27 @@
 // The key `vote_data_X` has type hash. The key is a user id and the value the
 // vote of the user.
 //
+// The key `vote_sequence_X` has type list. It contains the vote objects in the
+// order they were saved. The position in the list (starting with 1) is the
+// sequence number of a vote.
+//
+// The key `vote_nonce_X` has type set. It contains the nonces of the ballots,
+// that were saved for the poll.
+//
+// The key `vote_times_X` has type hash. The key is the name of a time and the
+// value the time as unix time in nanoseconds.
+//
 // The key `vote_polls` has type set. It contains the pollIDs of all known polls.
+//
+// The key `vote_count` has type hash. The key is a poll id and the value the
+// number of votes of the poll. A poll from an older version without a field is
+// counted from its vote data on the next vote or retract.
 package redis
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"strconv"
@@ -31,9 +48,13 @@ import (
 )
 
 const (
-	keyState = "vote_state_%d"
-	keyVote  = "vote_data_%d"
-	keyPolls = "vote_polls"
+	keyState    = "vote_state_%d"
+	keyVote     = "vote_data_%d"
+	keySequence = "vote_sequence_%d"
+	keyNonce    = "vote_nonce_%d"
+	keyTimes    = "vote_times_%d"
+	keyPolls    = "vote_polls"
+	keyCount    = "vote_count"
 )
 
 // Backend is the vote-Backend.
@@ -42,8 +63,11 @@ const (
 type Backend struct {
 	pool *redis.Pool
 
-	luaScriptVote     *redis.Script
-	luaScriptClearAll *redis.Script
+	luaScriptVote       *redis.Script
+	luaScriptRetract    *redis.Script
+	luaScriptClearAll   *redis.Script
+	luaScriptVotesSince *redis.Script
+	luaScriptSetTime    *redis.Script
 }
 
 // New creates an initializes Redis instance.
@@ -59,17 +83,22 @@ func New(addr string) *Backend {
 	return &Backend{
 		pool: &pool,
 
-		luaScriptVote:     redis.NewScript(2, luaVoteScript),
-		luaScriptClearAll: redis.NewScript(1, luaClearAll),
+		luaScriptVote:       redis.NewScript(5, luaVoteScript),
+		luaScriptRetract:    redis.NewScript(3, luaRetractScript),
+		luaScriptClearAll:   redis.NewScript(2, luaClearAll),
+		luaScriptVotesSince: redis.NewScript(2, luaVotesSinceScript),
+		luaScriptSetTime:    redis.NewScript(2, luaSetTimeScript),
 	}
 }
 
 // Wait blocks until a connection to redis can be established.
 func (b *Backend) Wait(ctx context.Context) {
 	for ctx.Err() == nil {
-		conn := b.pool.Get()
-		_, err := conn.Do("PING")
-		conn.Close()
+		conn, err := b.pool.GetContext(ctx)
+		if err == nil {
+			_, err = redis.DoContext(conn, ctx, "PING")
+			conn.Close()
+		}
 		if err == nil {
 			return
 		}
@@ -78,41 +107,75 @@ func (b *Backend) Wait(ctx context.Context) {
 	}
 }
 
+// Ping checks, that redis is reachable.
+func (b *Backend) Ping(ctx context.Context) error {
+	conn, err := b.pool.GetContext(ctx)
+	if err != nil {
+		return fmt.Errorf("getting redis connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := redis.DoContext(conn, ctx, "PING"); err != nil {
+		return fmt.Errorf("ping redis: %w", err)
+	}
+	return nil
+}
+
 func (b *Backend) String() string {
 	return "redis"
 }
 
 // Start starts the poll.
 func (b *Backend) Start(ctx context.Context, pollID int) error {
-	conn := b.pool.Get()
+	conn, err := b.pool.GetContext(ctx)
+	if err != nil {
+		return fmt.Errorf("getting redis connection: %w", err)
+	}
 	defer conn.Close()
 
 	sKey := fmt.Sprintf(keyState, pollID)
 
 	log.Debug("Redis: SETNX %s 1", sKey)
-	if _, err := conn.Do("SETNX", sKey, 1); err != nil {
+	if _, err := redis.DoContext(conn, ctx, "SETNX", sKey, 1); err != nil {
 		return fmt.Errorf("set state key to 1: %w", err)
 	}
 
 	log.Debug("Redis: SADD %s %d", keyPolls, pollID)
-	if _, err := conn.Do("SADD", keyPolls, pollID); err != nil {
+	if _, err := redis.DoContext(conn, ctx, "SADD", keyPolls, pollID); err != nil {
 		return fmt.Errorf("add poll ID to %s: %w", keyPolls, err)
 	}
 	return nil
 }
 
+// luaEnsureCount is a lua function, that sets the count of a poll in the
+// vote_count hash from the vote data, if the field is missing. This counts the
+// votes of a poll from an older version.
+const luaEnsureCount = `
+local function ensureCount(countKey, voteKey, pollID)
+	if redis.call("HEXISTS",countKey,pollID) == 0 then
+		redis.call("HSET",countKey,pollID,redis.call("HLEN",voteKey))
+	end
+end
+`
+
 // luaVoteScript checks for condition and saves a vote if all checks pass.
 //
 // KEYS[1] == state key
 // KEYS[2] == vote data
+// KEYS[3] == vote sequence
+// KEYS[4] == vote count
+// KEYS[5] == nonces
 // ARGV[1] == userID
 // ARGV[2] == Vote object
+// ARGV[3] == pollID
+// ARGV[4] == nonce or an empty string
 //
 // Returns 0 on success
 // Returns 1 if the poll is not started.
 // Returns 2 if the poll was stopped.
 // Returns 3 if the user has already voted.
-const luaVoteScript = `
+// Returns 4 if the nonce was already used.
+const luaVoteScript = luaEnsureCount + `
 local state = redis.call("GET",KEYS[1])
 if state == false then 
 	return 1
@@ -122,25 +185,69 @@ if state == "2" then
 	return 2
 end
 
-local saved = redis.call("HSETNX",KEYS[2],ARGV[1],ARGV[2])
-if saved == 0 then
+if redis.call("HEXISTS",KEYS[2],ARGV[1]) == 1 then
 	return 3
 end
 
+if ARGV[4] ~= "" and redis.call("SADD",KEYS[5],ARGV[4]) == 0 then
+	return 4
+end
+
+ensureCount(KEYS[4],KEYS[2],ARGV[3])
+
+redis.call("HSET",KEYS[2],ARGV[1],ARGV[2])
+redis.call("RPUSH",KEYS[3],ARGV[2])
+redis.call("HINCRBY",KEYS[4],ARGV[3],1)
+
 return 0`
 
 // Vote saves a vote in redis.
 //
 // It also checks, that the user did not vote before and that the poll is open.
 func (b *Backend) Vote(ctx context.Context, pollID int, userID int, object []byte) error {
-	conn := b.pool.Get()
-	defer conn.Close()
+	return b.VoteWithNonce(ctx, pollID, userID, "", object)
+}
 
+// VoteWithNonce saves a vote like Vote. The nonce is checked and saved in the
+// same lua script.
+func (b *Backend) VoteWithNonce(ctx context.Context, pollID int, userID int, nonce string, object []byte) error {
 	vKey := fmt.Sprintf(keyVote, pollID)
 	sKey := fmt.Sprintf(keyState, pollID)
+	seqKey := fmt.Sprintf(keySequence, pollID)
+	nKey := fmt.Sprintf(keyNonce, pollID)
+
+	log.Debug("Redis: lua script vote: '%s' 5 %s %s %s %s %s [userID] [vote] %d [nonce]", luaVoteScript, sKey, vKey, seqKey, keyCount, nKey, pollID)
+	var result int
+	var attempt int
+	err := retryOnConnError(ctx, func() error {
+		attempt++
+		conn, err := b.pool.GetContext(ctx)
+		if err != nil {
+			return fmt.Errorf("getting redis connection: %w", err)
+		}
+		defer conn.Close()
+
+		result, err = redis.Int(b.luaScriptVote.DoContext(ctx, conn, sKey, vKey, seqKey, keyCount, nKey, userID, object, pollID, nonce))
+		if err != nil {
+			return err
+		}
 
-	log.Debug("Redis: lua script vote: '%s' 2 %s %s [userID] [vote]", luaVoteScript, sKey, vKey)
-	result, err := redis.Int(b.luaScriptVote.Do(conn, sKey, vKey, userID, object))
+		if result == 3 && attempt > 1 {
+			// The connection of an earlier attempt could have broken after
+			// the script saved the vote. In this case, the saved vote is
+			// this vote.
+			log.Debug("Redis: HGET %s %d", vKey, userID)
+			saved, err := redis.Bytes(redis.DoContext(conn, ctx, "HGET", vKey, userID))
+			if err != nil {
+				return fmt.Errorf("reading saved vote: %w", err)
+			}
+
+			if bytes.Equal(saved, object) {
+				result = 0
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		return fmt.Errorf("executing luaVoteScript: %w", err)
 	}
@@ -153,6 +260,66 @@ func (b *Backend) Vote(ctx context.Context, pollID int, userID int, object []byt
 		return stoppedError{fmt.Errorf("poll is stopped")}
 	case 3:
 		return doubleVoteError{fmt.Errorf("user has voted")}
+	case 4:
+		return nonceUsedError{fmt.Errorf("nonce was already used")}
+	default:
+		return nil
+	}
+}
+
+// luaRetractScript removes the vote of a user, if the poll is started.
+//
+// KEYS[1] == state key
+// KEYS[2] == vote data
+// KEYS[3] == vote count
+// ARGV[1] == userID
+// ARGV[2] == pollID
+//
+// Returns 0 on success
+// Returns 1 if the poll is not started.
+// Returns 2 if the poll was stopped.
+const luaRetractScript = luaEnsureCount + `
+local state = redis.call("GET",KEYS[1])
+if state == false then
+	return 1
+end
+
+if state == "2" then
+	return 2
+end
+
+ensureCount(KEYS[3],KEYS[2],ARGV[2])
+
+if redis.call("HDEL",KEYS[2],ARGV[1]) == 1 then
+	redis.call("HINCRBY",KEYS[3],ARGV[2],-1)
+end
+
+return 0`
+
+// RetractVote removes the vote of a user.
+//
+// The vote object stays in the list `vote_sequence_X`.
+func (b *Backend) RetractVote(ctx context.Context, pollID int, userID int) error {
+	conn, err := b.pool.GetContext(ctx)
+	if err != nil {
+		return fmt.Errorf("getting redis connection: %w", err)
+	}
+	defer conn.Close()
+
+	vKey := fmt.Sprintf(keyVote, pollID)
+	sKey := fmt.Sprintf(keyState, pollID)
+
+	log.Debug("Redis: lua script retract: '%s' 3 %s %s %s %d %d", luaRetractScript, sKey, vKey, keyCount, userID, pollID)
+	result, err := redis.Int(b.luaScriptRetract.DoContext(ctx, conn, sKey, vKey, keyCount, userID, pollID))
+	if err != nil {
+		return fmt.Errorf("executing luaRetractScript: %w", err)
+	}
+
+	switch result {
+	case 1:
+		return doesNotExistError{fmt.Errorf("poll does not exist")}
+	case 2:
+		return stoppedError{fmt.Errorf("poll is stopped")}
 	default:
 		return nil
 	}
@@ -162,14 +329,16 @@ func (b *Backend) Vote(ctx context.Context, pollID int, userID int, object []byt
 //
 // It returns all vote objects.
 func (b *Backend) Stop(ctx context.Context, pollID int) ([][]byte, []int, error) {
-	conn := b.pool.Get()
+	conn, err := b.pool.GetContext(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting redis connection: %w", err)
+	}
 	defer conn.Close()
 
-	vKey := fmt.Sprintf(keyVote, pollID)
 	sKey := fmt.Sprintf(keyState, pollID)
 
 	log.Debug("SET %s 2 XX", sKey)
-	_, err := redis.String(conn.Do("SET", sKey, "2", "XX"))
+	_, err = redis.String(redis.DoContext(conn, ctx, "SET", sKey, "2", "XX"))
 	if err != nil {
 		if err == redis.ErrNil {
 			return nil, nil, doesNotExistError{fmt.Errorf("poll does not exist")}
@@ -177,12 +346,60 @@ func (b *Backend) Stop(ctx context.Context, pollID int) ([][]byte, []int, error)
 		return nil, nil, fmt.Errorf("set key %s to 2: %w", sKey, err)
 	}
 
+	return b.readVotes(ctx, conn, pollID)
+}
+
+// Result returns the vote objects and user ids of a stopped poll like Stop,
+// but does not change the poll.
+func (b *Backend) Result(ctx context.Context, pollID int) ([][]byte, []int, error) {
+	conn, err := b.pool.GetContext(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting redis connection: %w", err)
+	}
+	defer conn.Close()
+
+	sKey := fmt.Sprintf(keyState, pollID)
+
+	log.Debug("REDIS: GET %s", sKey)
+	state, err := redis.String(redis.DoContext(conn, ctx, "GET", sKey))
+	if err != nil {
+		if err == redis.ErrNil {
+			return nil, nil, doesNotExistError{fmt.Errorf("poll does not exist")}
+		}
+		return nil, nil, fmt.Errorf("getting state from %s: %w", sKey, err)
+	}
+
+	if state != "2" {
+		return nil, nil, startedError{fmt.Errorf("poll is not stopped")}
+	}
+
+	return b.readVotes(ctx, conn, pollID)
+}
+
+// readVotes returns the vote objects and user ids of a poll.
+func (b *Backend) readVotes(ctx context.Context, conn redis.Conn, pollID int) ([][]byte, []int, error) {
+	vKey := fmt.Sprintf(keyVote, pollID)
+
 	log.Debug("REDIS: HVALS %s", vKey)
-	data, err := redis.StringMap(conn.Do("HGETALL", vKey))
+	data, err := redis.StringMap(redis.DoContext(conn, ctx, "HGETALL", vKey))
 	if err != nil {
 		return nil, nil, fmt.Errorf("getting vote objects from %s: %w", vKey, err)
 	}
 
+	// Make sure, that all votes were returned. On an unstable connection, the
+	// result could be incomplete.
+	log.Debug("REDIS: HGET %s %d", keyCount, pollID)
+	count, err := redis.Int(redis.DoContext(conn, ctx, "HGET", keyCount, pollID))
+	if err != nil && err != redis.ErrNil {
+		return nil, nil, fmt.Errorf("getting vote count from %s: %w", keyCount, err)
+	}
+
+	// Without the count field, the poll has no votes or was created by an older
+	// version.
+	if err == nil && count != len(data) {
+		return nil, nil, fmt.Errorf("integrity error: got %d vote objects, but %d votes were counted", len(data), count)
+	}
+
 	userIDs := make([]int, 0, len(data))
 	voteObjects := make([][]byte, 0, len(data))
 	for uid, vote := range data {
@@ -198,21 +415,56 @@ func (b *Backend) Stop(ctx context.Context, pollID int) ([][]byte, []int, error)
 	return voteObjects, userIDs, nil
 }
 
+// Reopen starts a stopped poll again.
+//
+// The vote objects are not touched.
+func (b *Backend) Reopen(ctx context.Context, pollID int) error {
+	conn, err := b.pool.GetContext(ctx)
+	if err != nil {
+		return fmt.Errorf("getting redis connection: %w", err)
+	}
+	defer conn.Close()
+
+	sKey := fmt.Sprintf(keyState, pollID)
+
+	log.Debug("SET %s 1 XX", sKey)
+	_, err = redis.String(redis.DoContext(conn, ctx, "SET", sKey, "1", "XX"))
+	if err != nil {
+		if err == redis.ErrNil {
+			return doesNotExistError{fmt.Errorf("poll does not exist")}
+		}
+		return fmt.Errorf("set key %s to 1: %w", sKey, err)
+	}
+
+	return nil
+}
+
 // Clear delete all information from a poll.
 func (b *Backend) Clear(ctx context.Context, pollID int) error {
-	conn := b.pool.Get()
+	conn, err := b.pool.GetContext(ctx)
+	if err != nil {
+		return fmt.Errorf("getting redis connection: %w", err)
+	}
 	defer conn.Close()
 
 	vKey := fmt.Sprintf(keyVote, pollID)
 	sKey := fmt.Sprintf(keyState, pollID)
+	seqKey := fmt.Sprintf(keySequence, pollID)
+	nKey := fmt.Sprintf(keyNonce, pollID)
+	tKey := fmt.Sprintf(keyTimes, pollID)
 
-	log.Debug("REDIS: DEL %s %s", vKey, sKey)
-	if _, err := conn.Do("DEL", vKey, sKey); err != nil {
+	log.Debug("REDIS: DEL %s %s %s %s %s", vKey, sKey, seqKey, nKey, tKey)
+	if _, err := redis.DoContext(conn, ctx, "DEL", vKey, sKey, seqKey, nKey, tKey); err != nil {
 		return fmt.Errorf("removing keys: %w", err)
 	}
 
+	log.Debug("REDIS: HDEL %s %d", keyCount, pollID)
+	if _, err := redis.DoContext(conn, ctx, "HDEL", keyCount, pollID); err != nil {
+		return fmt.Errorf("remove pollID from %s: %w", keyCount, err)
+	}
+
 	log.Debug("REDIS: SREM %s %d", keyPolls, pollID)
-	if _, err := conn.Do("SREM", keyPolls, pollID); err != nil {
+	if _, err := redis.DoContext(conn, ctx, "SREM", keyPolls, pollID); err != nil {
 		return fmt.Errorf("remove pollID from %s: %w", keyPolls, err)
 	}
 
@@ -222,42 +474,269 @@ func (b *Backend) Clear(ctx context.Context, pollID int) error {
 // luaClearAll removes all vote related data from redis.
 //
 // KEYS[1] == polls
+// KEYS[2] == vote count
 //
 // ARGV[1] == state key pattern
 // ARGV[2] == vote data pattern
+// ARGV[3] == vote sequence pattern
+// ARGV[4] == nonce pattern
+// ARGV[5] == times pattern
 const luaClearAll = `
 for _, pollID in ipairs(redis.call("SMEMBERS",KEYS[1])) do
 	redis.call("DEL", ARGV[1]..pollID)
 	redis.call("DEL", ARGV[2]..pollID)
+	redis.call("DEL", ARGV[3]..pollID)
+	redis.call("DEL", ARGV[4]..pollID)
+	redis.call("DEL", ARGV[5]..pollID)
 end
 redis.call("DEL", KEYS[1])
+redis.call("DEL", KEYS[2])
 `
 
 // ClearAll removes all data from all polls.
 func (b *Backend) ClearAll(ctx context.Context) error {
-	conn := b.pool.Get()
+	conn, err := b.pool.GetContext(ctx)
+	if err != nil {
+		return fmt.Errorf("getting redis connection: %w", err)
+	}
 	defer conn.Close()
 
 	voteKeyPattern := strings.ReplaceAll(keyVote, "%d", "")
 	stateKeyPattern := strings.ReplaceAll(keyState, "%d", "")
+	sequenceKeyPattern := strings.ReplaceAll(keySequence, "%d", "")
+	nonceKeyPattern := strings.ReplaceAll(keyNonce, "%d", "")
+	timesKeyPattern := strings.ReplaceAll(keyTimes, "%d", "")
 
-	log.Debug("Redis: lua script clear all: '%s' 2 %s %s", luaClearAll, voteKeyPattern, stateKeyPattern)
-	if _, err := b.luaScriptClearAll.Do(conn, keyPolls, voteKeyPattern, stateKeyPattern); err != nil {
+	log.Debug("Redis: lua script clear all: '%s' 2 %s %s %s %s %s %s %s", luaClearAll, keyPolls, keyCount, stateKeyPattern, voteKeyPattern, sequenceKeyPattern, nonceKeyPattern, timesKeyPattern)
+	if _, err := b.luaScriptClearAll.DoContext(ctx, conn, keyPolls, keyCount, stateKeyPattern, voteKeyPattern, sequenceKeyPattern, nonceKeyPattern, timesKeyPattern); err != nil {
 		return fmt.Errorf("removing keys: %w", err)
 	}
 
 	return nil
 }
 
+// VoteCount returns for all polls the number of votes.
+//
+// It reads the counter, that is updated on each vote. Only polls without a
+// counter, for example polls without votes or from an older version, are
+// counted from their vote data.
+//
+// This command is not atomic.
+func (b *Backend) VoteCount(ctx context.Context) (map[int]int, error) {
+	conn, err := b.pool.GetContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting redis connection: %w", err)
+	}
+	defer conn.Close()
+
+	log.Debug("REDIS: SMEMBERS %s", keyPolls)
+	pollIDs, err := redis.Ints(redis.DoContext(conn, ctx, "SMEMBERS", keyPolls))
+	if err != nil {
+		return nil, fmt.Errorf("getting all known pollIDs: %w", err)
+	}
+
+	log.Debug("REDIS: HGETALL %s", keyCount)
+	data, err := redis.IntMap(redis.DoContext(conn, ctx, "HGETALL", keyCount))
+	if err != nil {
+		return nil, fmt.Errorf("getting vote count from %s: %w", keyCount, err)
+	}
+
+	count := make(map[int]int, len(pollIDs))
+	var uncounted []int
+	for _, pollID := range pollIDs {
+		c, ok := data[strconv.Itoa(pollID)]
+		if !ok {
+			uncounted = append(uncounted, pollID)
+			continue
+		}
+		count[pollID] = c
+	}
+
+	if len(uncounted) == 0 {
+		return count, nil
+	}
+
+	for _, pollID := range uncounted {
+		key := fmt.Sprintf(keyVote, pollID)
+		log.Debug("Redis: HLEN %s", key)
+		if err := conn.Send("HLEN", key); err != nil {
+			return nil, fmt.Errorf("sending HLEN for key %s: %w", key, err)
+		}
+	}
+
+	if err := conn.Flush(); err != nil {
+		return nil, fmt.Errorf("sending HLEN commands: %w", err)
+	}
+
+	for _, pollID := range uncounted {
+		c, err := redis.Int(redis.ReceiveContext(conn, ctx))
+		if err != nil {
+			return nil, fmt.Errorf("receiving HLEN for poll %d: %w", pollID, err)
+		}
+		count[pollID] = c
+	}
+
+	return count, nil
+}
+
+// Polls returns all known polls. The value is true, if the poll is stopped.
+//
+// This command is not atomic.
+func (b *Backend) Polls(ctx context.Context) (map[int]bool, error) {
+	conn, err := b.pool.GetContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting redis connection: %w", err)
+	}
+	defer conn.Close()
+
+	log.Debug("REDIS: SMEMBERS %s", keyPolls)
+	pollIDs, err := redis.Ints(redis.DoContext(conn, ctx, "SMEMBERS", keyPolls))
+	if err != nil {
+		return nil, fmt.Errorf("getting all known pollIDs: %w", err)
+	}
+
+	for _, pollID := range pollIDs {
+		key := fmt.Sprintf(keyState, pollID)
+		log.Debug("Redis: GET %s", key)
+		if err := conn.Send("GET", key); err != nil {
+			return nil, fmt.Errorf("sending GET for key %s: %w", key, err)
+		}
+	}
+
+	if err := conn.Flush(); err != nil {
+		return nil, fmt.Errorf("sending GET commands: %w", err)
+	}
+
+	out := make(map[int]bool, len(pollIDs))
+	for _, pollID := range pollIDs {
+		state, err := redis.String(redis.ReceiveContext(conn, ctx))
+		if err != nil {
+			if errors.Is(err, redis.ErrNil) {
+				// The poll was cleared after SMEMBERS.
+				continue
+			}
+			return nil, fmt.Errorf("receiving state of poll %d: %w", pollID, err)
+		}
+		out[pollID] = state == "2"
+	}
+
+	return out, nil
+}
+
+// luaSetTimeScript saves a time of a poll, if the poll exists.
+//
+// KEYS[1] == state key
+// KEYS[2] == times key
+// ARGV[1] == name
+// ARGV[2] == unix time in nanoseconds or an empty string to remove the time
+// ARGV[3] == 1, if an existing time is replaced
+//
+// Returns 0 on success
+// Returns 1 if the poll does not exist.
+const luaSetTimeScript = `
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 1
+end
+
+if ARGV[2] == "" then
+	redis.call("HDEL", KEYS[2], ARGV[1])
+elseif ARGV[3] == "1" then
+	redis.call("HSET", KEYS[2], ARGV[1], ARGV[2])
+else
+	redis.call("HSETNX", KEYS[2], ARGV[1], ARGV[2])
+end
+
+return 0
+`
+
+// SetTime saves a time with a name for a poll.
+func (b *Backend) SetTime(ctx context.Context, pollID int, name string, t time.Time, overwrite bool) error {
+	conn, err := b.pool.GetContext(ctx)
+	if err != nil {
+		return fmt.Errorf("getting redis connection: %w", err)
+	}
+	defer conn.Close()
+
+	sKey := fmt.Sprintf(keyState, pollID)
+	tKey := fmt.Sprintf(keyTimes, pollID)
+
+	value := ""
+	if !t.IsZero() {
+		value = strconv.FormatInt(t.UnixNano(), 10)
+	}
+
+	replace := 0
+	if overwrite {
+		replace = 1
+	}
+
+	log.Debug("Redis: lua script set time: '%s' 2 %s %s %s %s %d", luaSetTimeScript, sKey, tKey, name, value, replace)
+	result, err := redis.Int(b.luaScriptSetTime.DoContext(ctx, conn, sKey, tKey, name, value, replace))
+	if err != nil {
+		return fmt.Errorf("executing luaSetTimeScript: %w", err)
+	}
+
+	if result == 1 {
+		return doesNotExistError{fmt.Errorf("poll does not exist")}
+	}
+	return nil
+}
+
+// Times returns for all polls the time with the name.
+//
+// This command is not atomic.
+func (b *Backend) Times(ctx context.Context, name string) (map[int]time.Time, error) {
+	conn, err := b.pool.GetContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting redis connection: %w", err)
+	}
+	defer conn.Close()
+
+	log.Debug("REDIS: SMEMBERS %s", keyPolls)
+	pollIDs, err := redis.Ints(redis.DoContext(conn, ctx, "SMEMBERS", keyPolls))
+	if err != nil {
+		return nil, fmt.Errorf("getting all known pollIDs: %w", err)
+	}
+
+	for _, pollID := range pollIDs {
+		key := fmt.Sprintf(keyTimes, pollID)
+		log.Debug("Redis: HGET %s %s", key, name)
+		if err := conn.Send("HGET", key, name); err != nil {
+			return nil, fmt.Errorf("sending HGET for key %s: %w", key, err)
+		}
+	}
+
+	if err := conn.Flush(); err != nil {
+		return nil, fmt.Errorf("sending HGET commands: %w", err)
+	}
+
+	out := make(map[int]time.Time)
+	for _, pollID := range pollIDs {
+		nanos, err := redis.Int64(redis.ReceiveContext(conn, ctx))
+		if err != nil {
+			if errors.Is(err, redis.ErrNil) {
+				continue
+			}
+			return nil, fmt.Errorf("receiving time of poll %d: %w", pollID, err)
+		}
+		out[pollID] = time.Unix(0, nanos)
+	}
+
+	return out, nil
+}
+
 // Voted returns for all polls the userIDs, that have voted.
 //
 // This command is not atomic.
 func (b *Backend) Voted(ctx context.Context) (map[int][]int, error) {
-	conn := b.pool.Get()
+	conn, err := b.pool.GetContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting redis connection: %w", err)
+	}
 	defer conn.Close()
 
 	log.Debug("REDIS: SMEMBERS %s", keyPolls)
-	pollIDs, err := redis.Ints(conn.Do("SMEMBERS", keyPolls))
+	pollIDs, err := redis.Ints(redis.DoContext(conn, ctx, "SMEMBERS", keyPolls))
 	if err != nil {
 		return nil, fmt.Errorf("getting all known pollIDs: %w", err)
 	}
@@ -267,7 +746,7 @@ func (b *Backend) Voted(ctx context.Context) (map[int][]int, error) {
 		key := fmt.Sprintf(keyVote, pollID)
 
 		log.Debug("Redis: HKEYS %s", key)
-		userIDs, err := redis.Ints(conn.Do("HKEYS", key))
+		userIDs, err := redis.Ints(redis.DoContext(conn, ctx, "HKEYS", key))
 		if err != nil {
 			return nil, fmt.Errorf("HKEYS for key %s: %w", key, err)
 		}
@@ -278,6 +757,141 @@ func (b *Backend) Voted(ctx context.Context) (map[int][]int, error) {
 	return out, nil
 }
 
+// VotedObject returns the vote object of a user.
+func (b *Backend) VotedObject(ctx context.Context, pollID int, userID int) ([]byte, bool, error) {
+	conn, err := b.pool.GetContext(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("getting redis connection: %w", err)
+	}
+	defer conn.Close()
+
+	sKey := fmt.Sprintf(keyState, pollID)
+	vKey := fmt.Sprintf(keyVote, pollID)
+
+	log.Debug("Redis: EXISTS %s", sKey)
+	exists, err := redis.Bool(redis.DoContext(conn, ctx, "EXISTS", sKey))
+	if err != nil {
+		return nil, false, fmt.Errorf("checking poll state: %w", err)
+	}
+
+	if !exists {
+		return nil, false, doesNotExistError{fmt.Errorf("poll does not exist")}
+	}
+
+	log.Debug("Redis: HGET %s %d", vKey, userID)
+	object, err := redis.Bytes(redis.DoContext(conn, ctx, "HGET", vKey, userID))
+	if err != nil {
+		if err == redis.ErrNil {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("getting vote object from %s: %w", vKey, err)
+	}
+
+	return object, true, nil
+}
+
+// luaVotesSinceScript returns the vote objects after a sequence number.
+//
+// KEYS[1] == state key
+// KEYS[2] == vote sequence
+// ARGV[1] == afterSeq
+//
+// Returns nil, if the poll does not exist. Else it returns a list with the
+// latest sequence number as first element and the vote objects as second
+// element.
+const luaVotesSinceScript = `
+if redis.call("EXISTS",KEYS[1]) == 0 then
+	return nil
+end
+
+local latest = redis.call("LLEN",KEYS[2])
+local objects = redis.call("LRANGE",KEYS[2],ARGV[1],-1)
+return {latest, objects}`
+
+// VotesSince returns all vote objects with a sequence number greater then
+// afterSeq and the latest sequence number.
+func (b *Backend) VotesSince(ctx context.Context, pollID int, afterSeq int) ([][]byte, int, error) {
+	conn, err := b.pool.GetContext(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("getting redis connection: %w", err)
+	}
+	defer conn.Close()
+
+	if afterSeq < 0 {
+		afterSeq = 0
+	}
+
+	sKey := fmt.Sprintf(keyState, pollID)
+	seqKey := fmt.Sprintf(keySequence, pollID)
+
+	log.Debug("Redis: lua script votes since: '%s' 2 %s %s %d", luaVotesSinceScript, sKey, seqKey, afterSeq)
+	result, err := redis.Values(b.luaScriptVotesSince.DoContext(ctx, conn, sKey, seqKey, afterSeq))
+	if err != nil {
+		if err == redis.ErrNil {
+			return nil, 0, doesNotExistError{fmt.Errorf("poll does not exist")}
+		}
+		return nil, 0, fmt.Errorf("executing luaVotesSinceScript: %w", err)
+	}
+
+	if len(result) != 2 {
+		return nil, 0, fmt.Errorf("luaVotesSinceScript returned %d values, expected 2", len(result))
+	}
+
+	latest, err := redis.Int(result[0], nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("parsing latest sequence number: %w", err)
+	}
+
+	objects, err := redis.ByteSlices(result[1], nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("parsing vote objects: %w", err)
+	}
+
+	return objects, latest, nil
+}
+
+const (
+	// connRetries is the number of retries after a connection error.
+	connRetries = 5
+
+	// connRetryBackoff is the time to wait before the first retry. It is
+	// doubled with each retry.
+	connRetryBackoff = 50 * time.Millisecond
+)
+
+// retryOnConnError calls f until it succeeds or returns an error, that is not
+// a connection error. It gives up after connRetries retries or when the
+// context is done.
+//
+// Error replies from redis are not retried. If the connection breaks after the
+// command was executed, the retry sees the result of the first call. So f has
+// to handle the case, that its command was already executed.
+func retryOnConnError(ctx context.Context, f func() error) error {
+	backoff := connRetryBackoff
+	var err error
+	for i := 0; ; i++ {
+		err = f()
+		if err == nil || !isConnError(err) || i >= connRetries {
+			return err
+		}
+
+		log.Info("Redis connection error, retry in %s: %v", backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w, retry canceled: %w", err, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// isConnError returns true, if the error is not an answer from redis.
+func isConnError(err error) bool {
+	var errReply redis.Error
+	return !errors.As(err, &errReply) && !errors.Is(err, redis.ErrNil)
+}
+
 type doesNotExistError struct {
 	error
 }
@@ -295,3 +909,15 @@ type stoppedError struct {
 }
 
 func (stoppedError) Stopped() {}
+
+type nonceUsedError struct {
+	error
+}
+
+func (nonceUsedError) NonceUsed() {}
+
+type startedError struct {
+	error
+}
+
+func (startedError) Started() {}
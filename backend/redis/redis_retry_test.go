@@ -0,0 +1,147 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// fakeConn is a redis connection, that answers each script with 0.
+type fakeConn struct {
+	mu      *sync.Mutex
+	scripts *int
+}
+
+func (c fakeConn) Close() error { return nil }
+func (c fakeConn) Err() error   { return nil }
+func (c fakeConn) Flush() error { return nil }
+
+func (c fakeConn) Send(commandName string, args ...interface{}) error { return nil }
+
+func (c fakeConn) Receive() (interface{}, error) { return nil, nil }
+
+func (c fakeConn) ReceiveContext(ctx context.Context) (interface{}, error) { return c.Receive() }
+
+func (c fakeConn) DoContext(ctx context.Context, commandName string, args ...interface{}) (interface{}, error) {
+	return c.Do(commandName, args...)
+}
+
+func (c fakeConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if commandName == "EVALSHA" {
+		c.mu.Lock()
+		*c.scripts++
+		c.mu.Unlock()
+		return int64(0), nil
+	}
+	return nil, nil
+}
+
+func TestVoteRetryOnConnError(t *testing.T) {
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var dials, scripts int
+	b := New("")
+	b.pool.Dial = func() (redis.Conn, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		dials++
+		if dials <= 2 {
+			return nil, errors.New("connection refused")
+		}
+		return fakeConn{mu: &mu, scripts: &scripts}, nil
+	}
+
+	if err := b.Vote(ctx, 1, 5, []byte("vote")); err != nil {
+		t.Fatalf("Vote: %v", err)
+	}
+
+	if dials != 3 {
+		t.Errorf("Dialed %d times, expected 3", dials)
+	}
+
+	if scripts != 1 {
+		t.Errorf("Vote script was executed %d times, expected 1", scripts)
+	}
+}
+
+func TestVoteNoRetryOnReply(t *testing.T) {
+	ctx := context.Background()
+
+	var dials int
+	b := New("")
+	b.pool.Dial = func() (redis.Conn, error) {
+		dials++
+		return replyConn{replies: map[string]interface{}{"EVALSHA": int64(3)}}, nil
+	}
+
+	err := b.Vote(ctx, 1, 5, []byte("vote"))
+
+	var errDoubleVote interface{ DoubleVote() }
+	if !errors.As(err, &errDoubleVote) {
+		t.Fatalf("Vote returned %v, expected a double vote error", err)
+	}
+
+	if dials != 1 {
+		t.Errorf("Dialed %d times, expected 1", dials)
+	}
+}
+
+// brokenConn is a redis connection, that breaks after each command.
+type brokenConn struct {
+	fakeConn
+}
+
+func (c brokenConn) Err() error { return io.ErrUnexpectedEOF }
+
+func (c brokenConn) DoContext(ctx context.Context, commandName string, args ...interface{}) (interface{}, error) {
+	return nil, io.ErrUnexpectedEOF
+}
+
+func TestVoteRetryAfterSavedVote(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		name        string
+		saved       string
+		expectError bool
+	}{
+		{"saved by first attempt", "vote", false},
+		{"other vote", "other vote", true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var dials int
+			b := New("")
+			b.pool.Dial = func() (redis.Conn, error) {
+				dials++
+				if dials == 1 {
+					return brokenConn{}, nil
+				}
+
+				return replyConn{replies: map[string]interface{}{
+					"EVALSHA": int64(3),
+					"HGET":    []byte(tt.saved),
+				}}, nil
+			}
+
+			err := b.Vote(ctx, 1, 5, []byte("vote"))
+
+			if !tt.expectError {
+				if err != nil {
+					t.Errorf("Vote returned: %v", err)
+				}
+				return
+			}
+
+			var errDoubleVote interface{ DoubleVote() }
+			if !errors.As(err, &errDoubleVote) {
+				t.Errorf("Vote returned %v, expected a double vote error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package redis
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// replyConn is a redis connection, that answers each command with a fixed
+// reply.
+type replyConn struct {
+	fakeConn
+	replies map[string]interface{}
+}
+
+func (c replyConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	return c.replies[commandName], nil
+}
+
+func (c replyConn) DoContext(ctx context.Context, commandName string, args ...interface{}) (interface{}, error) {
+	return c.Do(commandName, args...)
+}
+
+func TestStopIntegrity(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		name      string
+		count     interface{}
+		expectErr bool
+	}{
+		{"matching count", int64(2), false},
+		{"missing votes", int64(3), true},
+		{"without count", nil, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			b := New("")
+			b.pool.Dial = func() (redis.Conn, error) {
+				return replyConn{replies: map[string]interface{}{
+					"SET":     "OK",
+					"HGETALL": []interface{}{[]byte("1"), []byte("vote1"), []byte("2"), []byte("vote2")},
+					"HGET":    tt.count,
+				}}, nil
+			}
+
+			objects, _, err := b.Stop(ctx, 1)
+
+			if !tt.expectErr {
+				if err != nil {
+					t.Fatalf("Stop: %v", err)
+				}
+
+				if len(objects) != 2 {
+					t.Errorf("Got %d objects, expected 2", len(objects))
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), "integrity error") {
+				t.Errorf("Stop returned %v, expected an integrity error", err)
+			}
+		})
+	}
+}
@@ -2,10 +2,13 @@ package redis_test
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/OpenSlides/openslides-vote-service/backend/redis"
 	"github.com/OpenSlides/openslides-vote-service/backend/test"
+	redigo "github.com/gomodule/redigo/redis"
 	"github.com/ory/dockertest/v3"
 )
 
@@ -39,3 +42,80 @@ func TestImplementBackendInterface(t *testing.T) {
 
 	test.Backend(t, r)
 }
+
+func TestVoteCount(t *testing.T) {
+	ctx := context.Background()
+	port, close := startRedis(t)
+	defer close()
+
+	r := redis.New("localhost:" + port)
+	r.Wait(ctx)
+
+	r.Start(ctx, 1)
+	r.Start(ctx, 2)
+	r.Start(ctx, 3)
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 50; i++ {
+		wg.Add(1)
+		go func(userID int) {
+			defer wg.Done()
+			if err := r.Vote(ctx, 1, userID, []byte("vote")); err != nil {
+				t.Errorf("Vote returned unexpected error: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// A double vote is not counted.
+	r.Vote(ctx, 1, 1, []byte("vote"))
+	r.Vote(ctx, 2, 1, []byte("vote"))
+
+	count, err := r.VoteCount(ctx)
+	if err != nil {
+		t.Fatalf("VoteCount returned unexpected error: %v", err)
+	}
+
+	if got := fmt.Sprint(count); got != "map[1:50 2:1 3:0]" {
+		t.Errorf("Got count %s, expected map[1:50 2:1 3:0]", got)
+	}
+
+	t.Run("without count hash", func(t *testing.T) {
+		// Remove the hash like on data from an older version.
+		conn, err := redigo.Dial("tcp", "localhost:"+port)
+		if err != nil {
+			t.Fatalf("connecting to redis: %v", err)
+		}
+		defer conn.Close()
+
+		if _, err := conn.Do("DEL", "vote_count"); err != nil {
+			t.Fatalf("deleting vote_count: %v", err)
+		}
+
+		r.Vote(ctx, 2, 2, []byte("vote"))
+
+		count, err := r.VoteCount(ctx)
+		if err != nil {
+			t.Fatalf("VoteCount returned unexpected error: %v", err)
+		}
+
+		if got := fmt.Sprint(count); got != "map[1:50 2:2 3:0]" {
+			t.Errorf("Got count %s, expected map[1:50 2:2 3:0]", got)
+		}
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		if err := r.Clear(ctx, 1); err != nil {
+			t.Fatalf("Clear: %v", err)
+		}
+
+		count, err := r.VoteCount(ctx)
+		if err != nil {
+			t.Fatalf("VoteCount returned unexpected error: %v", err)
+		}
+
+		if _, ok := count[1]; ok {
+			t.Errorf("Cleared poll 1 is still counted: %v", count)
+		}
+	})
+}
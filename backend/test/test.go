@@ -3,14 +3,18 @@
 package test
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"reflect"
 	"runtime"
 	"sort"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/OpenSlides/openslides-autoupdate-service/pkg/datastore/dsmock"
 	"github.com/OpenSlides/openslides-vote-service/vote"
 )
 
@@ -20,6 +24,12 @@ func Backend(t *testing.T, backend vote.Backend) {
 	ctx := context.Background()
 
 	pollID := 1
+	t.Run("Ping", func(t *testing.T) {
+		if err := backend.Ping(ctx); err != nil {
+			t.Errorf("Ping returned error: %v", err)
+		}
+	})
+
 	t.Run("Start", func(t *testing.T) {
 		t.Run("Start unknown poll", func(t *testing.T) {
 			if err := backend.Start(ctx, pollID); err != nil {
@@ -78,6 +88,49 @@ func Backend(t *testing.T, backend vote.Backend) {
 		})
 	})
 
+	pollID++
+	t.Run("Result", func(t *testing.T) {
+		t.Run("poll unknown", func(t *testing.T) {
+			_, _, err := backend.Result(ctx, 404)
+
+			var errDoesNotExist interface{ DoesNotExist() }
+			if !errors.As(err, &errDoesNotExist) {
+				t.Fatalf("Result of a unknown poll has to return an error with a method DoesNotExist(), got: %v", err)
+			}
+		})
+
+		t.Run("started poll", func(t *testing.T) {
+			backend.Start(ctx, pollID)
+
+			_, _, err := backend.Result(ctx, pollID)
+
+			var errStarted interface{ Started() }
+			if !errors.As(err, &errStarted) {
+				t.Fatalf("Result of a started poll has to return an error with a method Started(), got: %v", err)
+			}
+
+			if err := backend.Vote(ctx, pollID, 5, []byte("my vote")); err != nil {
+				t.Fatalf("Vote after Result returned unexpected error: %v", err)
+			}
+		})
+
+		t.Run("stopped poll", func(t *testing.T) {
+			stopData, stopUsers, err := backend.Stop(ctx, pollID)
+			if err != nil {
+				t.Fatalf("Stop returned unexpected error: %v", err)
+			}
+
+			data, users, err := backend.Result(ctx, pollID)
+			if err != nil {
+				t.Fatalf("Result returned unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(data, stopData) || !reflect.DeepEqual(users, stopUsers) {
+				t.Errorf("Result returned (%q, %v), Stop returned (%q, %v)", data, users, stopData, stopUsers)
+			}
+		})
+	})
+
 	pollID++
 	t.Run("Vote", func(t *testing.T) {
 		t.Run("on notstarted poll", func(t *testing.T) {
@@ -159,6 +212,115 @@ func Backend(t *testing.T, backend vote.Backend) {
 		})
 	})
 
+	pollID++
+	t.Run("VoteWithNonce", func(t *testing.T) {
+		backend.Start(ctx, pollID)
+
+		if err := backend.VoteWithNonce(ctx, pollID, 5, "abc", []byte("my vote")); err != nil {
+			t.Fatalf("VoteWithNonce returned unexpected error: %v", err)
+		}
+
+		t.Run("same nonce", func(t *testing.T) {
+			err := backend.VoteWithNonce(ctx, pollID, 6, "abc", []byte("my vote"))
+
+			var errNonceUsed interface{ NonceUsed() }
+			if !errors.As(err, &errNonceUsed) {
+				t.Fatalf("VoteWithNonce with a used nonce has to return an error with a method NonceUsed(), got: %v", err)
+			}
+
+			voted, err := backend.Voted(ctx)
+			if err != nil {
+				t.Fatalf("Voted returned unexpected error: %v", err)
+			}
+
+			if got := voted[pollID]; !reflect.DeepEqual(got, []int{5}) {
+				t.Errorf("Voted returned %v for the poll, expected [5]", got)
+			}
+		})
+
+		t.Run("other nonce", func(t *testing.T) {
+			if err := backend.VoteWithNonce(ctx, pollID, 6, "def", []byte("my vote")); err != nil {
+				t.Fatalf("VoteWithNonce returned unexpected error: %v", err)
+			}
+		})
+
+		t.Run("after clear", func(t *testing.T) {
+			if err := backend.Clear(ctx, pollID); err != nil {
+				t.Fatalf("Clear returned unexpected error: %v", err)
+			}
+
+			backend.Start(ctx, pollID)
+
+			if err := backend.VoteWithNonce(ctx, pollID, 6, "abc", []byte("my vote")); err != nil {
+				t.Fatalf("VoteWithNonce after clear returned unexpected error: %v", err)
+			}
+		})
+	})
+
+	pollID++
+	t.Run("Reopen", func(t *testing.T) {
+		t.Run("poll unknown", func(t *testing.T) {
+			err := backend.Reopen(ctx, pollID)
+
+			var errDoesNotExist interface{ DoesNotExist() }
+			if !errors.As(err, &errDoesNotExist) {
+				t.Fatalf("Reopen on an unknown poll has to return an error with a method DoesNotExist(), got: %v", err)
+			}
+		})
+
+		t.Run("keeps votes", func(t *testing.T) {
+			backend.Start(ctx, pollID)
+			if err := backend.Vote(ctx, pollID, 5, []byte("my vote")); err != nil {
+				t.Fatalf("Vote returned unexpected error: %v", err)
+			}
+
+			if _, _, err := backend.Stop(ctx, pollID); err != nil {
+				t.Fatalf("Stop returned unexpected error: %v", err)
+			}
+
+			if err := backend.Reopen(ctx, pollID); err != nil {
+				t.Fatalf("Reopen returned unexpected error: %v", err)
+			}
+
+			err := backend.Vote(ctx, pollID, 5, []byte("my second vote"))
+			var errDoubleVote interface{ DoubleVote() }
+			if !errors.As(err, &errDoubleVote) {
+				t.Errorf("Vote after reopen with the same user has to return a DoubleVote error, got: %v", err)
+			}
+
+			if err := backend.Vote(ctx, pollID, 6, []byte("other vote")); err != nil {
+				t.Fatalf("Vote after reopen returned unexpected error: %v", err)
+			}
+
+			data, userIDs, err := backend.Stop(ctx, pollID)
+			if err != nil {
+				t.Fatalf("Stop returned unexpected error: %v", err)
+			}
+
+			sort.Slice(data, func(i, j int) bool { return string(data[i]) < string(data[j]) })
+			expect := [][]byte{[]byte("my vote"), []byte("other vote")}
+			if !reflect.DeepEqual(data, expect) {
+				t.Errorf("Stop returned votes %q, expected %q", data, expect)
+			}
+
+			if !reflect.DeepEqual(userIDs, []int{5, 6}) {
+				t.Errorf("Stop returned user ids %v, expected [5 6]", userIDs)
+			}
+		})
+
+		t.Run("keeps voted users", func(t *testing.T) {
+			got, err := backend.Voted(ctx)
+			if err != nil {
+				t.Fatalf("Voted returned unexpected error: %v", err)
+			}
+
+			sort.Ints(got[pollID])
+			if !reflect.DeepEqual(got[pollID], []int{5, 6}) {
+				t.Errorf("Voted returned %v for the reopend poll, expected [5 6]", got[pollID])
+			}
+		})
+	})
+
 	pollID++
 	t.Run("Clear removes vote data", func(t *testing.T) {
 		backend.Start(ctx, pollID)
@@ -278,6 +440,431 @@ func Backend(t *testing.T, backend vote.Backend) {
 		}
 	})
 
+	pollID++
+	t.Run("Polls", func(t *testing.T) {
+		startedID := pollID
+		pollID++
+		stoppedID := pollID
+
+		backend.Start(ctx, startedID)
+		backend.Start(ctx, stoppedID)
+		if _, _, err := backend.Stop(ctx, stoppedID); err != nil {
+			t.Fatalf("Stop returned unexpected error: %v", err)
+		}
+
+		polls, err := backend.Polls(ctx)
+		if err != nil {
+			t.Fatalf("Polls returned unexpected error: %v", err)
+		}
+
+		if stopped, ok := polls[startedID]; !ok || stopped {
+			t.Errorf("Polls returned for the started poll (%t, %t), expected (false, true)", stopped, ok)
+		}
+
+		if stopped, ok := polls[stoppedID]; !ok || !stopped {
+			t.Errorf("Polls returned for the stopped poll (%t, %t), expected (true, true)", stopped, ok)
+		}
+
+		if _, ok := polls[404]; ok {
+			t.Errorf("Polls returned the unknown poll 404")
+		}
+
+		if err := backend.Clear(ctx, startedID); err != nil {
+			t.Fatalf("Clear returned unexpected error: %v", err)
+		}
+
+		polls, err = backend.Polls(ctx)
+		if err != nil {
+			t.Fatalf("Polls after clear returned unexpected error: %v", err)
+		}
+
+		if _, ok := polls[startedID]; ok {
+			t.Errorf("Polls returned the cleared poll")
+		}
+	})
+
+	pollID++
+	t.Run("SetTime", func(t *testing.T) {
+		first := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+		second := first.Add(time.Hour)
+
+		timeOf := func(t *testing.T) (time.Time, bool) {
+			t.Helper()
+
+			times, err := backend.Times(ctx, "test")
+			if err != nil {
+				t.Fatalf("Times returned unexpected error: %v", err)
+			}
+			got, ok := times[pollID]
+			return got, ok
+		}
+
+		t.Run("poll unknown", func(t *testing.T) {
+			err := backend.SetTime(ctx, 404, "test", first, true)
+
+			var errDoesNotExist interface{ DoesNotExist() }
+			if !errors.As(err, &errDoesNotExist) {
+				t.Fatalf("SetTime on a unknown poll has to return an error with a method DoesNotExist(), got: %v", err)
+			}
+		})
+
+		t.Run("set time", func(t *testing.T) {
+			backend.Start(ctx, pollID)
+
+			if err := backend.SetTime(ctx, pollID, "test", first, false); err != nil {
+				t.Fatalf("SetTime returned unexpected error: %v", err)
+			}
+
+			if got, ok := timeOf(t); !ok || !got.Equal(first) {
+				t.Errorf("Times returned (%v, %t), expected (%v, true)", got, ok, first)
+			}
+
+			times, err := backend.Times(ctx, "other")
+			if err != nil {
+				t.Fatalf("Times returned unexpected error: %v", err)
+			}
+
+			if _, ok := times[pollID]; ok {
+				t.Errorf("Times returned the poll for another name")
+			}
+		})
+
+		t.Run("without overwrite", func(t *testing.T) {
+			if err := backend.SetTime(ctx, pollID, "test", second, false); err != nil {
+				t.Fatalf("SetTime returned unexpected error: %v", err)
+			}
+
+			if got, _ := timeOf(t); !got.Equal(first) {
+				t.Errorf("Times returned %v, expected the old time %v", got, first)
+			}
+		})
+
+		t.Run("with overwrite", func(t *testing.T) {
+			if err := backend.SetTime(ctx, pollID, "test", second, true); err != nil {
+				t.Fatalf("SetTime returned unexpected error: %v", err)
+			}
+
+			if got, _ := timeOf(t); !got.Equal(second) {
+				t.Errorf("Times returned %v, expected the new time %v", got, second)
+			}
+		})
+
+		t.Run("zero time", func(t *testing.T) {
+			if err := backend.SetTime(ctx, pollID, "test", time.Time{}, false); err != nil {
+				t.Fatalf("SetTime returned unexpected error: %v", err)
+			}
+
+			if _, ok := timeOf(t); ok {
+				t.Errorf("Times returned the removed time")
+			}
+		})
+
+		t.Run("after clear", func(t *testing.T) {
+			if err := backend.SetTime(ctx, pollID, "test", first, true); err != nil {
+				t.Fatalf("SetTime returned unexpected error: %v", err)
+			}
+
+			if err := backend.Clear(ctx, pollID); err != nil {
+				t.Fatalf("Clear returned unexpected error: %v", err)
+			}
+
+			if _, ok := timeOf(t); ok {
+				t.Errorf("Times returned the time of a cleared poll")
+			}
+		})
+	})
+
+	pollID++
+	t.Run("VotesSince", func(t *testing.T) {
+		t.Run("poll unknown", func(t *testing.T) {
+			_, _, err := backend.VotesSince(ctx, 404, 0)
+
+			var errDoesNotExist interface{ DoesNotExist() }
+			if !errors.As(err, &errDoesNotExist) {
+				t.Fatalf("VotesSince on a unknown poll has to return an error with a method DoesNotExist(), got: %v", err)
+			}
+		})
+
+		t.Run("empty poll", func(t *testing.T) {
+			backend.Start(ctx, pollID)
+
+			objects, _, err := backend.VotesSince(ctx, pollID, 0)
+			if err != nil {
+				t.Fatalf("VotesSince returned unexpected error: %v", err)
+			}
+
+			if len(objects) != 0 {
+				t.Errorf("VotesSince returned %q, expected an empty list", objects)
+			}
+		})
+
+		t.Run("only votes after cursor", func(t *testing.T) {
+			backend.Start(ctx, pollID)
+			backend.Vote(ctx, pollID, 5, []byte("first vote"))
+			backend.Vote(ctx, pollID, 6, []byte("second vote"))
+
+			objects, cursor, err := backend.VotesSince(ctx, pollID, 0)
+			if err != nil {
+				t.Fatalf("VotesSince returned unexpected error: %v", err)
+			}
+
+			if len(objects) != 2 || string(objects[0]) != "first vote" || string(objects[1]) != "second vote" {
+				t.Fatalf("VotesSince(0) returned %q, expected [first vote second vote]", objects)
+			}
+
+			backend.Vote(ctx, pollID, 7, []byte("third vote"))
+
+			objects, newCursor, err := backend.VotesSince(ctx, pollID, cursor)
+			if err != nil {
+				t.Fatalf("VotesSince returned unexpected error: %v", err)
+			}
+
+			if len(objects) != 1 || string(objects[0]) != "third vote" {
+				t.Errorf("VotesSince(%d) returned %q, expected [third vote]", cursor, objects)
+			}
+
+			if newCursor <= cursor {
+				t.Errorf("Sequence number did not increase. Got %d, before %d", newCursor, cursor)
+			}
+
+			objects, lastCursor, err := backend.VotesSince(ctx, pollID, newCursor)
+			if err != nil {
+				t.Fatalf("VotesSince returned unexpected error: %v", err)
+			}
+
+			if len(objects) != 0 {
+				t.Errorf("VotesSince(%d) returned %q, expected an empty list", newCursor, objects)
+			}
+
+			if lastCursor != newCursor {
+				t.Errorf("VotesSince(%d) returned sequence number %d, expected %d", newCursor, lastCursor, newCursor)
+			}
+		})
+	})
+
+	pollID++
+	t.Run("VotedObject", func(t *testing.T) {
+		t.Run("poll unknown", func(t *testing.T) {
+			_, _, err := backend.VotedObject(ctx, 404, 5)
+
+			var errDoesNotExist interface{ DoesNotExist() }
+			if !errors.As(err, &errDoesNotExist) {
+				t.Fatalf("VotedObject on a unknown poll has to return an error with a method DoesNotExist(), got: %v", err)
+			}
+		})
+
+		// The vote object contains the vote_user_id, so backends that do not
+		// save the relation between users and vote objects can find it.
+		object := []byte(`{"vote_user_id":5,"value":"Y"}`)
+		backend.Start(ctx, pollID)
+		backend.Vote(ctx, pollID, 5, object)
+
+		t.Run("user has voted", func(t *testing.T) {
+			got, found, err := backend.VotedObject(ctx, pollID, 5)
+			if err != nil {
+				t.Fatalf("VotedObject returned unexpected error: %v", err)
+			}
+
+			if !found {
+				t.Fatalf("VotedObject did not find the vote object")
+			}
+
+			if string(got) != string(object) {
+				t.Errorf("VotedObject returned `%s`, expected `%s`", got, object)
+			}
+		})
+
+		t.Run("user has not voted", func(t *testing.T) {
+			_, found, err := backend.VotedObject(ctx, pollID, 6)
+			if err != nil {
+				t.Fatalf("VotedObject returned unexpected error: %v", err)
+			}
+
+			if found {
+				t.Errorf("VotedObject found a vote object for a user that has not voted")
+			}
+		})
+	})
+
+	pollID++
+	t.Run("RetractVote", func(t *testing.T) {
+		t.Run("poll unknown", func(t *testing.T) {
+			err := backend.RetractVote(ctx, 404, 5)
+
+			var errDoesNotExist interface{ DoesNotExist() }
+			if !errors.As(err, &errDoesNotExist) {
+				t.Fatalf("RetractVote on a unknown poll has to return an error with a method DoesNotExist(), got: %v", err)
+			}
+		})
+
+		// The vote objects contain the vote_user_id, so backends that do not
+		// save the relation between users and vote objects can find them.
+		backend.Start(ctx, pollID)
+		backend.Vote(ctx, pollID, 5, []byte(`{"vote_user_id":5,"value":"Y"}`))
+		backend.Vote(ctx, pollID, 6, []byte(`{"vote_user_id":6,"value":"N"}`))
+
+		t.Run("user has not voted", func(t *testing.T) {
+			if err := backend.RetractVote(ctx, pollID, 7); err != nil {
+				t.Fatalf("RetractVote for a user that has not voted returned: %v", err)
+			}
+		})
+
+		t.Run("vote again after retract", func(t *testing.T) {
+			if err := backend.RetractVote(ctx, pollID, 5); err != nil {
+				t.Fatalf("RetractVote returned unexpected error: %v", err)
+			}
+
+			voted, err := backend.Voted(ctx)
+			if err != nil {
+				t.Fatalf("Voted returned unexpected error: %v", err)
+			}
+
+			if got := voted[pollID]; !reflect.DeepEqual(got, []int{6}) {
+				t.Errorf("Voted returned %v for the poll, expected [6]", got)
+			}
+
+			if err := backend.Vote(ctx, pollID, 5, []byte(`{"vote_user_id":5,"value":"A"}`)); err != nil {
+				t.Fatalf("Vote after retract returned: %v", err)
+			}
+
+			data, userIDs, err := backend.Stop(ctx, pollID)
+			if err != nil {
+				t.Fatalf("Stop returned unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(userIDs, []int{5, 6}) {
+				t.Errorf("Stop returned user ids %v, expected [5 6]", userIDs)
+			}
+
+			got := make([]string, len(data))
+			for i, object := range data {
+				got[i] = string(object)
+			}
+			sort.Strings(got)
+
+			expect := []string{`{"vote_user_id":5,"value":"A"}`, `{"vote_user_id":6,"value":"N"}`}
+			if !reflect.DeepEqual(got, expect) {
+				t.Errorf("Stop returned objects %v, expected %v", got, expect)
+			}
+		})
+
+		t.Run("stopped poll", func(t *testing.T) {
+			err := backend.RetractVote(ctx, pollID, 5)
+
+			var errStopped interface{ Stopped() }
+			if !errors.As(err, &errStopped) {
+				t.Errorf("RetractVote on a stopped poll has to return an error with a method Stopped(), got: %v", err)
+			}
+		})
+	})
+
+	pollID++
+	t.Run("RetractVote without vote_user_id", func(t *testing.T) {
+		// Vote objects of pseudoanonymous polls do not contain the user id. A
+		// backend, that can not find the object, has to return an error with
+		// the method NotRetractable() and keep the vote.
+		backend.Start(ctx, pollID)
+		backend.Vote(ctx, pollID, 5, []byte(`{"value":"Y"}`))
+
+		expectVotes := 0
+		if err := backend.RetractVote(ctx, pollID, 5); err != nil {
+			var errNotRetractable interface{ NotRetractable() }
+			if !errors.As(err, &errNotRetractable) {
+				t.Fatalf("RetractVote returned an error without a method NotRetractable(): %v", err)
+			}
+			expectVotes = 1
+		}
+
+		data, userIDs, err := backend.Stop(ctx, pollID)
+		if err != nil {
+			t.Fatalf("Stop returned unexpected error: %v", err)
+		}
+
+		if len(data) != expectVotes || len(userIDs) != expectVotes {
+			t.Errorf("Stop returned %d objects and %d users, expected %d", len(data), len(userIDs), expectVotes)
+		}
+	})
+
+	pollID++
+	t.Run("Export and Import", func(t *testing.T) {
+		startedPoll := pollID
+		pollID++
+		stoppedPoll := pollID
+
+		if err := backend.ClearAll(ctx); err != nil {
+			t.Fatalf("ClearAll: %v", err)
+		}
+
+		ds := dsmock.NewFlow(dsmock.YAMLData(fmt.Sprintf(`
+		poll/%d/state: started
+		poll/%d/state: finished
+		`, startedPoll, stoppedPoll)))
+
+		objects := map[int][][]byte{
+			startedPoll: {[]byte(`{"vote_user_id":1,"value":"Y"}`), []byte(`{"vote_user_id":2,"value":"N"}`)},
+			stoppedPoll: {[]byte(`{"vote_user_id":1,"value":"A"}`)},
+		}
+
+		for _, pid := range []int{startedPoll, stoppedPoll} {
+			backend.Start(ctx, pid)
+			for i, object := range objects[pid] {
+				if err := backend.Vote(ctx, pid, i+1, object); err != nil {
+					t.Fatalf("Vote: %v", err)
+				}
+			}
+		}
+		backend.Stop(ctx, stoppedPoll)
+
+		v, _, err := vote.New(ctx, backend, backend, ds, true)
+		if err != nil {
+			t.Fatalf("vote.New: %v", err)
+		}
+
+		var buf bytes.Buffer
+		if err := v.Export(ctx, &buf); err != nil {
+			t.Fatalf("Export: %v", err)
+		}
+
+		if err := backend.ClearAll(ctx); err != nil {
+			t.Fatalf("ClearAll: %v", err)
+		}
+
+		if err := v.Import(ctx, &buf); err != nil {
+			t.Fatalf("Import: %v", err)
+		}
+
+		if err := backend.Vote(ctx, startedPoll, 3, []byte(`{"vote_user_id":3,"value":"Y"}`)); err != nil {
+			t.Errorf("Vote on imported started poll: %v", err)
+		}
+		objects[startedPoll] = append(objects[startedPoll], []byte(`{"vote_user_id":3,"value":"Y"}`))
+
+		var errStopped interface{ Stopped() }
+		if err := backend.Vote(ctx, stoppedPoll, 3, []byte(`{"vote_user_id":3,"value":"Y"}`)); !errors.As(err, &errStopped) {
+			t.Errorf("Vote on imported stopped poll returned %v, expected a stopped error", err)
+		}
+
+		for _, pid := range []int{startedPoll, stoppedPoll} {
+			gotObjects, gotUserIDs, err := backend.Stop(ctx, pid)
+			if err != nil {
+				t.Fatalf("Stop poll %d: %v", pid, err)
+			}
+
+			expectUserIDs := make([]int, len(objects[pid]))
+			for i := range expectUserIDs {
+				expectUserIDs[i] = i + 1
+			}
+			sort.Ints(gotUserIDs)
+			if !reflect.DeepEqual(gotUserIDs, expectUserIDs) {
+				t.Errorf("Poll %d has voted users %v, expected %v", pid, gotUserIDs, expectUserIDs)
+			}
+
+			sort.Slice(gotObjects, func(i, j int) bool { return string(gotObjects[i]) < string(gotObjects[j]) })
+			if !reflect.DeepEqual(gotObjects, objects[pid]) {
+				t.Errorf("Poll %d has objects %q, expected %q", pid, gotObjects, objects[pid])
+			}
+		}
+	})
+
 	pollID++
 	t.Run("Concurrency", func(t *testing.T) {
 		t.Run("Many Votes", func(t *testing.T) {
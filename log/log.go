@@ -1,21 +1,31 @@
 package log
 
 import (
-	"log"
+	"encoding/json"
+	"fmt"
+	"io"
 	"sync"
+	"time"
 )
 
 var (
 	loggerMu    sync.RWMutex
-	debugLogger *log.Logger
-	infoLogger  *log.Logger
+	debugLogger Logger
+	infoLogger  Logger
 )
 
+// Logger is the interface for the debug and info logger.
+//
+// A *log.Logger from the standard library implements this interface.
+type Logger interface {
+	Printf(format string, a ...interface{})
+}
+
 // SetDebugLogger sets the debug logger. The default is no log at all.
 //
 // This function should only be started at the beginnen of the program before
 // the Debug was called for the frist time.
-func SetDebugLogger(l *log.Logger) {
+func SetDebugLogger(l Logger) {
 	loggerMu.Lock()
 	defer loggerMu.Unlock()
 	debugLogger = l
@@ -25,7 +35,7 @@ func SetDebugLogger(l *log.Logger) {
 //
 // This function should only be started at the beginnen of the program before
 // the Debug was called for the frist time.
-func SetInfoLogger(l *log.Logger) {
+func SetInfoLogger(l Logger) {
 	loggerMu.Lock()
 	defer loggerMu.Unlock()
 	infoLogger = l
@@ -58,5 +68,43 @@ func Debug(format string, a ...interface{}) {
 
 // IsDebug returns if debug output is enabled.
 func IsDebug() bool {
+	loggerMu.RLock()
+	defer loggerMu.RUnlock()
+
 	return debugLogger != nil
 }
+
+// JSONLogger writes each message as one json object per line.
+type JSONLogger struct {
+	mu    sync.Mutex
+	w     io.Writer
+	level string
+}
+
+// NewJSONLogger initializes a JSONLogger that writes to w. The level is
+// written to each message, for example `info` or `debug`.
+func NewJSONLogger(w io.Writer, level string) *JSONLogger {
+	return &JSONLogger{
+		w:     w,
+		level: level,
+	}
+}
+
+// Printf formats the message and writes it as json object.
+func (l *JSONLogger) Printf(format string, a ...interface{}) {
+	msg := struct {
+		Level string `json:"level"`
+		TS    string `json:"ts"`
+		Msg   string `json:"msg"`
+	}{
+		l.level,
+		time.Now().UTC().Format(time.RFC3339Nano),
+		fmt.Sprintf(format, a...),
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Encode adds a newline after the object.
+	json.NewEncoder(l.w).Encode(msg)
+}
@@ -0,0 +1,55 @@
+package log_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/OpenSlides/openslides-vote-service/log"
+)
+
+func TestJSONLogger(t *testing.T) {
+	buf := new(bytes.Buffer)
+	log.SetInfoLogger(log.NewJSONLogger(buf, "info"))
+	log.SetDebugLogger(log.NewJSONLogger(buf, "debug"))
+	defer log.SetInfoLogger(nil)
+	defer log.SetDebugLogger(nil)
+
+	log.Info("hello %s", "info")
+	log.Debug("hello %d", 5)
+
+	var got []map[string]string
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var line map[string]string
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("Line `%s` is not valid json: %v", scanner.Text(), err)
+		}
+		got = append(got, line)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("Got %d lines, expected 2", len(got))
+	}
+
+	for i, expect := range []struct {
+		level string
+		msg   string
+	}{
+		{"info", "hello info"},
+		{"debug", "hello 5"},
+	} {
+		if got[i]["level"] != expect.level {
+			t.Errorf("Line %d has level %s, expected %s", i, got[i]["level"], expect.level)
+		}
+
+		if got[i]["msg"] != expect.msg {
+			t.Errorf("Line %d has msg %s, expected %s", i, got[i]["msg"], expect.msg)
+		}
+
+		if got[i]["ts"] == "" {
+			t.Errorf("Line %d has no ts", i)
+		}
+	}
+}
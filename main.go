@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	golog "log"
+	gohttp "net/http"
 	"os"
 	"strconv"
 
@@ -18,7 +19,13 @@ import (
 	"github.com/alecthomas/kong"
 )
 
-var envDebugLog = environment.NewVariable("VOTE_DEBUG_LOG", "false", "Show debug log.")
+var (
+	envDebugLog  = environment.NewVariable("VOTE_DEBUG_LOG", "false", "Show debug log.")
+	envLogFormat = environment.NewVariable("VOTE_LOG_FORMAT", "text", "Format of the log output. One of `text` or `json`.")
+
+	envAuth            = environment.NewVariable("AUTH", "ticket", "Authentication of the external requests. One of `ticket` to use the auth service of OpenSlides or `apikey` to use the keys from AUTH_API_KEYS_FILE.")
+	envAuthAPIKeysFile = environment.NewVariable("AUTH_API_KEYS_FILE", "/run/secrets/vote_api_keys", "File with the api keys, that are used with `AUTH=apikey`. Each line has the form `key:user_id`. The key is sent in the header `X-API-Key`. In development mode, the key `openslides` is used for the user 1.")
+)
 
 //go:generate  sh -c "go run main.go build-doc > environment.md"
 
@@ -63,8 +70,8 @@ func main() {
 func run(ctx context.Context) error {
 	lookup := new(environment.ForProduction)
 
-	if debug, _ := strconv.ParseBool(envDebugLog.Value(lookup)); debug {
-		log.SetDebugLogger(golog.Default())
+	if err := initLogger(lookup); err != nil {
+		return fmt.Errorf("init logger: %w", err)
 	}
 
 	service, err := initService(lookup)
@@ -78,6 +85,10 @@ func run(ctx context.Context) error {
 func buildDocu() error {
 	lookup := new(environment.ForDocu)
 
+	if err := initLogger(lookup); err != nil {
+		return fmt.Errorf("init logger: %w", err)
+	}
+
 	if _, err := initService(lookup); err != nil {
 		return fmt.Errorf("init services: %w", err)
 	}
@@ -91,13 +102,40 @@ func buildDocu() error {
 	return nil
 }
 
+// initLogger sets the info and debug logger.
+func initLogger(lookup environment.Environmenter) error {
+	debug, _ := strconv.ParseBool(envDebugLog.Value(lookup))
+
+	switch format := envLogFormat.Value(lookup); format {
+	case "text":
+		log.SetInfoLogger(golog.Default())
+		if debug {
+			log.SetDebugLogger(golog.Default())
+		}
+
+	case "json":
+		log.SetInfoLogger(log.NewJSONLogger(os.Stderr, "info"))
+		if debug {
+			log.SetDebugLogger(log.NewJSONLogger(os.Stderr, "debug"))
+		}
+
+	default:
+		return fmt.Errorf("invalid value for `%s`, expected text or json, got %s", envLogFormat.Key, format)
+	}
+
+	return nil
+}
+
 // initService initializes all packages needed for the vote service.
 //
 // Returns a the service as callable.
 func initService(lookup environment.Environmenter) (func(context.Context) error, error) {
 	var backgroundTasks []func(context.Context, func(error))
 
-	httpServer := http.New(lookup)
+	httpServer, err := http.New(lookup)
+	if err != nil {
+		return nil, fmt.Errorf("init http server: %w", err)
+	}
 
 	// Redis as message bus for datastore and logout events.
 	messageBus := messageBusRedis.New(lookup)
@@ -109,12 +147,17 @@ func initService(lookup environment.Environmenter) (func(context.Context) error,
 	}
 
 	// Auth Service.
-	authService, authBackground, err := auth.New(lookup, messageBus)
+	authService, authBackground, err := initAuth(lookup, messageBus)
 	if err != nil {
 		return nil, fmt.Errorf("init auth system: %w", err)
 	}
 	backgroundTasks = append(backgroundTasks, authBackground)
 
+	voteOptions, err := vote.Options(lookup)
+	if err != nil {
+		return nil, fmt.Errorf("init vote options: %w", err)
+	}
+
 	fastBackendStarter, longBackendStarter, singleInstance, err := backend.Build(lookup)
 	if err != nil {
 		return nil, fmt.Errorf("init vote backend: %w", err)
@@ -131,7 +174,7 @@ func initService(lookup environment.Environmenter) (func(context.Context) error,
 			return fmt.Errorf("start long backend: %w", err)
 		}
 
-		voteService, voteBackground, err := vote.New(ctx, fastBackend, longBackend, database, singleInstance)
+		voteService, voteBackground, err := vote.New(ctx, fastBackend, longBackend, database, singleInstance, voteOptions...)
 		if err != nil {
 			return fmt.Errorf("starting service: %w", err)
 		}
@@ -147,6 +190,39 @@ func initService(lookup environment.Environmenter) (func(context.Context) error,
 	return service, nil
 }
 
+type authenticater interface {
+	Authenticate(gohttp.ResponseWriter, *gohttp.Request) (context.Context, error)
+	FromContext(context.Context) int
+}
+
+// initAuth returns the authenticater, that is configured in the environment.
+func initAuth(lookup environment.Environmenter, messageBus auth.LogoutEventer) (authenticater, func(context.Context, func(error)), error) {
+	method := envAuth.Value(lookup)
+	keysFile := envAuthAPIKeysFile.Value(lookup)
+
+	switch method {
+	case "ticket":
+		return auth.New(lookup, messageBus)
+
+	case "apikey":
+		// In development mode, the key `openslides` is used for the user 1.
+		keyList, err := environment.ReadSecretWithDefault(lookup, envAuthAPIKeysFile, "openslides:1")
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading api keys: %w", err)
+		}
+
+		apiKeyAuth, err := http.NewAPIKeyAuth(keyList)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parsing api keys from %s: %w", keysFile, err)
+		}
+
+		return apiKeyAuth, func(context.Context, func(error)) {}, nil
+
+	default:
+		return nil, nil, fmt.Errorf("invalid value for `%s`, expected ticket or apikey, got %s", envAuth.Key, method)
+	}
+}
+
 // contextDone returns an empty error if the context is done or exceeded
 func contextDone(err error) error {
 	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
@@ -0,0 +1,90 @@
+package vote
+
+import (
+	"encoding/json"
+)
+
+// Aggregation is the weighted sum of the votes of a poll.
+type Aggregation struct {
+	// Options is the sum of the weights for each option and answer. On polls
+	// with the method Y or N, the answer is the method and a vote with an
+	// amount counts amount times.
+	Options map[int]map[string]string `json:"options"`
+
+	// Global is the sum of the weights for each global answer like "Y".
+	Global map[string]string `json:"global"`
+
+	// Invalid is the number of votes, that could not be decoded.
+	Invalid int `json:"invalid"`
+}
+
+// aggregationMethods are the poll methods, that can be aggregated.
+var aggregationMethods = map[string]bool{"Y": true, "N": true, "YN": true, "YNA": true}
+
+// aggregate sums the weights of the votes for each option and global answer.
+func aggregate(method string, votes [][]byte) Aggregation {
+	options := make(map[int]map[string]int64)
+	global := make(map[string]int64)
+	var invalid int
+
+	addOption := func(optionID int, answer string, weight int64) {
+		if options[optionID] == nil {
+			options[optionID] = make(map[string]int64)
+		}
+		options[optionID][answer] += weight
+	}
+
+	for _, object := range votes {
+		var saved struct {
+			Value  storedValue `json:"value"`
+			Weight string      `json:"weight"`
+		}
+		if err := json.Unmarshal(object, &saved); err != nil {
+			invalid++
+			continue
+		}
+
+		weight, err := parseWeight(saved.Weight)
+		if err != nil {
+			invalid++
+			continue
+		}
+
+		switch saved.Value.Type() {
+		case ballotValueString:
+			global[saved.Value.str] += weight
+
+		case ballotValueOptionAmount:
+			for optionID, amount := range saved.Value.optionAmount {
+				addOption(optionID, method, int64(amount)*weight)
+			}
+
+		case ballotValueOptionString:
+			for optionID, answer := range saved.Value.optionYNA {
+				addOption(optionID, answer, weight)
+			}
+
+		default:
+			invalid++
+		}
+	}
+
+	result := Aggregation{
+		Options: make(map[int]map[string]string, len(options)),
+		Global:  make(map[string]string, len(global)),
+		Invalid: invalid,
+	}
+
+	for optionID, answers := range options {
+		result.Options[optionID] = make(map[string]string, len(answers))
+		for answer, weight := range answers {
+			result.Options[optionID][answer] = formatWeight(weight)
+		}
+	}
+
+	for answer, weight := range global {
+		result.Global[answer] = formatWeight(weight)
+	}
+
+	return result
+}
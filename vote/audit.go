@@ -0,0 +1,96 @@
+package vote
+
+import (
+	"sync"
+	"time"
+)
+
+// Audit events.
+const (
+	AuditStart    = "start"
+	AuditStop     = "stop"
+	AuditReopen   = "reopen"
+	AuditClear    = "clear"
+	AuditClearAll = "clear_all"
+)
+
+// AuditEvent is a state transition of a poll.
+type AuditEvent struct {
+	PollID int       `json:"poll_id"`
+	Event  string    `json:"event"`
+	Time   time.Time `json:"time"`
+}
+
+// maxAuditEvents is the number of events, that are kept in the audit log. If
+// there are more events, the oldest are removed.
+const maxAuditEvents = 10_000
+
+// auditLog remembers the last maxAuditEvents state transitions of all polls in
+// the order they happened.
+//
+// The log is only known to this instance.
+type auditLog struct {
+	mu     sync.Mutex
+	events []AuditEvent
+}
+
+func (a *auditLog) record(pollID int, event string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.add(pollID, event)
+}
+
+// add appends an event. a.mu has to be locked.
+func (a *auditLog) add(pollID int, event string) {
+	a.events = append(a.events, AuditEvent{
+		PollID: pollID,
+		Event:  event,
+		Time:   time.Now(),
+	})
+
+	if len(a.events) > maxAuditEvents {
+		a.events = a.events[len(a.events)-maxAuditEvents:]
+	}
+}
+
+// recordAll records the event for each poll, that is in the log.
+func (a *auditLog) recordAll(event string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var pollIDs []int
+	seen := make(map[int]struct{})
+	for _, e := range a.events {
+		if _, ok := seen[e.PollID]; !ok {
+			seen[e.PollID] = struct{}{}
+			pollIDs = append(pollIDs, e.PollID)
+		}
+	}
+
+	for _, pollID := range pollIDs {
+		a.add(pollID, event)
+	}
+}
+
+func (a *auditLog) get(pollID int) []AuditEvent {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	events := []AuditEvent{}
+	for _, e := range a.events {
+		if e.PollID == pollID {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// Audit returns the state transitions of a poll in the order they happened.
+//
+// Start, Stop, Clear and ClearAll are recorded each time they succeed, even if
+// they did not change the state of the poll. The events are not removed, when
+// the poll is cleared. Only calls to this instance are known.
+func (v *Vote) Audit(pollID int) []AuditEvent {
+	return v.audit.get(pollID)
+}
@@ -0,0 +1,163 @@
+package vote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/OpenSlides/openslides-autoupdate-service/pkg/datastore/dsfetch"
+	"github.com/OpenSlides/openslides-vote-service/log"
+)
+
+// afterFunc calls f after the duration d. The returned function cancels the
+// call. It is time.AfterFunc, but can be replaced in tests.
+type afterFunc func(d time.Duration, f func()) (cancel func() bool)
+
+func realAfterFunc(d time.Duration, f func()) func() bool {
+	return time.AfterFunc(d, f).Stop
+}
+
+// timeDeadline is the name of the time in the backend, when a poll is stopped
+// automaticly.
+const timeDeadline = "deadline"
+
+// deadline is a planed automatic stop of a poll.
+type deadline struct {
+	at     time.Time
+	cancel func() bool
+}
+
+// StopAt stops a started poll automaticly at stopTime. A deadline, that was
+// set before, is replaced. The deadline is removed, when the poll is stopped or
+// cleared before. With the zero time, the deadline is only removed.
+//
+// The deadline is saved in the backend of the poll. It is restored from there
+// on startup, so it survives a restart.
+func (v *Vote) StopAt(ctx context.Context, pollID int, stopTime time.Time) error {
+	ds := dsfetch.New(v.flow)
+	poll, err := loadPoll(ctx, ds, pollID)
+	if err != nil {
+		return fmt.Errorf("loading poll: %w", err)
+	}
+
+	backend := v.backend(poll)
+	err = v.withBackendTimeout(ctx, "set deadline", func(ctx context.Context) error {
+		return backend.SetTime(ctx, pollID, timeDeadline, stopTime, true)
+	})
+	if err != nil {
+		var errNotExist interface{ DoesNotExist() }
+		if errors.As(err, &errNotExist) {
+			return MessageError(ErrNotExists, "Poll %d does not exist in the backend", pollID)
+		}
+
+		return fmt.Errorf("saving deadline: %w", err)
+	}
+
+	v.armStop(pollID, stopTime)
+	return nil
+}
+
+// armStop replaces the timer, that stops a poll at its deadline.
+func (v *Vote) armStop(pollID int, stopTime time.Time) {
+	v.deadlinesMu.Lock()
+	defer v.deadlinesMu.Unlock()
+
+	if d, ok := v.deadlines[pollID]; ok {
+		d.cancel()
+		delete(v.deadlines, pollID)
+	}
+
+	if stopTime.IsZero() {
+		return
+	}
+
+	log.Debug("Poll %d is stopped at %s", pollID, stopTime.Format(time.RFC3339))
+	v.deadlines[pollID] = deadline{
+		at: stopTime,
+		cancel: v.afterFunc(stopTime.Sub(v.now()), func() {
+			v.autoStop(pollID)
+		}),
+	}
+}
+
+// loadDeadlines restores the deadlines of the started polls from the backends.
+// A deadline, that was removed or changed in the backend, for example by
+// another instance, is also removed or changed here.
+func (v *Vote) loadDeadlines(ctx context.Context) error {
+	backends := []Backend{v.fastBackend}
+	if v.longBackend != v.fastBackend {
+		backends = append(backends, v.longBackend)
+	}
+	if v.memoryBackend != nil {
+		backends = append(backends, v.memoryBackend)
+	}
+
+	for _, backend := range backends {
+		states, err := backend.Polls(ctx)
+		if err != nil {
+			return fmt.Errorf("fetching polls: %w", err)
+		}
+
+		deadlines, err := backend.Times(ctx, timeDeadline)
+		if err != nil {
+			return fmt.Errorf("fetching deadlines: %w", err)
+		}
+
+		for pollID, stopped := range states {
+			at, ok := deadlines[pollID]
+			if stopped {
+				ok = false
+			}
+
+			v.deadlinesMu.Lock()
+			current, armed := v.deadlines[pollID]
+			v.deadlinesMu.Unlock()
+
+			switch {
+			case !ok && armed:
+				v.cancelStop(pollID)
+			case ok && (!armed || !current.at.Equal(at)):
+				v.armStop(pollID, at)
+			}
+		}
+	}
+
+	return nil
+}
+
+// cancelStop removes the deadline of a poll.
+func (v *Vote) cancelStop(pollID int) {
+	v.deadlinesMu.Lock()
+	defer v.deadlinesMu.Unlock()
+
+	if d, ok := v.deadlines[pollID]; ok {
+		d.cancel()
+		delete(v.deadlines, pollID)
+	}
+}
+
+// cancelAllStops removes the deadlines of all polls.
+func (v *Vote) cancelAllStops() {
+	v.deadlinesMu.Lock()
+	defer v.deadlinesMu.Unlock()
+
+	for _, d := range v.deadlines {
+		d.cancel()
+	}
+	v.deadlines = make(map[int]deadline)
+}
+
+// autoStop is called, when the deadline of a poll is reached.
+//
+// The deadline is removed by Stop. If the poll could not be stopped, the
+// deadline is also removed, so it is armed again by the next loadDeadlines.
+func (v *Vote) autoStop(pollID int) {
+	if _, err := v.Stop(context.Background(), pollID); err != nil {
+		log.Info("Error stopping poll %d at its deadline: %v", pollID, err)
+		v.cancelStop(pollID)
+		return
+	}
+
+	log.Info("Stopped poll %d at its deadline", pollID)
+}
@@ -1,6 +1,7 @@
 package vote
 
 import (
+	"errors"
 	"fmt"
 )
 
@@ -28,6 +29,43 @@ const (
 
 	// ErrStopped happens when a user tries to vote on a stopped poll.
 	ErrStopped
+
+	// ErrTemporary happens when a request could not be processed in time, for
+	// example because the datastore is slow. The client can try again later.
+	ErrTemporary
+
+	// ErrPollFull happens when a user tries to vote on a poll, that has
+	// reached the maximum number of voters.
+	ErrPollFull
+
+	// ErrMaintenance happens when a user tries to vote or a poll is started,
+	// while the service is in maintenance mode.
+	ErrMaintenance
+
+	// ErrNotStarted happens when a user tries to vote on a poll, that exists
+	// in the datastore, but was not started in the backend.
+	ErrNotStarted
+
+	// ErrWrongPollType happens when a poll is started, that can not be started
+	// electronically, because it is analog, finished or published.
+	ErrWrongPollType
+)
+
+// The types of the errors, that are returned to the client in the field
+// `error`. Clients can rely on these strings.
+const (
+	TypeInternal      = "internal"
+	TypeExists        = "exist"
+	TypeNotExists     = "not-exist"
+	TypeInvalid       = "invalid"
+	TypeDoubleVote    = "double-vote"
+	TypeNotAllowed    = "not-allowed"
+	TypeStopped       = "stopped"
+	TypeTemporary     = "temporary"
+	TypePollFull      = "poll-full"
+	TypeMaintenance   = "maintenance"
+	TypeNotStarted    = "not-started"
+	TypeWrongPollType = "wrong-poll-type"
 )
 
 // TypeError is an error that can happend in this API.
@@ -37,25 +75,40 @@ type TypeError int
 func (err TypeError) Type() string {
 	switch err {
 	case ErrExists:
-		return "exist"
+		return TypeExists
 
 	case ErrNotExists:
-		return "not-exist"
+		return TypeNotExists
 
 	case ErrInvalid:
-		return "invalid"
+		return TypeInvalid
 
 	case ErrDoubleVote:
-		return "double-vote"
+		return TypeDoubleVote
 
 	case ErrNotAllowed:
-		return "not-allowed"
+		return TypeNotAllowed
 
 	case ErrStopped:
-		return "stopped"
+		return TypeStopped
+
+	case ErrTemporary:
+		return TypeTemporary
+
+	case ErrPollFull:
+		return TypePollFull
+
+	case ErrMaintenance:
+		return TypeMaintenance
+
+	case ErrNotStarted:
+		return TypeNotStarted
+
+	case ErrWrongPollType:
+		return TypeWrongPollType
 
 	default:
-		return "internal"
+		return TypeInternal
 	}
 }
 
@@ -80,6 +133,21 @@ func (err TypeError) Error() string {
 	case ErrNotAllowed:
 		msg = "You are not allowed to vote"
 
+	case ErrTemporary:
+		msg = "The service is temporarily not available. Please try again later"
+
+	case ErrPollFull:
+		msg = "The maximum number of voters is reached"
+
+	case ErrMaintenance:
+		msg = "The service is in maintenance"
+
+	case ErrNotStarted:
+		msg = "The poll is not started"
+
+	case ErrWrongPollType:
+		msg = "The poll can not be started electronically"
+
 	default:
 		msg = "Ups, something went wrong!"
 
@@ -89,23 +157,44 @@ func (err TypeError) Error() string {
 
 type messageError struct {
 	TypeError
-	msg string
+	msg    string
+	reason string
 }
 
 // MessageError creates an typed error with a message.
 func MessageError(t TypeError, format string, a ...any) error {
 	return messageError{
-		t,
-		fmt.Sprintf(format, a...),
+		TypeError: t,
+		msg:       fmt.Sprintf(format, a...),
 	}
 }
 
 // WrapError wrapps an error with an type.
 func WrapError(t TypeError, err error) error {
 	return messageError{
-		t,
-		err.Error(),
+		TypeError: t,
+		msg:       err.Error(),
+	}
+}
+
+// notAllowedError creates an ErrNotAllowed error with a message and a short
+// reason like "not-present", that can be evaluated by the client.
+func notAllowedError(reason string, format string, a ...any) error {
+	return messageError{
+		TypeError: ErrNotAllowed,
+		msg:       fmt.Sprintf(format, a...),
+		reason:    reason,
+	}
+}
+
+// notAllowedReason returns the reason of an error created by notAllowedError.
+// It returns an empty string for other errors.
+func notAllowedReason(err error) string {
+	var errMessage messageError
+	if !errors.As(err, &errMessage) {
+		return ""
 	}
+	return errMessage.reason
 }
 
 func (err messageError) Type() string {
@@ -0,0 +1,202 @@
+package vote
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// exportedPoll is one line of the export format.
+//
+// Objects[i] is the vote object of UserIDs[i]. For backends, that can not
+// associate the objects with the users, the order of the objects is arbitrary.
+type exportedPoll struct {
+	PollID  int      `json:"poll_id"`
+	Backend string   `json:"backend"`
+	Stopped bool     `json:"stopped"`
+	UserIDs []int    `json:"user_ids"`
+	Objects [][]byte `json:"objects"`
+}
+
+// Export writes the data of all polls in the fast and the long backend to w.
+// Each poll is one json object on its own line. The output can be read with
+// Import, also into other backends.
+//
+// The polls are not changed. Only the methods of the Backend interface are
+// used, so every backend can be exported. The polls and their state are read
+// from the backends. If a poll can not be read, for example because it was
+// cleared while exporting, an error is returned.
+func (v *Vote) Export(ctx context.Context, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	for _, named := range v.namedBackends() {
+		polls, err := v.exportBackend(ctx, named.name, named.backend)
+		if err != nil {
+			return fmt.Errorf("exporting %s backend: %w", named.name, err)
+		}
+
+		for _, poll := range polls {
+			if err := encoder.Encode(poll); err != nil {
+				return fmt.Errorf("writing poll %d: %w", poll.PollID, err)
+			}
+		}
+	}
+	return nil
+}
+
+type namedBackend struct {
+	name    string
+	backend Backend
+}
+
+// namedBackends returns the fast and the long backend. If both are the same,
+// it is only returned once.
+func (v *Vote) namedBackends() []namedBackend {
+	backends := []namedBackend{{"fast", v.fastBackend}}
+	if v.longBackend != v.fastBackend {
+		backends = append(backends, namedBackend{"long", v.longBackend})
+	}
+	return backends
+}
+
+func (v *Vote) exportBackend(ctx context.Context, name string, backend Backend) ([]exportedPoll, error) {
+	states, err := backend.Polls(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fetching polls: %w", err)
+	}
+
+	voted, err := v.backendVoted(ctx, backend)
+	if err != nil {
+		return nil, fmt.Errorf("fetching voted users: %w", err)
+	}
+
+	pollIDs := make([]int, 0, len(states))
+	for pollID := range states {
+		pollIDs = append(pollIDs, pollID)
+	}
+	sort.Ints(pollIDs)
+
+	polls := make([]exportedPoll, 0, len(pollIDs))
+	for _, pollID := range pollIDs {
+		poll, err := exportPoll(ctx, backend, pollID, voted[pollID])
+		if err != nil {
+			return nil, fmt.Errorf("poll %d: %w", pollID, err)
+		}
+
+		poll.Backend = name
+		poll.Stopped = states[pollID]
+		polls = append(polls, poll)
+	}
+
+	return polls, nil
+}
+
+// exportPoll reads the vote objects of a poll.
+func exportPoll(ctx context.Context, backend Backend, pollID int, userIDs []int) (exportedPoll, error) {
+	poll := exportedPoll{
+		PollID:  pollID,
+		UserIDs: userIDs,
+		Objects: make([][]byte, 0, len(userIDs)),
+	}
+
+	if poll.UserIDs == nil {
+		poll.UserIDs = []int{}
+	}
+
+	for _, userID := range userIDs {
+		object, found, err := backend.VotedObject(ctx, pollID, userID)
+		if err != nil {
+			return exportedPoll{}, fmt.Errorf("fetching vote object of user %d: %w", userID, err)
+		}
+
+		if !found {
+			// The backend can not associate the objects with the users.
+			poll.Objects = nil
+			break
+		}
+		poll.Objects = append(poll.Objects, object)
+	}
+
+	if poll.Objects != nil && len(poll.Objects) == len(userIDs) && len(userIDs) > 0 {
+		return poll, nil
+	}
+
+	objects, _, err := backend.VotesSince(ctx, pollID, 0)
+	if err != nil {
+		return exportedPoll{}, fmt.Errorf("fetching vote objects: %w", err)
+	}
+
+	if len(objects) != len(userIDs) {
+		return exportedPoll{}, fmt.Errorf("backend returned %d vote objects for %d users", len(objects), len(userIDs))
+	}
+
+	poll.Objects = objects
+	if poll.Objects == nil {
+		poll.Objects = [][]byte{}
+	}
+	return poll, nil
+}
+
+// Import reads polls, that were written by Export, and saves them in the
+// backends. Each poll is saved in the backend with the same name (fast or
+// long), that it was exported from.
+//
+// The polls must not exist in the backends. Afterwards the voted users are
+// reloaded from the backends.
+func (v *Vote) Import(ctx context.Context, r io.Reader) error {
+	decoder := json.NewDecoder(r)
+	for {
+		var poll exportedPoll
+		if err := decoder.Decode(&poll); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return MessageError(ErrInvalid, "decoding export: %v", err)
+		}
+
+		if err := v.importPoll(ctx, poll); err != nil {
+			return fmt.Errorf("importing poll %d: %w", poll.PollID, err)
+		}
+	}
+
+	if err := v.loadVoted(ctx); err != nil {
+		return fmt.Errorf("loading voted: %w", err)
+	}
+	return nil
+}
+
+func (v *Vote) importPoll(ctx context.Context, poll exportedPoll) error {
+	var backend Backend
+	switch poll.Backend {
+	case "fast":
+		backend = v.fastBackend
+	case "long":
+		backend = v.longBackend
+	default:
+		return MessageError(ErrInvalid, "Unknown backend %s, expected fast or long", poll.Backend)
+	}
+
+	if len(poll.Objects) != len(poll.UserIDs) {
+		return MessageError(ErrInvalid, "Poll %d has %d vote objects for %d users", poll.PollID, len(poll.Objects), len(poll.UserIDs))
+	}
+
+	if err := backend.Start(ctx, poll.PollID); err != nil {
+		return fmt.Errorf("starting poll: %w", err)
+	}
+
+	for i, userID := range poll.UserIDs {
+		if err := backend.Vote(ctx, poll.PollID, userID, poll.Objects[i]); err != nil {
+			return fmt.Errorf("saving vote of user %d: %w", userID, err)
+		}
+	}
+
+	if poll.Stopped {
+		if _, _, err := backend.Stop(ctx, poll.PollID); err != nil {
+			return fmt.Errorf("stopping poll: %w", err)
+		}
+	}
+
+	return nil
+}
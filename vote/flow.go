@@ -5,8 +5,10 @@ import (
 
 	"github.com/OpenSlides/openslides-autoupdate-service/pkg/datastore"
 	"github.com/OpenSlides/openslides-autoupdate-service/pkg/datastore/cache"
+	"github.com/OpenSlides/openslides-autoupdate-service/pkg/datastore/dskey"
 	"github.com/OpenSlides/openslides-autoupdate-service/pkg/datastore/flow"
 	"github.com/OpenSlides/openslides-autoupdate-service/pkg/environment"
+	"github.com/OpenSlides/openslides-vote-service/log"
 )
 
 // Flow initializes a cached connection to postgres.
@@ -20,3 +22,76 @@ func Flow(lookup environment.Environmenter, messageBus flow.Updater) (flow.Flow,
 
 	return cache, nil
 }
+
+// cacheResetter is a flow with a cache, that can be removed at once.
+type cacheResetter interface {
+	Reset()
+}
+
+// cacheInvalidator is a flow with a cache, where single keys can be removed.
+type cacheInvalidator interface {
+	Invalidate(keys ...dskey.Key)
+}
+
+// rememberPreloaded saves the keys, that were loaded, when a poll was started.
+// They are invalidated by resetCache, if the flow can not be reset.
+func (v *Vote) rememberPreloaded(keys map[dskey.Key]struct{}) {
+	v.preloadedMu.Lock()
+	defer v.preloadedMu.Unlock()
+
+	if v.preloaded == nil {
+		v.preloaded = make(map[dskey.Key]struct{}, len(keys))
+	}
+
+	for key := range keys {
+		v.preloaded[key] = struct{}{}
+	}
+}
+
+// resetCache removes the datastore cache. It returns false, if the flow does
+// not support it.
+//
+// If the flow can not be reset, but supports to invalidate single keys, all
+// preloaded keys are invalidated. Other keys can still be outdated.
+func (v *Vote) resetCache() bool {
+	v.preloadedMu.Lock()
+	defer v.preloadedMu.Unlock()
+
+	if r, ok := v.flow.(cacheResetter); ok {
+		r.Reset()
+		v.preloaded = nil
+		return true
+	}
+
+	i, ok := v.flow.(cacheInvalidator)
+	if !ok {
+		log.Info("Warning: The datastore cache can not be reset. It could contain outdated data.")
+		return false
+	}
+
+	keys := make([]dskey.Key, 0, len(v.preloaded))
+	for key := range v.preloaded {
+		keys = append(keys, key)
+	}
+	i.Invalidate(keys...)
+	v.preloaded = nil
+
+	log.Info("Warning: The datastore cache can not be reset. Invalidated %d preloaded keys. Other keys could contain outdated data.", len(keys))
+	return false
+}
+
+// invalidateKeys removes the keys from the datastore cache. If the flow can not
+// invalidate single keys, the whole cache is reset. It returns false, if the
+// flow supports neither.
+func (v *Vote) invalidateKeys(keys map[dskey.Key]struct{}) bool {
+	if i, ok := v.flow.(cacheInvalidator); ok {
+		list := make([]dskey.Key, 0, len(keys))
+		for key := range keys {
+			list = append(list, key)
+		}
+		i.Invalidate(list...)
+		return true
+	}
+
+	return v.resetCache()
+}
@@ -0,0 +1,78 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/OpenSlides/openslides-vote-service/vote"
+)
+
+// APIKeyHeader is the header, that contains the api key.
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyAuth is an authenticater, that uses api keys instead of the auth
+// ticket of OpenSlides. It is meant for internal tooling, that has to act as
+// a user.
+//
+// Requests without the api key header are anonymous.
+type APIKeyAuth struct {
+	keys map[string]int
+}
+
+// NewAPIKeyAuth creates an APIKeyAuth from a list of keys. Each line of the
+// list has the form `key:user_id`. Empty lines are ignored.
+func NewAPIKeyAuth(keyList string) (*APIKeyAuth, error) {
+	keys := make(map[string]int)
+	for i, line := range strings.Split(keyList, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		key, rawUserID, found := strings.Cut(line, ":")
+		if !found || key == "" {
+			return nil, fmt.Errorf("line %d: expected `key:user_id`", i+1)
+		}
+
+		userID, err := strconv.Atoi(rawUserID)
+		if err != nil || userID <= 0 {
+			return nil, fmt.Errorf("line %d: invalid user id %s", i+1, rawUserID)
+		}
+
+		if _, ok := keys[key]; ok {
+			return nil, fmt.Errorf("line %d: duplicate key", i+1)
+		}
+
+		keys[key] = userID
+	}
+
+	return &APIKeyAuth{keys: keys}, nil
+}
+
+type apiKeyUserKey struct{}
+
+// Authenticate reads the api key from the request header and returns a
+// context with the user id, that belongs to the key.
+func (a *APIKeyAuth) Authenticate(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		return r.Context(), nil
+	}
+
+	userID, ok := a.keys[key]
+	if !ok {
+		return nil, statusCode(401, vote.MessageError(vote.ErrNotAllowed, "Invalid api key"))
+	}
+
+	return context.WithValue(r.Context(), apiKeyUserKey{}, userID), nil
+}
+
+// FromContext returns the user id from a context returned by Authenticate. It
+// returns 0 for anonymous requests.
+func (a *APIKeyAuth) FromContext(ctx context.Context) int {
+	userID, _ := ctx.Value(apiKeyUserKey{}).(int)
+	return userID
+}
@@ -20,6 +20,16 @@ func handleExternal(handler Handler) http.Handler {
 	return resolveError(handler, false)
 }
 
+// externalHandler returns the wrapper for external routes. If expose is true,
+// the messages of internal errors are shown like on internal routes. This
+// should only be used in development.
+func externalHandler(expose bool) func(Handler) http.Handler {
+	if expose {
+		return handleInternal
+	}
+	return handleExternal
+}
+
 func resolveError(handler Handler, internalRoute bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		err := handler.ServeHTTP(w, r)
@@ -32,6 +42,10 @@ func resolveError(handler Handler, internalRoute bool) http.HandlerFunc {
 	}
 }
 
+// retryAfter is the value in seconds for the Retry-After header on temporary
+// errors.
+const retryAfter = "5"
+
 func writeStatusCode(w http.ResponseWriter, err error) {
 	statusCode := 400
 	var errStatusCode statusCodeError
@@ -42,16 +56,35 @@ func writeStatusCode(w http.ResponseWriter, err error) {
 	var errTyped interface {
 		Type() string
 	}
-	if !errors.As(err, &errTyped) || errTyped.Type() == "internal" {
+	if !errors.As(err, &errTyped) || errTyped.Type() == vote.TypeInternal {
 		statusCode = 500
 	}
 
+	if errors.Is(err, vote.ErrTemporary) {
+		statusCode = 503
+		w.Header().Set("Retry-After", retryAfter)
+	}
+
+	if errors.Is(err, vote.ErrMaintenance) {
+		statusCode = 503
+	}
+
+	if errors.Is(err, vote.ErrWrongPollType) {
+		statusCode = 409
+	}
+
 	log.Debug("HTTP: Returning status %d", statusCode)
 	w.WriteHeader(statusCode)
 }
 
+// errorResponse is the body of a failed request.
+type errorResponse struct {
+	Error string `json:"error"`
+	MSG   string `json:"message"`
+}
+
 func writeFormattedError(w io.Writer, err error, internalRoute bool) {
-	errType := "internal"
+	errType := vote.TypeInternal
 	var errTyped interface {
 		error
 		Type() string
@@ -61,19 +94,16 @@ func writeFormattedError(w io.Writer, err error, internalRoute bool) {
 	}
 
 	msg := err.Error()
-	if errType == "internal" {
+	if errType == vote.TypeInternal {
 		log.Info("Error: %s", msg)
 		if !internalRoute {
 			msg = vote.ErrInternal.Error()
 		}
 	}
 
-	out := struct {
-		Error string `json:"error"`
-		MSG   string `json:"message"`
-	}{
-		errType,
-		msg,
+	out := errorResponse{
+		Error: errType,
+		MSG:   msg,
 	}
 
 	if err := json.NewEncoder(w).Encode(out); err != nil {
@@ -0,0 +1,174 @@
+package http
+
+import (
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/OpenSlides/openslides-vote-service/log"
+)
+
+// compressMinSize is the minimum size in bytes of a response, that is
+// compressed. Smaller responses get bigger with gzip.
+const compressMinSize = 1024
+
+// withGzip compresses the response with gzip, if the client accepts it and the
+// response has at least minSize bytes.
+//
+// The response is buffered until minSize bytes are written or until it is
+// flushed. A flush before minSize bytes are written sends the response
+// uncompressed. For streams, minSize should be 0, so they are always
+// compressed and each flush reaches the client.
+//
+// An error of the handler, that is returned after the header was sent, is only
+// logged.
+func withGzip(minSize int, next HandlerFunc) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		if !acceptsGzip(r) {
+			return next(w, r)
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w, minSize: minSize}
+		if err := next(gw, r); err != nil {
+			if !gw.decided {
+				// Nothing was sent. The error is written to the original
+				// writer.
+				return err
+			}
+
+			// The header and the start of the compressed body are already
+			// sent. An error message after them would corrupt the body.
+			log.Info("Error after the response was sent: %v", err)
+			if err := gw.Close(); err != nil {
+				log.Info("Error closing gzip writer: %v", err)
+			}
+			return nil
+		}
+
+		if err := gw.Close(); err != nil {
+			return fmt.Errorf("closing gzip writer: %w", err)
+		}
+		return nil
+	}
+}
+
+// acceptsGzip returns true, if the request has the header Accept-Encoding with
+// gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(encoding, ";")
+		if strings.TrimSpace(name) != "gzip" {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			q, _ = strconv.ParseFloat(value, 64)
+		}
+		return q > 0
+	}
+	return false
+}
+
+// gzipResponseWriter decides with the first minSize bytes, if the response is
+// compressed.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	minSize int
+
+	status  int
+	buf     []byte
+	decided bool
+	gz      *gzip.Writer
+}
+
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *gzipResponseWriter) Write(p []byte) (int, error) {
+	if w.decided {
+		if w.gz != nil {
+			return w.gz.Write(p)
+		}
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.minSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// decide sends the header and the buffered data.
+func (w *gzipResponseWriter) decide(compress bool) error {
+	w.decided = true
+
+	// Responses without a body are never compressed.
+	if w.status == http.StatusNotModified || w.status == http.StatusNoContent {
+		compress = false
+	}
+
+	if compress {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Del("Content-Length")
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+
+	if w.gz != nil {
+		_, err := w.gz.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// Flush sends the buffered data through the gzip writer to the client.
+func (w *gzipResponseWriter) Flush() {
+	if !w.decided {
+		if err := w.decide(len(w.buf) >= w.minSize); err != nil {
+			return
+		}
+	}
+
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Close sends the rest of the response.
+func (w *gzipResponseWriter) Close() error {
+	if !w.decided {
+		if err := w.decide(len(w.buf) >= w.minSize); err != nil {
+			return err
+		}
+	}
+
+	if w.gz != nil {
+		return w.gz.Close()
+	}
+	return nil
+}
@@ -1,13 +1,18 @@
 package http
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -17,19 +22,100 @@ import (
 	"github.com/OpenSlides/openslides-vote-service/vote"
 )
 
-var envVotePort = environment.NewVariable("VOTE_PORT", "9013", "Port on which the service listen on.")
+var (
+	envVotePort          = environment.NewVariable("VOTE_PORT", "9013", "Port on which the service listen on.")
+	envVoteMaxClockSkew  = environment.NewVariable("VOTE_MAX_CLOCK_SKEW", "0", "Maximum difference between the client time and the server time of a vote request. The client has to send its time in the header `X-Vote-Timestamp`. 0 disables the check.")
+	envVoteRequireJSON   = environment.NewVariable("VOTE_REQUIRE_JSON_CONTENT_TYPE", "false", "Reject vote requests without the header `Content-Type: application/json`.")
+	envVoteExposeErrors  = environment.NewVariable("VOTE_EXPOSE_INTERNAL_ERRORS", "false", "Show the message of internal errors also on external routes. Only use this in development.")
+	envVoteAcceptPut     = environment.NewVariable("VOTE_ACCEPT_PUT", "true", "Accept vote requests with the method PUT. Vote requests with POST are always accepted.")
+	envVoteShutdownGrace = environment.NewVariable("VOTE_SHUTDOWN_GRACE", "10s", "Time to wait on shutdown for votes, that are currently processed. New votes are rejected in this time.")
+	envVoteHeartbeat     = environment.NewVariable("VOTE_STREAM_HEARTBEAT", "25s", "Time after which the vote count stream sends an empty object `{}`, if there was no other data. It keeps the connection open behind proxies. 0 disables the heartbeat.")
+	envVoteSimulate      = environment.NewVariable("VOTE_ENABLE_SIMULATE", "false", "Enable the handler `/internal/vote/simulate` for load tests. It validates votes without saving them.")
+	envVoteCORSOrigins   = environment.NewVariable("VOTE_CORS_ORIGINS", "", "Comma separated list of origins, that are allowed to send requests to the external routes from a browser. Empty sends no CORS headers.")
+	envVoteMaxBodyBytes  = environment.NewVariable("VOTE_MAX_BODY_BYTES", "65536", "Maximum size of the body of a vote request in bytes. Has to be positive.")
+)
 
 // Server can start the service on a port.
 type Server struct {
 	Addr string
 	lst  net.Listener
+
+	maxClockSkew         time.Duration
+	shutdownGrace        time.Duration
+	streamHeartbeat      time.Duration
+	requireJSON          bool
+	acceptPut            bool
+	enableSimulate       bool
+	exposeInternalErrors bool
+	maxBodyBytes         int64
+	corsOrigins          []string
 }
 
 // New initializes a new Server.
-func New(lookup environment.Environmenter) Server {
-	return Server{
-		Addr: ":" + envVotePort.Value(lookup),
+func New(lookup environment.Environmenter) (Server, error) {
+	maxClockSkew, err := environment.ParseDuration(envVoteMaxClockSkew.Value(lookup))
+	if err != nil {
+		return Server{}, fmt.Errorf("invalid value for `%s`, expected duration got %s: %w", envVoteMaxClockSkew.Key, envVoteMaxClockSkew.Value(lookup), err)
+	}
+
+	shutdownGrace, err := environment.ParseDuration(envVoteShutdownGrace.Value(lookup))
+	if err != nil {
+		return Server{}, fmt.Errorf("invalid value for `%s`, expected duration got %s: %w", envVoteShutdownGrace.Key, envVoteShutdownGrace.Value(lookup), err)
+	}
+
+	streamHeartbeat, err := environment.ParseDuration(envVoteHeartbeat.Value(lookup))
+	if err != nil {
+		return Server{}, fmt.Errorf("invalid value for `%s`, expected duration got %s: %w", envVoteHeartbeat.Key, envVoteHeartbeat.Value(lookup), err)
+	}
+
+	requireJSON, err := strconv.ParseBool(envVoteRequireJSON.Value(lookup))
+	if err != nil {
+		return Server{}, fmt.Errorf("invalid value for `%s`, expected bool got %s: %w", envVoteRequireJSON.Key, envVoteRequireJSON.Value(lookup), err)
+	}
+
+	acceptPut, err := strconv.ParseBool(envVoteAcceptPut.Value(lookup))
+	if err != nil {
+		return Server{}, fmt.Errorf("invalid value for `%s`, expected bool got %s: %w", envVoteAcceptPut.Key, envVoteAcceptPut.Value(lookup), err)
 	}
+
+	enableSimulate, err := strconv.ParseBool(envVoteSimulate.Value(lookup))
+	if err != nil {
+		return Server{}, fmt.Errorf("invalid value for `%s`, expected bool got %s: %w", envVoteSimulate.Key, envVoteSimulate.Value(lookup), err)
+	}
+
+	exposeInternalErrors, err := strconv.ParseBool(envVoteExposeErrors.Value(lookup))
+	if err != nil {
+		return Server{}, fmt.Errorf("invalid value for `%s`, expected bool got %s: %w", envVoteExposeErrors.Key, envVoteExposeErrors.Value(lookup), err)
+	}
+
+	maxBodyBytes, err := strconv.ParseInt(envVoteMaxBodyBytes.Value(lookup), 10, 64)
+	if err != nil {
+		return Server{}, fmt.Errorf("invalid value for `%s`, expected int got %s: %w", envVoteMaxBodyBytes.Key, envVoteMaxBodyBytes.Value(lookup), err)
+	}
+
+	if maxBodyBytes <= 0 {
+		return Server{}, fmt.Errorf("invalid value for `%s`, expected positive int got %s", envVoteMaxBodyBytes.Key, envVoteMaxBodyBytes.Value(lookup))
+	}
+
+	var corsOrigins []string
+	for _, origin := range strings.Split(envVoteCORSOrigins.Value(lookup), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			corsOrigins = append(corsOrigins, origin)
+		}
+	}
+
+	return Server{
+		Addr:                 ":" + envVotePort.Value(lookup),
+		maxClockSkew:         maxClockSkew,
+		shutdownGrace:        shutdownGrace,
+		streamHeartbeat:      streamHeartbeat,
+		requireJSON:          requireJSON,
+		acceptPut:            acceptPut,
+		enableSimulate:       enableSimulate,
+		exposeInternalErrors: exposeInternalErrors,
+		maxBodyBytes:         maxBodyBytes,
+		corsOrigins:          corsOrigins,
+	}, nil
 }
 
 // StartListener starts the listener where the server will listen on.
@@ -53,17 +139,31 @@ func (s *Server) Run(ctx context.Context, auth authenticater, service *vote.Vote
 		return ticker.C, ticker.Stop
 	}
 
-	mux := registerHandlers(service, auth, ticketProvider)
+	mux := s.registerHandlers(service, auth, ticketProvider)
+
+	// The requests get their own context, so votes, that are currently saved,
+	// are not canceled on shutdown.
+	requestCtx, cancelRequests := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelRequests()
 
 	srv := &http.Server{
 		Handler:     mux,
-		BaseContext: func(net.Listener) context.Context { return ctx },
+		BaseContext: func(net.Listener) context.Context { return requestCtx },
 	}
 
 	// Shutdown logic in separate goroutine.
 	wait := make(chan error)
 	go func() {
 		<-ctx.Done()
+
+		graceCtx, cancel := context.WithTimeout(context.Background(), s.shutdownGrace)
+		defer cancel()
+
+		if err := service.Drain(graceCtx); err != nil {
+			log.Info("Votes are still processed after the shutdown grace of %s", s.shutdownGrace)
+		}
+		cancelRequests()
+
 		if err := srv.Shutdown(context.Background()); err != nil {
 			wait <- fmt.Errorf("HTTP server shutdown: %w", err)
 			return
@@ -82,115 +182,1216 @@ func (s *Server) Run(ctx context.Context, auth authenticater, service *vote.Vote
 		return fmt.Errorf("HTTP Server failed: %v", err)
 	}
 
-	return <-wait
-}
+	return <-wait
+}
+
+type voteService interface {
+	starter
+	stopper
+	reopener
+	reloader
+	retracter
+	auditor
+	resultHasher
+	tallier
+	maintainer
+	clearer
+	clearAller
+	clearManyer
+	meetingClearer
+	startManyer
+	refresher
+	exporter
+	voteCounter
+	voter
+	simulator
+	haveIvoteder
+	eligibler
+	eligibilityChecker
+	turnoutByGrouper
+	entitledCounter
+	meetingTurnouter
+	backendLoader
+	votesForUserer
+	activeMeetingser
+	healthChecker
+}
+
+type authenticater interface {
+	Authenticate(http.ResponseWriter, *http.Request) (context.Context, error)
+	FromContext(context.Context) int
+}
+
+func (s *Server) registerHandlers(service voteService, auth authenticater, ticketProvider func() (<-chan time.Time, func())) *http.ServeMux {
+	const (
+		internal = "/internal/vote"
+		external = "/system/vote"
+	)
+
+	mux := http.NewServeMux()
+	resolveExternal := externalHandler(s.exposeInternalErrors)
+	handleExternal := func(handler HandlerFunc) http.Handler {
+		return resolveExternal(checkCORS(s.corsOrigins, handler))
+	}
+
+	mux.Handle(internal+"/start", handleInternal(handleStart(service)))
+	mux.Handle(internal+"/start_batch", handleInternal(handleStartBatch(service)))
+	mux.Handle(internal+"/stop", handleInternal(withGzip(compressMinSize, handleStop(service))))
+	mux.Handle(internal+"/reopen", handleInternal(handleReopen(service)))
+	mux.Handle(internal+"/reload", handleInternal(handleReload(service)))
+	mux.Handle(internal+"/retract", handleInternal(handleRetract(service)))
+	mux.Handle(internal+"/result_hash", handleInternal(handleResultHash(service)))
+	mux.Handle(internal+"/tally", handleInternal(handleTally(service)))
+	mux.Handle(internal+"/maintenance", handleInternal(handleMaintenance(service)))
+	mux.Handle(internal+"/clear", handleInternal(handleClear(service)))
+	mux.Handle(internal+"/clear_batch", handleInternal(handleClearBatch(service)))
+	mux.Handle(internal+"/clear_meeting", handleInternal(handleClearMeeting(service)))
+	mux.Handle(internal+"/clear_all", handleInternal(handleClearAll(service)))
+	mux.Handle(internal+"/refresh", handleInternal(handleRefresh(service)))
+	mux.Handle(internal+"/export", handleInternal(handleExport(service)))
+	mux.Handle(internal+"/import", handleInternal(handleImport(service)))
+	mux.Handle(internal+"/vote_count", handleInternal(withGzip(0, handleVoteCount(service, ticketProvider, s.streamHeartbeat))))
+	mux.Handle(internal+"/turnout_by_group", handleInternal(handleTurnoutByGroup(service)))
+	mux.Handle(internal+"/audit", handleInternal(handleAudit(service)))
+	mux.Handle(internal+"/entitled", handleInternal(handleEntitled(service)))
+	mux.Handle(internal+"/meeting_turnout", handleInternal(handleMeetingTurnout(service)))
+	mux.Handle(internal+"/backend_load", handleInternal(handleBackendLoad(service)))
+	mux.Handle(internal+"/votes_for_user", handleInternal(handleVotesForUser(service)))
+	mux.Handle(internal+"/active_meetings", handleInternal(handleActiveMeetings(service)))
+	if s.enableSimulate {
+		mux.Handle(internal+"/simulate", handleInternal(handleSimulate(service)))
+	}
+	mux.Handle(external+"", handleExternal(checkVoteMethod(s.acceptPut, checkContentType(s.requireJSON, checkClockSkew(s.maxClockSkew, limitBody(s.maxBodyBytes, handleVote(service, auth)))))))
+	mux.Handle(external+"/voted", handleExternal(handleVoted(service, auth)))
+	mux.Handle(external+"/eligible", handleExternal(handleEligible(service, auth)))
+	mux.Handle(external+"/eligibility", handleExternal(handleEligibility(service, auth)))
+	mux.Handle(external+"/health", handleExternal(handleHealth(service)))
+	mux.Handle(external+"/health/live", handleExternal(handleLiveness()))
+	mux.Handle(external+"/openapi.json", handleExternal(handleOpenAPI()))
+
+	return mux
+}
+
+type starter interface {
+	Start(ctx context.Context, pollID int) error
+	StartWithEntitled(ctx context.Context, pollID int, userIDs []int) error
+	ForceBackend(pollID int, name string) error
+	StopAt(ctx context.Context, pollID int, stopTime time.Time) error
+}
+
+// handleStart starts a poll. If the request has a json body with the field
+// entitled_user_ids, only this users can vote on the poll.
+//
+// With the argument stop_at, the poll is stopped automaticly at this unix
+// time.
+//
+// In development, the argument force_backend overwrites the backend of the
+// poll.
+func handleStart(start starter) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		log.Info("Receiving start request")
+		w.Header().Set("Content-Type", "application/json")
+
+		id, err := pollID(r)
+		if err != nil {
+			return vote.WrapError(vote.ErrInvalid, err)
+		}
+
+		var stopTime time.Time
+		if rawStopAt := r.URL.Query().Get("stop_at"); rawStopAt != "" {
+			stopAt, err := strconv.ParseInt(rawStopAt, 10, 64)
+			if err != nil || stopAt <= 0 {
+				return vote.MessageError(vote.ErrInvalid, "Argument stop_at has to be a unix timestamp, got %s", rawStopAt)
+			}
+			stopTime = time.Unix(stopAt, 0)
+		}
+
+		forced := false
+		if name := r.URL.Query().Get("force_backend"); name != "" {
+			if err := start.ForceBackend(id, name); err != nil {
+				return err
+			}
+			forced = true
+		}
+
+		var entitledUserIDs []int
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+			var body struct {
+				EntitledUserIDs []int `json:"entitled_user_ids"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+				return vote.MessageError(vote.ErrInvalid, "decoding body: %v", err)
+			}
+			entitledUserIDs = body.EntitledUserIDs
+		}
+
+		if len(entitledUserIDs) > 0 {
+			err = start.StartWithEntitled(r.Context(), id, entitledUserIDs)
+		} else {
+			err = start.Start(r.Context(), id)
+		}
+		if err != nil {
+			if forced {
+				// The poll was not started, so the backend is not used.
+				if err := start.ForceBackend(id, ""); err != nil {
+					log.Info("Removing forced backend of poll %d: %v", id, err)
+				}
+			}
+			return err
+		}
+
+		if !stopTime.IsZero() {
+			return start.StopAt(r.Context(), id, stopTime)
+		}
+		return nil
+	}
+}
+
+type startManyer interface {
+	StartMany(ctx context.Context, pollIDs []int) error
+}
+
+// handleStartBatch starts many polls. Polls, that could not be started, are
+// listed with the reason. The other polls are started anyway.
+func handleStartBatch(start startManyer) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		log.Info("Receiving start batch request")
+		w.Header().Set("Content-Type", "application/json")
+
+		pollIDs, err := pollsID(r)
+		if err != nil {
+			return vote.WrapError(vote.ErrInvalid, err)
+		}
+
+		var errFailed vote.StartManyError
+		if err := start.StartMany(r.Context(), pollIDs); err != nil && !errors.As(err, &errFailed) {
+			return err
+		}
+
+		started := make([]int, 0, len(pollIDs))
+		failed := make(map[int]string, len(errFailed))
+		for _, pollID := range pollIDs {
+			if err, ok := errFailed[pollID]; ok {
+				log.Info("Starting poll %d failed: %v", pollID, err)
+				failed[pollID] = err.Error()
+				continue
+			}
+			started = append(started, pollID)
+		}
+
+		out := struct {
+			Started []int          `json:"started"`
+			Failed  map[int]string `json:"failed"`
+		}{
+			started,
+			failed,
+		}
+
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			return fmt.Errorf("encoding and sending start result: %w", err)
+		}
+		return nil
+	}
+}
+
+// stopper stops a poll. It sets the state of the poll, so that no other user
+// can vote. It writes the vote results to the writer.
+type stopper interface {
+	Stop(ctx context.Context, pollID int) (vote.StopResult, error)
+	StopWithValidity(ctx context.Context, pollID int) (vote.StopResult, error)
+	StopWithAggregation(ctx context.Context, pollID int) (vote.StopResult, error)
+}
+
+func handleStop(stop stopper) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		log.Info("Receiving stop request")
+		w.Header().Set("Content-Type", "application/json")
+
+		id, err := pollID(r)
+		if err != nil {
+			return vote.WrapError(vote.ErrInvalid, err)
+		}
+
+		if withValidity, _ := strconv.ParseBool(r.URL.Query().Get("with_validity")); withValidity {
+			result, err := stop.StopWithValidity(r.Context(), id)
+			if err != nil {
+				return err
+			}
+
+			return writeStopResultWithValidity(w, result)
+		}
+
+		if aggregate, _ := strconv.ParseBool(r.URL.Query().Get("aggregate")); aggregate {
+			withRaw := true
+			if raw := r.URL.Query().Get("raw"); raw != "" {
+				withRaw, err = strconv.ParseBool(raw)
+				if err != nil {
+					return vote.MessageError(vote.ErrInvalid, "raw invalid. Expected bool, got %s", raw)
+				}
+			}
+
+			result, err := stop.StopWithAggregation(r.Context(), id)
+			if err != nil {
+				return err
+			}
+
+			return writeStopResultWithAggregation(w, result, withRaw)
+		}
+
+		result, err := stop.Stop(r.Context(), id)
+		if err != nil {
+			return err
+		}
+
+		if wantsCSV(r) {
+			w.Header().Set("Content-Type", "text/csv")
+			return writeStopResultCSV(w, result)
+		}
+
+		// The result of a stopped poll does not change, so the client can
+		// cache it.
+		etag := stopResultETag(result)
+		w.Header().Set("ETag", etag)
+		if etagMatch(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return nil
+		}
+
+		// Convert vote objects to json.RawMessage
+		encodableObjects := make([]json.RawMessage, len(result.Votes))
+		for i := range result.Votes {
+			encodableObjects[i] = result.Votes[i]
+		}
+
+		if result.UserIDs == nil {
+			result.UserIDs = []int{}
+		}
+
+		out := struct {
+			Votes []json.RawMessage `json:"votes"`
+			Users []int             `json:"user_ids"`
+			Meta  vote.StopMeta     `json:"meta"`
+		}{
+			encodableObjects,
+			result.UserIDs,
+			result.Meta,
+		}
+
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			return fmt.Errorf("encoding and sending objects: %w", err)
+		}
+		return nil
+	}
+}
+
+// wantsCSV returns true, if the client requests the result as csv with the
+// argument `format=csv` or the header `Accept: text/csv`.
+func wantsCSV(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "csv" {
+		return true
+	}
+
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err == nil && mediaType == "text/csv" {
+			return true
+		}
+	}
+	return false
+}
+
+// writeStopResultCSV writes one row for each vote object.
+//
+// Vote objects of polls, that are not named, have no vote_user_id, so the
+// column is empty. A vote object, that can not be decoded, is written with a
+// message in the column error.
+func writeStopResultCSV(w io.Writer, result vote.StopResult) error {
+	csvWriter := csv.NewWriter(w)
+	if err := csvWriter.Write([]string{"vote_user_id", "value", "weight", "error"}); err != nil {
+		return fmt.Errorf("writing csv header: %w", err)
+	}
+
+	for _, object := range result.Votes {
+		var ballot struct {
+			VoteUserID int             `json:"vote_user_id"`
+			Value      json.RawMessage `json:"value"`
+			Weight     string          `json:"weight"`
+		}
+
+		var record []string
+		if err := json.Unmarshal(object, &ballot); err != nil {
+			record = []string{"", "", "", fmt.Sprintf("invalid vote object: %v", err)}
+		} else {
+			userID := ""
+			if ballot.VoteUserID != 0 {
+				userID = strconv.Itoa(ballot.VoteUserID)
+			}
+			record = []string{userID, csvValue(ballot.Value), ballot.Weight, ""}
+		}
+
+		if err := csvWriter.Write(record); err != nil {
+			return fmt.Errorf("writing csv row: %w", err)
+		}
+	}
+
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		return fmt.Errorf("writing csv: %w", err)
+	}
+	return nil
+}
+
+// csvValue returns a vote value for a csv cell. A string value is returned
+// without quotes. All other values are returned as json.
+func csvValue(value json.RawMessage) string {
+	var str string
+	if err := json.Unmarshal(value, &str); err == nil {
+		return str
+	}
+	return string(value)
+}
+
+// writeStopResultWithValidity writes the stop result and adds the field
+// `valid` to each vote object.
+//
+// The validity depends on the poll config, that can change. So there is no
+// ETag for this response.
+func writeStopResultWithValidity(w http.ResponseWriter, result vote.StopResult) error {
+	encodableObjects := make([]map[string]json.RawMessage, len(result.Votes))
+	for i := range result.Votes {
+		if err := json.Unmarshal(result.Votes[i], &encodableObjects[i]); err != nil {
+			return fmt.Errorf("decoding vote object: %w", err)
+		}
+
+		valid := json.RawMessage("false")
+		if i < len(result.Valid) && result.Valid[i] {
+			valid = json.RawMessage("true")
+		}
+		encodableObjects[i]["valid"] = valid
+	}
+
+	if result.UserIDs == nil {
+		result.UserIDs = []int{}
+	}
+
+	out := struct {
+		Votes []map[string]json.RawMessage `json:"votes"`
+		Users []int                        `json:"user_ids"`
+		Meta  vote.StopMeta                `json:"meta"`
+	}{
+		encodableObjects,
+		result.UserIDs,
+		result.Meta,
+	}
+
+	if err := json.NewEncoder(w).Encode(out); err != nil {
+		return fmt.Errorf("encoding and sending objects: %w", err)
+	}
+	return nil
+}
+
+// writeStopResultWithAggregation writes the stop result with the field
+// `aggregation`. If withRaw is false, the votes are not written.
+func writeStopResultWithAggregation(w http.ResponseWriter, result vote.StopResult, withRaw bool) error {
+	var encodableObjects *[]json.RawMessage
+	if withRaw {
+		objects := make([]json.RawMessage, len(result.Votes))
+		for i := range result.Votes {
+			objects[i] = result.Votes[i]
+		}
+		encodableObjects = &objects
+	}
+
+	if result.UserIDs == nil {
+		result.UserIDs = []int{}
+	}
+
+	out := struct {
+		Votes       *[]json.RawMessage `json:"votes,omitempty"`
+		Users       []int              `json:"user_ids"`
+		Meta        vote.StopMeta      `json:"meta"`
+		Aggregation *vote.Aggregation  `json:"aggregation"`
+	}{
+		encodableObjects,
+		result.UserIDs,
+		result.Meta,
+		result.Aggregation,
+	}
+
+	if err := json.NewEncoder(w).Encode(out); err != nil {
+		return fmt.Errorf("encoding and sending objects: %w", err)
+	}
+	return nil
+}
+
+// stopResultETag returns an ETag for the result of a stopped poll.
+func stopResultETag(result vote.StopResult) string {
+	return `"` + result.Hash() + `"`
+}
+
+// etagMatch returns true, if the value of an If-None-Match header contains the
+// etag.
+func etagMatch(ifNoneMatch string, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == etag || tag == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+type reopener interface {
+	Reopen(ctx context.Context, pollID int) error
+}
+
+// handleReopen starts a stopped poll again. The votes of the poll are kept.
+func handleReopen(reopen reopener) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		log.Info("Receiving reopen request")
+		w.Header().Set("Content-Type", "application/json")
+
+		id, err := pollID(r)
+		if err != nil {
+			return vote.WrapError(vote.ErrInvalid, err)
+		}
+
+		return reopen.Reopen(r.Context(), id)
+	}
+}
+
+type reloader interface {
+	ReloadPoll(ctx context.Context, pollID int) error
+}
+
+// handleReload loads the config of a started poll again from the datastore.
+// The votes of the poll are kept.
+func handleReload(reload reloader) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		log.Info("Receiving reload request")
+		w.Header().Set("Content-Type", "application/json")
+
+		id, err := pollID(r)
+		if err != nil {
+			return vote.WrapError(vote.ErrInvalid, err)
+		}
+
+		return reload.ReloadPoll(r.Context(), id)
+	}
+}
+
+type retracter interface {
+	RetractVote(ctx context.Context, pollID, userID int) error
+}
+
+// handleRetract removes the vote of the user given with the argument `user_id`
+// from a started poll. Afterwards, the user can vote again.
+func handleRetract(retract retracter) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		log.Info("Receiving retract request")
+		w.Header().Set("Content-Type", "application/json")
+
+		id, err := pollID(r)
+		if err != nil {
+			return vote.WrapError(vote.ErrInvalid, err)
+		}
+
+		userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+		if err != nil {
+			return vote.MessageError(vote.ErrInvalid, "user_id invalid. Expected int, got %s", r.URL.Query().Get("user_id"))
+		}
+
+		return retract.RetractVote(r.Context(), id, userID)
+	}
+}
+
+type resultHasher interface {
+	ResultHash(ctx context.Context, pollID int) (string, error)
+}
+
+// handleResultHash returns a hash over the result of a stopped poll. It can be
+// compared between instances to find differences.
+func handleResultHash(hasher resultHasher) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		log.Info("Receiving result hash request")
+		w.Header().Set("Content-Type", "application/json")
+
+		id, err := pollID(r)
+		if err != nil {
+			return vote.WrapError(vote.ErrInvalid, err)
+		}
+
+		hash, err := hasher.ResultHash(r.Context(), id)
+		if err != nil {
+			return err
+		}
+
+		if err := json.NewEncoder(w).Encode(map[string]string{"hash": hash}); err != nil {
+			return fmt.Errorf("encoding and sending hash: %w", err)
+		}
+		return nil
+	}
+}
+
+type tallier interface {
+	TallyWithCaps(ctx context.Context, pollID int, maxFraction float64) (vote.Tally, error)
+}
+
+// handleTally returns the weighted result of a stopped poll. The argument
+// max_fraction flags options, that got more then this part of the total
+// weight. Without it, no option is flagged.
+func handleTally(tally tallier) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		log.Info("Receiving tally request")
+		w.Header().Set("Content-Type", "application/json")
+
+		id, err := pollID(r)
+		if err != nil {
+			return vote.WrapError(vote.ErrInvalid, err)
+		}
+
+		maxFraction := 1.0
+		if raw := r.URL.Query().Get("max_fraction"); raw != "" {
+			maxFraction, err = strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return vote.MessageError(vote.ErrInvalid, "max_fraction invalid. Expected float, got %s", raw)
+			}
+		}
+
+		result, err := tally.TallyWithCaps(r.Context(), id, maxFraction)
+		if err != nil {
+			return err
+		}
+
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			return fmt.Errorf("encoding and sending tally: %w", err)
+		}
+		return nil
+	}
+}
+
+type maintainer interface {
+	SetMaintenance(enabled bool, message string)
+	Maintenance() (bool, string)
+}
+
+// handleMaintenance enables or disables the maintenance mode with the argument
+// on. The argument message sets the message for rejected requests. Without the
+// argument on, the current state is returned.
+func handleMaintenance(maintenance maintainer) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		log.Info("Receiving maintenance request")
+		w.Header().Set("Content-Type", "application/json")
+
+		if rawOn := r.URL.Query().Get("on"); rawOn != "" {
+			on, err := strconv.ParseBool(rawOn)
+			if err != nil {
+				return vote.MessageError(vote.ErrInvalid, "on invalid. Expected bool, got %s", rawOn)
+			}
+
+			maintenance.SetMaintenance(on, r.URL.Query().Get("message"))
+		}
+
+		enabled, message := maintenance.Maintenance()
+		out := struct {
+			Maintenance bool   `json:"maintenance"`
+			Message     string `json:"message,omitempty"`
+		}{
+			enabled,
+			message,
+		}
+
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			return fmt.Errorf("encoding and sending maintenance state: %w", err)
+		}
+		return nil
+	}
+}
+
+type clearer interface {
+	Clear(ctx context.Context, pollID int) error
+}
+
+func handleClear(clear clearer) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		log.Info("Receiving clear request")
+		w.Header().Set("Content-Type", "application/json")
+
+		id, err := pollID(r)
+		if err != nil {
+			return vote.WrapError(vote.ErrInvalid, err)
+		}
+
+		return clear.Clear(r.Context(), id)
+	}
+}
+
+type clearManyer interface {
+	ClearMany(ctx context.Context, pollIDs []int) error
+}
+
+// handleClearBatch clears many polls. If some polls could not be cleared, the
+// response is still 200 and contains the errors.
+func handleClearBatch(clear clearManyer) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		log.Info("Receiving clear batch request")
+		w.Header().Set("Content-Type", "application/json")
+
+		pollIDs, err := pollsID(r)
+		if err != nil {
+			return vote.WrapError(vote.ErrInvalid, err)
+		}
+
+		var errFailed vote.ClearManyError
+		if err := clear.ClearMany(r.Context(), pollIDs); err != nil && !errors.As(err, &errFailed) {
+			return err
+		}
+
+		cleared := make([]int, 0, len(pollIDs))
+		failed := make(map[int]string, len(errFailed))
+		for _, pollID := range pollIDs {
+			if err, ok := errFailed[pollID]; ok {
+				log.Info("Clearing poll %d failed: %v", pollID, err)
+				failed[pollID] = err.Error()
+				continue
+			}
+			cleared = append(cleared, pollID)
+		}
+
+		out := struct {
+			Cleared []int          `json:"cleared"`
+			Failed  map[int]string `json:"failed"`
+		}{
+			cleared,
+			failed,
+		}
+
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			return fmt.Errorf("encoding and sending clear result: %w", err)
+		}
+		return nil
+	}
+}
+
+type meetingClearer interface {
+	ClearMeeting(ctx context.Context, meetingID int) error
+}
+
+// handleClearMeeting clears all polls of a meeting.
+func handleClearMeeting(clear meetingClearer) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		log.Info("Receiving clear meeting request")
+		w.Header().Set("Content-Type", "application/json")
+
+		meetingID, err := strconv.Atoi(r.URL.Query().Get("meeting_id"))
+		if err != nil {
+			return vote.MessageError(vote.ErrInvalid, "meeting_id invalid. Expected int, got %s", r.URL.Query().Get("meeting_id"))
+		}
+
+		return clear.ClearMeeting(r.Context(), meetingID)
+	}
+}
+
+type clearAller interface {
+	ClearAll(ctx context.Context) (cacheReset bool, err error)
+}
+
+func handleClearAll(clear clearAller) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		log.Info("Receiving clear all request")
+		w.Header().Set("Content-Type", "application/json")
+
+		cacheReset, err := clear.ClearAll(r.Context())
+		if err != nil {
+			return err
+		}
+
+		out := struct {
+			CacheReset bool `json:"cache_reset"`
+		}{
+			cacheReset,
+		}
+
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			return fmt.Errorf("encoding and sending clear all result: %w", err)
+		}
+		return nil
+	}
+}
+
+type refresher interface {
+	RefreshVoted(ctx context.Context) error
+}
+
+func handleRefresh(refresh refresher) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		log.Info("Receiving refresh request")
+		w.Header().Set("Content-Type", "application/json")
+
+		return refresh.RefreshVoted(r.Context())
+	}
+}
+
+type exporter interface {
+	Export(ctx context.Context, w io.Writer) error
+	Import(ctx context.Context, r io.Reader) error
+}
+
+// handleExport writes the data of all polls of the backends. Each poll is a
+// json object on its own line.
+func handleExport(export exporter) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		log.Info("Receiving export request")
+		w.Header().Set("Content-Type", "application/x-ndjson")
+
+		return export.Export(r.Context(), w)
+	}
+}
+
+// handleImport saves the polls from the body, that was created by the export
+// handler, in the backends.
+func handleImport(export exporter) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		log.Info("Receiving import request")
+		w.Header().Set("Content-Type", "application/json")
+
+		return export.Import(r.Context(), r.Body)
+	}
+}
+
+type voter interface {
+	VoteWithResult(ctx context.Context, pollID, requestUser int, r io.Reader) (vote.VoteResult, error)
+}
+
+func handleVote(service voter, auth authenticater) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		log.Info("Receiving vote request")
+		w.Header().Set("Content-Type", "application/json")
+
+		start := time.Now()
+		ctx, err := auth.Authenticate(w, r)
+		if err != nil {
+			return err
+		}
+		authDuration := time.Since(start)
+
+		uid := auth.FromContext(ctx)
+		if uid == 0 {
+			return statusCode(401, vote.MessageError(vote.ErrNotAllowed, "Anonymous user can not vote"))
+		}
+
+		id, err := pollID(r)
+		if err != nil {
+			return vote.WrapError(vote.ErrInvalid, err)
+		}
+
+		if key := r.Header.Get("Idempotency-Key"); key != "" {
+			ctx = vote.WithIdempotencyKey(ctx, key)
+		}
+
+		if ack, _ := strconv.ParseBool(r.URL.Query().Get("ack")); ack {
+			ctx = vote.WithBallotAck(ctx)
+		}
+
+		// Tracing is only available with debug logging.
+		var trace *vote.Trace
+		if withTrace, _ := strconv.ParseBool(r.URL.Query().Get("trace")); withTrace && log.IsDebug() {
+			ctx, trace = vote.WithTrace(ctx)
+		}
+
+		result, err := service.VoteWithResult(ctx, id, uid, r.Body)
+		if err != nil {
+			return err
+		}
+
+		out := voteResponse{
+			VoteUserID: result.VoteUserID,
+			Weight:     result.Weight,
+			BallotID:   result.BallotID,
+		}
+
+		if trace != nil {
+			out.Trace = &traceResult{
+				AuthMS:       milliseconds(authDuration),
+				LoadMS:       milliseconds(trace.Load),
+				ValidationMS: milliseconds(trace.Validation),
+				WeightMS:     milliseconds(trace.Weight),
+				BackendMS:    milliseconds(trace.Backend),
+				TotalMS:      milliseconds(time.Since(start)),
+			}
+		}
+
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			return fmt.Errorf("encoding vote result: %w", err)
+		}
+
+		return nil
+	}
+}
+
+// voteResponse is the body of a successful vote request.
+type voteResponse struct {
+	VoteUserID int          `json:"vote_user_id"`
+	Weight     string       `json:"weight"`
+	BallotID   string       `json:"ballot_id,omitempty"`
+	Trace      *traceResult `json:"trace,omitempty"`
+}
+
+// traceResult is the timing breakdown of a vote request.
+type traceResult struct {
+	AuthMS       float64 `json:"auth_ms"`
+	LoadMS       float64 `json:"load_ms"`
+	ValidationMS float64 `json:"validation_ms"`
+	WeightMS     float64 `json:"weight_ms"`
+	BackendMS    float64 `json:"backend_ms"`
+	TotalMS      float64 `json:"total_ms"`
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// checkCORS adds the CORS headers to requests from one of the allowed origins.
+// A preflight request is answered without calling next.
+//
+// Without origins, no headers are added.
+func checkCORS(origins []string, next HandlerFunc) HandlerFunc {
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		allowed[origin] = true
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if len(allowed) == 0 {
+			return next(w, r)
+		}
+
+		w.Header().Add("Vary", "Origin")
+
+		origin := r.Header.Get("Origin")
+		if !allowed[origin] {
+			return next(w, r)
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Idempotency-Key, X-Vote-Timestamp, "+APIKeyHeader)
+			w.WriteHeader(http.StatusNoContent)
+			return nil
+		}
+
+		return next(w, r)
+	}
+}
+
+// checkVoteMethod makes sure, that a vote request uses the method POST or, if
+// acceptPut is true, PUT.
+func checkVoteMethod(acceptPut bool, next HandlerFunc) HandlerFunc {
+	allowed := "POST"
+	if acceptPut {
+		allowed = "POST, PUT"
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if r.Method == http.MethodPost || (acceptPut && r.Method == http.MethodPut) {
+			return next(w, r)
+		}
+
+		w.Header().Set("Allow", allowed)
+		return statusCode(http.StatusMethodNotAllowed, vote.MessageError(vote.ErrInvalid, "Method %s is not allowed. Use %s", r.Method, allowed))
+	}
+}
+
+// checkContentType makes sure, that the request has the content type
+// application/json.
+//
+// If required is false, the check is disabled.
+func checkContentType(required bool, next HandlerFunc) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if !required {
+			return next(w, r)
+		}
+
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mediaType != "application/json" {
+			return vote.MessageError(vote.ErrInvalid, "Content-Type has to be application/json")
+		}
+
+		return next(w, r)
+	}
+}
+
+// checkClockSkew makes sure, that the time from the header `X-Vote-Timestamp`
+// does not differ more then maxSkew from the server time. The time is a unix
+// timestamp in seconds. It is added to the request context so it can be saved
+// with the ballot.
+//
+// If maxSkew is 0, the check is disabled.
+func checkClockSkew(maxSkew time.Duration, next HandlerFunc) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if maxSkew == 0 {
+			return next(w, r)
+		}
+
+		rawTimestamp := r.Header.Get("X-Vote-Timestamp")
+		if rawTimestamp == "" {
+			return vote.MessageError(vote.ErrInvalid, "Header X-Vote-Timestamp is required")
+		}
+
+		timestamp, err := strconv.ParseInt(rawTimestamp, 10, 64)
+		if err != nil {
+			return vote.MessageError(vote.ErrInvalid, "Header X-Vote-Timestamp invalid. Expected unix timestamp, got %s", rawTimestamp)
+		}
+
+		clientTime := time.Unix(timestamp, 0)
+		skew := time.Since(clientTime)
+		if skew < 0 {
+			skew = -skew
+		}
+
+		if skew > maxSkew {
+			return vote.MessageError(vote.ErrInvalid, "The time of your device differs %s from the server time", skew.Round(time.Second))
+		}
+
+		return next(w, r.WithContext(vote.WithClientTime(r.Context(), clientTime)))
+	}
+}
+
+// limitBody rejects requests with a body bigger then maxBytes. The body is
+// read before next is called.
+func limitBody(maxBytes int64, next HandlerFunc) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+		if err != nil {
+			var errTooLarge *http.MaxBytesError
+			if errors.As(err, &errTooLarge) {
+				return vote.MessageError(vote.ErrInvalid, "The request body is bigger then %d bytes", maxBytes)
+			}
+			return fmt.Errorf("reading request body: %w", err)
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		return next(w, r)
+	}
+}
+
+type simulator interface {
+	Simulate(ctx context.Context, pollID, requestUser int, r io.Reader) (vote.SimulateResult, error)
+}
+
+// handleSimulate validates a vote for the user given with the argument
+// `user_id` without saving it. It returns the time the validation took.
+func handleSimulate(simulate simulator) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		log.Info("Receiving simulate request")
+		w.Header().Set("Content-Type", "application/json")
+
+		id, err := pollID(r)
+		if err != nil {
+			return vote.WrapError(vote.ErrInvalid, err)
+		}
+
+		userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+		if err != nil {
+			return vote.MessageError(vote.ErrInvalid, "user_id invalid. Expected int, got %s", r.URL.Query().Get("user_id"))
+		}
+
+		result, err := simulate.Simulate(r.Context(), id, userID, r.Body)
+		if err != nil {
+			return err
+		}
+
+		out := struct {
+			VoteUserID int     `json:"vote_user_id"`
+			Weight     string  `json:"weight"`
+			DurationMS float64 `json:"duration_ms"`
+		}{
+			result.VoteUserID,
+			result.Weight,
+			milliseconds(result.Duration),
+		}
+
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			return fmt.Errorf("encoding simulate result: %w", err)
+		}
+		return nil
+	}
+}
+
+type haveIvoteder interface {
+	Voted(ctx context.Context, pollIDs []int, requestUser int) (map[int][]int, error)
+	VotedHashes(ctx context.Context, pollIDs []int, requestUser int) (map[int]map[int]string, error)
+}
+
+func handleVoted(voted haveIvoteder, auth authenticater) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		log.Info("Receiving has voted request")
+		w.Header().Set("Content-Type", "application/json")
+
+		ctx, err := auth.Authenticate(w, r)
+		if err != nil {
+			return err
+		}
+
+		uid := auth.FromContext(ctx)
+		if uid == 0 {
+			return statusCode(401, vote.MessageError(vote.ErrNotAllowed, "Anonymous user can not vote"))
+		}
 
-type voteService interface {
-	starter
-	stopper
-	clearer
-	clearAller
-	voteCounter
-	voter
-	haveIvoteder
-}
+		pollIDs, err := pollsID(r)
+		if err != nil {
+			return vote.WrapError(vote.ErrInvalid, err)
+		}
 
-type authenticater interface {
-	Authenticate(http.ResponseWriter, *http.Request) (context.Context, error)
-	FromContext(context.Context) int
-}
+		if includeHash, _ := strconv.ParseBool(r.URL.Query().Get("include_hash")); includeHash {
+			hashes, err := voted.VotedHashes(ctx, pollIDs, uid)
+			if err != nil {
+				return err
+			}
 
-func registerHandlers(service voteService, auth authenticater, ticketProvider func() (<-chan time.Time, func())) *http.ServeMux {
-	const (
-		internal = "/internal/vote"
-		external = "/system/vote"
-	)
+			if err := json.NewEncoder(w).Encode(hashes); err != nil {
+				return fmt.Errorf("encoding and sending hashes: %w", err)
+			}
+			return nil
+		}
 
-	mux := http.NewServeMux()
+		voted, err := voted.Voted(ctx, pollIDs, uid)
+		if err != nil {
+			return err
+		}
 
-	mux.Handle(internal+"/start", handleInternal(handleStart(service)))
-	mux.Handle(internal+"/stop", handleInternal(handleStop(service)))
-	mux.Handle(internal+"/clear", handleInternal(handleClear(service)))
-	mux.Handle(internal+"/clear_all", handleInternal(handleClearAll(service)))
-	mux.Handle(internal+"/vote_count", handleInternal(handleVoteCount(service, ticketProvider)))
-	mux.Handle(external+"", handleExternal(handleVote(service, auth)))
-	mux.Handle(external+"/voted", handleExternal(handleVoted(service, auth)))
-	mux.Handle(external+"/health", handleExternal(handleHealth()))
+		if err := json.NewEncoder(w).Encode(voted); err != nil {
+			return fmt.Errorf("encoding and sending objects: %w", err)
+		}
 
-	return mux
+		return nil
+	}
 }
 
-type starter interface {
-	Start(ctx context.Context, pollID int) error
+type eligibler interface {
+	Eligible(ctx context.Context, pollID, requestUser, voteUser int) (vote.Eligibility, error)
 }
 
-func handleStart(start starter) HandlerFunc {
+// handleEligible tells, if the request user can vote on a poll. With the
+// argument user_id, it tells, if the request user can vote for this user.
+func handleEligible(eligible eligibler, auth authenticater) HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) error {
-		log.Info("Receiving start request")
+		log.Info("Receiving eligible request")
 		w.Header().Set("Content-Type", "application/json")
 
+		ctx, err := auth.Authenticate(w, r)
+		if err != nil {
+			return err
+		}
+
+		uid := auth.FromContext(ctx)
+		if uid == 0 {
+			return statusCode(401, vote.MessageError(vote.ErrNotAllowed, "Anonymous user can not vote"))
+		}
+
 		id, err := pollID(r)
 		if err != nil {
 			return vote.WrapError(vote.ErrInvalid, err)
 		}
 
-		return start.Start(r.Context(), id)
+		voteUser := uid
+		if rawUserID := r.URL.Query().Get("user_id"); rawUserID != "" {
+			voteUser, err = strconv.Atoi(rawUserID)
+			if err != nil {
+				return vote.MessageError(vote.ErrInvalid, "user_id invalid. Expected int, got %s", rawUserID)
+			}
+		}
+
+		result, err := eligible.Eligible(ctx, id, uid, voteUser)
+		if err != nil {
+			return err
+		}
+
+		out := struct {
+			Eligible bool   `json:"eligible"`
+			Reason   string `json:"reason,omitempty"`
+		}{
+			result.Eligible,
+			result.Reason,
+		}
+
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			return fmt.Errorf("encoding and sending eligibility: %w", err)
+		}
+		return nil
 	}
 }
 
-// stopper stops a poll. It sets the state of the poll, so that no other user
-// can vote. It writes the vote results to the writer.
-type stopper interface {
-	Stop(ctx context.Context, pollID int) (vote.StopResult, error)
+type eligibilityChecker interface {
+	EligibilityChecks(ctx context.Context, pollID, requestUser, voteUser int) ([]vote.EligibilityCheck, error)
 }
 
-func handleStop(stop stopper) HandlerFunc {
+// handleEligibility returns all checks, that the request user has to pass to
+// vote on a poll, and if they passed. With the argument user_id, the checks are
+// for a vote for this user.
+func handleEligibility(checker eligibilityChecker, auth authenticater) HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) error {
-		log.Info("Receiving stop request")
+		log.Info("Receiving eligibility request")
 		w.Header().Set("Content-Type", "application/json")
 
+		ctx, err := auth.Authenticate(w, r)
+		if err != nil {
+			return err
+		}
+
+		uid := auth.FromContext(ctx)
+		if uid == 0 {
+			return statusCode(401, vote.MessageError(vote.ErrNotAllowed, "Anonymous user can not vote"))
+		}
+
 		id, err := pollID(r)
 		if err != nil {
 			return vote.WrapError(vote.ErrInvalid, err)
 		}
 
-		result, err := stop.Stop(r.Context(), id)
-		if err != nil {
-			return err
+		voteUser := uid
+		if rawUserID := r.URL.Query().Get("user_id"); rawUserID != "" {
+			voteUser, err = strconv.Atoi(rawUserID)
+			if err != nil {
+				return vote.MessageError(vote.ErrInvalid, "user_id invalid. Expected int, got %s", rawUserID)
+			}
 		}
 
-		// Convert vote objects to json.RawMessage
-		encodableObjects := make([]json.RawMessage, len(result.Votes))
-		for i := range result.Votes {
-			encodableObjects[i] = result.Votes[i]
+		checks, err := checker.EligibilityChecks(ctx, id, uid, voteUser)
+		if err != nil {
+			return err
 		}
 
-		if result.UserIDs == nil {
-			result.UserIDs = []int{}
+		eligible := true
+		for _, check := range checks {
+			eligible = eligible && check.Passed
 		}
 
 		out := struct {
-			Votes []json.RawMessage `json:"votes"`
-			Users []int             `json:"user_ids"`
+			Eligible bool                    `json:"eligible"`
+			Checks   []vote.EligibilityCheck `json:"checks"`
 		}{
-			encodableObjects,
-			result.UserIDs,
+			eligible,
+			checks,
 		}
 
 		if err := json.NewEncoder(w).Encode(out); err != nil {
-			return fmt.Errorf("encoding and sending objects: %w", err)
+			return fmt.Errorf("encoding and sending eligibility checks: %w", err)
 		}
 		return nil
 	}
 }
 
-type clearer interface {
-	Clear(ctx context.Context, pollID int) error
+type turnoutByGrouper interface {
+	TurnoutByGroup(ctx context.Context, pollID int) (map[int]vote.GroupTurnout, error)
 }
 
-func handleClear(clear clearer) HandlerFunc {
+func handleTurnoutByGroup(turnout turnoutByGrouper) HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) error {
-		log.Info("Receiving clear request")
+		log.Info("Receiving turnout by group request")
 		w.Header().Set("Content-Type", "application/json")
 
 		id, err := pollID(r)
@@ -198,107 +1399,252 @@ func handleClear(clear clearer) HandlerFunc {
 			return vote.WrapError(vote.ErrInvalid, err)
 		}
 
-		return clear.Clear(r.Context(), id)
+		result, err := turnout.TurnoutByGroup(r.Context(), id)
+		if err != nil {
+			return err
+		}
+
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			return fmt.Errorf("encoding and sending turnout: %w", err)
+		}
+		return nil
 	}
 }
 
-type clearAller interface {
-	ClearAll(ctx context.Context) error
+type entitledCounter interface {
+	EntitledCount(ctx context.Context, pollID int) (int, error)
+	VoteCount(ctx context.Context) map[int]int
 }
 
-func handleClearAll(clear clearAller) HandlerFunc {
+// handleEntitled returns the number of entitled users of a poll and how many
+// of them have voted.
+func handleEntitled(counter entitledCounter) HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) error {
-		log.Info("Receiving clear all request")
+		log.Info("Receiving entitled request")
 		w.Header().Set("Content-Type", "application/json")
 
-		return clear.ClearAll(r.Context())
+		id, err := pollID(r)
+		if err != nil {
+			return vote.WrapError(vote.ErrInvalid, err)
+		}
+
+		entitled, err := counter.EntitledCount(r.Context(), id)
+		if err != nil {
+			return err
+		}
+
+		out := struct {
+			Entitled int `json:"entitled"`
+			Voted    int `json:"voted"`
+		}{
+			entitled,
+			counter.VoteCount(r.Context())[id],
+		}
+
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			return fmt.Errorf("encoding and sending entitled count: %w", err)
+		}
+		return nil
 	}
 }
 
-type voter interface {
-	Vote(ctx context.Context, pollID, requestUser int, r io.Reader) error
+type meetingTurnouter interface {
+	MeetingTurnout(ctx context.Context, meetingID int) (map[int]vote.PollTurnout, error)
 }
 
-func handleVote(service voter, auth authenticater) HandlerFunc {
+// handleMeetingTurnout returns for each poll of a meeting the number of votes
+// and entitled users.
+func handleMeetingTurnout(turnout meetingTurnouter) HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) error {
-		log.Info("Receiving vote request")
+		log.Info("Receiving meeting turnout request")
 		w.Header().Set("Content-Type", "application/json")
 
-		ctx, err := auth.Authenticate(w, r)
+		meetingID, err := strconv.Atoi(r.URL.Query().Get("meeting_id"))
+		if err != nil {
+			return vote.MessageError(vote.ErrInvalid, "meeting_id invalid. Expected int, got %s", r.URL.Query().Get("meeting_id"))
+		}
+
+		result, err := turnout.MeetingTurnout(r.Context(), meetingID)
 		if err != nil {
 			return err
 		}
 
-		uid := auth.FromContext(ctx)
-		if uid == 0 {
-			return statusCode(401, vote.MessageError(vote.ErrNotAllowed, "Anonymous user can not vote"))
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			return fmt.Errorf("encoding and sending meeting turnout: %w", err)
 		}
+		return nil
+	}
+}
+
+type auditor interface {
+	Audit(pollID int) []vote.AuditEvent
+}
+
+// handleAudit returns the start, stop, reopen and clear events of a poll in the
+// order
+// they happened.
+func handleAudit(audit auditor) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		log.Info("Receiving audit request")
+		w.Header().Set("Content-Type", "application/json")
 
 		id, err := pollID(r)
 		if err != nil {
 			return vote.WrapError(vote.ErrInvalid, err)
 		}
 
-		return service.Vote(ctx, id, uid, r.Body)
+		out := struct {
+			Events []vote.AuditEvent `json:"events"`
+		}{
+			audit.Audit(id),
+		}
+
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			return fmt.Errorf("encoding and sending audit events: %w", err)
+		}
+		return nil
 	}
 }
 
-type haveIvoteder interface {
-	Voted(ctx context.Context, pollIDs []int, requestUser int) (map[int][]int, error)
+type backendLoader interface {
+	BackendLoad(ctx context.Context) (map[string]vote.BackendUsage, error)
 }
 
-func handleVoted(voted haveIvoteder, auth authenticater) HandlerFunc {
+// handleBackendLoad returns for each backend, how many polls and votes it
+// holds.
+func handleBackendLoad(loader backendLoader) HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) error {
-		log.Info("Receiving has voted request")
+		log.Info("Receiving backend load request")
 		w.Header().Set("Content-Type", "application/json")
 
-		ctx, err := auth.Authenticate(w, r)
+		load, err := loader.BackendLoad(r.Context())
 		if err != nil {
 			return err
 		}
 
-		uid := auth.FromContext(ctx)
-		if uid == 0 {
-			return statusCode(401, vote.MessageError(vote.ErrNotAllowed, "Anonymous user can not vote"))
+		if err := json.NewEncoder(w).Encode(load); err != nil {
+			return fmt.Errorf("encoding and sending backend load: %w", err)
 		}
+		return nil
+	}
+}
 
-		pollIDs, err := pollsID(r)
+type votesForUserer interface {
+	PollsVotedByUser(ctx context.Context, userID int) ([]int, error)
+}
+
+// handleVotesForUser returns the ids of all polls, on which a user has voted.
+func handleVotesForUser(votes votesForUserer) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		log.Info("Receiving votes for user request")
+		w.Header().Set("Content-Type", "application/json")
+
+		userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
 		if err != nil {
-			return vote.WrapError(vote.ErrInvalid, err)
+			return vote.MessageError(vote.ErrInvalid, "user_id invalid. Expected int, got %s", r.URL.Query().Get("user_id"))
 		}
 
-		voted, err := voted.Voted(ctx, pollIDs, uid)
+		pollIDs, err := votes.PollsVotedByUser(r.Context(), userID)
 		if err != nil {
 			return err
 		}
 
-		if err := json.NewEncoder(w).Encode(voted); err != nil {
-			return fmt.Errorf("encoding and sending objects: %w", err)
+		out := struct {
+			PollIDs []int `json:"poll_ids"`
+		}{
+			pollIDs,
+		}
+
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			return fmt.Errorf("encoding and sending poll ids: %w", err)
+		}
+		return nil
+	}
+}
+
+type activeMeetingser interface {
+	ActiveMeetings(ctx context.Context) ([]int, error)
+}
+
+func handleActiveMeetings(active activeMeetingser) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		log.Info("Receiving active meetings request")
+		w.Header().Set("Content-Type", "application/json")
+
+		meetingIDs, err := active.ActiveMeetings(r.Context())
+		if err != nil {
+			return err
+		}
+
+		if meetingIDs == nil {
+			meetingIDs = []int{}
 		}
 
+		if err := json.NewEncoder(w).Encode(meetingIDs); err != nil {
+			return fmt.Errorf("encoding and sending meeting ids: %w", err)
+		}
 		return nil
 	}
 }
 
 type voteCounter interface {
 	VoteCount(ctx context.Context) map[int]int
+	PollStates(ctx context.Context) (map[int]bool, error)
 }
 
-func handleVoteCount(voteCounter voteCounter, eventer func() (<-chan time.Time, func())) HandlerFunc {
+// handleVoteCount returns the vote count of all polls and then streams the
+// changes.
+//
+// If nothing was sent for the heartbeat duration, an empty object is sent, so
+// proxies do not close the connection. The time is taken from the events. A
+// heartbeat of 0 disables this.
+func handleVoteCount(voteCounter voteCounter, eventer func() (<-chan time.Time, func()), heartbeat time.Duration) HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) error {
 		log.Info("Receiving vote count request")
 		w.Header().Set("Content-Type", "application/json")
 
 		encoder := json.NewEncoder(w)
 
+		if r.URL.Query().Has("ids") {
+			// With ids, the count is only returned once.
+			pollIDs, err := pollsID(r)
+			if err != nil {
+				return vote.WrapError(vote.ErrInvalid, err)
+			}
+
+			count := voteCounter.VoteCount(r.Context())
+			filtered := make(map[int]int, len(pollIDs))
+			for _, pollID := range pollIDs {
+				filtered[pollID] = count[pollID]
+			}
+
+			return encoder.Encode(filtered)
+		}
+
+		lifecycle, _ := strconv.ParseBool(r.URL.Query().Get("lifecycle"))
+
 		event, cancel := eventer()
 		defer cancel()
 
 		var countMemory map[int]int
+		var running map[int]bool
 		firstData := true
+		now := time.Now()
+		lastSent := now
 		for {
 			count := voteCounter.VoteCount(r.Context())
 
+			var started, stopped []int
+			if lifecycle {
+				states, err := voteCounter.PollStates(r.Context())
+				if err != nil {
+					// The markers are sent with the next event.
+					log.Info("Fetching poll states for the vote count: %v", err)
+				} else {
+					started, stopped, running = pollLifecycle(running, states)
+				}
+			}
+
 			if countMemory == nil {
 				countMemory = count
 			} else {
@@ -320,9 +1666,21 @@ func handleVoteCount(voteCounter voteCounter, eventer func() (<-chan time.Time,
 				}
 			}
 
-			if firstData || len(count) > 0 {
+			if firstData || len(count) > 0 || len(started) > 0 || len(stopped) > 0 {
 				firstData = false
-				if err := encoder.Encode(count); err != nil {
+				lastSent = now
+
+				var data any = count
+				if lifecycle {
+					data = lifecycleCount{Count: count, Started: started, Stopped: stopped}
+				}
+
+				if err := encoder.Encode(data); err != nil {
+					return err
+				}
+			} else if heartbeat > 0 && now.Sub(lastSent) >= heartbeat {
+				lastSent = now
+				if err := encoder.Encode(struct{}{}); err != nil {
 					return err
 				}
 			}
@@ -333,10 +1691,11 @@ func handleVoteCount(voteCounter voteCounter, eventer func() (<-chan time.Time,
 			w.(http.Flusher).Flush()
 
 			select {
-			case _, ok := <-event:
+			case t, ok := <-event:
 				if !ok {
 					return nil
 				}
+				now = t
 			case <-r.Context().Done():
 				return nil
 			}
@@ -344,7 +1703,89 @@ func handleVoteCount(voteCounter voteCounter, eventer func() (<-chan time.Time,
 	}
 }
 
-func handleHealth() HandlerFunc {
+// lifecycleCount is the message of the vote count stream with the argument
+// `lifecycle`.
+type lifecycleCount struct {
+	Count   map[int]int `json:"count"`
+	Started []int       `json:"started,omitempty"`
+	Stopped []int       `json:"stopped,omitempty"`
+}
+
+// pollLifecycle compares the polls, that are started in the backends, with
+// the polls, that were started before. It returns the polls, that were started
+// or stopped since then, and the polls, that are started now. A poll, that was
+// cleared without being stopped, is also reported as stopped.
+//
+// On the first call, running is nil and no poll is reported.
+func pollLifecycle(running map[int]bool, states map[int]bool) (started, stopped []int, now map[int]bool) {
+	now = make(map[int]bool, len(states))
+	for pollID, isStopped := range states {
+		if isStopped {
+			continue
+		}
+
+		now[pollID] = true
+		if running != nil && !running[pollID] {
+			started = append(started, pollID)
+		}
+	}
+
+	for pollID := range running {
+		if !now[pollID] {
+			stopped = append(stopped, pollID)
+		}
+	}
+
+	sort.Ints(started)
+	sort.Ints(stopped)
+	return started, stopped, now
+}
+
+type healthChecker interface {
+	BackendHealth(ctx context.Context) map[string]error
+}
+
+// healthResponse is the body of the health request.
+type healthResponse struct {
+	Healthy  bool              `json:"healthy"`
+	Backends map[string]string `json:"backends"`
+}
+
+// handleHealth checks, that the service and all backends are reachable.
+func handleHealth(health healthChecker) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Content-Type", "application/json")
+
+		healthy := true
+		backends := make(map[string]string)
+		for name, err := range health.BackendHealth(r.Context()) {
+			backends[name] = "ok"
+			if err != nil {
+				log.Info("Backend %s is down: %v", name, err)
+				healthy = false
+				backends[name] = "down"
+			}
+		}
+
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		out := healthResponse{
+			Healthy:  healthy,
+			Backends: backends,
+		}
+
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			return fmt.Errorf("encoding health: %w", err)
+		}
+		return nil
+	}
+}
+
+// handleLiveness tells, that the process is running. It does not check the
+// backends.
+func handleLiveness() HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) error {
 		w.Header().Set("Content-Type", "application/json")
 
@@ -419,13 +1860,20 @@ func pollsID(r *http.Request) ([]int, error) {
 		return nil, fmt.Errorf("no ids argument provided")
 	}
 
-	ids := make([]int, len(rawIDs))
+	// Repeated ids are only returned once in the order they were first seen.
+	ids := make([]int, 0, len(rawIDs))
+	seen := make(map[int]struct{}, len(rawIDs))
 	for i, rawID := range rawIDs {
 		id, err := strconv.Atoi(rawID)
 		if err != nil {
 			return nil, fmt.Errorf("%dth id invalid. Expected int, got %s", i, rawID)
 		}
-		ids[i] = id
+
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		ids = append(ids, id)
 	}
 
 	return ids, nil
@@ -0,0 +1,73 @@
+package http
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/OpenSlides/openslides-vote-service/vote"
+)
+
+func TestAPIKeyAuth(t *testing.T) {
+	auth, err := NewAPIKeyAuth("key1:5\n\nkey2:7\n")
+	if err != nil {
+		t.Fatalf("NewAPIKeyAuth: %v", err)
+	}
+
+	t.Run("Valid key", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/system/vote", nil)
+		req.Header.Set(APIKeyHeader, "key2")
+
+		ctx, err := auth.Authenticate(httptest.NewRecorder(), req)
+		if err != nil {
+			t.Fatalf("Authenticate: %v", err)
+		}
+
+		if got := auth.FromContext(ctx); got != 7 {
+			t.Errorf("Got user id %d, expected 7", got)
+		}
+	})
+
+	t.Run("Invalid key", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/system/vote", nil)
+		req.Header.Set(APIKeyHeader, "unknown")
+
+		_, err := auth.Authenticate(httptest.NewRecorder(), req)
+		if !errors.Is(err, vote.ErrNotAllowed) {
+			t.Errorf("Authenticate returned %v, expected ErrNotAllowed", err)
+		}
+
+		resp := httptest.NewRecorder()
+		writeStatusCode(resp, err)
+		if resp.Code != 401 {
+			t.Errorf("Got status %d, expected 401", resp.Code)
+		}
+	})
+
+	t.Run("Anonymous", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/system/vote", nil)
+
+		ctx, err := auth.Authenticate(httptest.NewRecorder(), req)
+		if err != nil {
+			t.Fatalf("Authenticate: %v", err)
+		}
+
+		if got := auth.FromContext(ctx); got != 0 {
+			t.Errorf("Got user id %d, expected 0", got)
+		}
+	})
+}
+
+func TestNewAPIKeyAuthInvalid(t *testing.T) {
+	for _, keyList := range []string{
+		"key1",
+		":5",
+		"key1:abc",
+		"key1:0",
+		"key1:5\nkey1:6",
+	} {
+		if _, err := NewAPIKeyAuth(keyList); err == nil {
+			t.Errorf("NewAPIKeyAuth(%q) returned no error", keyList)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
@@ -46,7 +47,10 @@ func TestRun(t *testing.T) {
 	backend := memory.New()
 	ds := dsmock.NewFlow(nil)
 	service, _, _ := vote.New(ctx, backend, backend, ds, true)
-	httpServer := votehttp.New(environment.ForTests(map[string]string{"VOTE_PORT": "0"}))
+	httpServer, err := votehttp.New(environment.ForTests(map[string]string{"VOTE_PORT": "0"}))
+	if err != nil {
+		t.Fatalf("creating server: %v", err)
+	}
 
 	if err := httpServer.StartListener(); err != nil {
 		t.Fatalf("start listening: %v", err)
@@ -66,12 +70,16 @@ func TestRun(t *testing.T) {
 		for _, url := range []string{
 			"/internal/vote/start",
 			"/internal/vote/stop",
+			"/internal/vote/reopen",
+			"/internal/vote/reload",
 			"/internal/vote/clear",
 			"/internal/vote/clear_all",
 			"/internal/vote/vote_count",
+			"/internal/vote/active_meetings",
 			"/system/vote",
 			"/system/vote/voted",
 			"/system/vote/health",
+			"/system/vote/health/live",
 		} {
 			resp, err := http.Get(fmt.Sprintf("http://%s%s", httpServer.Addr, url))
 			if err != nil {
@@ -84,3 +92,115 @@ func TestRun(t *testing.T) {
 		}
 	})
 }
+
+// blockingBackend is a backend, that blocks votes until release is closed or
+// the context is canceled.
+type blockingBackend struct {
+	*memory.Backend
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingBackend) Vote(ctx context.Context, pollID int, userID int, object []byte) error {
+	close(b.started)
+	select {
+	case <-b.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return b.Backend.Vote(ctx, pollID, userID, object)
+}
+
+func TestRunGracefulShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	backend := &blockingBackend{
+		Backend: memory.New(),
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		pollmethod: Y
+		global_yes: true
+		backend: fast
+		type: pseudoanonymous
+
+	meeting/1/users_enable_vote_weight: false
+
+	user/1:
+		is_present_in_meeting_ids: [1]
+		meeting_user_ids: [10]
+
+	meeting_user/10:
+		user_id: 1
+		group_ids: [1]
+		meeting_id: 1
+	`))
+	service, _, _ := vote.New(ctx, backend, backend, ds, true)
+	backend.Start(ctx, 1)
+
+	httpServer, err := votehttp.New(environment.ForTests(map[string]string{"VOTE_PORT": "0", "VOTE_SHUTDOWN_GRACE": "5s"}))
+	if err != nil {
+		t.Fatalf("creating server: %v", err)
+	}
+
+	if err := httpServer.StartListener(); err != nil {
+		t.Fatalf("start listening: %v", err)
+	}
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- httpServer.Run(ctx, &autherStub{userID: 1}, service)
+	}()
+
+	if err := waitForServer(httpServer.Addr); err != nil {
+		t.Fatalf("waiting for server: %v", err)
+	}
+
+	voteStatus := make(chan int, 1)
+	go func() {
+		resp, err := http.Post(fmt.Sprintf("http://%s/system/vote?id=1", httpServer.Addr), "application/json", strings.NewReader(`{"value":"Y"}`))
+		if err != nil {
+			t.Errorf("sending vote: %v", err)
+			voteStatus <- 0
+			return
+		}
+		resp.Body.Close()
+		voteStatus <- resp.StatusCode
+	}()
+
+	select {
+	case <-backend.started:
+	case status := <-voteStatus:
+		t.Fatalf("Vote returned with status %d before it was saved", status)
+	}
+
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	close(backend.release)
+
+	if status := <-voteStatus; status != 200 {
+		t.Errorf("Vote returned status %d, expected 200", status)
+	}
+
+	if err := <-runErr; err != nil {
+		t.Errorf("Run returned: %v", err)
+	}
+
+	backend.AssertUserHasVoted(t, 1, 1)
+}
+
+func TestNewMaxBodyBytes(t *testing.T) {
+	for _, value := range []string{"0", "-1"} {
+		t.Run(value, func(t *testing.T) {
+			_, err := votehttp.New(environment.ForTests(map[string]string{"VOTE_MAX_BODY_BYTES": value}))
+			if err == nil {
+				t.Errorf("New accepted VOTE_MAX_BODY_BYTES=%s", value)
+			}
+		})
+	}
+}
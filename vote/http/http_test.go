@@ -1,27 +1,56 @@
 package http
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/OpenSlides/openslides-autoupdate-service/pkg/datastore/dskey"
+	"github.com/OpenSlides/openslides-autoupdate-service/pkg/datastore/dsmock"
+	"github.com/OpenSlides/openslides-vote-service/backend/memory"
+	"github.com/OpenSlides/openslides-vote-service/log"
 	"github.com/OpenSlides/openslides-vote-service/vote"
 )
 
 type starterStub struct {
 	id        int
+	entitled  []int
+	forced    string
+	stopAt    time.Time
 	expectErr error
 }
 
+func (c *starterStub) StopAt(ctx context.Context, pollID int, stopTime time.Time) error {
+	c.stopAt = stopTime
+	return nil
+}
+
+func (c *starterStub) ForceBackend(pollID int, name string) error {
+	c.forced = name
+	return nil
+}
+
 func (c *starterStub) Start(ctx context.Context, pollID int) error {
 	c.id = pollID
+	c.entitled = nil
+	return c.expectErr
+}
+
+func (c *starterStub) StartWithEntitled(ctx context.Context, pollID int, userIDs []int) error {
+	c.id = pollID
+	c.entitled = userIDs
 	return c.expectErr
 }
 
@@ -62,6 +91,85 @@ func TestHandleStart(t *testing.T) {
 		}
 	})
 
+	t.Run("Entitled users", func(t *testing.T) {
+		req := httptest.NewRequest("POST", url+"?id=1", strings.NewReader(`{"entitled_user_ids":[3,4]}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
+
+		if resp.Result().StatusCode != 200 {
+			t.Errorf("Got status %s, expected 200 - OK", resp.Result().Status)
+		}
+
+		if !reflect.DeepEqual(starter.entitled, []int{3, 4}) {
+			t.Errorf("Start was called with entitled users %v, expected [3 4]", starter.entitled)
+		}
+	})
+
+	t.Run("Force backend", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1&force_backend=memory", nil))
+
+		if resp.Result().StatusCode != 200 {
+			t.Errorf("Got status %s, expected 200 - OK", resp.Result().Status)
+		}
+
+		if starter.forced != "memory" {
+			t.Errorf("ForceBackend was called with `%s`, expected `memory`", starter.forced)
+		}
+	})
+
+	t.Run("Force backend on failed start", func(t *testing.T) {
+		starter.expectErr = vote.ErrNotExists
+		defer func() { starter.expectErr = nil }()
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1&force_backend=memory", nil))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400 - Bad Request", resp.Result().Status)
+		}
+
+		if starter.forced != "" {
+			t.Errorf("Forced backend is `%s` after the failed start, expected it to be removed", starter.forced)
+		}
+	})
+
+	t.Run("Stop at", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1&stop_at=1700000000", nil))
+
+		if resp.Result().StatusCode != 200 {
+			t.Errorf("Got status %s, expected 200 - OK", resp.Result().Status)
+		}
+
+		if !starter.stopAt.Equal(time.Unix(1700000000, 0)) {
+			t.Errorf("StopAt was called with %s, expected 1700000000", starter.stopAt)
+		}
+	})
+
+	t.Run("Invalid stop at", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1&stop_at=tomorrow", nil))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400", resp.Result().Status)
+		}
+	})
+
+	t.Run("Invalid json body", func(t *testing.T) {
+		req := httptest.NewRequest("POST", url+"?id=1", strings.NewReader(`{"entitled_user_ids":"foo"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400 - Bad Request", resp.Result().Status)
+		}
+	})
+
 	t.Run("Exist error", func(t *testing.T) {
 		starter.expectErr = vote.ErrExists
 
@@ -114,12 +222,157 @@ func TestHandleStart(t *testing.T) {
 	})
 }
 
+func TestHandleStartWrongPollType(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	flow := dsmock.NewFlow(dsmock.YAMLData(`
+	poll:
+		1:
+			meeting_id: 1
+			type: analog
+			state: created
+			backend: fast
+			pollmethod: Y
+		2:
+			meeting_id: 1
+			type: named
+			state: finished
+			backend: fast
+			pollmethod: Y
+		3:
+			meeting_id: 1
+			type: named
+			state: published
+			backend: fast
+			pollmethod: Y
+	`))
+
+	service, _, err := vote.New(ctx, backend, backend, flow, true)
+	if err != nil {
+		t.Fatalf("vote.New: %v", err)
+	}
+
+	mux := handleInternal(handleStart(service))
+
+	for _, tt := range []struct {
+		name       string
+		query      string
+		expectCode int
+		expectType string
+	}{
+		{"analog", "?id=1", 409, vote.TypeWrongPollType},
+		{"finished", "?id=2", 409, vote.TypeWrongPollType},
+		{"published", "?id=3", 409, vote.TypeWrongPollType},
+		{"non-numeric id", "?id=one", 400, vote.TypeInvalid},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			mux.ServeHTTP(resp, httptest.NewRequest("POST", "/vote/start"+tt.query, nil))
+
+			if resp.Result().StatusCode != tt.expectCode {
+				t.Errorf("Got status %s, expected %d", resp.Result().Status, tt.expectCode)
+			}
+
+			var body errorResponse
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+
+			if body.Error != tt.expectType {
+				t.Errorf("Got error `%s`, expected `%s`", body.Error, tt.expectType)
+			}
+		})
+	}
+}
+
+// blockingFlow returns the data for the first request and blocks all other
+// requests until the context is done.
+type blockingFlow struct {
+	data  map[dskey.Key][]byte
+	calls int
+}
+
+func (f *blockingFlow) Get(ctx context.Context, keys ...dskey.Key) (map[dskey.Key][]byte, error) {
+	f.calls++
+	if f.calls > 1 {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	out := make(map[dskey.Key][]byte, len(keys))
+	for _, k := range keys {
+		out[k] = f.data[k]
+	}
+	return out, nil
+}
+
+func (f *blockingFlow) Update(context.Context, func(map[dskey.Key][]byte, error)) {}
+
+func TestHandleStartDatastoreTimeout(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	flow := &blockingFlow{
+		data: dsmock.YAMLData(`
+		poll/1:
+			meeting_id: 1
+			entitled_group_ids: [1]
+			pollmethod: Y
+			global_yes: true
+			backend: fast
+			type: pseudoanonymous
+
+		meeting/1/id: 1
+		`),
+	}
+
+	service, _, err := vote.New(ctx, backend, backend, flow, true)
+	if err != nil {
+		t.Fatalf("vote.New: %v", err)
+	}
+
+	mux := handleInternal(handleStart(service))
+
+	reqCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, httptest.NewRequest("POST", "/vote/start?id=1", nil).WithContext(reqCtx))
+
+	if resp.Result().StatusCode != 503 {
+		t.Errorf("Got status %s, expected 503", resp.Result().Status)
+	}
+
+	if got := resp.Result().Header.Get("Retry-After"); got == "" {
+		t.Errorf("Retry-After header is not set")
+	}
+
+	var body struct {
+		Error string `json:"error"`
+		MSG   string `json:"message"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decoding resp body: %v", err)
+	}
+
+	if body.Error != "temporary" {
+		t.Errorf("Got error `%s`, expected `temporary`", body.Error)
+	}
+
+	if !strings.Contains(body.MSG, "fetching users") {
+		t.Errorf("Got error message `%s`, expected the preload phase `fetching users`", body.MSG)
+	}
+}
+
 type stopperStub struct {
 	id        int
 	expectErr error
 
-	expectedVotes   [][]byte
-	expectedUserIDs []int
+	expectedVotes       [][]byte
+	expectedUserIDs     []int
+	expectedValid       []bool
+	expectedMeta        vote.StopMeta
+	expectedAggregation *vote.Aggregation
 }
 
 func (s *stopperStub) Stop(ctx context.Context, pollID int) (vote.StopResult, error) {
@@ -132,11 +385,32 @@ func (s *stopperStub) Stop(ctx context.Context, pollID int) (vote.StopResult, er
 	return vote.StopResult{
 		Votes:   s.expectedVotes,
 		UserIDs: s.expectedUserIDs,
+		Meta:    s.expectedMeta,
 	}, nil
 }
 
+func (s *stopperStub) StopWithValidity(ctx context.Context, pollID int) (vote.StopResult, error) {
+	result, err := s.Stop(ctx, pollID)
+	if err != nil {
+		return vote.StopResult{}, err
+	}
+
+	result.Valid = s.expectedValid
+	return result, nil
+}
+
+func (s *stopperStub) StopWithAggregation(ctx context.Context, pollID int) (vote.StopResult, error) {
+	result, err := s.Stop(ctx, pollID)
+	if err != nil {
+		return vote.StopResult{}, err
+	}
+
+	result.Aggregation = s.expectedAggregation
+	return result, nil
+}
+
 func TestHandleStop(t *testing.T) {
-	stopper := &stopperStub{}
+	stopper := &stopperStub{expectedMeta: vote.StopMeta{SequentialNumber: 3, ContentObjectID: "motion/5"}}
 
 	url := "/vote/stop"
 	mux := handleInternal(handleStop(stopper))
@@ -164,76 +438,161 @@ func TestHandleStop(t *testing.T) {
 			t.Errorf("Stopper was called with id %d, expected 1", stopper.id)
 		}
 
-		expect := `{"votes":["some values"],"user_ids":[]}`
+		expect := `{"votes":["some values"],"user_ids":[],"meta":{"sequential_number":3,"content_object_id":"motion/5"}}`
 		if trimed := strings.TrimSpace(resp.Body.String()); trimed != expect {
 			t.Errorf("Got body:\n`%s`, expected:\n`%s`", trimed, expect)
 		}
 	})
 
-	t.Run("Not Exist error", func(t *testing.T) {
-		stopper.expectErr = vote.ErrNotExists
+	t.Run("ETag", func(t *testing.T) {
+		stopper.expectedVotes = [][]byte{[]byte(`"first"`), []byte(`"second"`)}
 
 		resp := httptest.NewRecorder()
 		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1", nil))
 
-		if resp.Result().StatusCode != 400 {
-			t.Errorf("Got status %s, expected 400", resp.Result().Status)
+		etag := resp.Result().Header.Get("ETag")
+		if etag == "" {
+			t.Fatalf("Response has no ETag")
 		}
 
-		var body struct {
-			Error string `json:"error"`
-		}
+		// Same data in an other order.
+		stopper.expectedVotes = [][]byte{[]byte(`"second"`), []byte(`"first"`)}
 
-		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
-			t.Fatalf("decoding resp body: %v", err)
-		}
+		resp = httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1", nil))
 
-		if body.Error != "not-exist" {
-			t.Errorf("Got error `%s`, expected `not-exist`", body.Error)
+		if got := resp.Result().Header.Get("ETag"); got != etag {
+			t.Errorf("Got ETag %s on second request, expected %s", got, etag)
 		}
-	})
-}
-
-type clearerStub struct {
-	id        int
-	expectErr error
-}
 
-func (c *clearerStub) Clear(ctx context.Context, pollID int) error {
-	c.id = pollID
-	return c.expectErr
-}
+		req := httptest.NewRequest("POST", url+"?id=1", nil)
+		req.Header.Set("If-None-Match", etag)
+		resp = httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
 
-func TestHandleClear(t *testing.T) {
-	clearer := &clearerStub{}
+		if resp.Result().StatusCode != 304 {
+			t.Errorf("Got status %s with matching If-None-Match, expected 304", resp.Result().Status)
+		}
 
-	url := "/vote/clear"
-	mux := handleInternal(handleClear(clearer))
+		if resp.Body.Len() != 0 {
+			t.Errorf("Got body `%s`, expected no body", resp.Body.String())
+		}
 
-	t.Run("No id", func(t *testing.T) {
-		resp := httptest.NewRecorder()
-		mux.ServeHTTP(resp, httptest.NewRequest("POST", url, nil))
+		stopper.expectedVotes = [][]byte{[]byte(`"other"`)}
+		resp = httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
 
-		if resp.Result().StatusCode != 400 {
-			t.Errorf("Got status %s, expected 400 - Bad Request", resp.Result().Status)
+		if resp.Result().StatusCode != 200 {
+			t.Errorf("Got status %s with changed data, expected 200", resp.Result().Status)
 		}
 	})
 
-	t.Run("Valid", func(t *testing.T) {
+	t.Run("With validity", func(t *testing.T) {
+		stopper.expectedVotes = [][]byte{[]byte(`{"value":"Y"}`), []byte(`{"value":"X"}`)}
+		stopper.expectedValid = []bool{true, false}
+
 		resp := httptest.NewRecorder()
-		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1", nil))
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1&with_validity=1", nil))
 
 		if resp.Result().StatusCode != 200 {
 			t.Errorf("Got status %s, expected 200 - OK", resp.Result().Status)
 		}
 
-		if clearer.id != 1 {
-			t.Errorf("Clearer was called with id %d, expected 1", clearer.id)
+		if etag := resp.Result().Header.Get("ETag"); etag != "" {
+			t.Errorf("Got ETag %s, expected none", etag)
+		}
+
+		expect := `{"votes":[{"valid":true,"value":"Y"},{"valid":false,"value":"X"}],"user_ids":[],"meta":{"sequential_number":3,"content_object_id":"motion/5"}}`
+		if trimed := strings.TrimSpace(resp.Body.String()); trimed != expect {
+			t.Errorf("Got body:\n`%s`, expected:\n`%s`", trimed, expect)
+		}
+	})
+
+	t.Run("With aggregation", func(t *testing.T) {
+		stopper.expectedVotes = [][]byte{[]byte(`{"value":{"1":"Y"},"weight":"2.000000"}`)}
+		stopper.expectedAggregation = &vote.Aggregation{
+			Options: map[int]map[string]string{1: {"Y": "2.000000"}},
+			Global:  map[string]string{},
+		}
+
+		for _, tt := range []struct {
+			query  string
+			expect string
+		}{
+			{
+				"&aggregate=1",
+				`{"votes":[{"value":{"1":"Y"},"weight":"2.000000"}],"user_ids":[],"meta":{"sequential_number":3,"content_object_id":"motion/5"},"aggregation":{"options":{"1":{"Y":"2.000000"}},"global":{},"invalid":0}}`,
+			},
+			{
+				"&aggregate=1&raw=0",
+				`{"user_ids":[],"meta":{"sequential_number":3,"content_object_id":"motion/5"},"aggregation":{"options":{"1":{"Y":"2.000000"}},"global":{},"invalid":0}}`,
+			},
+		} {
+			resp := httptest.NewRecorder()
+			mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1"+tt.query, nil))
+
+			if resp.Result().StatusCode != 200 {
+				t.Errorf("%s: Got status %s, expected 200 - OK", tt.query, resp.Result().Status)
+			}
+
+			if trimed := strings.TrimSpace(resp.Body.String()); trimed != tt.expect {
+				t.Errorf("%s: Got body:\n`%s`, expected:\n`%s`", tt.query, trimed, tt.expect)
+			}
+		}
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		stopper.expectedVotes = [][]byte{
+			[]byte(`{"vote_user_id":5,"value":"Y","weight":"1.000000"}`),
+			[]byte(`{"value":{"1":"N"},"weight":"2.000000"}`),
+			[]byte(`broken`),
+		}
+
+		for _, req := range []*http.Request{
+			httptest.NewRequest("POST", url+"?id=1&format=csv", nil),
+			func() *http.Request {
+				r := httptest.NewRequest("POST", url+"?id=1", nil)
+				r.Header.Set("Accept", "text/csv")
+				return r
+			}(),
+		} {
+			resp := httptest.NewRecorder()
+			mux.ServeHTTP(resp, req)
+
+			if resp.Result().StatusCode != 200 {
+				t.Errorf("Got status %s, expected 200 - OK", resp.Result().Status)
+			}
+
+			if ct := resp.Result().Header.Get("Content-Type"); ct != "text/csv" {
+				t.Errorf("Got content type %s, expected text/csv", ct)
+			}
+
+			records, err := csv.NewReader(resp.Body).ReadAll()
+			if err != nil {
+				t.Fatalf("reading csv: %v", err)
+			}
+
+			if len(records) != 4 {
+				t.Fatalf("Got %d rows, expected a header and 3 rows", len(records))
+			}
+
+			expect := [][]string{
+				{"vote_user_id", "value", "weight", "error"},
+				{"5", "Y", "1.000000", ""},
+				{"", `{"1":"N"}`, "2.000000", ""},
+			}
+			if !reflect.DeepEqual(records[:3], expect) {
+				t.Errorf("Got rows %v, expected %v", records[:3], expect)
+			}
+
+			if records[3][3] == "" {
+				t.Errorf("Got no error for the broken vote object")
+			}
 		}
 	})
 
 	t.Run("Not Exist error", func(t *testing.T) {
-		clearer.expectErr = vote.ErrNotExists
+		stopper.expectErr = vote.ErrNotExists
 
 		resp := httptest.NewRecorder()
 		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1", nil))
@@ -256,121 +615,1579 @@ func TestHandleClear(t *testing.T) {
 	})
 }
 
-type clearAllerStub struct {
-	expectErr error
-}
-
-func (c *clearAllerStub) ClearAll(ctx context.Context) error {
-	return c.expectErr
-}
+func TestHandleStopGzip(t *testing.T) {
+	stopper := &stopperStub{}
+	url := "/vote/stop?id=1"
+	mux := handleInternal(withGzip(compressMinSize, handleStop(stopper)))
 
-func TestHandleClearAll(t *testing.T) {
-	clearAller := &clearAllerStub{}
+	t.Run("Big response", func(t *testing.T) {
+		votes := make([][]byte, 100)
+		userIDs := make([]int, 100)
+		for i := range votes {
+			votes[i] = []byte(`{"value":"Y","weight":"1.000000"}`)
+			userIDs[i] = i + 1
+		}
+		stopper.expectedVotes = votes
+		stopper.expectedUserIDs = userIDs
 
-	url := "/vote/clear_all"
-	mux := handleInternal(handleClearAll(clearAller))
+		expect := httptest.NewRecorder()
+		handleInternal(handleStop(stopper)).ServeHTTP(expect, httptest.NewRequest("POST", url, nil))
 
-	t.Run("Valid", func(t *testing.T) {
+		req := httptest.NewRequest("POST", url, nil)
+		req.Header.Set("Accept-Encoding", "gzip, deflate")
 		resp := httptest.NewRecorder()
-		mux.ServeHTTP(resp, httptest.NewRequest("POST", url, nil))
+		mux.ServeHTTP(resp, req)
 
 		if resp.Result().StatusCode != 200 {
 			t.Errorf("Got status %s, expected 200 - OK", resp.Result().Status)
 		}
+
+		if got := resp.Result().Header.Get("Content-Encoding"); got != "gzip" {
+			t.Fatalf("Got Content-Encoding `%s`, expected gzip", got)
+		}
+
+		reader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			t.Fatalf("Body is not gzip: %v", err)
+		}
+
+		body, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("Decompressing body: %v", err)
+		}
+
+		if string(body) != expect.Body.String() {
+			t.Errorf("Got body:\n`%s`, expected:\n`%s`", body, expect.Body.String())
+		}
 	})
 
-	t.Run("Not Exist error", func(t *testing.T) {
-		clearAller.expectErr = vote.ErrNotExists
+	t.Run("Small response", func(t *testing.T) {
+		stopper.expectedVotes = [][]byte{[]byte(`"Y"`)}
+		stopper.expectedUserIDs = []int{1}
 
+		req := httptest.NewRequest("POST", url, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
 		resp := httptest.NewRecorder()
-		mux.ServeHTTP(resp, httptest.NewRequest("POST", url, nil))
+		mux.ServeHTTP(resp, req)
 
-		if resp.Result().StatusCode != 400 {
-			t.Errorf("Got status %s, expected 400", resp.Result().Status)
+		if got := resp.Result().Header.Get("Content-Encoding"); got != "" {
+			t.Errorf("Got Content-Encoding `%s`, expected none", got)
 		}
 
-		var body struct {
-			Error string `json:"error"`
+		expect := `{"votes":["Y"],"user_ids":[1],"meta":{"sequential_number":0,"content_object_id":""}}`
+		if got := strings.TrimSpace(resp.Body.String()); got != expect {
+			t.Errorf("Got body:\n`%s`, expected:\n`%s`", got, expect)
 		}
+	})
 
-		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
-			t.Fatalf("decoding resp body: %v", err)
+	t.Run("Error after the response was sent", func(t *testing.T) {
+		data := strings.Repeat("x", compressMinSize)
+		handler := withGzip(compressMinSize, func(w http.ResponseWriter, r *http.Request) error {
+			fmt.Fprint(w, data)
+			return fmt.Errorf("some error")
+		})
+
+		req := httptest.NewRequest("POST", url, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		resp := httptest.NewRecorder()
+		if err := handler(resp, req); err != nil {
+			t.Errorf("Handler returned %v, expected no error", err)
 		}
 
-		if body.Error != "not-exist" {
-			t.Errorf("Got error `%s`, expected `not-exist`", body.Error)
+		reader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			t.Fatalf("Body is not gzip: %v", err)
+		}
+
+		body, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("Decompressing body: %v", err)
+		}
+
+		if string(body) != data {
+			t.Errorf("Got body with %d bytes, expected only the sent %d bytes", len(body), len(data))
+		}
+	})
+
+	t.Run("Not accepted", func(t *testing.T) {
+		req := httptest.NewRequest("POST", url, nil)
+		req.Header.Set("Accept-Encoding", "gzip;q=0")
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
+
+		if got := resp.Result().Header.Get("Content-Encoding"); got != "" {
+			t.Errorf("Got Content-Encoding `%s`, expected none", got)
 		}
 	})
 }
 
-type voterStub struct {
-	id        int
-	user      int
-	body      string
-	expectErr error
+type simulatorStub struct {
+	pollID int
+	userID int
+	body   string
 }
 
-func (v *voterStub) Vote(ctx context.Context, pollID, requestUser int, r io.Reader) error {
-	v.id = pollID
-	v.user = requestUser
+func (s *simulatorStub) Simulate(ctx context.Context, pollID, requestUser int, r io.Reader) (vote.SimulateResult, error) {
+	s.pollID = pollID
+	s.userID = requestUser
 
 	body, err := io.ReadAll(r)
 	if err != nil {
-		return err
+		return vote.SimulateResult{}, err
 	}
-	v.body = string(body)
-	return v.expectErr
-}
-
-type AuthError struct{}
+	s.body = string(body)
 
-func (AuthError) Error() string {
-	return `{"error":"auth","message":"auth error"}`
+	return vote.SimulateResult{VoteUserID: requestUser, Weight: "1.000000", Duration: 1500 * time.Microsecond}, nil
 }
 
-func (AuthError) Type() string {
-	return "auth"
-}
+func TestHandleSimulate(t *testing.T) {
+	simulator := &simulatorStub{}
 
-type autherStub struct {
-	userID  int
-	authErr bool
-}
+	url := "/vote/simulate"
+	mux := handleInternal(handleSimulate(simulator))
 
-func (a *autherStub) Authenticate(w http.ResponseWriter, r *http.Request) (context.Context, error) {
-	if a.authErr {
-		return nil, AuthError{}
-	}
-	return r.Context(), nil
-}
+	t.Run("No user id", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1", strings.NewReader(`{"value":"Y"}`)))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400 - Bad Request", resp.Result().Status)
+		}
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1&user_id=5", strings.NewReader(`{"value":"Y"}`)))
+
+		if resp.Result().StatusCode != 200 {
+			t.Errorf("Got status %s, expected 200 - OK", resp.Result().Status)
+		}
+
+		if simulator.pollID != 1 || simulator.userID != 5 || simulator.body != `{"value":"Y"}` {
+			t.Errorf("Simulator was called with poll %d, user %d and body %s", simulator.pollID, simulator.userID, simulator.body)
+		}
+
+		expect := `{"vote_user_id":5,"weight":"1.000000","duration_ms":1.5}`
+		if got := strings.TrimSpace(resp.Body.String()); got != expect {
+			t.Errorf("Got body `%s`, expected `%s`", got, expect)
+		}
+	})
+}
+
+type reopenerStub struct {
+	id        int
+	expectErr error
+}
+
+func (r *reopenerStub) Reopen(ctx context.Context, pollID int) error {
+	r.id = pollID
+	return r.expectErr
+}
+
+func TestHandleReopen(t *testing.T) {
+	reopener := &reopenerStub{}
+
+	url := "/vote/reopen"
+	mux := handleInternal(handleReopen(reopener))
+
+	t.Run("No id", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url, nil))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400 - Bad Request", resp.Result().Status)
+		}
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1", nil))
+
+		if resp.Result().StatusCode != 200 {
+			t.Errorf("Got status %s, expected 200 - OK", resp.Result().Status)
+		}
+
+		if reopener.id != 1 {
+			t.Errorf("Reopener was called with id %d, expected 1", reopener.id)
+		}
+	})
+
+	t.Run("Not Exist error", func(t *testing.T) {
+		reopener.expectErr = vote.ErrNotExists
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1", nil))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400", resp.Result().Status)
+		}
+	})
+}
+
+type reloaderStub struct {
+	id        int
+	expectErr error
+}
+
+func (r *reloaderStub) ReloadPoll(ctx context.Context, pollID int) error {
+	r.id = pollID
+	return r.expectErr
+}
+
+func TestHandleReload(t *testing.T) {
+	reloader := &reloaderStub{}
+
+	url := "/vote/reload"
+	mux := handleInternal(handleReload(reloader))
+
+	t.Run("No id", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url, nil))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400 - Bad Request", resp.Result().Status)
+		}
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1", nil))
+
+		if resp.Result().StatusCode != 200 {
+			t.Errorf("Got status %s, expected 200 - OK", resp.Result().Status)
+		}
+
+		if reloader.id != 1 {
+			t.Errorf("Reloader was called with id %d, expected 1", reloader.id)
+		}
+	})
+
+	t.Run("Not allowed error", func(t *testing.T) {
+		reloader.expectErr = vote.ErrNotAllowed
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1", nil))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400", resp.Result().Status)
+		}
+	})
+}
+
+type retracterStub struct {
+	id        int
+	userID    int
+	expectErr error
+}
+
+func (r *retracterStub) RetractVote(ctx context.Context, pollID, userID int) error {
+	r.id = pollID
+	r.userID = userID
+	return r.expectErr
+}
+
+func TestHandleRetract(t *testing.T) {
+	retracter := &retracterStub{}
+
+	url := "/vote/retract"
+	mux := handleInternal(handleRetract(retracter))
+
+	t.Run("No id", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?user_id=5", nil))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400 - Bad Request", resp.Result().Status)
+		}
+	})
+
+	t.Run("No user id", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1", nil))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400 - Bad Request", resp.Result().Status)
+		}
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1&user_id=5", nil))
+
+		if resp.Result().StatusCode != 200 {
+			t.Errorf("Got status %s, expected 200 - OK", resp.Result().Status)
+		}
+
+		if retracter.id != 1 || retracter.userID != 5 {
+			t.Errorf("Retracter was called with poll %d and user %d, expected poll 1 and user 5", retracter.id, retracter.userID)
+		}
+	})
+
+	t.Run("Not Exist error", func(t *testing.T) {
+		retracter.expectErr = vote.ErrNotExists
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1&user_id=5", nil))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400", resp.Result().Status)
+		}
+	})
+}
+
+type resultHasherStub struct {
+	id        int
+	hash      string
+	expectErr error
+}
+
+func (r *resultHasherStub) ResultHash(ctx context.Context, pollID int) (string, error) {
+	r.id = pollID
+	return r.hash, r.expectErr
+}
+
+func TestHandleResultHash(t *testing.T) {
+	hasher := &resultHasherStub{hash: "abc"}
+
+	url := "/vote/result_hash"
+	mux := handleInternal(handleResultHash(hasher))
+
+	t.Run("No id", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url, nil))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400 - Bad Request", resp.Result().Status)
+		}
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1", nil))
+
+		if resp.Result().StatusCode != 200 {
+			t.Errorf("Got status %s, expected 200 - OK", resp.Result().Status)
+		}
+
+		if hasher.id != 1 {
+			t.Errorf("ResultHash was called with id %d, expected 1", hasher.id)
+		}
+
+		expect := `{"hash":"abc"}` + "\n"
+		if got := resp.Body.String(); got != expect {
+			t.Errorf("Got body `%s`, expected `%s`", got, expect)
+		}
+	})
+
+	t.Run("Not allowed error", func(t *testing.T) {
+		hasher.expectErr = vote.ErrNotAllowed
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1", nil))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400", resp.Result().Status)
+		}
+	})
+}
+
+type tallierStub struct {
+	pollID      int
+	maxFraction float64
+	expect      vote.Tally
+}
+
+func (s *tallierStub) TallyWithCaps(ctx context.Context, pollID int, maxFraction float64) (vote.Tally, error) {
+	s.pollID = pollID
+	s.maxFraction = maxFraction
+	return s.expect, nil
+}
+
+func TestHandleTally(t *testing.T) {
+	tally := &tallierStub{expect: vote.Tally{
+		TotalWeight: "2.000000",
+		Options:     map[int]vote.OptionTally{1: {Weight: "2.000000", Fraction: 1, ExceedsCap: true}},
+	}}
+	mux := handleInternal(handleTally(tally))
+
+	t.Run("Valid", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("GET", "/vote/tally?id=1&max_fraction=0.4", nil))
+
+		if resp.Result().StatusCode != 200 {
+			t.Errorf("Got status %s, expected 200", resp.Result().Status)
+		}
+
+		if tally.pollID != 1 || tally.maxFraction != 0.4 {
+			t.Errorf("TallyWithCaps was called with %d and %v, expected 1 and 0.4", tally.pollID, tally.maxFraction)
+		}
+
+		expect := `{"total_weight":"2.000000","options":{"1":{"weight":"2.000000","fraction":1,"exceeds_cap":true}}}`
+		if got := strings.TrimSpace(resp.Body.String()); got != expect {
+			t.Errorf("Got body `%s`, expected `%s`", got, expect)
+		}
+	})
+
+	t.Run("Without max_fraction", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("GET", "/vote/tally?id=1", nil))
+
+		if tally.maxFraction != 1 {
+			t.Errorf("TallyWithCaps was called with max fraction %v, expected 1", tally.maxFraction)
+		}
+	})
+
+	t.Run("Invalid max_fraction", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("GET", "/vote/tally?id=1&max_fraction=foo", nil))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400", resp.Result().Status)
+		}
+	})
+}
+
+type maintainerStub struct {
+	enabled bool
+	message string
+}
+
+func (m *maintainerStub) SetMaintenance(enabled bool, message string) {
+	m.enabled = enabled
+	m.message = message
+}
+
+func (m *maintainerStub) Maintenance() (bool, string) {
+	return m.enabled, m.message
+}
+
+func TestHandleMaintenance(t *testing.T) {
+	maintainer := &maintainerStub{}
+
+	url := "/internal/vote/maintenance"
+	mux := handleInternal(handleMaintenance(maintainer))
+
+	for _, tt := range []struct {
+		name       string
+		query      string
+		expectCode int
+		expectBody string
+	}{
+		{"state", "", 200, `{"maintenance":false}`},
+		{"enable", "?on=true&message=back+soon", 200, `{"maintenance":true,"message":"back soon"}`},
+		{"state enabled", "", 200, `{"maintenance":true,"message":"back soon"}`},
+		{"invalid", "?on=foo", 400, ""},
+		{"disable", "?on=false", 200, `{"maintenance":false}`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			mux.ServeHTTP(resp, httptest.NewRequest("POST", url+tt.query, nil))
+
+			if resp.Result().StatusCode != tt.expectCode {
+				t.Errorf("Got status %s, expected %d", resp.Result().Status, tt.expectCode)
+			}
+
+			if tt.expectBody != "" && strings.TrimSpace(resp.Body.String()) != tt.expectBody {
+				t.Errorf("Got body `%s`, expected `%s`", strings.TrimSpace(resp.Body.String()), tt.expectBody)
+			}
+		})
+	}
+}
+
+type clearerStub struct {
+	id        int
+	expectErr error
+}
+
+func (c *clearerStub) Clear(ctx context.Context, pollID int) error {
+	c.id = pollID
+	return c.expectErr
+}
+
+func TestHandleClear(t *testing.T) {
+	clearer := &clearerStub{}
+
+	url := "/vote/clear"
+	mux := handleInternal(handleClear(clearer))
+
+	t.Run("No id", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url, nil))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400 - Bad Request", resp.Result().Status)
+		}
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1", nil))
+
+		if resp.Result().StatusCode != 200 {
+			t.Errorf("Got status %s, expected 200 - OK", resp.Result().Status)
+		}
+
+		if clearer.id != 1 {
+			t.Errorf("Clearer was called with id %d, expected 1", clearer.id)
+		}
+	})
+
+	t.Run("Not Exist error", func(t *testing.T) {
+		clearer.expectErr = vote.ErrNotExists
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1", nil))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400", resp.Result().Status)
+		}
+
+		var body struct {
+			Error string `json:"error"`
+		}
+
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("decoding resp body: %v", err)
+		}
+
+		if body.Error != "not-exist" {
+			t.Errorf("Got error `%s`, expected `not-exist`", body.Error)
+		}
+	})
+}
+
+type meetingClearerStub struct {
+	meetingID int
+	expectErr error
+}
+
+func (c *meetingClearerStub) ClearMeeting(ctx context.Context, meetingID int) error {
+	c.meetingID = meetingID
+	return c.expectErr
+}
+
+func TestHandleClearMeeting(t *testing.T) {
+	clearer := &meetingClearerStub{}
+
+	url := "/vote/clear_meeting"
+	mux := handleInternal(handleClearMeeting(clearer))
+
+	t.Run("No meeting id", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url, nil))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400 - Bad Request", resp.Result().Status)
+		}
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?meeting_id=5", nil))
+
+		if resp.Result().StatusCode != 200 {
+			t.Errorf("Got status %s, expected 200 - OK", resp.Result().Status)
+		}
+
+		if clearer.meetingID != 5 {
+			t.Errorf("ClearMeeting was called with meeting %d, expected 5", clearer.meetingID)
+		}
+	})
+
+	t.Run("Not Exist error", func(t *testing.T) {
+		clearer.expectErr = vote.ErrNotExists
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?meeting_id=5", nil))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400", resp.Result().Status)
+		}
+	})
+}
+
+type clearManyerStub struct {
+	pollIDs   []int
+	expectErr error
+}
+
+func (c *clearManyerStub) ClearMany(ctx context.Context, pollIDs []int) error {
+	c.pollIDs = pollIDs
+	return c.expectErr
+}
+
+func TestHandleClearBatch(t *testing.T) {
+	clearManyer := &clearManyerStub{}
+
+	url := "/vote/clear_batch"
+	mux := handleInternal(handleClearBatch(clearManyer))
+
+	t.Run("Invalid ids", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?ids=1,foo", nil))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400", resp.Result().Status)
+		}
+	})
+
+	t.Run("All cleared", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?ids=1,2,3", nil))
+
+		if resp.Result().StatusCode != 200 {
+			t.Errorf("Got status %s, expected 200", resp.Result().Status)
+		}
+
+		if !reflect.DeepEqual(clearManyer.pollIDs, []int{1, 2, 3}) {
+			t.Errorf("ClearMany was called with %v, expected [1 2 3]", clearManyer.pollIDs)
+		}
+
+		expect := `{"cleared":[1,2,3],"failed":{}}`
+		if got := strings.TrimSpace(resp.Body.String()); got != expect {
+			t.Errorf("Got body `%s`, expected `%s`", got, expect)
+		}
+	})
+
+	t.Run("Partial failure", func(t *testing.T) {
+		clearManyer.expectErr = vote.ClearManyError{2: errors.New("broken")}
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?ids=1,2,3", nil))
+
+		if resp.Result().StatusCode != 200 {
+			t.Errorf("Got status %s, expected 200", resp.Result().Status)
+		}
+
+		expect := `{"cleared":[1,3],"failed":{"2":"broken"}}`
+		if got := strings.TrimSpace(resp.Body.String()); got != expect {
+			t.Errorf("Got body `%s`, expected `%s`", got, expect)
+		}
+	})
+}
+
+type startManyerStub struct {
+	pollIDs   []int
+	expectErr error
+}
+
+func (s *startManyerStub) StartMany(ctx context.Context, pollIDs []int) error {
+	s.pollIDs = pollIDs
+	return s.expectErr
+}
+
+func TestHandleStartBatch(t *testing.T) {
+	startManyer := &startManyerStub{}
+
+	url := "/vote/start_batch"
+	mux := handleInternal(handleStartBatch(startManyer))
+
+	t.Run("Invalid ids", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?ids=1,foo", nil))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400", resp.Result().Status)
+		}
+	})
+
+	t.Run("All started", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?ids=1,2,3", nil))
+
+		if resp.Result().StatusCode != 200 {
+			t.Errorf("Got status %s, expected 200", resp.Result().Status)
+		}
+
+		if !reflect.DeepEqual(startManyer.pollIDs, []int{1, 2, 3}) {
+			t.Errorf("StartMany was called with %v, expected [1 2 3]", startManyer.pollIDs)
+		}
+
+		expect := `{"started":[1,2,3],"failed":{}}`
+		if got := strings.TrimSpace(resp.Body.String()); got != expect {
+			t.Errorf("Got body `%s`, expected `%s`", got, expect)
+		}
+	})
+
+	t.Run("Partial failure", func(t *testing.T) {
+		startManyer.expectErr = vote.StartManyError{2: errors.New("analog")}
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?ids=1,2,3", nil))
+
+		if resp.Result().StatusCode != 200 {
+			t.Errorf("Got status %s, expected 200", resp.Result().Status)
+		}
+
+		expect := `{"started":[1,3],"failed":{"2":"analog"}}`
+		if got := strings.TrimSpace(resp.Body.String()); got != expect {
+			t.Errorf("Got body `%s`, expected `%s`", got, expect)
+		}
+	})
+
+	t.Run("Other error", func(t *testing.T) {
+		startManyer.expectErr = errors.New("broken")
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?ids=1,2,3", nil))
+
+		if resp.Result().StatusCode != 500 {
+			t.Errorf("Got status %s, expected 500", resp.Result().Status)
+		}
+	})
+}
+
+type clearAllerStub struct {
+	cacheReset bool
+	expectErr  error
+}
+
+func (c *clearAllerStub) ClearAll(ctx context.Context) (bool, error) {
+	return c.cacheReset, c.expectErr
+}
+
+func TestHandleClearAll(t *testing.T) {
+	clearAller := &clearAllerStub{cacheReset: true}
+
+	url := "/vote/clear_all"
+	mux := handleInternal(handleClearAll(clearAller))
+
+	t.Run("Valid", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url, nil))
+
+		if resp.Result().StatusCode != 200 {
+			t.Errorf("Got status %s, expected 200 - OK", resp.Result().Status)
+		}
+
+		expect := `{"cache_reset":true}`
+		if got := strings.TrimSpace(resp.Body.String()); got != expect {
+			t.Errorf("Got body `%s`, expected `%s`", got, expect)
+		}
+	})
+
+	t.Run("Not Exist error", func(t *testing.T) {
+		clearAller.expectErr = vote.ErrNotExists
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url, nil))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400", resp.Result().Status)
+		}
+
+		var body struct {
+			Error string `json:"error"`
+		}
+
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("decoding resp body: %v", err)
+		}
+
+		if body.Error != "not-exist" {
+			t.Errorf("Got error `%s`, expected `not-exist`", body.Error)
+		}
+	})
+}
+
+type refresherStub struct {
+	called    bool
+	expectErr error
+}
+
+func (r *refresherStub) RefreshVoted(ctx context.Context) error {
+	r.called = true
+	return r.expectErr
+}
+
+func TestHandleRefresh(t *testing.T) {
+	refresher := &refresherStub{}
+	mux := handleInternal(handleRefresh(refresher))
+
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, httptest.NewRequest("POST", "/vote/refresh", nil))
+
+	if resp.Result().StatusCode != 200 {
+		t.Errorf("Got status %s, expected 200 - OK", resp.Result().Status)
+	}
+
+	if !refresher.called {
+		t.Errorf("RefreshVoted was not called")
+	}
+}
+
+type exporterStub struct {
+	data string
+}
+
+func (e *exporterStub) Export(ctx context.Context, w io.Writer) error {
+	_, err := io.WriteString(w, e.data)
+	return err
+}
+
+func (e *exporterStub) Import(ctx context.Context, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	e.data = string(data)
+	return nil
+}
+
+func TestHandleExportImport(t *testing.T) {
+	exporter := &exporterStub{}
+	data := `{"poll_id":1,"backend":"fast","stopped":false,"user_ids":[1],"objects":["InZvdGUi"]}` + "\n"
+
+	resp := httptest.NewRecorder()
+	handleInternal(handleImport(exporter)).ServeHTTP(resp, httptest.NewRequest("POST", "/vote/import", strings.NewReader(data)))
+
+	if resp.Result().StatusCode != 200 {
+		t.Errorf("Import: Got status %s, expected 200 - OK", resp.Result().Status)
+	}
+
+	resp = httptest.NewRecorder()
+	handleInternal(handleExport(exporter)).ServeHTTP(resp, httptest.NewRequest("GET", "/vote/export", nil))
+
+	if resp.Result().StatusCode != 200 {
+		t.Errorf("Export: Got status %s, expected 200 - OK", resp.Result().Status)
+	}
+
+	if got := resp.Body.String(); got != data {
+		t.Errorf("Export returned `%s`, expected `%s`", got, data)
+	}
+}
+
+type voterStub struct {
+	id        int
+	user      int
+	body      string
+	expectErr error
+}
+
+func (v *voterStub) VoteWithResult(ctx context.Context, pollID, requestUser int, r io.Reader) (vote.VoteResult, error) {
+	v.id = pollID
+	v.user = requestUser
+
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return vote.VoteResult{}, err
+	}
+	v.body = string(body)
+
+	if v.expectErr != nil {
+		return vote.VoteResult{}, v.expectErr
+	}
+
+	return vote.VoteResult{VoteUserID: requestUser, Weight: "1.000000", AlreadyVotedCount: 1}, nil
+}
+
+func TestHandleVoteAck(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	backend.Start(ctx, 1)
+	flow := dsmock.NewFlow(dsmock.YAMLData(`
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		pollmethod: Y
+		global_yes: true
+		backend: fast
+		type: pseudoanonymous
+
+	meeting/1/users_enable_vote_weight: false
+
+	user:
+		1:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [10]
+		2:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [20]
+
+	meeting_user:
+		10:
+			user_id: 1
+			group_ids: [1]
+			meeting_id: 1
+		20:
+			user_id: 2
+			group_ids: [1]
+			meeting_id: 1
+	`))
+
+	service, _, err := vote.New(ctx, backend, backend, flow, true)
+	if err != nil {
+		t.Fatalf("vote.New: %v", err)
+	}
+
+	sendVote := func(t *testing.T, userID int, url string) map[string]json.RawMessage {
+		t.Helper()
+
+		mux := handleExternal(handleVote(service, &autherStub{userID: userID}))
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url, strings.NewReader(`{"value":"Y"}`)))
+
+		if resp.Result().StatusCode != 200 {
+			t.Fatalf("Got status %s, expected 200: %s", resp.Result().Status, resp.Body.String())
+		}
+
+		var body map[string]json.RawMessage
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		return body
+	}
+
+	t.Run("with ack", func(t *testing.T) {
+		body := sendVote(t, 1, "/system/vote?id=1&ack=true")
+
+		var ballotID string
+		if err := json.Unmarshal(body["ballot_id"], &ballotID); err != nil || ballotID == "" {
+			t.Errorf("Got no ballot_id in response: %v", body)
+		}
+	})
+
+	t.Run("without ack", func(t *testing.T) {
+		body := sendVote(t, 2, "/system/vote?id=1")
+
+		if _, ok := body["ballot_id"]; ok {
+			t.Errorf("Got ballot_id without ack: %v", body)
+		}
+	})
+}
+
+func TestHandleVoteDelegated(t *testing.T) {
+	for _, pollType := range []string{"named", "pseudoanonymous"} {
+		t.Run(pollType, func(t *testing.T) {
+			ctx := context.Background()
+			backend := memory.New()
+			backend.Start(ctx, 1)
+			flow := dsmock.NewFlow(dsmock.YAMLData(fmt.Sprintf(`
+			poll/1:
+				meeting_id: 1
+				entitled_group_ids: [1]
+				pollmethod: Y
+				global_yes: true
+				backend: fast
+				type: %s
+
+			meeting/1:
+				users_enable_vote_weight: false
+				users_enable_vote_delegations: true
+
+			user:
+				1:
+					is_present_in_meeting_ids: [1]
+					meeting_user_ids: [10]
+				2:
+					meeting_user_ids: [20]
+
+			meeting_user:
+				10:
+					user_id: 1
+					group_ids: [1]
+					vote_delegations_from_ids: [20]
+					meeting_id: 1
+				20:
+					user_id: 2
+					group_ids: [1]
+					vote_delegated_to_id: 10
+					meeting_id: 1
+			`, pollType)))
+
+			service, _, err := vote.New(ctx, backend, backend, flow, true)
+			if err != nil {
+				t.Fatalf("vote.New: %v", err)
+			}
+
+			mux := handleExternal(handleVote(service, &autherStub{userID: 1}))
+
+			for _, tt := range []struct {
+				name       string
+				body       string
+				expectUser int
+			}{
+				{"for the principal", `{"user_id":2,"value":"Y"}`, 2},
+				{"without user_id", `{"value":"Y"}`, 1},
+			} {
+				t.Run(tt.name, func(t *testing.T) {
+					resp := httptest.NewRecorder()
+					mux.ServeHTTP(resp, httptest.NewRequest("POST", "/system/vote?id=1", strings.NewReader(tt.body)))
+
+					if resp.Result().StatusCode != 200 {
+						t.Fatalf("Got status %s, expected 200: %s", resp.Result().Status, resp.Body.String())
+					}
+
+					var body struct {
+						VoteUserID int `json:"vote_user_id"`
+					}
+					if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+						t.Fatalf("decoding response: %v", err)
+					}
+
+					if body.VoteUserID != tt.expectUser {
+						t.Errorf("Got vote_user_id %d, expected %d", body.VoteUserID, tt.expectUser)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestHandleVoteTrace(t *testing.T) {
+	voter := &voterStub{}
+	auther := &autherStub{userID: 5}
+
+	url := "/system/vote?id=1&trace=true"
+	mux := handleExternal(handleVote(voter, auther))
+
+	sendVote := func(t *testing.T) map[string]json.RawMessage {
+		t.Helper()
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url, strings.NewReader(`{"value":"Y"}`)))
+
+		if resp.Result().StatusCode != 200 {
+			t.Fatalf("Got status %s, expected 200", resp.Result().Status)
+		}
+
+		var body map[string]json.RawMessage
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("decoding resp body: %v", err)
+		}
+		return body
+	}
+
+	t.Run("without debug", func(t *testing.T) {
+		if _, ok := sendVote(t)["trace"]; ok {
+			t.Errorf("Got trace without debug logging")
+		}
+	})
+
+	t.Run("with debug", func(t *testing.T) {
+		log.SetDebugLogger(log.NewJSONLogger(io.Discard, "debug"))
+		defer log.SetDebugLogger(nil)
+
+		var trace map[string]float64
+		if err := json.Unmarshal(sendVote(t)["trace"], &trace); err != nil {
+			t.Fatalf("decoding trace: %v", err)
+		}
+
+		for _, field := range []string{"auth_ms", "load_ms", "validation_ms", "weight_ms", "backend_ms", "total_ms"} {
+			if _, ok := trace[field]; !ok {
+				t.Errorf("Trace has no field %s", field)
+			}
+		}
+	})
+}
+
+type AuthError struct{}
+
+func (AuthError) Error() string {
+	return `{"error":"auth","message":"auth error"}`
+}
+
+func (AuthError) Type() string {
+	return "auth"
+}
+
+type autherStub struct {
+	userID  int
+	authErr bool
+}
+
+func (a *autherStub) Authenticate(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+	if a.authErr {
+		return nil, AuthError{}
+	}
+	return r.Context(), nil
+}
 
 func (a *autherStub) FromContext(context.Context) int {
 	return a.userID
 }
 
-func TestHandleVote(t *testing.T) {
+func TestHandleVote(t *testing.T) {
+	voter := &voterStub{}
+	auther := &autherStub{}
+
+	url := "/system/vote"
+	mux := handleExternal(handleVote(voter, auther))
+
+	t.Run("No id", func(t *testing.T) {
+		auther.userID = 5
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url, nil))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400 - Bad Request", resp.Result().Status)
+		}
+	})
+
+	t.Run("ErrDoubleVote error", func(t *testing.T) {
+		voter.expectErr = vote.ErrDoubleVote
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1", nil))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400", resp.Result().Status)
+		}
+
+		var body struct {
+			Error string `json:"error"`
+		}
+
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("decoding resp body: %v", err)
+		}
+
+		if body.Error != "double-vote" {
+			t.Errorf("Got error `%s`, expected `double-vote`", body.Error)
+		}
+	})
+
+	t.Run("ErrNotStarted error", func(t *testing.T) {
+		voter.expectErr = vote.ErrNotStarted
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1", nil))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400", resp.Result().Status)
+		}
+
+		var body struct {
+			Error string `json:"error"`
+		}
+
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("decoding resp body: %v", err)
+		}
+
+		if body.Error != "not-started" {
+			t.Errorf("Got error `%s`, expected `not-started`", body.Error)
+		}
+	})
+
+	t.Run("ErrMaintenance error", func(t *testing.T) {
+		voter.expectErr = vote.MessageError(vote.ErrMaintenance, "back soon")
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1", nil))
+
+		if resp.Result().StatusCode != 503 {
+			t.Errorf("Got status %s, expected 503", resp.Result().Status)
+		}
+
+		var body struct {
+			Error string `json:"error"`
+			MSG   string `json:"message"`
+		}
+
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("decoding resp body: %v", err)
+		}
+
+		if body.Error != "maintenance" || body.MSG != "back soon" {
+			t.Errorf("Got error `%s` with message `%s`, expected `maintenance` with `back soon`", body.Error, body.MSG)
+		}
+	})
+
+	t.Run("Auth error", func(t *testing.T) {
+		auther.authErr = true
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1", nil))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400", resp.Result().Status)
+		}
+
+		var body struct {
+			Error string `json:"error"`
+		}
+
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("decoding resp body: %v", err)
+		}
+
+		if body.Error != "auth" {
+			t.Errorf("Got error `%s`, expected `auth`", body.Error)
+		}
+	})
+
+	t.Run("Anonymous", func(t *testing.T) {
+		auther.userID = 0
+		auther.authErr = false
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1", nil))
+
+		if resp.Result().StatusCode != 401 {
+			t.Errorf("Got status %s, expected 401", resp.Result().Status)
+		}
+
+		var body struct {
+			Error string `json:"error"`
+		}
+
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("decoding resp body: %v", err)
+		}
+
+		if body.Error != "not-allowed" {
+			t.Errorf("Got error `%s`, expected `auth`", body.Error)
+		}
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		auther.userID = 5
+		voter.body = "request body"
+		voter.expectErr = nil
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1", strings.NewReader("request body")))
+
+		if resp.Result().StatusCode != 200 {
+			t.Errorf("Got status %s, expected 200 - OK", resp.Result().Status)
+		}
+
+		if voter.id != 1 {
+			t.Errorf("Voter was called with id %d, expected 1", voter.id)
+		}
+
+		if voter.user != 5 {
+			t.Errorf("Voter was called with userID %d, expected 5", voter.user)
+		}
+
+		var body struct {
+			VoteUserID int    `json:"vote_user_id"`
+			Weight     string `json:"weight"`
+		}
+
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("decoding resp body: %v", err)
+		}
+
+		if body.VoteUserID != 5 || body.Weight != "1.000000" {
+			t.Errorf("Got body %+v, expected vote_user_id 5 and weight 1.000000", body)
+		}
+
+		if voter.body != "request body" {
+			t.Errorf("Voter was called with body `%s` expected `request body`", voter.body)
+		}
+	})
+}
+
+func TestCheckContentType(t *testing.T) {
+	voter := &voterStub{}
+	auther := &autherStub{userID: 5}
+	url := "/system/vote?id=1"
+
+	for _, tt := range []struct {
+		name        string
+		required    bool
+		contentType string
+		expectCode  int
+	}{
+		{"lenient without content type", false, "", 200},
+		{"lenient with other content type", false, "text/plain", 200},
+		{"strict without content type", true, "", 400},
+		{"strict with other content type", true, "text/plain", 400},
+		{"strict with json", true, "application/json", 200},
+		{"strict with json and charset", true, "application/json; charset=utf-8", 200},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := handleExternal(checkContentType(tt.required, handleVote(voter, auther)))
+
+			req := httptest.NewRequest("POST", url, strings.NewReader(`{"value":"Y"}`))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			resp := httptest.NewRecorder()
+			mux.ServeHTTP(resp, req)
+
+			if resp.Result().StatusCode != tt.expectCode {
+				t.Errorf("Got status %s, expected %d", resp.Result().Status, tt.expectCode)
+			}
+		})
+	}
+}
+
+func TestCheckVoteMethod(t *testing.T) {
+	voter := &voterStub{}
+	auther := &autherStub{userID: 5}
+
+	url := "/system/vote?id=1"
+
+	for _, tt := range []struct {
+		name       string
+		acceptPut  bool
+		method     string
+		expectCode int
+	}{
+		{"POST", true, "POST", 200},
+		{"PUT", true, "PUT", 200},
+		{"GET", true, "GET", 405},
+		{"DELETE", true, "DELETE", 405},
+		{"POST without PUT", false, "POST", 200},
+		{"PUT without PUT", false, "PUT", 405},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := handleExternal(checkVoteMethod(tt.acceptPut, handleVote(voter, auther)))
+
+			resp := httptest.NewRecorder()
+			mux.ServeHTTP(resp, httptest.NewRequest(tt.method, url, strings.NewReader(`{"value":"Y"}`)))
+
+			if resp.Result().StatusCode != tt.expectCode {
+				t.Errorf("Got status %s, expected %d", resp.Result().Status, tt.expectCode)
+			}
+
+			if tt.expectCode == 405 && resp.Header().Get("Allow") == "" {
+				t.Errorf("Got no Allow header")
+			}
+		})
+	}
+}
+
+func TestCheckCORS(t *testing.T) {
+	voter := &voterStub{}
+	auther := &autherStub{userID: 5}
+
+	url := "/system/vote?id=1"
+	mux := handleExternal(checkCORS([]string{"https://allowed.example"}, checkVoteMethod(true, handleVote(voter, auther))))
+
+	t.Run("Allowed origin", func(t *testing.T) {
+		req := httptest.NewRequest("POST", url, strings.NewReader("request body"))
+		req.Header.Set("Origin", "https://allowed.example")
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
+
+		if resp.Result().StatusCode != 200 {
+			t.Errorf("Got status %s, expected 200 - OK", resp.Result().Status)
+		}
+
+		if got := resp.Header().Get("Access-Control-Allow-Origin"); got != "https://allowed.example" {
+			t.Errorf("Got Access-Control-Allow-Origin `%s`, expected `https://allowed.example`", got)
+		}
+	})
+
+	t.Run("Disallowed origin", func(t *testing.T) {
+		req := httptest.NewRequest("POST", url, strings.NewReader("request body"))
+		req.Header.Set("Origin", "https://other.example")
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
+
+		if got := resp.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("Got Access-Control-Allow-Origin `%s`, expected none", got)
+		}
+	})
+
+	t.Run("Preflight", func(t *testing.T) {
+		voter.body = ""
+		req := httptest.NewRequest("OPTIONS", url, nil)
+		req.Header.Set("Origin", "https://allowed.example")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
+
+		if resp.Result().StatusCode != 204 {
+			t.Errorf("Got status %s, expected 204", resp.Result().Status)
+		}
+
+		if got := resp.Header().Get("Access-Control-Allow-Methods"); !strings.Contains(got, "POST") {
+			t.Errorf("Got Access-Control-Allow-Methods `%s`, expected POST", got)
+		}
+
+		if voter.body != "" {
+			t.Errorf("Voter was called")
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		mux := handleExternal(checkCORS(nil, handleVote(voter, auther)))
+		req := httptest.NewRequest("POST", url, strings.NewReader("request body"))
+		req.Header.Set("Origin", "https://allowed.example")
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
+
+		if got := resp.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("Got Access-Control-Allow-Origin `%s`, expected none", got)
+		}
+	})
+}
+
+func TestLimitBody(t *testing.T) {
+	voter := &voterStub{}
+	auther := &autherStub{userID: 5}
+
+	url := "/system/vote?id=1"
+	mux := handleExternal(limitBody(16, handleVote(voter, auther)))
+
+	t.Run("Small body", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url, strings.NewReader("request body")))
+
+		if resp.Result().StatusCode != 200 {
+			t.Errorf("Got status %s, expected 200 - OK", resp.Result().Status)
+		}
+
+		if voter.body != "request body" {
+			t.Errorf("Voter was called with body `%s` expected `request body`", voter.body)
+		}
+	})
+
+	t.Run("Oversized body", func(t *testing.T) {
+		voter.body = ""
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url, strings.NewReader(`{"value":{"1":{"2":{"3":"Y"}}}}`)))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400", resp.Result().Status)
+		}
+
+		expect := `{"error":"invalid","message":"The request body is bigger then 16 bytes"}`
+		if got := strings.TrimSpace(resp.Body.String()); got != expect {
+			t.Errorf("Got body `%s`, expected `%s`", got, expect)
+		}
+
+		if voter.body != "" {
+			t.Errorf("Voter was called")
+		}
+	})
+}
+
+func TestCheckClockSkew(t *testing.T) {
 	voter := &voterStub{}
+	auther := &autherStub{userID: 5}
+
+	url := "/system/vote?id=1"
+	mux := handleExternal(checkClockSkew(time.Minute, handleVote(voter, auther)))
+
+	t.Run("No timestamp", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("POST", url, strings.NewReader("request body")))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400", resp.Result().Status)
+		}
+	})
+
+	t.Run("In skew", func(t *testing.T) {
+		req := httptest.NewRequest("POST", url, strings.NewReader("request body"))
+		req.Header.Set("X-Vote-Timestamp", strconv.FormatInt(time.Now().Add(-30*time.Second).Unix(), 10))
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
+
+		if resp.Result().StatusCode != 200 {
+			t.Errorf("Got status %s, expected 200 - OK", resp.Result().Status)
+		}
+
+		if voter.body != "request body" {
+			t.Errorf("Voter was called with body `%s` expected `request body`", voter.body)
+		}
+	})
+
+	t.Run("Out of skew", func(t *testing.T) {
+		voter.body = ""
+		req := httptest.NewRequest("POST", url, strings.NewReader("request body"))
+		req.Header.Set("X-Vote-Timestamp", strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10))
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400", resp.Result().Status)
+		}
+
+		var body struct {
+			Error string `json:"error"`
+		}
+
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("decoding resp body: %v", err)
+		}
+
+		if body.Error != "invalid" {
+			t.Errorf("Got error `%s`, expected `invalid`", body.Error)
+		}
+
+		if voter.body != "" {
+			t.Errorf("Voter was called")
+		}
+	})
+}
+
+type votederStub struct {
+	pollIDs      []int
+	user         int
+	expectVote   map[int][]int
+	expectHash   map[int]map[int]string
+	expectErr    error
+	calledHashes bool
+}
+
+func (v *votederStub) VotedHashes(ctx context.Context, pollIDs []int, requestUser int) (map[int]map[int]string, error) {
+	v.pollIDs = pollIDs
+	v.user = requestUser
+	v.calledHashes = true
+
+	if v.expectErr != nil {
+		return nil, v.expectErr
+	}
+	return v.expectHash, nil
+}
+
+func (v *votederStub) Voted(ctx context.Context, pollIDs []int, requestUser int) (map[int][]int, error) {
+	v.pollIDs = pollIDs
+	v.user = requestUser
+
+	if v.expectErr != nil {
+		return nil, v.expectErr
+	}
+	return v.expectVote, nil
+}
+
+func TestHandleVoted(t *testing.T) {
+	voted := &votederStub{}
 	auther := &autherStub{}
 
-	url := "/system/vote"
-	mux := handleExternal(handleVote(voter, auther))
+	url := "/system/vote/voted"
+	mux := handleExternal(handleVoted(voted, auther))
 
-	t.Run("No id", func(t *testing.T) {
+	t.Run("No polls given", func(t *testing.T) {
 		auther.userID = 5
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("GET", url, nil))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400", resp.Result().Status)
+		}
+	})
 
+	t.Run("Wrong polls value", func(t *testing.T) {
+		auther.userID = 5
 		resp := httptest.NewRecorder()
-		mux.ServeHTTP(resp, httptest.NewRequest("POST", url, nil))
+		mux.ServeHTTP(resp, httptest.NewRequest("GET", url+"?ids=foo", nil))
 
 		if resp.Result().StatusCode != 400 {
-			t.Errorf("Got status %s, expected 400 - Bad Request", resp.Result().Status)
+			t.Errorf("Got status %s, expected 400", resp.Result().Status)
 		}
 	})
 
-	t.Run("ErrDoubleVote error", func(t *testing.T) {
-		voter.expectErr = vote.ErrDoubleVote
+	t.Run("Auth error", func(t *testing.T) {
+		auther.authErr = true
 
 		resp := httptest.NewRecorder()
-		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1", nil))
+		mux.ServeHTTP(resp, httptest.NewRequest("GET", url+"?ids=1", nil))
 
 		if resp.Result().StatusCode != 400 {
 			t.Errorf("Got status %s, expected 400", resp.Result().Status)
@@ -384,19 +2201,20 @@ func TestHandleVote(t *testing.T) {
 			t.Fatalf("decoding resp body: %v", err)
 		}
 
-		if body.Error != "double-vote" {
-			t.Errorf("Got error `%s`, expected `double-vote`", body.Error)
+		if body.Error != "auth" {
+			t.Errorf("Got error `%s`, expected `auth`", body.Error)
 		}
 	})
 
-	t.Run("Auth error", func(t *testing.T) {
-		auther.authErr = true
+	t.Run("Anonymous", func(t *testing.T) {
+		auther.authErr = false
+		auther.userID = 0
 
 		resp := httptest.NewRecorder()
-		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1", nil))
+		mux.ServeHTTP(resp, httptest.NewRequest("GET", url+"?ids=1", nil))
 
-		if resp.Result().StatusCode != 400 {
-			t.Errorf("Got status %s, expected 400", resp.Result().Status)
+		if resp.Result().StatusCode != 401 {
+			t.Errorf("Got status %s, expected 401", resp.Result().Status)
 		}
 
 		var body struct {
@@ -407,20 +2225,89 @@ func TestHandleVote(t *testing.T) {
 			t.Fatalf("decoding resp body: %v", err)
 		}
 
-		if body.Error != "auth" {
-			t.Errorf("Got error `%s`, expected `auth`", body.Error)
+		if body.Error != "not-allowed" {
+			t.Errorf("Got error `%s`, expected `not-allowed`", body.Error)
 		}
 	})
 
-	t.Run("Anonymous", func(t *testing.T) {
-		auther.userID = 0
+	t.Run("Correct", func(t *testing.T) {
+		auther.userID = 5
 		auther.authErr = false
 
 		resp := httptest.NewRecorder()
-		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1", nil))
+		mux.ServeHTTP(resp, httptest.NewRequest("GET", url+"?ids=1,2", nil))
 
-		if resp.Result().StatusCode != 401 {
-			t.Errorf("Got status %s, expected 401", resp.Result().Status)
+		if resp.Result().StatusCode != 200 {
+			t.Errorf("Got status %s, expected 200", resp.Result().Status)
+		}
+
+		if len(voted.pollIDs) != 2 || voted.pollIDs[0] != 1 || voted.pollIDs[1] != 2 {
+			t.Errorf("Voted was called with pollIDs %v, expected [1,2]", voted.pollIDs)
+		}
+
+		if voted.calledHashes {
+			t.Errorf("VotedHashes was called without include_hash")
+		}
+	})
+
+	t.Run("Duplicate ids", func(t *testing.T) {
+		auther.userID = 5
+		auther.authErr = false
+		voted.expectVote = map[int][]int{1: {5}, 2: {}}
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("GET", url+"?ids=2,1,2,1", nil))
+
+		if resp.Result().StatusCode != 200 {
+			t.Errorf("Got status %s, expected 200", resp.Result().Status)
+		}
+
+		if len(voted.pollIDs) != 2 || voted.pollIDs[0] != 2 || voted.pollIDs[1] != 1 {
+			t.Errorf("Voted was called with pollIDs %v, expected [2,1]", voted.pollIDs)
+		}
+
+		expect := `{"1":[5],"2":[]}`
+		if got := strings.TrimSpace(resp.Body.String()); got != expect {
+			t.Errorf("Got body `%s`, expected `%s`", got, expect)
+		}
+	})
+
+	t.Run("With hash", func(t *testing.T) {
+		auther.userID = 5
+		auther.authErr = false
+		voted.expectHash = map[int]map[int]string{1: {5: "abc"}, 2: {}}
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("GET", url+"?ids=1,2&include_hash=1", nil))
+
+		if resp.Result().StatusCode != 200 {
+			t.Errorf("Got status %s, expected 200", resp.Result().Status)
+		}
+
+		if !voted.calledHashes {
+			t.Errorf("VotedHashes was not called")
+		}
+
+		var got map[int]map[int]string
+		if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+			t.Fatalf("decoding resp body: %v", err)
+		}
+
+		if !reflect.DeepEqual(got, voted.expectHash) {
+			t.Errorf("Got %v, expected %v", got, voted.expectHash)
+		}
+	})
+
+	t.Run("Voted Error", func(t *testing.T) {
+		auther.userID = 5
+		auther.authErr = false
+		voted.expectErr = vote.ErrNotExists
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("GET", url+"?ids=1,2", nil))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400", resp.Result().Status)
 		}
 
 		var body struct {
@@ -431,178 +2318,422 @@ func TestHandleVote(t *testing.T) {
 			t.Fatalf("decoding resp body: %v", err)
 		}
 
-		if body.Error != "not-allowed" {
-			t.Errorf("Got error `%s`, expected `auth`", body.Error)
+		if body.Error != "not-exist" {
+			t.Errorf("Got error `%s`, expected `not-exist`", body.Error)
 		}
 	})
+}
 
-	t.Run("Valid", func(t *testing.T) {
-		auther.userID = 5
-		voter.body = "request body"
-		voter.expectErr = nil
+type eligiblerStub struct {
+	pollID      int
+	requestUser int
+	voteUser    int
+	result      vote.Eligibility
+}
+
+func (e *eligiblerStub) Eligible(ctx context.Context, pollID, requestUser, voteUser int) (vote.Eligibility, error) {
+	e.pollID = pollID
+	e.requestUser = requestUser
+	e.voteUser = voteUser
+	return e.result, nil
+}
+
+func TestHandleEligible(t *testing.T) {
+	eligible := &eligiblerStub{}
+	auther := &autherStub{userID: 5}
 
+	url := "/system/vote/eligible"
+	mux := handleExternal(handleEligible(eligible, auther))
+
+	t.Run("No id", func(t *testing.T) {
 		resp := httptest.NewRecorder()
-		mux.ServeHTTP(resp, httptest.NewRequest("POST", url+"?id=1", strings.NewReader("request body")))
+		mux.ServeHTTP(resp, httptest.NewRequest("GET", url, nil))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400", resp.Result().Status)
+		}
+	})
+
+	t.Run("Invalid user_id", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("GET", url+"?id=1&user_id=foo", nil))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400", resp.Result().Status)
+		}
+	})
+
+	t.Run("Eligible", func(t *testing.T) {
+		eligible.result = vote.Eligibility{Eligible: true}
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("GET", url+"?id=1", nil))
 
 		if resp.Result().StatusCode != 200 {
-			t.Errorf("Got status %s, expected 200 - OK", resp.Result().Status)
+			t.Errorf("Got status %s, expected 200", resp.Result().Status)
 		}
 
-		if voter.id != 1 {
-			t.Errorf("Voter was called with id %d, expected 1", voter.id)
+		if eligible.pollID != 1 || eligible.requestUser != 5 || eligible.voteUser != 5 {
+			t.Errorf("Eligible was called with poll %d, request user %d and vote user %d, expected 1, 5, 5", eligible.pollID, eligible.requestUser, eligible.voteUser)
 		}
 
-		if voter.user != 5 {
-			t.Errorf("Voter was called with userID %d, expected 5", voter.user)
+		expect := `{"eligible":true}` + "\n"
+		if got := resp.Body.String(); got != expect {
+			t.Errorf("Got body `%s`, expected `%s`", got, expect)
 		}
+	})
 
-		if voter.body != "request body" {
-			t.Errorf("Voter was called with body `%s` expected `request body`", voter.body)
+	t.Run("Not eligible for other user", func(t *testing.T) {
+		eligible.result = vote.Eligibility{Reason: "not-delegated"}
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("GET", url+"?id=1&user_id=7", nil))
+
+		if resp.Result().StatusCode != 200 {
+			t.Errorf("Got status %s, expected 200", resp.Result().Status)
+		}
+
+		if eligible.voteUser != 7 {
+			t.Errorf("Eligible was called with vote user %d, expected 7", eligible.voteUser)
+		}
+
+		expect := `{"eligible":false,"reason":"not-delegated"}` + "\n"
+		if got := resp.Body.String(); got != expect {
+			t.Errorf("Got body `%s`, expected `%s`", got, expect)
 		}
 	})
 }
 
-type votederStub struct {
-	pollIDs    []int
-	user       int
-	expectVote map[int][]int
-	expectErr  error
+type eligibilityCheckerStub struct {
+	pollID      int
+	requestUser int
+	voteUser    int
+	checks      []vote.EligibilityCheck
 }
 
-func (v *votederStub) Voted(ctx context.Context, pollIDs []int, requestUser int) (map[int][]int, error) {
-	v.pollIDs = pollIDs
-	v.user = requestUser
+func (e *eligibilityCheckerStub) EligibilityChecks(ctx context.Context, pollID, requestUser, voteUser int) ([]vote.EligibilityCheck, error) {
+	e.pollID = pollID
+	e.requestUser = requestUser
+	e.voteUser = voteUser
+	return e.checks, nil
+}
 
-	if v.expectErr != nil {
-		return nil, v.expectErr
+func TestHandleEligibility(t *testing.T) {
+	checker := &eligibilityCheckerStub{checks: []vote.EligibilityCheck{
+		{Check: "started", Passed: true},
+		{Check: "present", Passed: false},
+	}}
+	auther := &autherStub{userID: 5}
+	mux := handleExternal(handleEligibility(checker, auther))
+
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, httptest.NewRequest("GET", "/system/vote/eligibility?id=1", nil))
+
+	if resp.Result().StatusCode != 200 {
+		t.Errorf("Got status %s, expected 200", resp.Result().Status)
 	}
-	return v.expectVote, nil
+
+	if checker.pollID != 1 || checker.requestUser != 5 || checker.voteUser != 5 {
+		t.Errorf("EligibilityChecks was called with poll %d and users %d and %d, expected 1, 5 and 5", checker.pollID, checker.requestUser, checker.voteUser)
+	}
+
+	expect := `{"eligible":false,"checks":[{"check":"started","passed":true},{"check":"present","passed":false}]}`
+	if got := strings.TrimSpace(resp.Body.String()); got != expect {
+		t.Errorf("Got body `%s`, expected `%s`", got, expect)
+	}
+
+	t.Run("with user_id", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("GET", "/system/vote/eligibility?id=1&user_id=7", nil))
+
+		if resp.Result().StatusCode != 200 {
+			t.Errorf("Got status %s, expected 200", resp.Result().Status)
+		}
+
+		if checker.requestUser != 5 || checker.voteUser != 7 {
+			t.Errorf("EligibilityChecks was called with users %d and %d, expected 5 and 7", checker.requestUser, checker.voteUser)
+		}
+	})
 }
 
-func TestHandleVoted(t *testing.T) {
-	voted := &votederStub{}
-	auther := &autherStub{}
+type turnoutByGrouperStub struct {
+	id     int
+	expect map[int]vote.GroupTurnout
+}
 
-	url := "/system/vote/voted"
-	mux := handleExternal(handleVoted(voted, auther))
+func (s *turnoutByGrouperStub) TurnoutByGroup(ctx context.Context, pollID int) (map[int]vote.GroupTurnout, error) {
+	s.id = pollID
+	return s.expect, nil
+}
 
-	t.Run("No polls given", func(t *testing.T) {
-		auther.userID = 5
+func TestHandleTurnoutByGroup(t *testing.T) {
+	turnout := &turnoutByGrouperStub{expect: map[int]vote.GroupTurnout{1: {Entitled: 3, Voted: 2}}}
+	mux := handleInternal(handleTurnoutByGroup(turnout))
+
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, httptest.NewRequest("GET", "/vote/turnout_by_group?id=1", nil))
+
+	if resp.Result().StatusCode != 200 {
+		t.Errorf("Got status %s, expected 200", resp.Result().Status)
+	}
+
+	if turnout.id != 1 {
+		t.Errorf("TurnoutByGroup was called with id %d, expected 1", turnout.id)
+	}
+
+	expect := `{"1":{"entitled":3,"voted":2}}`
+	if got := strings.TrimSpace(resp.Body.String()); got != expect {
+		t.Errorf("Got body `%s`, expected `%s`", got, expect)
+	}
+}
+
+type auditorStub struct {
+	events []vote.AuditEvent
+}
+
+func (s *auditorStub) Audit(pollID int) []vote.AuditEvent {
+	var events []vote.AuditEvent
+	for _, event := range s.events {
+		if event.PollID == pollID {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func TestHandleAudit(t *testing.T) {
+	auditor := &auditorStub{
+		events: []vote.AuditEvent{
+			{PollID: 1, Event: vote.AuditStart, Time: time.Unix(1000, 0).UTC()},
+			{PollID: 2, Event: vote.AuditStart, Time: time.Unix(1001, 0).UTC()},
+			{PollID: 1, Event: vote.AuditStop, Time: time.Unix(1002, 0).UTC()},
+		},
+	}
+
+	url := "/vote/audit"
+	mux := handleInternal(handleAudit(auditor))
+
+	t.Run("No id", func(t *testing.T) {
 		resp := httptest.NewRecorder()
 		mux.ServeHTTP(resp, httptest.NewRequest("GET", url, nil))
 
-		if resp.Result().StatusCode != 400 {
-			t.Errorf("Got status %s, expected 400", resp.Result().Status)
-		}
-	})
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400 - Bad Request", resp.Result().Status)
+		}
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("GET", url+"?id=1", nil))
+
+		if resp.Result().StatusCode != 200 {
+			t.Errorf("Got status %s, expected 200 - OK", resp.Result().Status)
+		}
+
+		expect := `{"events":[{"poll_id":1,"event":"start","time":"1970-01-01T00:16:40Z"},{"poll_id":1,"event":"stop","time":"1970-01-01T00:16:42Z"}]}`
+		if got := strings.TrimSpace(resp.Body.String()); got != expect {
+			t.Errorf("Got body `%s`, expected `%s`", got, expect)
+		}
+	})
+}
+
+type entitledCounterStub struct {
+	id       int
+	entitled int
+	count    map[int]int
+}
+
+func (s *entitledCounterStub) EntitledCount(ctx context.Context, pollID int) (int, error) {
+	s.id = pollID
+	return s.entitled, nil
+}
+
+func (s *entitledCounterStub) VoteCount(ctx context.Context) map[int]int {
+	return s.count
+}
+
+func TestHandleEntitled(t *testing.T) {
+	counter := &entitledCounterStub{entitled: 4, count: map[int]int{1: 2, 2: 5}}
+	mux := handleInternal(handleEntitled(counter))
+
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, httptest.NewRequest("GET", "/vote/entitled?id=1", nil))
+
+	if resp.Result().StatusCode != 200 {
+		t.Errorf("Got status %s, expected 200", resp.Result().Status)
+	}
+
+	if counter.id != 1 {
+		t.Errorf("EntitledCount was called with id %d, expected 1", counter.id)
+	}
+
+	expect := `{"entitled":4,"voted":2}`
+	if got := strings.TrimSpace(resp.Body.String()); got != expect {
+		t.Errorf("Got body `%s`, expected `%s`", got, expect)
+	}
+}
+
+type meetingTurnouterStub struct {
+	meetingID int
+}
+
+func (s *meetingTurnouterStub) MeetingTurnout(ctx context.Context, meetingID int) (map[int]vote.PollTurnout, error) {
+	s.meetingID = meetingID
+	return map[int]vote.PollTurnout{
+		1: {Votes: 3, Entitled: 4},
+		2: {Votes: 0, Entitled: 4},
+	}, nil
+}
+
+func TestHandleMeetingTurnout(t *testing.T) {
+	turnout := &meetingTurnouterStub{}
+	mux := handleInternal(handleMeetingTurnout(turnout))
 
-	t.Run("Wrong polls value", func(t *testing.T) {
-		auther.userID = 5
+	t.Run("No meeting_id", func(t *testing.T) {
 		resp := httptest.NewRecorder()
-		mux.ServeHTTP(resp, httptest.NewRequest("GET", url+"?ids=foo", nil))
+		mux.ServeHTTP(resp, httptest.NewRequest("GET", "/vote/meeting_turnout", nil))
 
 		if resp.Result().StatusCode != 400 {
 			t.Errorf("Got status %s, expected 400", resp.Result().Status)
 		}
 	})
 
-	t.Run("Auth error", func(t *testing.T) {
-		auther.authErr = true
-
+	t.Run("Valid", func(t *testing.T) {
 		resp := httptest.NewRecorder()
-		mux.ServeHTTP(resp, httptest.NewRequest("GET", url+"?ids=1", nil))
-
-		if resp.Result().StatusCode != 400 {
-			t.Errorf("Got status %s, expected 400", resp.Result().Status)
-		}
+		mux.ServeHTTP(resp, httptest.NewRequest("GET", "/vote/meeting_turnout?meeting_id=5", nil))
 
-		var body struct {
-			Error string `json:"error"`
+		if resp.Result().StatusCode != 200 {
+			t.Errorf("Got status %s, expected 200", resp.Result().Status)
 		}
 
-		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
-			t.Fatalf("decoding resp body: %v", err)
+		if turnout.meetingID != 5 {
+			t.Errorf("MeetingTurnout was called with meeting %d, expected 5", turnout.meetingID)
 		}
 
-		if body.Error != "auth" {
-			t.Errorf("Got error `%s`, expected `auth`", body.Error)
+		expect := `{"1":{"votes":3,"entitled":4},"2":{"votes":0,"entitled":4}}`
+		if got := strings.TrimSpace(resp.Body.String()); got != expect {
+			t.Errorf("Got body `%s`, expected `%s`", got, expect)
 		}
 	})
+}
 
-	t.Run("Anonymous", func(t *testing.T) {
-		auther.authErr = false
-		auther.userID = 0
+type backendLoaderStub struct {
+	expect map[string]vote.BackendUsage
+}
 
-		resp := httptest.NewRecorder()
-		mux.ServeHTTP(resp, httptest.NewRequest("GET", url+"?ids=1", nil))
+func (s *backendLoaderStub) BackendLoad(ctx context.Context) (map[string]vote.BackendUsage, error) {
+	return s.expect, nil
+}
 
-		if resp.Result().StatusCode != 401 {
-			t.Errorf("Got status %s, expected 401", resp.Result().Status)
-		}
+func TestHandleBackendLoad(t *testing.T) {
+	loader := &backendLoaderStub{expect: map[string]vote.BackendUsage{
+		"fast": {Polls: 2, Votes: 3},
+		"long": {Polls: 1, Votes: 0},
+	}}
+	mux := handleInternal(handleBackendLoad(loader))
 
-		var body struct {
-			Error string `json:"error"`
-		}
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, httptest.NewRequest("GET", "/vote/backend_load", nil))
 
-		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
-			t.Fatalf("decoding resp body: %v", err)
-		}
+	if resp.Result().StatusCode != 200 {
+		t.Errorf("Got status %s, expected 200", resp.Result().Status)
+	}
 
-		if body.Error != "not-allowed" {
-			t.Errorf("Got error `%s`, expected `not-allowed`", body.Error)
-		}
-	})
+	expect := `{"fast":{"polls":2,"votes":3},"long":{"polls":1,"votes":0}}`
+	if got := strings.TrimSpace(resp.Body.String()); got != expect {
+		t.Errorf("Got body `%s`, expected `%s`", got, expect)
+	}
+}
 
-	t.Run("Correct", func(t *testing.T) {
-		auther.userID = 5
-		auther.authErr = false
+type votesForUsererStub struct {
+	userID int
+	expect []int
+}
+
+func (s *votesForUsererStub) PollsVotedByUser(ctx context.Context, userID int) ([]int, error) {
+	s.userID = userID
+	return s.expect, nil
+}
+
+func TestHandleVotesForUser(t *testing.T) {
+	votes := &votesForUsererStub{expect: []int{1, 3}}
+	mux := handleInternal(handleVotesForUser(votes))
 
+	t.Run("Valid", func(t *testing.T) {
 		resp := httptest.NewRecorder()
-		mux.ServeHTTP(resp, httptest.NewRequest("GET", url+"?ids=1,2", nil))
+		mux.ServeHTTP(resp, httptest.NewRequest("GET", "/vote/votes_for_user?user_id=5", nil))
 
 		if resp.Result().StatusCode != 200 {
 			t.Errorf("Got status %s, expected 200", resp.Result().Status)
 		}
 
-		if len(voted.pollIDs) != 2 || voted.pollIDs[0] != 1 || voted.pollIDs[1] != 2 {
-			t.Errorf("Voted was called with pollIDs %v, expected [1,2]", voted.pollIDs)
+		if votes.userID != 5 {
+			t.Errorf("PollsVotedByUser was called with user %d, expected 5", votes.userID)
 		}
-	})
 
-	t.Run("Voted Error", func(t *testing.T) {
-		auther.userID = 5
-		auther.authErr = false
-		voted.expectErr = vote.ErrNotExists
+		expect := `{"poll_ids":[1,3]}`
+		if got := strings.TrimSpace(resp.Body.String()); got != expect {
+			t.Errorf("Got body `%s`, expected `%s`", got, expect)
+		}
+	})
 
+	t.Run("Invalid user id", func(t *testing.T) {
 		resp := httptest.NewRecorder()
-		mux.ServeHTTP(resp, httptest.NewRequest("GET", url+"?ids=1,2", nil))
+		mux.ServeHTTP(resp, httptest.NewRequest("GET", "/vote/votes_for_user?user_id=foo", nil))
 
 		if resp.Result().StatusCode != 400 {
 			t.Errorf("Got status %s, expected 400", resp.Result().Status)
 		}
+	})
+}
 
-		var body struct {
-			Error string `json:"error"`
-		}
+type activeMeetingserStub struct {
+	expect []int
+}
 
-		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
-			t.Fatalf("decoding resp body: %v", err)
-		}
+func (s *activeMeetingserStub) ActiveMeetings(ctx context.Context) ([]int, error) {
+	return s.expect, nil
+}
 
-		if body.Error != "not-exist" {
-			t.Errorf("Got error `%s`, expected `not-exist`", body.Error)
-		}
-	})
+func TestHandleActiveMeetings(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		ids    []int
+		expect string
+	}{
+		{"no meetings", nil, `[]`},
+		{"two meetings", []int{1, 2}, `[1,2]`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := handleInternal(handleActiveMeetings(&activeMeetingserStub{expect: tt.ids}))
+
+			resp := httptest.NewRecorder()
+			mux.ServeHTTP(resp, httptest.NewRequest("GET", "/vote/active_meetings", nil))
+
+			if resp.Result().StatusCode != 200 {
+				t.Errorf("Got status %s, expected 200", resp.Result().Status)
+			}
+
+			if got := strings.TrimSpace(resp.Body.String()); got != tt.expect {
+				t.Errorf("Got body `%s`, expected `%s`", got, tt.expect)
+			}
+		})
+	}
 }
 
 type voteCounterStub struct {
-	expectCount map[int]int
+	expectCount  map[int]int
+	expectStates map[int]bool
 }
 
 func (v *voteCounterStub) VoteCount(ctx context.Context) map[int]int {
 	return v.expectCount
 }
 
+func (v *voteCounterStub) PollStates(ctx context.Context) (map[int]bool, error) {
+	return v.expectStates, nil
+}
+
 func TestHandleVoteCountFirstData(t *testing.T) {
 	voteCounter := &voteCounterStub{}
 
@@ -610,7 +2741,7 @@ func TestHandleVoteCountFirstData(t *testing.T) {
 		return make(chan time.Time), func() {}
 	}
 
-	mux := handleVoteCount(voteCounter, eventer)
+	mux := handleVoteCount(voteCounter, eventer, 0)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
 	defer cancel()
@@ -637,6 +2768,51 @@ func TestHandleVoteCountFirstData(t *testing.T) {
 	}
 }
 
+func TestHandleVoteCountGzip(t *testing.T) {
+	voteCounter := &voteCounterStub{expectCount: map[int]int{1: 10, 2: 20}}
+	event := make(chan time.Time)
+
+	eventer := func() (<-chan time.Time, func()) {
+		return event, func() {}
+	}
+
+	mux := withGzip(0, handleVoteCount(voteCounter, eventer, 0))
+
+	req := httptest.NewRequest("GET", "/vote/vote_count", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp := httptest.NewRecorder()
+
+	// The first message has to reach the client with the first flush, before
+	// the stream ends.
+	var flushed []byte
+	flushResp := onFlush{resp, func() {
+		flushed = append([]byte{}, resp.Body.Bytes()...)
+		close(event)
+	}}
+
+	if err := mux(flushResp, req); err != nil {
+		t.Fatalf("Handler returned: %v", err)
+	}
+
+	if got := resp.Result().Header.Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Got Content-Encoding `%s`, expected gzip", got)
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(flushed))
+	if err != nil {
+		t.Fatalf("Flushed data is not gzip: %v", err)
+	}
+
+	var got map[int]int
+	if err := json.NewDecoder(reader).Decode(&got); err != nil {
+		t.Fatalf("decoding flushed data: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, voteCounter.expectCount) {
+		t.Errorf("Got %v, expected %v", got, voteCounter.expectCount)
+	}
+}
+
 func TestHandleVoteCountFirstDataEmpty(t *testing.T) {
 	voteCounter := &voteCounterStub{}
 
@@ -644,7 +2820,7 @@ func TestHandleVoteCountFirstDataEmpty(t *testing.T) {
 		return make(chan time.Time), func() {}
 	}
 
-	mux := handleVoteCount(voteCounter, eventer)
+	mux := handleVoteCount(voteCounter, eventer, 0)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
 	defer cancel()
@@ -671,6 +2847,45 @@ func TestHandleVoteCountFirstDataEmpty(t *testing.T) {
 	}
 }
 
+func TestHandleVoteCountWithIDs(t *testing.T) {
+	voteCounter := &voteCounterStub{}
+
+	eventer := func() (<-chan time.Time, func()) {
+		return make(chan time.Time), func() {}
+	}
+
+	mux := handleInternal(handleVoteCount(voteCounter, eventer, 0))
+	voteCounter.expectCount = map[int]int{1: 10, 2: 20, 3: 30}
+
+	t.Run("Valid", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("GET", "/vote/vote_count?ids=1,3,4", nil))
+
+		if resp.Result().StatusCode != 200 {
+			t.Fatalf("Got status %s, expected 200", resp.Result().Status)
+		}
+
+		var got map[int]int
+		if err := json.NewDecoder(resp.Result().Body).Decode(&got); err != nil {
+			t.Fatalf("decoding: %v", err)
+		}
+
+		expect := map[int]int{1: 10, 3: 30, 4: 0}
+		if !reflect.DeepEqual(got, expect) {
+			t.Errorf("Got %v, expected %v", got, expect)
+		}
+	})
+
+	t.Run("Invalid ids", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("GET", "/vote/vote_count?ids=1,foo", nil))
+
+		if resp.Result().StatusCode != 400 {
+			t.Errorf("Got status %s, expected 400", resp.Result().Status)
+		}
+	})
+}
+
 func TestHandleVoteCountSecondData(t *testing.T) {
 	voteCounter := &voteCounterStub{}
 
@@ -679,7 +2894,7 @@ func TestHandleVoteCountSecondData(t *testing.T) {
 		return event, func() {}
 	}
 
-	mux := handleVoteCount(voteCounter, eventer)
+	mux := handleVoteCount(voteCounter, eventer, 0)
 
 	ctx := context.Background()
 
@@ -740,9 +2955,147 @@ func TestHandleVoteCountSecondData(t *testing.T) {
 	}
 }
 
+func TestHandleVoteCountHeartbeat(t *testing.T) {
+	voteCounter := &voteCounterStub{expectCount: map[int]int{1: 10}}
+
+	event := make(chan time.Time, 1)
+	eventer := func() (<-chan time.Time, func()) {
+		return event, func() {}
+	}
+
+	mux := handleVoteCount(voteCounter, eventer, 25*time.Second)
+
+	// Ticks without a change. Only the second and the fourth tick are more
+	// then 25 seconds after the last data.
+	start := time.Now()
+	ticks := []time.Time{
+		start.Add(10 * time.Second),
+		start.Add(30 * time.Second),
+		start.Add(40 * time.Second),
+		start.Add(60 * time.Second),
+	}
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/vote/vote_count", nil)
+
+	i := 0
+	flushResp := onFlush{resp, func() {
+		if i >= len(ticks) {
+			close(event)
+			return
+		}
+		event <- ticks[i]
+		i++
+	}}
+
+	mux.ServeHTTP(flushResp, req)
+
+	expect := "{\"1\":10}\n{}\n{}\n"
+	if got := resp.Body.String(); got != expect {
+		t.Errorf("Got body `%s`, expected `%s`", got, expect)
+	}
+}
+
+func TestHandleVoteCountLifecycle(t *testing.T) {
+	voteCounter := &voteCounterStub{}
+
+	event := make(chan time.Time, 1)
+	eventer := func() (<-chan time.Time, func()) {
+		return event, func() {}
+	}
+
+	mux := handleVoteCount(voteCounter, eventer, 0)
+
+	// The polls 2 and 3 are started and stopped without votes.
+	data := []struct {
+		count  map[int]int
+		states map[int]bool
+	}{
+		{map[int]int{1: 10}, map[int]bool{1: false}},
+		{map[int]int{1: 10}, map[int]bool{1: false, 2: false, 3: false}}, // Start 2 and 3
+		{map[int]int{1: 11}, map[int]bool{1: false, 2: true, 3: false}},  // Vote on 1, stop 2
+		{map[int]int{1: 11}, map[int]bool{1: false, 2: true}},            // Clear 3
+	}
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/vote/vote_count?lifecycle=true", nil)
+
+	voteCounter.expectCount = data[0].count
+	voteCounter.expectStates = data[0].states
+	i := 0
+	flushResp := onFlush{resp, func() {
+		i++
+		if i >= len(data) {
+			close(event)
+			return
+		}
+		voteCounter.expectCount = data[i].count
+		voteCounter.expectStates = data[i].states
+		event <- time.Now()
+	}}
+
+	mux.ServeHTTP(flushResp, req)
+
+	expect := strings.Join([]string{
+		`{"count":{"1":10}}`,
+		`{"count":{},"started":[2,3]}`,
+		`{"count":{"1":11},"stopped":[2]}`,
+		`{"count":{},"stopped":[3]}`,
+	}, "\n") + "\n"
+
+	if got := resp.Body.String(); got != expect {
+		t.Errorf("Got body:\n%s\nexpected:\n%s", got, expect)
+	}
+}
+
+type healthCheckerStub struct {
+	fastErr error
+	longErr error
+}
+
+func (h *healthCheckerStub) BackendHealth(ctx context.Context) map[string]error {
+	return map[string]error{"fast": h.fastErr, "long": h.longErr}
+}
+
 func TestHandleHealth(t *testing.T) {
 	url := "/system/vote/health"
-	mux := handleHealth()
+
+	t.Run("healthy", func(t *testing.T) {
+		mux := handleHealth(&healthCheckerStub{})
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("GET", url, nil))
+
+		if resp.Result().StatusCode != 200 {
+			t.Errorf("Got status %s, expected 200 - OK", resp.Result().Status)
+		}
+
+		expect := `{"healthy":true,"backends":{"fast":"ok","long":"ok"}}`
+		if got := strings.TrimSpace(resp.Body.String()); got != expect {
+			t.Errorf("Got body `%s`, expected `%s`", got, expect)
+		}
+	})
+
+	t.Run("backend down", func(t *testing.T) {
+		mux := handleHealth(&healthCheckerStub{longErr: errors.New("connection refused")})
+
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, httptest.NewRequest("GET", url, nil))
+
+		if resp.Result().StatusCode != 503 {
+			t.Errorf("Got status %s, expected 503", resp.Result().Status)
+		}
+
+		expect := `{"healthy":false,"backends":{"fast":"ok","long":"down"}}`
+		if got := strings.TrimSpace(resp.Body.String()); got != expect {
+			t.Errorf("Got body `%s`, expected `%s`", got, expect)
+		}
+	})
+}
+
+func TestHandleLiveness(t *testing.T) {
+	url := "/system/vote/health/live"
+	mux := handleLiveness()
 
 	resp := httptest.NewRecorder()
 	mux.ServeHTTP(resp, httptest.NewRequest("GET", url, nil))
@@ -757,6 +3110,47 @@ func TestHandleHealth(t *testing.T) {
 	}
 }
 
+func TestHandleOpenAPI(t *testing.T) {
+	url := "/system/vote/openapi.json"
+	mux := handleOpenAPI()
+
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, httptest.NewRequest("GET", url, nil))
+
+	if resp.Result().StatusCode != 200 {
+		t.Errorf("Got status %s, expected 200 - OK", resp.Result().Status)
+	}
+
+	var document struct {
+		Paths map[string]struct {
+			Post *struct {
+				RequestBody struct {
+					Content map[string]struct {
+						Schema struct {
+							Properties map[string]json.RawMessage `json:"properties"`
+						} `json:"schema"`
+					} `json:"content"`
+				} `json:"requestBody"`
+			} `json:"post"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &document); err != nil {
+		t.Fatalf("Body is not valid json: %v", err)
+	}
+
+	votePath, ok := document.Paths["/system/vote"]
+	if !ok || votePath.Post == nil {
+		t.Fatalf("Document has no post request on /system/vote")
+	}
+
+	properties := votePath.Post.RequestBody.Content["application/json"].Schema.Properties
+	for _, field := range []string{"user_id", "value", "nonce"} {
+		if _, ok := properties[field]; !ok {
+			t.Errorf("Ballot schema has no field %s", field)
+		}
+	}
+}
+
 type onFlush struct {
 	http.ResponseWriter
 	f func()
@@ -768,3 +3162,46 @@ func (f onFlush) Flush() {
 		flusher.Flush()
 	}
 }
+
+func TestInternalErrorMessage(t *testing.T) {
+	internalErr := errors.New("secret database detail")
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return internalErr
+	})
+
+	for _, tt := range []struct {
+		name       string
+		wrapper    func(Handler) http.Handler
+		expectShow bool
+	}{
+		{"internal route", handleInternal, true},
+		{"external route", externalHandler(false), false},
+		{"external route with exposed errors", externalHandler(true), true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			tt.wrapper(handler).ServeHTTP(resp, httptest.NewRequest("GET", "/", nil))
+
+			if resp.Result().StatusCode != 500 {
+				t.Errorf("Got status %s, expected 500", resp.Result().Status)
+			}
+
+			var body struct {
+				Error   string `json:"error"`
+				Message string `json:"message"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decoding body: %v", err)
+			}
+
+			if body.Error != "internal" {
+				t.Errorf("Got error type %s, expected internal", body.Error)
+			}
+
+			shown := strings.Contains(body.Message, internalErr.Error())
+			if shown != tt.expectShow {
+				t.Errorf("Got message `%s`, expected detail shown: %v", body.Message, tt.expectShow)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/OpenSlides/openslides-vote-service/vote"
+)
+
+// openAPIDocument returns the OpenAPI description of the external routes.
+//
+// The paths are written by hand. The schemas are generated from the types,
+// that are used to decode the requests and encode the responses.
+func openAPIDocument() map[string]any {
+	jsonContent := func(schema map[string]any) map[string]any {
+		return map[string]any{
+			"application/json": map[string]any{"schema": schema},
+		}
+	}
+
+	errorResponse := map[string]any{
+		"description": "The request failed.",
+		"content":     jsonContent(vote.JSONSchema(errorResponse{})),
+	}
+
+	pollIDParameter := map[string]any{
+		"name":        "id",
+		"in":          "query",
+		"required":    true,
+		"description": "The id of the poll.",
+		"schema":      map[string]any{"type": "integer"},
+	}
+
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":   "OpenSlides Vote Service",
+			"version": "1",
+		},
+		"paths": map[string]any{
+			"/system/vote": map[string]any{
+				"post": map[string]any{
+					"summary":    "Send a vote.",
+					"parameters": []any{pollIDParameter},
+					"requestBody": map[string]any{
+						"required": true,
+						"content":  jsonContent(vote.BallotSchema()),
+					},
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "The vote was saved.",
+							"content":     jsonContent(vote.JSONSchema(voteResponse{})),
+						},
+						"default": errorResponse,
+					},
+				},
+			},
+			"/system/vote/voted": map[string]any{
+				"get": map[string]any{
+					"summary": "Tell, on which polls the request user or the delegated users have voted.",
+					"parameters": []any{
+						map[string]any{
+							"name":        "ids",
+							"in":          "query",
+							"required":    true,
+							"description": "Comma separated list of poll ids.",
+							"schema":      map[string]any{"type": "string"},
+						},
+					},
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "Poll id to the list of user ids, that have voted.",
+							"content":     jsonContent(vote.JSONSchema(map[int][]int{})),
+						},
+						"default": errorResponse,
+					},
+				},
+			},
+			"/system/vote/health": map[string]any{
+				"get": map[string]any{
+					"summary": "Check, that the service and all backends are reachable.",
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "The service is healthy.",
+							"content":     jsonContent(vote.JSONSchema(healthResponse{})),
+						},
+						"503": map[string]any{
+							"description": "At least one backend is down.",
+							"content":     jsonContent(vote.JSONSchema(healthResponse{})),
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// handleOpenAPI serves the OpenAPI description of the external routes.
+func handleOpenAPI() HandlerFunc {
+	document, err := json.Marshal(openAPIDocument())
+	if err != nil {
+		// The document only contains maps, slices and strings.
+		panic(fmt.Sprintf("encoding openapi document: %v", err))
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(document)
+		return nil
+	}
+}
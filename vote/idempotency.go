@@ -0,0 +1,155 @@
+package vote
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// WithIdempotencyKey returns a context that carries the idempotency key of a
+// vote request.
+//
+// If a vote request with the same key is sent again for the same poll and vote
+// user, the result of the first request is returned instead of a double vote
+// error.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyKey, key)
+}
+
+func idempotencyKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKeyKey).(string)
+	return key
+}
+
+type idempotencyID struct {
+	pollID   int
+	voteUser int
+	key      string
+}
+
+type idempotencyEntry struct {
+	done    chan struct{} // done is closed, when the first request is finished.
+	pending bool
+	result  VoteResult
+	expires time.Time
+}
+
+// idempotencyCache remembers the results of successful vote requests for a
+// time.
+//
+// A ttl of 0 disables the cache.
+type idempotencyCache struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	entries   map[idempotencyID]*idempotencyEntry
+	nextSweep time.Time
+}
+
+// reserve reserves a key for a vote request.
+//
+// If a successful request with the key was already finished, its result is
+// returned with found true. If a request with the key is currently running,
+// reserve waits until it is finished.
+//
+// Otherwise the caller has to save the vote and call release with the result.
+// If the vote was not saved, the next request with the key is handled again.
+func (c *idempotencyCache) reserve(ctx context.Context, now func() time.Time, pollID, voteUser int, key string) (result VoteResult, found bool, release func(VoteResult, bool), err error) {
+	if c.ttl <= 0 || key == "" {
+		return VoteResult{}, false, func(VoteResult, bool) {}, nil
+	}
+
+	id := idempotencyID{pollID, voteUser, key}
+	for {
+		c.mu.Lock()
+		if c.entries == nil {
+			c.entries = make(map[idempotencyID]*idempotencyEntry)
+		}
+		c.sweep(now())
+
+		entry, ok := c.entries[id]
+		if ok && entry.pending {
+			c.mu.Unlock()
+
+			select {
+			case <-entry.done:
+				continue
+			case <-ctx.Done():
+				return VoteResult{}, false, nil, fmt.Errorf("waiting for the request with the same idempotency key: %w", ctx.Err())
+			}
+		}
+
+		if ok && now().Before(entry.expires) {
+			c.mu.Unlock()
+			return entry.result, true, nil, nil
+		}
+
+		entry = &idempotencyEntry{done: make(chan struct{}), pending: true}
+		c.entries[id] = entry
+		c.mu.Unlock()
+
+		release := func(result VoteResult, success bool) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+
+			entry.pending = false
+			if success {
+				entry.result = result
+				entry.expires = now().Add(c.ttl)
+			} else if c.entries[id] == entry {
+				delete(c.entries, id)
+			}
+			close(entry.done)
+		}
+		return VoteResult{}, false, release, nil
+	}
+}
+
+// sweep removes the expired entries. To not look at all entries on each vote,
+// it only does this once in the ttl.
+//
+// Has to be called with the lock.
+func (c *idempotencyCache) sweep(now time.Time) {
+	if now.Before(c.nextSweep) {
+		return
+	}
+
+	for id, entry := range c.entries {
+		if !entry.pending && now.After(entry.expires) {
+			delete(c.entries, id)
+		}
+	}
+	c.nextSweep = now.Add(c.ttl)
+}
+
+// clearPoll removes all entries of a poll.
+func (c *idempotencyCache) clearPoll(pollID int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id := range c.entries {
+		if id.pollID == pollID {
+			delete(c.entries, id)
+		}
+	}
+}
+
+// clearVoteUser removes all entries of a vote user in a poll.
+func (c *idempotencyCache) clearVoteUser(pollID, voteUser int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id := range c.entries {
+		if id.pollID == pollID && id.voteUser == voteUser {
+			delete(c.entries, id)
+		}
+	}
+}
+
+// clearAll removes all entries.
+func (c *idempotencyCache) clearAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = nil
+}
@@ -0,0 +1,63 @@
+package vote
+
+import "sync"
+
+// defaultMaintenanceMessage is the message of ErrMaintenance, if nothing else
+// is configured.
+const defaultMaintenanceMessage = "The vote service is in maintenance. Please try again later"
+
+// maintenanceMode rejects new votes and new polls, when it is enabled.
+//
+// It is only saved in memory. Each instance has to be set separately.
+type maintenanceMode struct {
+	mu             sync.Mutex
+	enabled        bool
+	message        string
+	defaultMessage string
+}
+
+func (m *maintenanceMode) set(enabled bool, message string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if message == "" {
+		message = m.defaultMessage
+	}
+
+	m.enabled = enabled
+	m.message = message
+	if !enabled {
+		m.message = ""
+	}
+}
+
+func (m *maintenanceMode) get() (bool, string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.enabled, m.message
+}
+
+// check returns ErrMaintenance, if the maintenance mode is enabled.
+func (m *maintenanceMode) check() error {
+	enabled, message := m.get()
+	if enabled {
+		return MessageError(ErrMaintenance, "%s", message)
+	}
+	return nil
+}
+
+// SetMaintenance enables or disables the maintenance mode. While it is
+// enabled, new votes and new polls are rejected with ErrMaintenance and the
+// given message. If the message is empty, the configured default message is
+// used.
+//
+// All other requests keep working.
+func (v *Vote) SetMaintenance(enabled bool, message string) {
+	v.maintenance.set(enabled, message)
+}
+
+// Maintenance returns, if the maintenance mode is enabled and its message.
+func (v *Vote) Maintenance() (bool, string) {
+	return v.maintenance.get()
+}
@@ -2,6 +2,7 @@ package vote_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/OpenSlides/openslides-autoupdate-service/pkg/datastore/dskey"
@@ -29,6 +30,22 @@ func (g *StubGetter) Get(ctx context.Context, keys ...dskey.Key) (map[dskey.Key]
 	return out, nil
 }
 
+// flakyGetter is a StubGetter, that fails the first requests for a group.
+type flakyGetter struct {
+	StubGetter
+	failures int
+}
+
+func (g *flakyGetter) Get(ctx context.Context, keys ...dskey.Key) (map[dskey.Key][]byte, error) {
+	for _, k := range keys {
+		if k.Collection() == "group" && g.failures > 0 {
+			g.failures--
+			return nil, errors.New("datastore is not reachable")
+		}
+	}
+	return g.StubGetter.Get(ctx, keys...)
+}
+
 func (g *StubGetter) Update(context.Context, func(map[dskey.Key][]byte, error)) {}
 
 func (g *StubGetter) assertKeys(t *testing.T, keys ...dskey.Key) {
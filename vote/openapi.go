@@ -0,0 +1,131 @@
+package vote
+
+import (
+	"reflect"
+	"strings"
+)
+
+// schemaOverrides are the schemas of types, that decode themself and can
+// therefore not be described by their fields.
+var schemaOverrides = map[reflect.Type]map[string]any{
+	reflect.TypeOf(maybeInt{}): {"type": "integer"},
+	reflect.TypeOf(ballotValue{}): {
+		"oneOf": []any{
+			map[string]any{
+				"description": `Global answer like "Y", "N" or "A".`,
+				"type":        "string",
+			},
+			map[string]any{
+				"description": "Option id to amount.",
+				"type":        "object",
+				"propertyNames": map[string]any{
+					"pattern": "^[0-9]+$",
+				},
+				"additionalProperties": map[string]any{"type": "integer"},
+			},
+			map[string]any{
+				"description": "Option id to Y, N or A.",
+				"type":        "object",
+				"propertyNames": map[string]any{
+					"pattern": "^[0-9]+$",
+				},
+				"additionalProperties": map[string]any{
+					"type": "string",
+					"enum": []string{"Y", "N", "A"},
+				},
+			},
+			map[string]any{
+				"description": "Answer on a text poll.",
+				"type":        "object",
+				"properties": map[string]any{
+					"text": map[string]any{"type": "string"},
+				},
+				"required":             []string{"text"},
+				"additionalProperties": false,
+			},
+		},
+	},
+}
+
+// BallotSchema returns the JSON schema of the body of a vote request.
+func BallotSchema() map[string]any {
+	return JSONSchema(ballot{})
+}
+
+// JSONSchema builds a JSON schema from the type of value by reflecting over
+// its json tags. Fields with omitempty, pointers and maybeInt are optional,
+// all other fields are required.
+func JSONSchema(value any) map[string]any {
+	return schemaOf(reflect.TypeOf(value))
+}
+
+func schemaOf(t reflect.Type) map[string]any {
+	if schema, ok := schemaOverrides[t]; ok {
+		return schema
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaOf(t.Elem())
+
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+
+	case reflect.String:
+		return map[string]any{"type": "string"}
+
+	case reflect.Slice, reflect.Array:
+		return map[string]any{
+			"type":  "array",
+			"items": schemaOf(t.Elem()),
+		}
+
+	case reflect.Map:
+		return map[string]any{
+			"type":                 "object",
+			"additionalProperties": schemaOf(t.Elem()),
+		}
+
+	case reflect.Struct:
+		properties := make(map[string]any)
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+
+			properties[name] = schemaOf(field.Type)
+			optional := strings.Contains(options, "omitempty") ||
+				field.Type.Kind() == reflect.Pointer ||
+				field.Type == reflect.TypeOf(maybeInt{})
+			if !optional {
+				required = append(required, name)
+			}
+		}
+
+		return map[string]any{
+			"type":       "object",
+			"properties": properties,
+			"required":   required,
+		}
+
+	default:
+		return map[string]any{}
+	}
+}
@@ -0,0 +1,409 @@
+package vote
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/OpenSlides/openslides-autoupdate-service/pkg/environment"
+	"github.com/OpenSlides/openslides-vote-service/backend/memory"
+)
+
+var (
+	envMaxVoters           = environment.NewVariable("VOTE_MAX_VOTERS", "0", "Maximum number of users that can vote on one poll. Votes after the limit is reached are rejected. 0 means no limit.")
+	envEntitleDefaultGroup = environment.NewVariable("VOTE_ENTITLE_DEFAULT_GROUP", "false", "Treat meeting users without any group as members of the default group of the meeting.")
+	envHideNamedIdentity   = environment.NewVariable("VOTE_HIDE_NAMED_IDENTITY", "false", "Do not save the user ids in the votes of named polls. The users, that have voted, are still returned when a poll is stopped.")
+	envLiveResults         = environment.NewVariable("VOTE_LIVE_RESULTS", "false", "Show results like the turnout of named polls, before the poll is stopped. Polls, that are not named, never show results before they are stopped.")
+	envMaxTextLength       = environment.NewVariable("VOTE_MAX_TEXT_LENGTH", strconv.Itoa(defaultMaxTextLength), "Maximum length in bytes of a ballot on a poll with the method TEXT.")
+	envDelegateEntitled    = environment.NewVariable("VOTE_DELEGATE_MUST_BE_ENTITLED", "false", "A user, that votes for someone else, has to be in an entitled group himself.")
+	envAbsentDelegates     = environment.NewVariable("VOTE_ALLOW_ABSENT_DELEGATES", "true", "A user, that is not present, can be represented by a present user. If false, both have to be present.")
+	envMaxDelegationDepth  = environment.NewVariable("VOTE_MAX_DELEGATION_DEPTH", "1", "Maximum length of a delegation chain. With 2, a user can vote for a user, that delegated to someone, who delegated to him.")
+	envRejectExplicitUser  = environment.NewVariable("VOTE_REJECT_EXPLICIT_USER", "false", "Reject ballots with the field `user_id` in meetings without vote delegation, even if it is the id of the request user.")
+	envCanonicalBallots    = environment.NewVariable("VOTE_CANONICAL_BALLOTS", "false", "Save the value of a ballot with sorted keys and without whitespace. Ballots with the same meaning are saved with the same bytes.")
+	envWeightDecimals      = environment.NewVariable("VOTE_WEIGHT_DECIMALS", strconv.Itoa(weightDecimals), "Number of decimal places of the vote weight, that is saved with a vote. Weights with more places are rounded. The maximum is 6.")
+	envPreloadRetries      = environment.NewVariable("VOTE_PRELOAD_RETRIES", "2", "Number of retries, when the datastore fails while a poll is started.")
+	envPreloadBackoff      = environment.NewVariable("VOTE_PRELOAD_RETRY_BACKOFF", "100ms", "Time to wait before the first retry of a failed datastore request, when a poll is started. It is doubled with each retry.")
+	envStoppedRetention    = environment.NewVariable("VOTE_STOPPED_RETENTION", "0", "Time after which stopped polls are cleared automatically. The time, when a poll was stopped, is saved in the backend. 0 disables the feature.")
+	envMaintenanceMessage  = environment.NewVariable("VOTE_MAINTENANCE_MESSAGE", defaultMaintenanceMessage, "Message for rejected requests, while the maintenance mode is enabled.")
+	envStartupSelfcheck    = environment.NewVariable("VOTE_STARTUP_SELFCHECK", "false", "Start, vote on, stop and clear a dummy poll on each backend at startup. The service does not start, if a backend fails.")
+	envPublishVoterList    = environment.NewVariable("VOTE_PUBLISH_VOTER_LIST", "false", "Mark the list of users, that voted on a pseudoanonymous poll, as public in the stop result. The ballots stay anonymous.")
+	envNormalizeGlobal     = environment.NewVariable("VOTE_NORMALIZE_GLOBAL", "false", "Save global answers like `Y` as object with the answer and the vote weight of the user: `{\"global\":\"Y\",\"weight\":\"1.000000\"}`.")
+	envMaxConcurrentStops  = environment.NewVariable("VOTE_MAX_CONCURRENT_STOPS", "0", "Maximum number of polls, that are stopped at the same time. More stop requests wait until a stop is finished. 0 means no limit.")
+	envBackendTimeout      = environment.NewVariable("VOTE_BACKEND_TIMEOUT", "5s", "Maximum time for a call to the fast or the long backend. A request, that reaches the timeout, is answered with a temporary error. 0 disables the timeout.")
+	envIdempotencyTTL      = environment.NewVariable("VOTE_IDEMPOTENCY_TTL", "0", "Time to remember the `Idempotency-Key` of successful vote requests. A repeated request with the same key returns the first result instead of a double vote error. 0 disables the feature.")
+	envPresenceCacheTTL    = environment.NewVariable("VOTE_PRESENCE_CACHE_TTL", "0", "Time to remember, if a user is present in a meeting. It reduces the datastore requests, when many users vote at the same time. 0 disables the cache.")
+)
+
+// defaultBackendTimeout is the maximum time for a backend call, if nothing else
+// is configured.
+const defaultBackendTimeout = 5 * time.Second
+
+// defaultMaxTextLength is the maximum length of a text ballot, if nothing else
+// is configured.
+const defaultMaxTextLength = 256
+
+// Option is an optional argument for vote.New.
+type Option func(*Vote)
+
+// WithMaxVoters limits the number of users that can vote on one poll.
+//
+// Zero or a negative number means, that there is no limit.
+func WithMaxVoters(n int) Option {
+	return func(v *Vote) {
+		v.maxVoters = n
+	}
+}
+
+// WithMaxConcurrentStops limits the number of backend calls to stop a poll,
+// that run at the same time. Other calls wait, until a call is finished.
+//
+// Zero or a negative number means, that there is no limit.
+func WithMaxConcurrentStops(n int) Option {
+	return func(v *Vote) {
+		v.stopSlots = nil
+		if n > 0 {
+			v.stopSlots = make(chan struct{}, n)
+		}
+	}
+}
+
+// WithDevelopment enables features, that are only for debugging, like
+// ForceBackend.
+func WithDevelopment(enabled bool) Option {
+	return func(v *Vote) {
+		v.memoryBackend = nil
+		if enabled {
+			v.memoryBackend = memory.New()
+		}
+	}
+}
+
+// WithDefaultGroupEntitlement treats meeting users without groups as members
+// of the default group of the meeting. If the default group is entitled for a
+// poll, this users can vote.
+func WithDefaultGroupEntitlement(enabled bool) Option {
+	return func(v *Vote) {
+		v.entitleDefaultGroup = enabled
+	}
+}
+
+// WithDelegateMustBeEntitled requires, that a user, that votes for someone
+// else, is in an entitled group himself. Without this option, only the
+// represented user has to be entitled.
+func WithDelegateMustBeEntitled(enabled bool) Option {
+	return func(v *Vote) {
+		v.delegateMustBeEntitled = enabled
+	}
+}
+
+// WithAbsentDelegates allows a present user to vote for a user, that has
+// delegated his vote and is not present. This is the default. If it is
+// disabled, the represented user also has to be present.
+//
+// The request user always has to be present.
+func WithAbsentDelegates(allowed bool) Option {
+	return func(v *Vote) {
+		v.allowAbsentDelegates = allowed
+	}
+}
+
+// WithMaxDelegationDepth allows to vote over a chain of delegations. If user A
+// delegated to B and B delegated to C, C can vote for A with a depth of 2.
+//
+// The default is 1, so only direct delegations are allowed. Values smaller then
+// 1 are treated as 1.
+func WithMaxDelegationDepth(depth int) Option {
+	return func(v *Vote) {
+		v.maxDelegationDepth = max(depth, 1)
+	}
+}
+
+// WithRejectExplicitUser rejects ballots with a user_id in meetings, where
+// vote delegation is not activated. Without this option, the user_id of the
+// request user is accepted.
+func WithRejectExplicitUser(enabled bool) Option {
+	return func(v *Vote) {
+		v.rejectExplicitUser = enabled
+	}
+}
+
+// WithCanonicalBallots saves the value of each ballot in a canonical form. The
+// keys are sorted and whitespace is removed. This helps to compare and hash
+// the saved votes.
+func WithCanonicalBallots(enabled bool) Option {
+	return func(v *Vote) {
+		v.canonicalBallots = enabled
+	}
+}
+
+// WithPublishVoterList marks the user ids of stopped pseudoanonymous polls as
+// public. Who voted can be shown, but the ballots stay anonymous.
+func WithPublishVoterList(enabled bool) Option {
+	return func(v *Vote) {
+		v.publishVoterList = enabled
+	}
+}
+
+// WithNormalizeGlobal saves global answers like "Y" as an object with the
+// answer and the vote weight, so they can be tallied without the weight field
+// of the vote object.
+func WithNormalizeGlobal(enabled bool) Option {
+	return func(v *Vote) {
+		v.normalizeGlobal = enabled
+	}
+}
+
+// WithHiddenNamedIdentity removes the request user and the vote user from the
+// votes of named polls, like on the other poll types.
+func WithHiddenNamedIdentity(enabled bool) Option {
+	return func(v *Vote) {
+		v.hideNamedIdentity = enabled
+	}
+}
+
+// WithLiveResults allows to read the results of named polls, before they are
+// stopped. The results of other polls are never shown, before they are
+// stopped.
+func WithLiveResults(enabled bool) Option {
+	return func(v *Vote) {
+		v.liveResults = enabled
+	}
+}
+
+// WithMaxTextLength sets the maximum length in bytes of a ballot on a poll with
+// the method TEXT.
+func WithMaxTextLength(n int) Option {
+	return func(v *Vote) {
+		v.maxTextLength = n
+	}
+}
+
+// WithWeightDecimals sets the number of decimal places of the vote weight, that
+// is saved with a vote. The value is limited to 0 to 6.
+func WithWeightDecimals(n int) Option {
+	return func(v *Vote) {
+		v.weightPrecision = min(max(n, 0), weightDecimals)
+	}
+}
+
+// WithPreloadRetry retries to preload the datastore, when a poll is started and
+// the datastore returns a transient error. Before the first retry, it waits for
+// the backoff. The backoff is doubled with each retry.
+func WithPreloadRetry(retries int, backoff time.Duration) Option {
+	return func(v *Vote) {
+		v.preloadRetries = retries
+		v.preloadBackoff = backoff
+	}
+}
+
+// WithPreloadExtension registers additional keys, that are preloaded, when a
+// poll is started. A nil extension removes a registered extension.
+func WithPreloadExtension(extension PreloadExtension) Option {
+	return func(v *Vote) {
+		v.preloadExtension = noPreloadExtension
+		if extension != nil {
+			v.preloadExtension = extension
+		}
+	}
+}
+
+// WithBallotTransformer registers a function, that changes each ballot, before
+// it is saved in the backend. A nil transformer removes a registered
+// transformer.
+func WithBallotTransformer(transformer BallotTransformer) Option {
+	return func(v *Vote) {
+		v.ballotTransformer = noBallotTransformer
+		if transformer != nil {
+			v.ballotTransformer = transformer
+		}
+	}
+}
+
+// WithBackendTimeout sets the maximum time for a call to a backend. Zero or a
+// negative value disables the timeout.
+func WithBackendTimeout(timeout time.Duration) Option {
+	return func(v *Vote) {
+		v.backendTimeout = timeout
+	}
+}
+
+// WithIdempotencyTTL sets the time, the results of vote requests with an
+// idempotency key are remembered. See WithIdempotencyKey.
+func WithIdempotencyTTL(ttl time.Duration) Option {
+	return func(v *Vote) {
+		v.idempotency.ttl = ttl
+	}
+}
+
+// WithPresenceCacheTTL sets the time, it is remembered, if a user is present in
+// a meeting. A change of the presence is noticed after this time. Zero or a
+// negative value disables the cache.
+func WithPresenceCacheTTL(ttl time.Duration) Option {
+	return func(v *Vote) {
+		v.presence.ttl = ttl
+	}
+}
+
+// WithStoppedRetention clears polls automatically, after they were stopped for
+// the given time. The results should be saved somewhere else in this time.
+//
+// Zero or a negative duration disables the feature.
+func WithStoppedRetention(retention time.Duration) Option {
+	return func(v *Vote) {
+		v.stoppedRetention = retention
+	}
+}
+
+// WithMaintenanceMessage sets the default message, that is returned while the
+// maintenance mode is enabled.
+func WithMaintenanceMessage(message string) Option {
+	return func(v *Vote) {
+		v.maintenance.defaultMessage = message
+	}
+}
+
+// WithStartupSelfcheck runs a full poll cycle on each backend in vote.New. If
+// a backend misbehaves, vote.New returns an error.
+func WithStartupSelfcheck(enabled bool) Option {
+	return func(v *Vote) {
+		v.startupSelfcheck = enabled
+	}
+}
+
+// Options reads the options of the vote service from the environment.
+func Options(lookup environment.Environmenter) ([]Option, error) {
+	maxVoters, err := strconv.Atoi(envMaxVoters.Value(lookup))
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for `%s`, expected int got %s: %w", envMaxVoters.Key, envMaxVoters.Value(lookup), err)
+	}
+
+	maxConcurrentStops, err := strconv.Atoi(envMaxConcurrentStops.Value(lookup))
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for `%s`, expected int got %s: %w", envMaxConcurrentStops.Key, envMaxConcurrentStops.Value(lookup), err)
+	}
+
+	entitleDefaultGroup, err := strconv.ParseBool(envEntitleDefaultGroup.Value(lookup))
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for `%s`, expected bool got %s: %w", envEntitleDefaultGroup.Key, envEntitleDefaultGroup.Value(lookup), err)
+	}
+
+	delegateEntitled, err := strconv.ParseBool(envDelegateEntitled.Value(lookup))
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for `%s`, expected bool got %s: %w", envDelegateEntitled.Key, envDelegateEntitled.Value(lookup), err)
+	}
+
+	absentDelegates, err := strconv.ParseBool(envAbsentDelegates.Value(lookup))
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for `%s`, expected bool got %s: %w", envAbsentDelegates.Key, envAbsentDelegates.Value(lookup), err)
+	}
+
+	maxDelegationDepth, err := strconv.Atoi(envMaxDelegationDepth.Value(lookup))
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for `%s`, expected int got %s: %w", envMaxDelegationDepth.Key, envMaxDelegationDepth.Value(lookup), err)
+	}
+
+	rejectExplicitUser, err := strconv.ParseBool(envRejectExplicitUser.Value(lookup))
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for `%s`, expected bool got %s: %w", envRejectExplicitUser.Key, envRejectExplicitUser.Value(lookup), err)
+	}
+
+	canonicalBallots, err := strconv.ParseBool(envCanonicalBallots.Value(lookup))
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for `%s`, expected bool got %s: %w", envCanonicalBallots.Key, envCanonicalBallots.Value(lookup), err)
+	}
+
+	hideNamedIdentity, err := strconv.ParseBool(envHideNamedIdentity.Value(lookup))
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for `%s`, expected bool got %s: %w", envHideNamedIdentity.Key, envHideNamedIdentity.Value(lookup), err)
+	}
+
+	publishVoterList, err := strconv.ParseBool(envPublishVoterList.Value(lookup))
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for `%s`, expected bool got %s: %w", envPublishVoterList.Key, envPublishVoterList.Value(lookup), err)
+	}
+
+	normalizeGlobal, err := strconv.ParseBool(envNormalizeGlobal.Value(lookup))
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for `%s`, expected bool got %s: %w", envNormalizeGlobal.Key, envNormalizeGlobal.Value(lookup), err)
+	}
+
+	liveResults, err := strconv.ParseBool(envLiveResults.Value(lookup))
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for `%s`, expected bool got %s: %w", envLiveResults.Key, envLiveResults.Value(lookup), err)
+	}
+
+	maxTextLength, err := strconv.Atoi(envMaxTextLength.Value(lookup))
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for `%s`, expected int got %s: %w", envMaxTextLength.Key, envMaxTextLength.Value(lookup), err)
+	}
+
+	voteWeightDecimals, err := strconv.Atoi(envWeightDecimals.Value(lookup))
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for `%s`, expected int got %s: %w", envWeightDecimals.Key, envWeightDecimals.Value(lookup), err)
+	}
+
+	preloadRetries, err := strconv.Atoi(envPreloadRetries.Value(lookup))
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for `%s`, expected int got %s: %w", envPreloadRetries.Key, envPreloadRetries.Value(lookup), err)
+	}
+
+	preloadBackoff, err := environment.ParseDuration(envPreloadBackoff.Value(lookup))
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for `%s`, expected duration got %s: %w", envPreloadBackoff.Key, envPreloadBackoff.Value(lookup), err)
+	}
+
+	idempotencyTTL, err := environment.ParseDuration(envIdempotencyTTL.Value(lookup))
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for `%s`, expected duration got %s: %w", envIdempotencyTTL.Key, envIdempotencyTTL.Value(lookup), err)
+	}
+
+	presenceCacheTTL, err := environment.ParseDuration(envPresenceCacheTTL.Value(lookup))
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for `%s`, expected duration got %s: %w", envPresenceCacheTTL.Key, envPresenceCacheTTL.Value(lookup), err)
+	}
+
+	stoppedRetention, err := environment.ParseDuration(envStoppedRetention.Value(lookup))
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for `%s`, expected duration got %s: %w", envStoppedRetention.Key, envStoppedRetention.Value(lookup), err)
+	}
+
+	backendTimeout, err := environment.ParseDuration(envBackendTimeout.Value(lookup))
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for `%s`, expected duration got %s: %w", envBackendTimeout.Key, envBackendTimeout.Value(lookup), err)
+	}
+
+	development, err := strconv.ParseBool(environment.EnvDevelopment.Value(lookup))
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for `%s`, expected bool got %s: %w", environment.EnvDevelopment.Key, environment.EnvDevelopment.Value(lookup), err)
+	}
+
+	startupSelfcheck, err := strconv.ParseBool(envStartupSelfcheck.Value(lookup))
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for `%s`, expected bool got %s: %w", envStartupSelfcheck.Key, envStartupSelfcheck.Value(lookup), err)
+	}
+
+	return []Option{
+		WithMaxVoters(maxVoters),
+		WithMaxConcurrentStops(maxConcurrentStops),
+		WithDefaultGroupEntitlement(entitleDefaultGroup),
+		WithDelegateMustBeEntitled(delegateEntitled),
+		WithAbsentDelegates(absentDelegates),
+		WithMaxDelegationDepth(maxDelegationDepth),
+		WithRejectExplicitUser(rejectExplicitUser),
+		WithCanonicalBallots(canonicalBallots),
+		WithHiddenNamedIdentity(hideNamedIdentity),
+		WithPublishVoterList(publishVoterList),
+		WithNormalizeGlobal(normalizeGlobal),
+		WithLiveResults(liveResults),
+		WithMaxTextLength(maxTextLength),
+		WithWeightDecimals(voteWeightDecimals),
+		WithPreloadRetry(preloadRetries, preloadBackoff),
+		WithIdempotencyTTL(idempotencyTTL),
+		WithPresenceCacheTTL(presenceCacheTTL),
+		WithStoppedRetention(stoppedRetention),
+		WithMaintenanceMessage(envMaintenanceMessage.Value(lookup)),
+		WithStartupSelfcheck(startupSelfcheck),
+		WithDevelopment(development),
+		WithBackendTimeout(backendTimeout),
+	}, nil
+}
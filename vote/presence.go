@@ -0,0 +1,77 @@
+package vote
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/OpenSlides/openslides-autoupdate-service/pkg/datastore/dsfetch"
+)
+
+type presenceID struct {
+	userID    int
+	meetingID int
+}
+
+type presenceEntry struct {
+	present bool
+	expires time.Time
+}
+
+// presenceCache remembers for a short time, if a user is present in a
+// meeting. It reduces the datastore requests, when many users vote at the same
+// time and the flow has no cache.
+//
+// A ttl of 0 disables the cache.
+type presenceCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[presenceID]presenceEntry
+}
+
+// isPresent is like the function isPresent, but uses the cache.
+func (c *presenceCache) isPresent(ctx context.Context, ds *dsfetch.Fetch, meetingID, user int) (bool, error) {
+	if c.ttl <= 0 {
+		return isPresent(ctx, ds, meetingID, user)
+	}
+
+	id := presenceID{userID: user, meetingID: meetingID}
+
+	c.mu.Lock()
+	entry, ok := c.entries[id]
+	c.mu.Unlock()
+
+	now := time.Now()
+	if ok && now.Before(entry.expires) {
+		return entry.present, nil
+	}
+
+	present, err := isPresent(ctx, ds, meetingID, user)
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[presenceID]presenceEntry)
+	}
+
+	for id, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, id)
+		}
+	}
+
+	c.entries[id] = presenceEntry{present: present, expires: now.Add(c.ttl)}
+	return present, nil
+}
+
+// clearAll removes all entries.
+func (c *presenceCache) clearAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = nil
+}
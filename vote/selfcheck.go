@@ -0,0 +1,85 @@
+package vote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+)
+
+// selfcheckPollIDs is the number of poll ids, that are reserved for the
+// selfcheck. They are the biggest ids, that fit into the postgres schema.
+const selfcheckPollIDs = 1_000_000
+
+// newSelfcheckPollID returns a random id from the reserved poll ids. Each
+// instance uses its own id, so instances, that start at the same time, do not
+// check the same poll.
+func newSelfcheckPollID() int {
+	return math.MaxInt32 - rand.IntN(selfcheckPollIDs)
+}
+
+// selfcheck runs a full cycle of a poll on each backend. It returns an error,
+// if a backend does not behave as expected.
+func (v *Vote) selfcheck(ctx context.Context) error {
+	backends := []Backend{v.fastBackend}
+	if v.longBackend != v.fastBackend {
+		backends = append(backends, v.longBackend)
+	}
+
+	pollID := newSelfcheckPollID()
+	for _, backend := range backends {
+		if err := selfcheckBackend(ctx, backend, pollID); err != nil {
+			return fmt.Errorf("backend %s: %w", backend, err)
+		}
+	}
+	return nil
+}
+
+func selfcheckBackend(ctx context.Context, backend Backend, pollID int) (err error) {
+	const userID = 1
+	object := []byte(`"selfcheck"`)
+
+	if err := backend.Clear(ctx, pollID); err != nil {
+		return fmt.Errorf("clearing poll before check: %w", err)
+	}
+
+	defer func() {
+		if clearErr := backend.Clear(ctx, pollID); clearErr != nil && err == nil {
+			err = fmt.Errorf("clearing poll: %w", clearErr)
+		}
+	}()
+
+	if err := backend.Start(ctx, pollID); err != nil {
+		return fmt.Errorf("starting poll: %w", err)
+	}
+
+	if err := backend.Vote(ctx, pollID, userID, object); err != nil {
+		return fmt.Errorf("saving vote: %w", err)
+	}
+
+	var errDoubleVote interface{ DoubleVote() }
+	if err := backend.Vote(ctx, pollID, userID, object); !errors.As(err, &errDoubleVote) {
+		return fmt.Errorf("second vote of the same user returned %v, expected a double vote error", err)
+	}
+
+	objects, userIDs, err := backend.Stop(ctx, pollID)
+	if err != nil {
+		return fmt.Errorf("stopping poll: %w", err)
+	}
+
+	if len(objects) != 1 || string(objects[0]) != string(object) {
+		return fmt.Errorf("stop returned the objects %q, expected [%q]", objects, object)
+	}
+
+	if len(userIDs) != 1 || userIDs[0] != userID {
+		return fmt.Errorf("stop returned the user ids %v, expected [%d]", userIDs, userID)
+	}
+
+	var errStopped interface{ Stopped() }
+	if err := backend.Vote(ctx, pollID, userID+1, object); !errors.As(err, &errStopped) {
+		return fmt.Errorf("vote on stopped poll returned %v, expected a stopped error", err)
+	}
+
+	return nil
+}
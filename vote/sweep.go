@@ -0,0 +1,116 @@
+package vote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/OpenSlides/openslides-autoupdate-service/pkg/datastore/dsfetch"
+	"github.com/OpenSlides/openslides-vote-service/log"
+)
+
+// timeStopped is the name of the time in the backend, when a poll was stopped.
+const timeStopped = "stopped"
+
+// sweepInterval is the longest time between two sweeps of stopped polls.
+const sweepInterval = time.Minute
+
+// sweepStopped clears all polls, that were stopped longer then the retention
+// time before now.
+//
+// The time, when a poll was stopped, is saved in the backend by Stop. For a
+// stopped poll without this time, for example, if it could not be saved, the
+// retention starts, when it is found. Polls, that are started in the
+// datastore, are never cleared.
+func (v *Vote) sweepStopped(ctx context.Context, now time.Time) error {
+	expired := make(map[int]time.Time)
+	for _, named := range v.namedBackends() {
+		var polls map[int]bool
+		var stoppedAt map[int]time.Time
+		err := v.withBackendTimeout(ctx, "polls", func(ctx context.Context) error {
+			var err error
+			polls, err = named.backend.Polls(ctx)
+			if err != nil {
+				return err
+			}
+
+			stoppedAt, err = named.backend.Times(ctx, timeStopped)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("fetching polls from %s backend: %w", named.name, err)
+		}
+
+		for pollID, stopped := range polls {
+			if !stopped {
+				continue
+			}
+
+			at, ok := stoppedAt[pollID]
+			if !ok {
+				err := v.withBackendTimeout(ctx, "set stop time", func(ctx context.Context) error {
+					return named.backend.SetTime(ctx, pollID, timeStopped, now, false)
+				})
+				if err != nil {
+					return fmt.Errorf("saving stop time of poll %d in %s backend: %w", pollID, named.name, err)
+				}
+				continue
+			}
+
+			if now.Sub(at) > v.stoppedRetention {
+				expired[pollID] = at
+			}
+		}
+	}
+
+	ds := dsfetch.New(v.flow)
+	for pollID, stoppedAt := range expired {
+		state, err := ds.Poll_State(pollID).Value(ctx)
+		if err != nil {
+			var errDoesNotExist dsfetch.DoesNotExistError
+			if !errors.As(err, &errDoesNotExist) {
+				return fmt.Errorf("fetching state of poll %d: %w", pollID, err)
+			}
+		}
+
+		if state == "started" {
+			// The poll was reopened.
+			continue
+		}
+
+		if err := v.Clear(ctx, pollID); err != nil {
+			return fmt.Errorf("clearing poll %d: %w", pollID, err)
+		}
+
+		log.Info("Cleared poll %d, that was stopped at %s", pollID, stoppedAt.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// sweepStoppedLoop calls sweepStopped periodically until the context is done.
+//
+// The interval does not depend on the retention time, so a poll is cleared at
+// most sweepInterval after its retention time.
+func (v *Vote) sweepStoppedLoop(ctx context.Context, errorHandler func(error)) {
+	interval := sweepInterval
+	if v.stoppedRetention < interval {
+		interval = v.stoppedRetention
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			if err := v.sweepStopped(ctx, v.now()); err != nil {
+				errorHandler(fmt.Errorf("sweeping stopped polls: %w", err))
+			}
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package vote
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/OpenSlides/openslides-autoupdate-service/pkg/datastore/dsfetch"
+)
+
+// weightDecimals is the number of decimal places of a vote weight.
+const weightDecimals = 6
+
+// Tally is the weighted result of a stopped poll.
+type Tally struct {
+	// TotalWeight is the sum of the weights of all votes.
+	TotalWeight string `json:"total_weight"`
+
+	Options map[int]OptionTally `json:"options"`
+}
+
+// OptionTally is the weighted result of one option.
+type OptionTally struct {
+	// Weight is the sum of the weights, that were given to the option. A vote
+	// on an option with an amount counts amount times.
+	Weight string `json:"weight"`
+
+	// Fraction is the part of the total weight, that the option got.
+	Fraction float64 `json:"fraction"`
+
+	// ExceedsCap is true, if the fraction is bigger then the allowed maximum.
+	ExceedsCap bool `json:"exceeds_cap"`
+}
+
+// TallyWithCaps sums the weights of the votes for each option of a stopped
+// poll. Options, that got more then maxFraction of the total weight, are
+// flagged.
+//
+// Only votes on options are counted for the options. Global answers like "Y"
+// only count for the total weight.
+func (v *Vote) TallyWithCaps(ctx context.Context, pollID int, maxFraction float64) (Tally, error) {
+	if maxFraction <= 0 || maxFraction > 1 {
+		return Tally{}, MessageError(ErrInvalid, "max_fraction has to be bigger then 0 and at most 1, got %v", maxFraction)
+	}
+
+	ds := dsfetch.New(v.flow)
+	poll, err := loadPoll(ctx, ds, pollID)
+	if err != nil {
+		return Tally{}, fmt.Errorf("loading poll: %w", err)
+	}
+
+	if poll.state == "started" {
+		return Tally{}, MessageError(ErrNotAllowed, "Poll %d is not stopped", pollID)
+	}
+
+	result, err := v.stoppedResult(ctx, poll)
+	if err != nil {
+		return Tally{}, err
+	}
+
+	var total int64
+	optionWeights := make(map[int]int64, len(poll.options))
+	for _, optionID := range poll.options {
+		optionWeights[optionID] = 0
+	}
+
+	for i, object := range result.Votes {
+		var saved struct {
+			Value  storedValue `json:"value"`
+			Weight string      `json:"weight"`
+		}
+		if err := json.Unmarshal(object, &saved); err != nil {
+			return Tally{}, fmt.Errorf("decoding vote %d: %w", i, err)
+		}
+
+		weight, err := parseWeight(saved.Weight)
+		if err != nil {
+			return Tally{}, fmt.Errorf("vote %d: %w", i, err)
+		}
+
+		total += weight
+
+		for optionID, amount := range saved.Value.optionAmount {
+			optionWeights[optionID] += int64(amount) * weight
+		}
+
+		for optionID, answer := range saved.Value.optionYNA {
+			if answer == "Y" {
+				optionWeights[optionID] += weight
+			}
+		}
+	}
+
+	tally := Tally{
+		TotalWeight: formatWeight(total),
+		Options:     make(map[int]OptionTally, len(optionWeights)),
+	}
+
+	for optionID, weight := range optionWeights {
+		var fraction float64
+		if total > 0 {
+			fraction = float64(weight) / float64(total)
+		}
+
+		tally.Options[optionID] = OptionTally{
+			Weight:     formatWeight(weight),
+			Fraction:   fraction,
+			ExceedsCap: fraction > maxFraction,
+		}
+	}
+
+	return tally, nil
+}
+
+// parseWeight parses a vote weight like "1.500000" to an integer in millionth.
+// This prevents rounding errors, when many weights are added.
+func parseWeight(weight string) (int64, error) {
+	intPart, fracPart, _ := strings.Cut(weight, ".")
+	if intPart == "" {
+		return 0, fmt.Errorf("invalid weight %s", weight)
+	}
+
+	if len(fracPart) > weightDecimals {
+		return 0, fmt.Errorf("weight %s has more then %d decimal places", weight, weightDecimals)
+	}
+	fracPart += strings.Repeat("0", weightDecimals-len(fracPart))
+
+	n, err := strconv.ParseInt(intPart+fracPart, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid weight %s", weight)
+	}
+	return n, nil
+}
+
+// formatWeight is the reverse of parseWeight.
+func formatWeight(weight int64) string {
+	s := fmt.Sprintf("%0*d", weightDecimals+1, weight)
+	return s[:len(s)-weightDecimals] + "." + s[len(s)-weightDecimals:]
+}
+
+// normalizeWeight parses a decimal weight and formats it with the given number
+// of decimal places. Additional places are rounded half up.
+func normalizeWeight(weight string, decimals int) (string, error) {
+	intPart, fracPart, _ := strings.Cut(weight, ".")
+	if intPart == "" || !onlyDigits(intPart) || !onlyDigits(fracPart) {
+		return "", fmt.Errorf("invalid weight %s", weight)
+	}
+
+	roundUp := false
+	if len(fracPart) > decimals {
+		roundUp = fracPart[decimals] >= '5'
+		fracPart = fracPart[:decimals]
+	}
+	fracPart += strings.Repeat("0", decimals-len(fracPart))
+
+	n, err := strconv.ParseInt(intPart+fracPart, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid weight %s", weight)
+	}
+
+	if roundUp {
+		n++
+	}
+
+	if decimals == 0 {
+		return strconv.FormatInt(n, 10), nil
+	}
+
+	s := fmt.Sprintf("%0*d", decimals+1, n)
+	return s[:len(s)-decimals] + "." + s[len(s)-decimals:], nil
+}
+
+func onlyDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
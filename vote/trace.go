@@ -0,0 +1,80 @@
+package vote
+
+import (
+	"context"
+	"time"
+)
+
+// Trace holds the time spent in the phases of a vote request.
+type Trace struct {
+	// Load is the time to load the poll and check the users from the
+	// datastore.
+	Load time.Duration
+
+	// Validation is the time to validate the ballot.
+	Validation time.Duration
+
+	// Weight is the time to find the vote weight.
+	Weight time.Duration
+
+	// Backend is the time to save the vote in the backend.
+	Backend time.Duration
+}
+
+// WithTrace returns a context that enables tracing for a vote request. The
+// returned Trace is filled, while the vote is processed.
+func WithTrace(ctx context.Context) (context.Context, *Trace) {
+	trace := new(Trace)
+	return context.WithValue(ctx, traceKey, trace), trace
+}
+
+func traceFromContext(ctx context.Context) *Trace {
+	trace, _ := ctx.Value(traceKey).(*Trace)
+	return trace
+}
+
+type tracePhase int
+
+const (
+	phaseLoad tracePhase = iota
+	phaseValidation
+	phaseWeight
+	phaseBackend
+)
+
+// stopwatch measures the phases of a vote request. It does nothing, if tracing
+// is not enabled in the context.
+type stopwatch struct {
+	trace *Trace
+	last  time.Time
+}
+
+func newStopwatch(ctx context.Context) *stopwatch {
+	trace := traceFromContext(ctx)
+	if trace == nil {
+		return &stopwatch{}
+	}
+	return &stopwatch{trace: trace, last: time.Now()}
+}
+
+// lap adds the time since the last lap to the phase.
+func (s *stopwatch) lap(phase tracePhase) {
+	if s.trace == nil {
+		return
+	}
+
+	now := time.Now()
+	d := now.Sub(s.last)
+	s.last = now
+
+	switch phase {
+	case phaseLoad:
+		s.trace.Load += d
+	case phaseValidation:
+		s.trace.Validation += d
+	case phaseWeight:
+		s.trace.Weight += d
+	case phaseBackend:
+		s.trace.Backend += d
+	}
+}
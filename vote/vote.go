@@ -1,11 +1,18 @@
 package vote
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,6 +21,7 @@ import (
 	"github.com/OpenSlides/openslides-autoupdate-service/pkg/datastore/dsrecorder"
 	"github.com/OpenSlides/openslides-autoupdate-service/pkg/datastore/flow"
 	"github.com/OpenSlides/openslides-vote-service/log"
+	"golang.org/x/sync/errgroup"
 )
 
 // Vote holds the state of the service.
@@ -26,23 +34,104 @@ type Vote struct {
 
 	votedMu sync.Mutex
 	voted   map[int][]int // voted holds for all running polls, which user ids have already voted.
+	pending map[int]int   // pending holds for all polls the number of votes, that are currently saved.
+
+	entitledMu sync.Mutex
+	entitled   map[int]map[int]struct{} // entitled holds the explicit entitled users for polls, that were started with StartWithEntitled.
+
+	preloadedMu sync.Mutex
+	preloaded   map[dskey.Key]struct{} // preloaded holds the datastore keys, that were loaded, when polls were started.
+
+	forcedMu      sync.Mutex
+	forced        map[int]string // forced holds the backend names, that were forced with ForceBackend.
+	memoryBackend Backend        // memoryBackend is only set in development for ForceBackend.
+
+	preloadExtension  PreloadExtension  // preloadExtension returns additional keys to preload, when a poll is started.
+	ballotTransformer BallotTransformer // ballotTransformer changes each ballot, before it is saved.
+
+	deadlinesMu sync.Mutex
+	deadlines   map[int]deadline // deadlines holds the automatic stops of polls.
+	afterFunc   afterFunc
+	now         func() time.Time
+
+	stopSlots chan struct{} // stopSlots limits the number of concurrent backend stops. nil means no limit.
+
+	drainMu  sync.Mutex
+	draining bool           // draining is true, after Drain was called. New votes are rejected.
+	inflight sync.WaitGroup // inflight counts the vote requests, that are currently processed.
+
+	singleInstance         bool
+	maxVoters              int
+	entitleDefaultGroup    bool
+	delegateMustBeEntitled bool
+	allowAbsentDelegates   bool
+	maxDelegationDepth     int
+	canonicalBallots       bool
+	hideNamedIdentity      bool
+	publishVoterList       bool
+	normalizeGlobal        bool
+	rejectExplicitUser     bool
+	liveResults            bool
+	maxTextLength          int
+	weightPrecision        int
+	preloadRetries         int
+	preloadBackoff         time.Duration
+	stoppedRetention       time.Duration
+	startupSelfcheck       bool
+	backendTimeout         time.Duration
+
+	idempotency idempotencyCache
+	presence    presenceCache
+	audit       auditLog
+	maintenance maintenanceMode
 }
 
 // New creates an initializes vote service.
-func New(ctx context.Context, fast, long Backend, flow flow.Flow, singleInstance bool) (*Vote, func(context.Context, func(error)), error) {
+func New(ctx context.Context, fast, long Backend, flow flow.Flow, singleInstance bool, options ...Option) (*Vote, func(context.Context, func(error)), error) {
 	v := &Vote{
-		fastBackend: fast,
-		longBackend: long,
-		flow:        flow,
+		fastBackend:          fast,
+		longBackend:          long,
+		flow:                 flow,
+		singleInstance:       singleInstance,
+		pending:              make(map[int]int),
+		entitled:             make(map[int]map[int]struct{}),
+		allowAbsentDelegates: true,
+		maxDelegationDepth:   1,
+		weightPrecision:      weightDecimals,
+		backendTimeout:       defaultBackendTimeout,
+		preloadExtension:     noPreloadExtension,
+		ballotTransformer:    noBallotTransformer,
+		deadlines:            make(map[int]deadline),
+		afterFunc:            realAfterFunc,
+		now:                  time.Now,
+	}
+	v.maintenance.defaultMessage = defaultMaintenanceMessage
+
+	for _, o := range options {
+		o(v)
+	}
+
+	if v.startupSelfcheck {
+		if err := v.selfcheck(ctx); err != nil {
+			return nil, nil, fmt.Errorf("startup selfcheck: %w", err)
+		}
 	}
 
 	if err := v.loadVoted(ctx); err != nil {
 		return nil, nil, fmt.Errorf("loading voted: %w", err)
 	}
 
+	if err := v.loadDeadlines(ctx); err != nil {
+		return nil, nil, fmt.Errorf("loading deadlines: %w", err)
+	}
+
 	bg := func(ctx context.Context, errorHandler func(error)) {
 		go v.flow.Update(ctx, nil)
 
+		if v.stoppedRetention > 0 {
+			go v.sweepStoppedLoop(ctx, errorHandler)
+		}
+
 		if singleInstance {
 			return
 		}
@@ -52,6 +141,9 @@ func New(ctx context.Context, fast, long Backend, flow flow.Flow, singleInstance
 				if err := v.loadVoted(ctx); err != nil {
 					errorHandler(err)
 				}
+				if err := v.loadDeadlines(ctx); err != nil {
+					errorHandler(err)
+				}
 				time.Sleep(time.Second)
 			}
 		}()
@@ -66,16 +158,70 @@ func (v *Vote) backend(p pollConfig) Backend {
 	if p.backend == "fast" {
 		backend = v.fastBackend
 	}
+
+	v.forcedMu.Lock()
+	switch v.forced[p.id] {
+	case "memory":
+		backend = v.memoryBackend
+	case "fast":
+		backend = v.fastBackend
+	case "long":
+		backend = v.longBackend
+	}
+	v.forcedMu.Unlock()
+
 	log.Debug("Used backend: %v", backend)
 	return backend
 }
 
+// ForceBackend uses the backend with the given name for a poll, regardless of
+// the backend field of the poll. Valid names are memory, fast and long. It has
+// to be called before the poll is started. An empty name removes the forced
+// backend, for example, if the poll could not be started.
+//
+// It is only for debugging and is ignored, if the service is not in
+// development mode.
+func (v *Vote) ForceBackend(pollID int, name string) error {
+	if v.memoryBackend == nil {
+		log.Info("Ignoring force backend %s for poll %d outside of development", name, pollID)
+		return nil
+	}
+
+	switch name {
+	case "", "memory", "fast", "long":
+	default:
+		return MessageError(ErrInvalid, "Unknown backend %s, expected memory, fast or long", name)
+	}
+
+	v.forcedMu.Lock()
+	defer v.forcedMu.Unlock()
+
+	if name == "" {
+		delete(v.forced, pollID)
+		return nil
+	}
+
+	if v.forced == nil {
+		v.forced = make(map[int]string)
+	}
+	v.forced[pollID] = name
+	return nil
+}
+
 // Start an electronic vote.
 //
 // This function is idempotence. If you call it with the same input, you will
-// get the same output. This means, that when a poll is stopped, Start() will
-// not throw an error.
+// get the same output. To start a started poll is not an error. A poll, that
+// is stopped in the backend but not finished in the datastore, stays stopped
+// and no error is returned.
+//
+// Analog polls and polls, that are finished or published in the datastore,
+// can not be started. For them, ErrWrongPollType is returned.
 func (v *Vote) Start(ctx context.Context, pollID int) error {
+	if err := v.maintenance.check(); err != nil {
+		return err
+	}
+
 	recorder := dsrecorder.New(v.flow)
 	ds := dsfetch.New(recorder)
 
@@ -84,318 +230,1595 @@ func (v *Vote) Start(ctx context.Context, pollID int) error {
 		return fmt.Errorf("loading poll: %w", err)
 	}
 
-	if poll.ptype == "analog" {
-		return MessageError(ErrInvalid, "Analog poll can not be started")
+	if err := poll.checkStartable(); err != nil {
+		return err
 	}
 
-	if err := poll.preload(ctx, ds); err != nil {
+	if err := poll.checkAmounts(); err != nil {
+		return err
+	}
+
+	if err := v.preloadWithRetry(ctx, ds, poll); err != nil {
 		return fmt.Errorf("preloading data: %w", err)
 	}
 	log.Debug("Preload cache. Received keys: %v", recorder.Keys())
+	v.rememberPreloaded(recorder.Keys())
+
+	return v.startBackend(ctx, poll)
+}
 
+// startBackend starts a poll, that was loaded and preloaded, in its backend.
+func (v *Vote) startBackend(ctx context.Context, poll pollConfig) error {
 	backend := v.backend(poll)
-	if err := backend.Start(ctx, pollID); err != nil {
+	err := v.withBackendTimeout(ctx, "start", func(ctx context.Context) error {
+		return backend.Start(ctx, poll.id)
+	})
+	if err != nil {
 		return fmt.Errorf("starting poll in the backend: %w", err)
 	}
 
+	v.audit.record(poll.id, AuditStart)
 	return nil
 }
 
-// StopResult is the return value from vote.Stop.
-type StopResult struct {
-	Votes   [][]byte
-	UserIDs []int
-}
-
-// Stop ends a poll.
+// PreloadKeys returns the datastore keys, that are loaded, when the poll is
+// started. The poll is not started.
 //
-// This method is idempotence. Many requests with the same pollID will return
-// the same data. Calling vote.Clear will stop this behavior.
-func (v *Vote) Stop(ctx context.Context, pollID int) (StopResult, error) {
-	ds := dsfetch.New(v.flow)
+// It can be used to fill a cache before a poll is opened.
+func (v *Vote) PreloadKeys(ctx context.Context, pollID int) ([]string, error) {
+	recorder := dsrecorder.New(v.flow)
+	ds := dsfetch.New(recorder)
+
 	poll, err := loadPoll(ctx, ds, pollID)
 	if err != nil {
-		return StopResult{}, fmt.Errorf("loading poll: %w", err)
+		return nil, fmt.Errorf("loading poll: %w", err)
 	}
 
-	backend := v.backend(poll)
-	ballots, userIDs, err := backend.Stop(ctx, pollID)
-	if err != nil {
-		var errNotExist interface{ DoesNotExist() }
-		if errors.As(err, &errNotExist) {
-			return StopResult{}, MessageError(ErrNotExists, "Poll %d does not exist in the backend", pollID)
-		}
-
-		return StopResult{}, fmt.Errorf("fetching vote objects: %w", err)
+	if err := poll.preload(ctx, ds); err != nil {
+		return nil, fmt.Errorf("preloading data: %w", err)
 	}
 
-	return StopResult{ballots, userIDs}, nil
-}
-
-// Clear removes all knowlage of a poll.
-func (v *Vote) Clear(ctx context.Context, pollID int) error {
-	if err := v.fastBackend.Clear(ctx, pollID); err != nil {
-		return fmt.Errorf("clearing fastBackend: %w", err)
+	if err := v.preloadExtra(ctx, poll, ds); err != nil {
+		return nil, fmt.Errorf("preloading extension data: %w", err)
 	}
 
-	if err := v.longBackend.Clear(ctx, pollID); err != nil {
-		return fmt.Errorf("clearing longBackend: %w", err)
+	keys := make([]string, 0, len(recorder.Keys()))
+	for key := range recorder.Keys() {
+		keys = append(keys, key.String())
 	}
+	sort.Strings(keys)
 
-	v.votedMu.Lock()
-	v.voted[pollID] = nil
-	v.votedMu.Unlock()
-
-	return nil
+	return keys, nil
 }
 
-// ClearAll removes all knowlage of all polls and the datastore-cache.
-func (v *Vote) ClearAll(ctx context.Context) error {
-	// Reset the cache if it has the ResetCach() method.
-	type ResetCacher interface {
-		Reset()
-	}
-	if r, ok := v.flow.(ResetCacher); ok {
-		r.Reset()
+// ReloadPoll loads the config of a poll again from the datastore. It can be
+// used, if the poll was changed after it was started, for example, when the
+// entitled groups were changed.
+//
+// The keys, that were preloaded for the poll, are removed from the datastore
+// cache and loaded again. If the cache can only be removed at once, the whole
+// cache is removed. The poll in the backend and its votes are not changed.
+func (v *Vote) ReloadPoll(ctx context.Context, pollID int) error {
+	if err := v.maintenance.check(); err != nil {
+		return err
 	}
 
-	if err := v.fastBackend.ClearAll(ctx); err != nil {
-		return fmt.Errorf("clearing fastBackend: %w", err)
+	// Find the keys, that are in the cache for this poll.
+	recorder := dsrecorder.New(v.flow)
+	ds := dsfetch.New(recorder)
+	poll, err := loadPoll(ctx, ds, pollID)
+	if err != nil {
+		return fmt.Errorf("loading poll: %w", err)
 	}
 
-	if err := v.longBackend.ClearAll(ctx); err != nil {
-		return fmt.Errorf("clearing long Backend: %w", err)
+	if err := v.preloadWithRetry(ctx, ds, poll); err != nil {
+		return fmt.Errorf("preloading data: %w", err)
 	}
 
-	v.votedMu.Lock()
-	v.voted = make(map[int][]int)
-	v.votedMu.Unlock()
-
-	return nil
-}
+	v.invalidateKeys(recorder.Keys())
 
-// Vote validates and saves the vote.
-func (v *Vote) Vote(ctx context.Context, pollID, requestUser int, r io.Reader) error {
-	ds := dsfetch.New(v.flow)
-	poll, err := loadPoll(ctx, ds, pollID)
+	recorder = dsrecorder.New(v.flow)
+	ds = dsfetch.New(recorder)
+	poll, err = loadPoll(ctx, ds, pollID)
 	if err != nil {
-		return fmt.Errorf("loading poll: %w", err)
+		return fmt.Errorf("loading poll again: %w", err)
 	}
-	log.Debug("Poll config: %v", poll)
 
-	if err := ensurePresent(ctx, ds, poll.meetingID, requestUser); err != nil {
+	if err := poll.checkStartable(); err != nil {
 		return err
 	}
 
-	var vote ballot
-	if err := json.NewDecoder(r).Decode(&vote); err != nil {
-		return MessageError(ErrInvalid, "decoding payload: %v", err)
+	if err := poll.checkAmounts(); err != nil {
+		return err
 	}
 
-	voteUser, exist := vote.UserID.Value()
-	if !exist {
-		voteUser = requestUser
+	if err := v.preloadWithRetry(ctx, ds, poll); err != nil {
+		return fmt.Errorf("preloading data again: %w", err)
 	}
+	log.Debug("Reload cache. Received keys: %v", recorder.Keys())
+	v.rememberPreloaded(recorder.Keys())
 
-	if voteUser == 0 {
-		return MessageError(ErrNotAllowed, "Votes for anonymous user are not allowed")
-	}
+	return nil
+}
 
-	voteMeetingUserID, found, err := getMeetingUser(ctx, ds, voteUser, poll.meetingID)
-	if err != nil {
-		return fmt.Errorf("get meeting user for vote user: %w", err)
+// StartMany starts many polls. Each poll is started, even if starting another
+// poll failed. Polls, that are finished or published, are not started. If
+// some polls could not be started, a StartManyError is returned.
+//
+// The config of all polls is loaded with one datastore request. The data of
+// all startable polls is preloaded together.
+func (v *Vote) StartMany(ctx context.Context, pollIDs []int) error {
+	if err := v.maintenance.check(); err != nil {
+		return err
 	}
 
-	if !found {
-		return MessageError(ErrNotAllowed, "You are not in the right meeting")
-	}
+	recorder := dsrecorder.New(v.flow)
+	ds := dsfetch.New(recorder)
+	polls := make([]pollConfig, len(pollIDs))
+	for i, pollID := range pollIDs {
+		polls[i].id = pollID
+		polls[i].lazy(ds)
+	}
+
+	// If a poll does not exist, the request fails for all polls. In this case,
+	// each poll is loaded on its own to find the error.
+	batchLoaded := ds.Execute(ctx) == nil
+
+	failed := make(StartManyError)
+	startable := make([]pollConfig, 0, len(pollIDs))
+	for i, pollID := range pollIDs {
+		poll := polls[i]
+		if !batchLoaded {
+			var err error
+			poll, err = loadPoll(ctx, ds, pollID)
+			if err != nil {
+				failed[pollID] = fmt.Errorf("loading poll: %w", err)
+				continue
+			}
+		}
 
-	if err := ensureVoteUser(ctx, ds, poll, voteUser, voteMeetingUserID, requestUser); err != nil {
-		return err
-	}
+		if err := poll.checkStartable(); err != nil {
+			failed[pollID] = err
+			continue
+		}
 
-	if validation := validate(poll, vote.Value); validation != "" {
-		return MessageError(ErrInvalid, validation)
-	}
+		if err := poll.checkAmounts(); err != nil {
+			failed[pollID] = err
+			continue
+		}
 
-	// voteData.Weight is a DecimalField with 6 zeros.
-	var voteWeightEnabled bool
-	var meetingUserVoteWeight string
-	var userDefaultVoteWeight string
-	ds.Meeting_UsersEnableVoteWeight(poll.meetingID).Lazy(&voteWeightEnabled)
-	ds.MeetingUser_VoteWeight(voteMeetingUserID).Lazy(&meetingUserVoteWeight)
-	ds.User_DefaultVoteWeight(voteUser).Lazy(&userDefaultVoteWeight)
+		startable = append(startable, poll)
+	}
 
-	if err := ds.Execute(ctx); err != nil {
-		return fmt.Errorf("getting vote weight: %w", err)
+	// If the preload fails for all polls, each poll is preloaded on its own,
+	// so only the broken polls are not started.
+	if err := v.preloadWithRetry(ctx, ds, startable...); err != nil {
+		preloaded := startable[:0]
+		for _, poll := range startable {
+			if err := v.preloadWithRetry(ctx, ds, poll); err != nil {
+				failed[poll.id] = fmt.Errorf("preloading data: %w", err)
+				continue
+			}
+			preloaded = append(preloaded, poll)
+		}
+		startable = preloaded
 	}
+	log.Debug("Preload cache. Received keys: %v", recorder.Keys())
+	v.rememberPreloaded(recorder.Keys())
 
-	var voteWeight string
-	if voteWeightEnabled {
-		voteWeight = meetingUserVoteWeight
-		if voteWeight == "" {
-			voteWeight = userDefaultVoteWeight
+	for _, poll := range startable {
+		if err := v.startBackend(ctx, poll); err != nil {
+			failed[poll.id] = err
 		}
 	}
 
-	if voteWeight == "" {
-		voteWeight = "1.000000"
+	if len(failed) > 0 {
+		return failed
 	}
+	return nil
+}
 
-	log.Debug("Using voteWeight %s", voteWeight)
+// StartManyError holds for each poll, that could not be started by
+// StartMany, the error.
+type StartManyError map[int]error
 
-	voteData := struct {
-		RequestUser int             `json:"request_user_id,omitempty"`
-		VoteUser    int             `json:"vote_user_id,omitempty"`
-		Value       json.RawMessage `json:"value"`
-		Weight      string          `json:"weight"`
-	}{
-		requestUser,
-		voteUser,
-		vote.Value.original,
-		voteWeight,
-	}
+func (e StartManyError) Error() string {
+	return batchErrorMessage("starting", e)
+}
 
-	if poll.ptype != "named" {
-		voteData.RequestUser = 0
-		voteData.VoteUser = 0
+// StartWithEntitled starts a poll like Start, but only the given users are
+// entitled to vote. The entitled groups of the poll are ignored.
+//
+// The list is only saved in memory of this instance. It is removed by Clear.
+// Therefore a list is only accepted, if the service runs as a single instance.
+// Without user ids, it is the same as Start.
+func (v *Vote) StartWithEntitled(ctx context.Context, pollID int, userIDs []int) error {
+	if len(userIDs) > 0 && !v.singleInstance {
+		return MessageError(ErrNotAllowed, "A list of entitled users is only supported with VOTE_SINGLE_INSTANCE")
 	}
 
-	bs, err := json.Marshal(voteData)
-	if err != nil {
-		return fmt.Errorf("decoding vote data: %w", err)
+	if err := v.Start(ctx, pollID); err != nil {
+		return err
 	}
 
-	if err := v.backend(poll).Vote(ctx, pollID, voteUser, bs); err != nil {
-		var errNotExist interface{ DoesNotExist() }
-		if errors.As(err, &errNotExist) {
-			return ErrNotExists
-		}
-
-		var errDoubleVote interface{ DoubleVote() }
-		if errors.As(err, &errDoubleVote) {
-			return ErrDoubleVote
-		}
-
-		var errNotOpen interface{ Stopped() }
-		if errors.As(err, &errNotOpen) {
-			return ErrStopped
-		}
+	if len(userIDs) == 0 {
+		return nil
+	}
 
-		return fmt.Errorf("save vote: %w", err)
+	entitled := make(map[int]struct{}, len(userIDs))
+	for _, id := range userIDs {
+		entitled[id] = struct{}{}
 	}
 
-	v.votedMu.Lock()
-	v.voted[pollID] = append(v.voted[pollID], voteUser)
-	v.votedMu.Unlock()
+	v.entitledMu.Lock()
+	v.entitled[pollID] = entitled
+	v.entitledMu.Unlock()
 
 	return nil
 }
 
-// getMeetingUser returns the meeting_user id between a userID and a meetingID.
-func getMeetingUser(ctx context.Context, fetch *dsfetch.Fetch, userID, meetingID int) (int, bool, error) {
-	meetingUserIDs, err := fetch.User_MeetingUserIDs(userID).Value(ctx)
-	if err != nil {
-		return 0, false, fmt.Errorf("getting all meeting_user ids: %w", err)
-	}
+// explicitEntitled returns, if the user is in the explicit entitled list of a
+// poll. The second value is false, if the poll has no explicit list.
+func (v *Vote) explicitEntitled(pollID, userID int) (entitled bool, explicit bool) {
+	v.entitledMu.Lock()
+	defer v.entitledMu.Unlock()
 
-	meetingIDs := make([]int, len(meetingUserIDs))
-	for i := 0; i < len(meetingUserIDs); i++ {
-		fetch.MeetingUser_MeetingID(meetingUserIDs[i]).Lazy(&meetingIDs[i])
+	users, ok := v.entitled[pollID]
+	if !ok {
+		return false, false
 	}
 
-	if err := fetch.Execute(ctx); err != nil {
-		return 0, false, fmt.Errorf("get all meeting IDs: %w", err)
-	}
+	_, entitled = users[userID]
+	return entitled, true
+}
 
-	for i, mid := range meetingIDs {
-		if mid == meetingID {
-			return meetingUserIDs[i], true, nil
+// preloadWithRetry preloads the data of all given polls. On a transient
+// datastore error, the preload is repeated up to v.preloadRetries times with a
+// growing backoff.
+//
+// A missing object is not a transient error and is returned at once.
+func (v *Vote) preloadWithRetry(ctx context.Context, ds *dsfetch.Fetch, polls ...pollConfig) error {
+	backoff := v.preloadBackoff
+	for attempt := 0; ; attempt++ {
+		err := preloadPolls(ctx, ds, polls)
+		for i := 0; err == nil && i < len(polls); i++ {
+			err = v.preloadExtra(ctx, polls[i], ds)
 		}
-	}
 
-	return 0, false, nil
-}
+		if err == nil || attempt >= v.preloadRetries || !transientDatastoreError(ctx, err) {
+			return err
+		}
 
-// ensurePresent makes sure that the user sending the vote request is present.
-func ensurePresent(ctx context.Context, ds *dsfetch.Fetch, meetingID, user int) error {
-	presentMeetings, err := ds.User_IsPresentInMeetingIDs(user).Value(ctx)
-	if err != nil {
-		return fmt.Errorf("fetching is present in meetings: %w", err)
-	}
+		log.Info("Preload of polls %v failed, try again in %s: %v", idsOfPolls(polls), backoff, err)
 
-	for _, present := range presentMeetings {
-		if present == meetingID {
-			return nil
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
 		}
+		backoff *= 2
 	}
-	return MessageError(ErrNotAllowed, "You have to be present in meeting %d", meetingID)
 }
 
-// ensureVoteUser makes sure the user from the vote:
-// * the delegation is correct and
-// * is in the correct group
-func ensureVoteUser(ctx context.Context, ds *dsfetch.Fetch, poll pollConfig, voteUser, voteMeetingUserID, requestUser int) error {
-	groupIDs, err := ds.MeetingUser_GroupIDs(voteMeetingUserID).Value(ctx)
-	if err != nil {
-		return fmt.Errorf("fetching groups of user %d in meeting %d: %w", voteUser, poll.meetingID, err)
-	}
+// PreloadExtension returns additional datastore keys, that are loaded, when a
+// poll is started.
+//
+// It can be used by custom vote logic, that reads other fields then the vote
+// service, so the vote requests do not have to read from the datastore.
+type PreloadExtension func(pollID, meetingID int) []dskey.Key
 
-	if !equalElement(groupIDs, poll.groups) {
-		return MessageError(ErrNotAllowed, "User %d is not allowed to vote. He is not in an entitled group", voteUser)
-	}
+// noPreloadExtension is the default PreloadExtension. It does not add keys.
+func noPreloadExtension(pollID, meetingID int) []dskey.Key {
+	return nil
+}
 
-	if voteUser == requestUser {
+// preloadExtra loads the keys from the preload extension.
+func (v *Vote) preloadExtra(ctx context.Context, poll pollConfig, ds *dsfetch.Fetch) error {
+	keys := v.preloadExtension(poll.id, poll.meetingID)
+	if len(keys) == 0 {
 		return nil
 	}
 
-	log.Debug("Vote delegation")
-
-	delegationActivated, err := ds.Meeting_UsersEnableVoteDelegations(poll.meetingID).Value(ctx)
-	if err != nil {
-		return fmt.Errorf("fetching user enable vote delegation: %w", err)
+	if _, err := ds.Get(ctx, keys...); err != nil {
+		return preloadError("preloading extension keys", err)
 	}
+	return nil
+}
 
-	if !delegationActivated {
-		return MessageError(ErrNotAllowed, "Vote delegation is not activated in meeting %d", poll.meetingID)
-	}
+// BallotTransformer changes a ballot, before it is saved in the backend. raw is
+// the vote object, that would be saved. The returned bytes are saved instead.
+//
+// It can be used to attach data like a server timestamp or a signature to each
+// ballot. An error rejects the vote as an internal error.
+type BallotTransformer func(pollID int, raw []byte) ([]byte, error)
+
+// noBallotTransformer is the default BallotTransformer. It returns the ballot
+// unchanged.
+func noBallotTransformer(pollID int, raw []byte) ([]byte, error) {
+	return raw, nil
+}
 
-	requestMeetingUserID, found, err := getMeetingUser(ctx, ds, requestUser, poll.meetingID)
-	if err != nil {
-		return fmt.Errorf("getting meeting_user for request user: %w", err)
+// transientDatastoreError returns true, if the error could go away, when the
+// datastore is asked again.
+func transientDatastoreError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
 	}
 
-	if !found {
-		return MessageError(ErrNotAllowed, "You are not in the right meeting")
-	}
+	var errDoesNotExist dsfetch.DoesNotExistError
+	return !errors.As(err, &errDoesNotExist)
+}
 
-	delegation, found, err := ds.MeetingUser_VoteDelegatedToID(voteMeetingUserID).Value(ctx)
-	if err != nil {
-		return fmt.Errorf("fetching delegation : %w", err)
-	}
+// StopResult is the return value from vote.Stop.
+type StopResult struct {
+	Votes   [][]byte
+	UserIDs []int
 
-	if !found || delegation != requestMeetingUserID {
-		return MessageError(ErrNotAllowed, "You can not vote for user %d", voteUser)
-	}
+	// Valid tells for each vote, if it is valid under the current poll
+	// config. It is only set by vote.StopWithValidity.
+	Valid []bool
 
-	return nil
+	// Aggregation is the weighted sum of the votes. It is only set by
+	// vote.StopWithAggregation.
+	Aggregation *Aggregation
+
+	Meta StopMeta
 }
 
-// delegatedUserIDs returns all user ids for which the user can vote.
-func delegatedUserIDs(ctx context.Context, fetch *dsfetch.Fetch, userID int) ([]int, error) {
-	meetingUserIDs, err := fetch.User_MeetingUserIDs(userID).Value(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("fetching meeting user: %w", err)
-	}
+// StopMeta tells, which poll was stopped. It helps to assign the result to its
+// motion or assignment.
+type StopMeta struct {
+	SequentialNumber int    `json:"sequential_number"`
+	ContentObjectID  string `json:"content_object_id"`
 
-	meetingUserDelegationsIDs := make([][]int, len(meetingUserIDs))
-	for i, muid := range meetingUserIDs {
-		fetch.MeetingUser_VoteDelegationsFromIDs(muid).Lazy(&meetingUserDelegationsIDs[i])
-	}
+	// VoterListPublic is true, if the user ids of a pseudoanonymous poll can
+	// be published. The ballots are anonymous in any case.
+	VoterListPublic bool `json:"voter_list_public,omitempty"`
+}
 
-	if err := fetch.Execute(ctx); err != nil {
-		return nil, fmt.Errorf("getting vote_delegation_from values: %w", err)
-	}
+// Hash returns a stable hash over the votes and user ids of the result.
+//
+// Some backends do not return the votes in a stable order, so the votes are
+// sorted before hashing.
+func (r StopResult) Hash() string {
+	votes := make([][]byte, len(r.Votes))
+	copy(votes, r.Votes)
+	sort.Slice(votes, func(i, j int) bool {
+		return bytes.Compare(votes[i], votes[j]) < 0
+	})
+
+	userIDs := make([]int, len(r.UserIDs))
+	copy(userIDs, r.UserIDs)
+	sort.Ints(userIDs)
+
+	hash := sha256.New()
+	for _, v := range votes {
+		fmt.Fprintf(hash, "%d:%s", len(v), v)
+	}
+	for _, id := range userIDs {
+		fmt.Fprintf(hash, "%d,", id)
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+// Stop ends a poll.
+//
+// This method is idempotence. Many requests with the same pollID will return
+// the same data. Calling vote.Clear will stop this behavior.
+func (v *Vote) Stop(ctx context.Context, pollID int) (StopResult, error) {
+	return v.stop(ctx, pollID, false, false)
+}
+
+// StopWithValidity is like Stop, but also validates each vote again with the
+// current poll config.
+//
+// This helps to find votes, that would be excluded, if the poll config was
+// changed after the votes were given.
+func (v *Vote) StopWithValidity(ctx context.Context, pollID int) (StopResult, error) {
+	return v.stop(ctx, pollID, true, false)
+}
+
+// StopWithAggregation is like Stop, but also sums the weights of the votes for
+// each option and global answer.
+//
+// It only works for polls with the method Y, N, YN or YNA.
+func (v *Vote) StopWithAggregation(ctx context.Context, pollID int) (StopResult, error) {
+	return v.stop(ctx, pollID, false, true)
+}
+
+func (v *Vote) stop(ctx context.Context, pollID int, withValidity, withAggregation bool) (StopResult, error) {
+	ds := dsfetch.New(v.flow)
+
+	poll, err := loadPoll(ctx, ds, pollID)
+	if err != nil {
+		return StopResult{}, fmt.Errorf("loading poll: %w", err)
+	}
+
+	meta, err := loadStopMeta(ctx, ds, pollID)
+	if err != nil {
+		return StopResult{}, fmt.Errorf("loading poll meta: %w", err)
+	}
+
+	if withAggregation && !aggregationMethods[poll.method] {
+		return StopResult{}, MessageError(ErrInvalid, "Votes of poll method %s can not be aggregated", poll.method)
+	}
+
+	release, err := v.acquireStopSlot(ctx)
+	if err != nil {
+		return StopResult{}, err
+	}
+
+	backend := v.backend(poll)
+	var ballots [][]byte
+	var userIDs []int
+	err = v.withBackendTimeout(ctx, "stop", func(ctx context.Context) error {
+		var err error
+		ballots, userIDs, err = backend.Stop(ctx, pollID)
+		return err
+	})
+	release()
+	if err != nil {
+		var errNotExist interface{ DoesNotExist() }
+		if errors.As(err, &errNotExist) {
+			return StopResult{}, MessageError(ErrNotExists, "Poll %d does not exist in the backend", pollID)
+		}
+
+		return StopResult{}, fmt.Errorf("fetching vote objects: %w", err)
+	}
+
+	v.cancelStop(pollID)
+	v.audit.record(pollID, AuditStop)
+
+	// The poll is already stopped. If the time can not be saved, the next sweep
+	// saves it.
+	err = v.withBackendTimeout(ctx, "set stop time", func(ctx context.Context) error {
+		return backend.SetTime(ctx, pollID, timeStopped, v.now(), false)
+	})
+	if err != nil {
+		log.Info("Saving stop time of poll %d: %v", pollID, err)
+	}
+
+	meta.VoterListPublic = poll.ptype == "pseudoanonymous" && v.publishVoterList
+
+	result := StopResult{Votes: ballots, UserIDs: userIDs, Meta: meta}
+	if withValidity {
+		poll.maxTextLength = v.maxTextLength
+		result.Valid = make([]bool, len(ballots))
+		for i, b := range ballots {
+			result.Valid[i] = revalidate(poll, b)
+		}
+	}
+
+	if withAggregation {
+		aggregation := aggregate(poll.method, ballots)
+		result.Aggregation = &aggregation
+	}
+
+	return result, nil
+}
+
+// loadStopMeta loads the meta fields of a poll. The fields are optional. If
+// they are empty, the zero values are used.
+func loadStopMeta(ctx context.Context, ds *dsfetch.Fetch, pollID int) (StopMeta, error) {
+	seqKey, err := dskey.FromParts("poll", pollID, "sequential_number")
+	if err != nil {
+		return StopMeta{}, fmt.Errorf("building sequential number key: %w", err)
+	}
+
+	contentKey, err := dskey.FromParts("poll", pollID, "content_object_id")
+	if err != nil {
+		return StopMeta{}, fmt.Errorf("building content object key: %w", err)
+	}
+
+	data, err := ds.Get(ctx, seqKey, contentKey)
+	if err != nil {
+		return StopMeta{}, fmt.Errorf("fetching meta fields: %w", err)
+	}
+
+	var meta StopMeta
+	if raw := data[seqKey]; raw != nil {
+		if err := json.Unmarshal(raw, &meta.SequentialNumber); err != nil {
+			return StopMeta{}, fmt.Errorf("decoding %s: %w", seqKey, err)
+		}
+	}
+
+	if raw := data[contentKey]; raw != nil {
+		if err := json.Unmarshal(raw, &meta.ContentObjectID); err != nil {
+			return StopMeta{}, fmt.Errorf("decoding %s: %w", contentKey, err)
+		}
+	}
+
+	return meta, nil
+}
+
+// acquireStopSlot waits until a backend stop can be started. The returned
+// function has to be called, when the stop is finished.
+func (v *Vote) acquireStopSlot(ctx context.Context) (func(), error) {
+	if v.stopSlots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case v.stopSlots <- struct{}{}:
+		return func() { <-v.stopSlots }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for other polls to stop: %w", ctx.Err())
+	}
+}
+
+// ResultHash returns the hash of the result of a stopped poll.
+//
+// Instances that have the same data return the same hash. It can be used to
+// check, that replicas agree on a result, without comparing all votes.
+func (v *Vote) ResultHash(ctx context.Context, pollID int) (string, error) {
+	ds := dsfetch.New(v.flow)
+	poll, err := loadPoll(ctx, ds, pollID)
+	if err != nil {
+		return "", fmt.Errorf("loading poll: %w", err)
+	}
+
+	if poll.state == "started" {
+		return "", MessageError(ErrNotAllowed, "Poll %d is not stopped", pollID)
+	}
+
+	result, err := v.stoppedResult(ctx, poll)
+	if err != nil {
+		return "", err
+	}
+
+	return result.Hash(), nil
+}
+
+// stoppedResult reads the votes of a stopped poll from the backend.
+//
+// In difference to Stop, nothing is changed. Not in the backend and not in the
+// state of this instance.
+func (v *Vote) stoppedResult(ctx context.Context, poll pollConfig) (StopResult, error) {
+	var ballots [][]byte
+	var userIDs []int
+	err := v.withBackendTimeout(ctx, "result", func(ctx context.Context) error {
+		var err error
+		ballots, userIDs, err = v.backend(poll).Result(ctx, poll.id)
+		return err
+	})
+	if err != nil {
+		var errNotExist interface{ DoesNotExist() }
+		if errors.As(err, &errNotExist) {
+			return StopResult{}, MessageError(ErrNotExists, "Poll %d does not exist in the backend", poll.id)
+		}
+
+		var errStarted interface{ Started() }
+		if errors.As(err, &errStarted) {
+			return StopResult{}, MessageError(ErrNotAllowed, "Poll %d is not stopped in the backend", poll.id)
+		}
+
+		return StopResult{}, fmt.Errorf("fetching vote objects: %w", err)
+	}
+
+	return StopResult{Votes: ballots, UserIDs: userIDs}, nil
+}
+
+// revalidate returns true, if a saved vote object is valid under the poll
+// config.
+func revalidate(poll pollConfig, voteObject []byte) bool {
+	var saved struct {
+		Value storedValue `json:"value"`
+	}
+	if err := json.Unmarshal(voteObject, &saved); err != nil {
+		return false
+	}
+
+	return validate(poll, saved.Value.ballotValue) == ""
+}
+
+// RetractVote removes the vote of a user from a started poll. Afterwards, the
+// user can vote again.
+//
+// It returns ErrNotExists, if the poll is not started or already stopped.
+func (v *Vote) RetractVote(ctx context.Context, pollID, userID int) error {
+	ds := dsfetch.New(v.flow)
+	poll, err := loadPoll(ctx, ds, pollID)
+	if err != nil {
+		return fmt.Errorf("loading poll: %w", err)
+	}
+
+	err = v.withBackendTimeout(ctx, "retract", func(ctx context.Context) error {
+		return v.backend(poll).RetractVote(ctx, pollID, userID)
+	})
+	if err != nil {
+		var errNotExist interface{ DoesNotExist() }
+		if errors.As(err, &errNotExist) {
+			return MessageError(ErrNotExists, "Poll %d does not exist in the backend", pollID)
+		}
+
+		var errStopped interface{ Stopped() }
+		if errors.As(err, &errStopped) {
+			return MessageError(ErrNotExists, "Poll %d is stopped", pollID)
+		}
+
+		var errNotRetractable interface{ NotRetractable() }
+		if errors.As(err, &errNotRetractable) {
+			return MessageError(ErrNotAllowed, "The vote of user %d on poll %d can not be retracted in this backend", userID, pollID)
+		}
+
+		return fmt.Errorf("retract vote in the backend: %w", err)
+	}
+
+	v.votedMu.Lock()
+	for i, id := range v.voted[pollID] {
+		if id == userID {
+			v.voted[pollID] = append(v.voted[pollID][:i], v.voted[pollID][i+1:]...)
+			break
+		}
+	}
+	v.votedMu.Unlock()
+
+	v.idempotency.clearVoteUser(pollID, userID)
+	return nil
+}
+
+// Reopen starts a stopped poll again.
+//
+// The votes of the poll are kept. Users, that have already voted, can not vote
+// again.
+func (v *Vote) Reopen(ctx context.Context, pollID int) error {
+	ds := dsfetch.New(v.flow)
+	poll, err := loadPoll(ctx, ds, pollID)
+	if err != nil {
+		return fmt.Errorf("loading poll: %w", err)
+	}
+
+	backend := v.backend(poll)
+	err = v.withBackendTimeout(ctx, "reopen", func(ctx context.Context) error {
+		return backend.Reopen(ctx, pollID)
+	})
+	if err != nil {
+		var errNotExist interface{ DoesNotExist() }
+		if errors.As(err, &errNotExist) {
+			return MessageError(ErrNotExists, "Poll %d does not exist in the backend", pollID)
+		}
+
+		return fmt.Errorf("reopen poll in the backend: %w", err)
+	}
+
+	// The deadline was reached or the poll was stopped before. In both cases,
+	// it would stop the reopened poll.
+	err = v.withBackendTimeout(ctx, "remove times", func(ctx context.Context) error {
+		if err := backend.SetTime(ctx, pollID, timeDeadline, time.Time{}, true); err != nil {
+			return fmt.Errorf("removing deadline: %w", err)
+		}
+
+		if err := backend.SetTime(ctx, pollID, timeStopped, time.Time{}, true); err != nil {
+			return fmt.Errorf("removing stop time: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	v.audit.record(pollID, AuditReopen)
+	return nil
+}
+
+// Clear removes all knowlage of a poll.
+func (v *Vote) Clear(ctx context.Context, pollID int) error {
+	if err := v.fastBackend.Clear(ctx, pollID); err != nil {
+		return fmt.Errorf("clearing fastBackend: %w", err)
+	}
+
+	if err := v.longBackend.Clear(ctx, pollID); err != nil {
+		return fmt.Errorf("clearing longBackend: %w", err)
+	}
+
+	if v.memoryBackend != nil {
+		if err := v.memoryBackend.Clear(ctx, pollID); err != nil {
+			return fmt.Errorf("clearing memoryBackend: %w", err)
+		}
+
+		v.forcedMu.Lock()
+		delete(v.forced, pollID)
+		v.forcedMu.Unlock()
+	}
+
+	v.votedMu.Lock()
+	delete(v.voted, pollID)
+	v.votedMu.Unlock()
+
+	v.idempotency.clearPoll(pollID)
+	v.presence.clearAll()
+	v.cancelStop(pollID)
+
+	v.entitledMu.Lock()
+	delete(v.entitled, pollID)
+	v.entitledMu.Unlock()
+
+	v.audit.record(pollID, AuditClear)
+	return nil
+}
+
+// ClearMany clears many polls. Each poll is cleared, even if clearing another
+// poll failed. If some polls could not be cleared, a ClearManyError is
+// returned.
+func (v *Vote) ClearMany(ctx context.Context, pollIDs []int) error {
+	failed := make(ClearManyError)
+	for _, pollID := range pollIDs {
+		if err := v.Clear(ctx, pollID); err != nil {
+			failed[pollID] = err
+		}
+	}
+
+	if len(failed) > 0 {
+		return failed
+	}
+	return nil
+}
+
+// ClearMeeting clears all polls of a meeting. The polls of other meetings are
+// not touched.
+//
+// The polls are loaded from the datastore. Polls, that were already deleted in
+// the datastore, are not cleared.
+func (v *Vote) ClearMeeting(ctx context.Context, meetingID int) error {
+	pollIDs, err := dsfetch.New(v.flow).Meeting_PollIDs(meetingID).Value(ctx)
+	if err != nil {
+		var errDoesNotExist dsfetch.DoesNotExistError
+		if errors.As(err, &errDoesNotExist) {
+			return MessageError(ErrNotExists, "Meeting %d does not exist", meetingID)
+		}
+		return fmt.Errorf("fetching polls of meeting %d: %w", meetingID, err)
+	}
+
+	return v.ClearMany(ctx, pollIDs)
+}
+
+// ClearManyError holds for each poll, that could not be cleared by ClearMany,
+// the error.
+type ClearManyError map[int]error
+
+func (e ClearManyError) Error() string {
+	return batchErrorMessage("clearing", e)
+}
+
+// batchErrorMessage returns the message for the errors of many polls. The
+// polls are sorted by id.
+func batchErrorMessage(action string, errs map[int]error) string {
+	pollIDs := make([]int, 0, len(errs))
+	for pollID := range errs {
+		pollIDs = append(pollIDs, pollID)
+	}
+	sort.Ints(pollIDs)
+
+	msgs := make([]string, len(pollIDs))
+	for i, pollID := range pollIDs {
+		msgs[i] = fmt.Sprintf("poll %d: %v", pollID, errs[pollID])
+	}
+	return fmt.Sprintf("%s %d polls failed: %s", action, len(errs), strings.Join(msgs, ", "))
+}
+
+// ClearAll removes all knowlage of all polls and the datastore-cache.
+//
+// It returns false, if the flow does not support to reset the cache. In this
+// case, only the keys, that were preloaded, are invalidated, if the flow
+// supports it.
+func (v *Vote) ClearAll(ctx context.Context) (cacheReset bool, err error) {
+	cacheReset = v.resetCache()
+
+	if err := v.fastBackend.ClearAll(ctx); err != nil {
+		return cacheReset, fmt.Errorf("clearing fastBackend: %w", err)
+	}
+
+	if err := v.longBackend.ClearAll(ctx); err != nil {
+		return cacheReset, fmt.Errorf("clearing long Backend: %w", err)
+	}
+
+	if v.memoryBackend != nil {
+		if err := v.memoryBackend.ClearAll(ctx); err != nil {
+			return cacheReset, fmt.Errorf("clearing memory Backend: %w", err)
+		}
+
+		v.forcedMu.Lock()
+		v.forced = nil
+		v.forcedMu.Unlock()
+	}
+
+	v.votedMu.Lock()
+	v.voted = make(map[int][]int)
+	v.votedMu.Unlock()
+
+	v.idempotency.clearAll()
+	v.presence.clearAll()
+	v.cancelAllStops()
+
+	v.entitledMu.Lock()
+	v.entitled = make(map[int]map[int]struct{})
+	v.entitledMu.Unlock()
+
+	v.audit.recordAll(AuditClearAll)
+	return cacheReset, nil
+}
+
+// VoteResult is the return value from vote.VoteWithResult.
+type VoteResult struct {
+	VoteUserID        int
+	Weight            string
+	AlreadyVotedCount int
+
+	// BallotID is the id, that was saved with the ballot. It is only set, if
+	// the context was created with WithBallotAck.
+	BallotID string
+}
+
+// Vote validates and saves the vote.
+func (v *Vote) Vote(ctx context.Context, pollID, requestUser int, r io.Reader) error {
+	_, err := v.VoteWithResult(ctx, pollID, requestUser, r)
+	return err
+}
+
+// VoteWithResult validates and saves the vote like Vote. It returns the user
+// the vote was saved for, the used weight and the number of users that have
+// voted for the poll including this vote.
+func (v *Vote) VoteWithResult(ctx context.Context, pollID, requestUser int, r io.Reader) (VoteResult, error) {
+	if err := v.maintenance.check(); err != nil {
+		return VoteResult{}, err
+	}
+
+	v.drainMu.Lock()
+	if v.draining {
+		v.drainMu.Unlock()
+		return VoteResult{}, MessageError(ErrTemporary, "The service is shutting down")
+	}
+	v.inflight.Add(1)
+	v.drainMu.Unlock()
+	defer v.inflight.Done()
+
+	prepared, err := v.prepareVote(ctx, pollID, requestUser, r)
+	if err != nil {
+		return VoteResult{}, err
+	}
+
+	idempotencyKey := idempotencyKeyFromContext(ctx)
+	result, found, release, err := v.idempotency.reserve(ctx, v.now, pollID, prepared.voteUser, idempotencyKey)
+	if err != nil {
+		return VoteResult{}, err
+	}
+
+	if found {
+		log.Debug("Vote with idempotency key %s was already saved", idempotencyKey)
+		return result, nil
+	}
+
+	result, err = v.saveVote(ctx, pollID, prepared)
+	release(result, err == nil)
+	return result, err
+}
+
+// saveVote saves a prepared vote in the backend.
+func (v *Vote) saveVote(ctx context.Context, pollID int, prepared preparedVote) (VoteResult, error) {
+	object, err := v.ballotTransformer(pollID, prepared.object)
+	if err != nil {
+		return VoteResult{}, fmt.Errorf("transforming ballot: %w", err)
+	}
+
+	if err := v.reserveVoter(pollID); err != nil {
+		return VoteResult{}, err
+	}
+
+	watch := newStopwatch(ctx)
+	err = v.withBackendTimeout(ctx, "vote", func(ctx context.Context) error {
+		if prepared.nonce == "" {
+			return v.backend(prepared.poll).Vote(ctx, pollID, prepared.voteUser, object)
+		}
+		return v.backend(prepared.poll).VoteWithNonce(ctx, pollID, prepared.voteUser, prepared.nonce, object)
+	})
+	watch.lap(phaseBackend)
+	votedCount := v.releaseVoter(pollID, prepared.voteUser, err == nil)
+	if err != nil {
+		var errNotExist interface{ DoesNotExist() }
+		if errors.As(err, &errNotExist) {
+			// The poll was found in the datastore, so it only misses in the
+			// backend.
+			return VoteResult{}, ErrNotStarted
+		}
+
+		var errDoubleVote interface{ DoubleVote() }
+		if errors.As(err, &errDoubleVote) {
+			return VoteResult{}, ErrDoubleVote
+		}
+
+		var errNotOpen interface{ Stopped() }
+		if errors.As(err, &errNotOpen) {
+			return VoteResult{}, ErrStopped
+		}
+
+		var errNonceUsed interface{ NonceUsed() }
+		if errors.As(err, &errNonceUsed) {
+			return VoteResult{}, MessageError(ErrInvalid, "The nonce was already used")
+		}
+
+		return VoteResult{}, fmt.Errorf("save vote: %w", err)
+	}
+
+	return VoteResult{
+		VoteUserID:        prepared.voteUser,
+		Weight:            prepared.weight,
+		AlreadyVotedCount: votedCount,
+		BallotID:          prepared.ballotID,
+	}, nil
+}
+
+// Drain rejects all new votes with ErrTemporary and waits until all votes,
+// that are currently processed, are saved.
+//
+// It returns the error of the context, if the context is done before.
+func (v *Vote) Drain(ctx context.Context) error {
+	v.drainMu.Lock()
+	v.draining = true
+	v.drainMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		v.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SimulateResult is the return value from vote.Simulate.
+type SimulateResult struct {
+	VoteUserID int
+	Weight     string
+	Duration   time.Duration
+}
+
+// Simulate validates a vote like VoteWithResult, but does not save it. It
+// returns the time, the validation took.
+//
+// This is for load tests of the datastore bound part of a vote request.
+func (v *Vote) Simulate(ctx context.Context, pollID, requestUser int, r io.Reader) (SimulateResult, error) {
+	start := time.Now()
+	prepared, err := v.prepareVote(ctx, pollID, requestUser, r)
+	if err != nil {
+		return SimulateResult{}, err
+	}
+
+	return SimulateResult{
+		VoteUserID: prepared.voteUser,
+		Weight:     prepared.weight,
+		Duration:   time.Since(start),
+	}, nil
+}
+
+// preparedVote is a validated vote, that is ready to be saved.
+type preparedVote struct {
+	poll     pollConfig
+	voteUser int
+	weight   string
+	ballotID string
+	nonce    string
+	object   []byte
+}
+
+// prepareVote validates the vote request and creates the vote object. It does
+// not touch the backend.
+func (v *Vote) prepareVote(ctx context.Context, pollID, requestUser int, r io.Reader) (preparedVote, error) {
+	watch := newStopwatch(ctx)
+
+	ds := dsfetch.New(v.flow)
+	poll, err := loadPoll(ctx, ds, pollID)
+	if err != nil {
+		return preparedVote{}, fmt.Errorf("loading poll: %w", err)
+	}
+	log.Debug("Poll config: %v", poll)
+
+	// A poll always belongs to a meeting. Without it, no user could vote and
+	// the user would get a misleading error.
+	if poll.meetingID == 0 {
+		return preparedVote{}, MessageError(ErrInvalid, "Poll %d has no meeting", pollID)
+	}
+
+	if err := v.ensurePresent(ctx, ds, poll.meetingID, requestUser); err != nil {
+		return preparedVote{}, err
+	}
+
+	var vote ballot
+	if err := json.NewDecoder(r).Decode(&vote); err != nil {
+		return preparedVote{}, MessageError(ErrInvalid, "decoding payload: %v", err)
+	}
+
+	voteUser, exist := vote.UserID.Value()
+	if !exist {
+		voteUser = requestUser
+	}
+
+	if exist && v.rejectExplicitUser {
+		delegationActivated, err := ds.Meeting_UsersEnableVoteDelegations(poll.meetingID).Value(ctx)
+		if err != nil {
+			return preparedVote{}, fmt.Errorf("fetching user enable vote delegation: %w", err)
+		}
+
+		if !delegationActivated {
+			return preparedVote{}, MessageError(ErrInvalid, "Vote delegation is not activated in meeting %d. The field user_id is not allowed", poll.meetingID)
+		}
+	}
+
+	voteMeetingUserID, err := v.checkVoteUser(ctx, ds, poll, voteUser, requestUser)
+	if err != nil {
+		return preparedVote{}, err
+	}
+	watch.lap(phaseLoad)
+
+	poll.maxTextLength = v.maxTextLength
+	if validation := validate(poll, vote.Value); validation != "" {
+		if otherPoll := foreignOptionsPoll(ctx, ds, poll, vote.Value); otherPoll != 0 {
+			return preparedVote{}, MessageError(ErrInvalid, "The options belong to poll %d and not to poll %d", otherPoll, poll.id)
+		}
+		return preparedVote{}, MessageError(ErrInvalid, validation)
+	}
+	watch.lap(phaseValidation)
+
+	// voteData.Weight is a DecimalField with 6 zeros.
+	var voteWeightEnabled bool
+	var meetingUserVoteWeight string
+	var userDefaultVoteWeight string
+	ds.Meeting_UsersEnableVoteWeight(poll.meetingID).Lazy(&voteWeightEnabled)
+	ds.MeetingUser_VoteWeight(voteMeetingUserID).Lazy(&meetingUserVoteWeight)
+	ds.User_DefaultVoteWeight(voteUser).Lazy(&userDefaultVoteWeight)
+
+	if err := ds.Execute(ctx); err != nil {
+		return preparedVote{}, fmt.Errorf("getting vote weight: %w", err)
+	}
+
+	var voteWeight string
+	if voteWeightEnabled {
+		voteWeight = meetingUserVoteWeight
+		if voteWeight == "" {
+			voteWeight = userDefaultVoteWeight
+		}
+	}
+
+	if voteWeight == "" {
+		voteWeight = "1"
+	}
+
+	voteWeight, err = normalizeWeight(voteWeight, v.weightPrecision)
+	if err != nil {
+		return preparedVote{}, WrapError(ErrInvalid, fmt.Errorf("vote weight of user %d: %w", voteUser, err))
+	}
+
+	watch.lap(phaseWeight)
+	log.Debug("Using voteWeight %s", voteWeight)
+
+	value := json.RawMessage(vote.Value.original)
+	if v.canonicalBallots {
+		value, err = canonicalJSON(value)
+		if err != nil {
+			return preparedVote{}, fmt.Errorf("canonicalize ballot: %w", err)
+		}
+	}
+
+	if v.normalizeGlobal && vote.Value.Type() == ballotValueString {
+		value, err = json.Marshal(globalValue{Global: vote.Value.str, Weight: voteWeight})
+		if err != nil {
+			return preparedVote{}, fmt.Errorf("encoding global value: %w", err)
+		}
+	}
+
+	voteData := struct {
+		RequestUser int             `json:"request_user_id,omitempty"`
+		VoteUser    int             `json:"vote_user_id,omitempty"`
+		Value       json.RawMessage `json:"value"`
+		Weight      string          `json:"weight"`
+		Method      string          `json:"method,omitempty"`
+		ClientTime  int64           `json:"client_time,omitempty"`
+		BallotID    string          `json:"ballot_id,omitempty"`
+		Nonce       string          `json:"nonce,omitempty"`
+	}{
+		RequestUser: requestUser,
+		VoteUser:    voteUser,
+		Value:       value,
+		Weight:      voteWeight,
+		Method:      poll.method,
+		Nonce:       vote.Nonce,
+	}
+
+	if clientTime, ok := clientTimeFromContext(ctx); ok {
+		voteData.ClientTime = clientTime.Unix()
+	}
+
+	if ballotAckFromContext(ctx) {
+		voteData.BallotID, err = newBallotID()
+		if err != nil {
+			return preparedVote{}, fmt.Errorf("creating ballot id: %w", err)
+		}
+	}
+
+	if poll.ptype != "named" || v.hideNamedIdentity {
+		// The backend still knows the user ids, so double votes are
+		// prevented.
+		voteData.RequestUser = 0
+		voteData.VoteUser = 0
+		voteData.ClientTime = 0
+	}
+
+	bs, err := json.Marshal(voteData)
+	if err != nil {
+		return preparedVote{}, fmt.Errorf("decoding vote data: %w", err)
+	}
+
+	return preparedVote{
+		poll:     poll,
+		voteUser: voteUser,
+		weight:   voteWeight,
+		ballotID: voteData.BallotID,
+		nonce:    vote.Nonce,
+		object:   bs,
+	}, nil
+}
+
+// reserveVoter reserves a place for a vote. It returns ErrPollFull, if the
+// maximum number of voters is reached.
+//
+// Votes that are currently saved are also counted, so concurrent votes can not
+// exceed the limit. Each call has to be followed by a call to releaseVoter.
+func (v *Vote) reserveVoter(pollID int) error {
+	v.votedMu.Lock()
+	defer v.votedMu.Unlock()
+
+	if v.maxVoters > 0 && len(v.voted[pollID])+v.pending[pollID] >= v.maxVoters {
+		return MessageError(ErrPollFull, "The poll is limited to %d voters", v.maxVoters)
+	}
+
+	v.pending[pollID]++
+	return nil
+}
+
+// releaseVoter frees the place reserved by reserveVoter. If the vote was saved,
+// the user is added to the voted users.
+//
+// Returns the number of users, that have voted for the poll.
+func (v *Vote) releaseVoter(pollID int, userID int, saved bool) int {
+	v.votedMu.Lock()
+	defer v.votedMu.Unlock()
+
+	v.pending[pollID]--
+	if v.pending[pollID] <= 0 {
+		delete(v.pending, pollID)
+	}
+
+	if saved {
+		v.voted[pollID] = append(v.voted[pollID], userID)
+	}
+
+	return len(v.voted[pollID])
+}
+
+type contextKey int
+
+const (
+	clientTimeKey contextKey = iota
+	idempotencyKeyKey
+	traceKey
+	ballotAckKey
+)
+
+// WithClientTime returns a context that carries the time, the client has sent
+// the vote request.
+//
+// On named polls, the time is saved with the ballot.
+func WithClientTime(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, clientTimeKey, t)
+}
+
+func clientTimeFromContext(ctx context.Context) (time.Time, bool) {
+	t, ok := ctx.Value(clientTimeKey).(time.Time)
+	return t, ok
+}
+
+// WithBallotAck returns a context that requests an id for the ballot.
+//
+// A random id is saved with the ballot and returned in VoteResult.BallotID.
+// The client can use it to find its ballot in the result.
+func WithBallotAck(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ballotAckKey, true)
+}
+
+func ballotAckFromContext(ctx context.Context) bool {
+	ack, _ := ctx.Value(ballotAckKey).(bool)
+	return ack
+}
+
+// newBallotID returns a random id for a ballot.
+func newBallotID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("reading random bytes: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// getMeetingUser returns the meeting_user id between a userID and a meetingID.
+func getMeetingUser(ctx context.Context, fetch *dsfetch.Fetch, userID, meetingID int) (int, bool, error) {
+	meetingUserIDs, err := fetch.User_MeetingUserIDs(userID).Value(ctx)
+	if err != nil {
+		return 0, false, fmt.Errorf("getting all meeting_user ids: %w", err)
+	}
+
+	meetingIDs := make([]int, len(meetingUserIDs))
+	for i := 0; i < len(meetingUserIDs); i++ {
+		fetch.MeetingUser_MeetingID(meetingUserIDs[i]).Lazy(&meetingIDs[i])
+	}
+
+	if err := fetch.Execute(ctx); err != nil {
+		return 0, false, fmt.Errorf("get all meeting IDs: %w", err)
+	}
+
+	for i, mid := range meetingIDs {
+		if mid == meetingID {
+			return meetingUserIDs[i], true, nil
+		}
+	}
+
+	return 0, false, nil
+}
+
+// checkVoteUser makes sure, that the request user can vote for the vote user.
+// It returns the meeting user id of the vote user.
+//
+// The presence of the request user has to be checked separately.
+func (v *Vote) checkVoteUser(ctx context.Context, ds *dsfetch.Fetch, poll pollConfig, voteUser, requestUser int) (int, error) {
+	if voteUser == 0 {
+		return 0, notAllowedError("anonymous", "Votes for anonymous user are not allowed")
+	}
+
+	voteMeetingUserID, found, err := getMeetingUser(ctx, ds, voteUser, poll.meetingID)
+	if err != nil {
+		return 0, fmt.Errorf("get meeting user for vote user: %w", err)
+	}
+
+	if !found {
+		return 0, notAllowedError("not-in-meeting", "You are not in the right meeting")
+	}
+
+	if err := v.ensureVoteUser(ctx, ds, poll, voteUser, voteMeetingUserID, requestUser); err != nil {
+		return 0, err
+	}
+
+	return voteMeetingUserID, nil
+}
+
+// Eligibility tells, if a user can vote on a poll.
+type Eligibility struct {
+	Eligible bool
+
+	// Reason is a short string like "not-present" or "already-voted", that
+	// tells, why the user can not vote.
+	Reason string
+}
+
+// Eligible checks, if the request user can vote for the vote user on a poll.
+//
+// It runs the same checks as Vote, but without a ballot. Nothing is saved.
+func (v *Vote) Eligible(ctx context.Context, pollID, requestUser, voteUser int) (Eligibility, error) {
+	ds := dsfetch.New(v.flow)
+	poll, err := loadPoll(ctx, ds, pollID)
+	if err != nil {
+		return Eligibility{}, fmt.Errorf("loading poll: %w", err)
+	}
+
+	if poll.state != "started" {
+		return Eligibility{Reason: "not-started"}, nil
+	}
+
+	err = v.ensurePresent(ctx, ds, poll.meetingID, requestUser)
+	if err == nil {
+		_, err = v.checkVoteUser(ctx, ds, poll, voteUser, requestUser)
+	}
+	if err != nil {
+		if reason := notAllowedReason(err); reason != "" {
+			return Eligibility{Reason: reason}, nil
+		}
+		return Eligibility{}, err
+	}
+
+	if v.hasVoted(pollID, voteUser) {
+		return Eligibility{Reason: "already-voted"}, nil
+	}
+
+	return Eligibility{Eligible: true}, nil
+}
+
+// hasVoted returns true, if the user is in v.voted for the poll.
+//
+// In difference to Backend.VotedObject, this also works for polls, where the
+// vote objects do not contain the user id.
+func (v *Vote) hasVoted(pollID, userID int) bool {
+	v.votedMu.Lock()
+	defer v.votedMu.Unlock()
+
+	for _, id := range v.voted[pollID] {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// EligibilityCheck is the result of one check, that a user has to pass to
+// vote on a poll.
+type EligibilityCheck struct {
+	Check  string `json:"check"`
+	Passed bool   `json:"passed"`
+}
+
+// EligibilityChecks runs all checks, that the request user has to pass to vote
+// for the vote user on a poll. In difference to Eligible, it does not stop at
+// the first failed check, so all problems are reported at once.
+//
+// The checks are `started`, `present`, `in-meeting`, `in-group`, `delegation`
+// and `not-voted`. The check `delegation` always passes, if the request user
+// votes for himself. If the request user can not vote for the vote user, only
+// the checks `started`, `present` and the failed check `delegation` are
+// returned, so nothing about the vote user is revealed.
+func (v *Vote) EligibilityChecks(ctx context.Context, pollID, requestUser, voteUser int) ([]EligibilityCheck, error) {
+	ds := dsfetch.New(v.flow)
+	poll, err := loadPoll(ctx, ds, pollID)
+	if err != nil {
+		return nil, fmt.Errorf("loading poll: %w", err)
+	}
+
+	present, err := isPresent(ctx, ds, poll.meetingID, requestUser)
+	if err != nil {
+		return nil, fmt.Errorf("checking presence: %w", err)
+	}
+
+	meetingUserID, inMeeting, err := getMeetingUser(ctx, ds, voteUser, poll.meetingID)
+	if err != nil {
+		return nil, fmt.Errorf("get meeting user: %w", err)
+	}
+
+	delegated := voteUser == requestUser
+	if !delegated && inMeeting {
+		err := v.ensureDelegation(ctx, ds, poll, voteUser, meetingUserID, requestUser)
+		if err != nil && notAllowedReason(err) == "" {
+			return nil, fmt.Errorf("checking delegation: %w", err)
+		}
+		delegated = err == nil
+	}
+
+	checks := []EligibilityCheck{
+		{Check: "started", Passed: poll.state == "started"},
+		{Check: "present", Passed: present},
+	}
+
+	if !delegated {
+		// The request user is not allowed to see anything about the other
+		// user.
+		return append(checks, EligibilityCheck{Check: "delegation", Passed: false}), nil
+	}
+
+	var entitled bool
+	if inMeeting {
+		entitled, err = v.isEntitled(ctx, ds, poll, voteUser, meetingUserID)
+		if err != nil {
+			return nil, fmt.Errorf("checking entitlement: %w", err)
+		}
+	}
+
+	return append(checks, []EligibilityCheck{
+		{Check: "in-meeting", Passed: inMeeting},
+		{Check: "in-group", Passed: entitled},
+		{Check: "delegation", Passed: delegated},
+		{Check: "not-voted", Passed: !v.hasVoted(pollID, voteUser)},
+	}...), nil
+}
+
+// ensurePresent makes sure that the user sending the vote request is present.
+//
+// The result can come from the presence cache.
+func (v *Vote) ensurePresent(ctx context.Context, ds *dsfetch.Fetch, meetingID, user int) error {
+	present, err := v.presence.isPresent(ctx, ds, meetingID, user)
+	if err != nil {
+		return err
+	}
+
+	if !present {
+		return notAllowedError("not-present", "You have to be present in meeting %d", meetingID)
+	}
+	return nil
+}
+
+// isPresent returns true, if the user is present in the meeting.
+func isPresent(ctx context.Context, ds *dsfetch.Fetch, meetingID, user int) (bool, error) {
+	presentMeetings, err := ds.User_IsPresentInMeetingIDs(user).Value(ctx)
+	if err != nil {
+		return false, fmt.Errorf("fetching is present in meetings: %w", err)
+	}
+
+	for _, present := range presentMeetings {
+		if present == meetingID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ensureVoteUser makes sure the user from the vote:
+// * the delegation is correct and
+// * is in the correct group
+func (v *Vote) ensureVoteUser(ctx context.Context, ds *dsfetch.Fetch, poll pollConfig, voteUser, voteMeetingUserID, requestUser int) error {
+	entitled, err := v.isEntitled(ctx, ds, poll, voteUser, voteMeetingUserID)
+	if err != nil {
+		return fmt.Errorf("checking entitlement of user %d: %w", voteUser, err)
+	}
+
+	if !entitled {
+		return notAllowedError("not-in-group", "User %d is not allowed to vote. He is not in an entitled group", voteUser)
+	}
+
+	if voteUser == requestUser {
+		return nil
+	}
+
+	return v.ensureDelegation(ctx, ds, poll, voteUser, voteMeetingUserID, requestUser)
+}
+
+// ensureDelegation makes sure, that the request user can vote for the vote
+// user by a delegation.
+//
+// If delegateMustBeEntitled is set, the request user also has to be in the
+// correct group.
+//
+// If allowAbsentDelegates is not set, the user, that is represented, also has
+// to be present.
+func (v *Vote) ensureDelegation(ctx context.Context, ds *dsfetch.Fetch, poll pollConfig, voteUser, voteMeetingUserID, requestUser int) error {
+	log.Debug("Vote delegation")
+
+	delegationActivated, err := ds.Meeting_UsersEnableVoteDelegations(poll.meetingID).Value(ctx)
+	if err != nil {
+		return fmt.Errorf("fetching user enable vote delegation: %w", err)
+	}
+
+	if !delegationActivated {
+		return notAllowedError("delegation-disabled", "Vote delegation is not activated in meeting %d", poll.meetingID)
+	}
+
+	requestMeetingUserID, found, err := getMeetingUser(ctx, ds, requestUser, poll.meetingID)
+	if err != nil {
+		return fmt.Errorf("getting meeting_user for request user: %w", err)
+	}
+
+	if !found {
+		return notAllowedError("not-in-meeting", "You are not in the right meeting")
+	}
+
+	delegated, err := v.isDelegatedTo(ctx, ds, voteMeetingUserID, requestMeetingUserID)
+	if err != nil {
+		return fmt.Errorf("fetching delegation : %w", err)
+	}
+
+	if !delegated {
+		return notAllowedError("not-delegated", "You can not vote for user %d", voteUser)
+	}
+
+	if v.delegateMustBeEntitled {
+		requestEntitled, err := v.isEntitled(ctx, ds, poll, requestUser, requestMeetingUserID)
+		if err != nil {
+			return fmt.Errorf("checking entitlement of user %d: %w", requestUser, err)
+		}
+
+		if !requestEntitled {
+			return notAllowedError("delegate-not-in-group", "You can not vote for user %d. You are not in an entitled group", voteUser)
+		}
+	}
+
+	if !v.allowAbsentDelegates {
+		present, err := isPresent(ctx, ds, poll.meetingID, voteUser)
+		if err != nil {
+			return fmt.Errorf("checking presence of user %d: %w", voteUser, err)
+		}
+
+		if !present {
+			return notAllowedError("principal-not-present", "You can not vote for user %d. He is not present in meeting %d", voteUser, poll.meetingID)
+		}
+	}
+
+	return nil
+}
+
+// isDelegatedTo returns true, if the vote of a meeting user is delegated to
+// another meeting user.
+//
+// The delegation can go over other meeting users, as long as the chain is not
+// longer then maxDelegationDepth. A cycle in the chain ends the search.
+func (v *Vote) isDelegatedTo(ctx context.Context, ds *dsfetch.Fetch, fromMeetingUserID, toMeetingUserID int) (bool, error) {
+	current := fromMeetingUserID
+	seen := map[int]struct{}{current: {}}
+	for depth := 0; depth < v.maxDelegationDepth; depth++ {
+		delegation, found, err := ds.MeetingUser_VoteDelegatedToID(current).Value(ctx)
+		if err != nil {
+			return false, fmt.Errorf("fetching delegation of meeting user %d: %w", current, err)
+		}
+
+		if !found {
+			return false, nil
+		}
+
+		if delegation == toMeetingUserID {
+			return true, nil
+		}
+
+		if _, ok := seen[delegation]; ok {
+			return false, nil
+		}
+		seen[delegation] = struct{}{}
+		current = delegation
+	}
+	return false, nil
+}
+
+// isEntitled returns true, if the user is entitled to vote on the poll.
+//
+// If the poll was started with an explicit list of entitled users, only this
+// list is used. In other case, the user has to be in an entitled group.
+func (v *Vote) isEntitled(ctx context.Context, ds *dsfetch.Fetch, poll pollConfig, userID, meetingUserID int) (bool, error) {
+	if entitled, explicit := v.explicitEntitled(poll.id, userID); explicit {
+		return entitled, nil
+	}
+
+	groupIDs, err := v.meetingUserGroups(ctx, ds, poll.meetingID, meetingUserID)
+	if err != nil {
+		return false, fmt.Errorf("fetching groups of user %d in meeting %d: %w", userID, poll.meetingID, err)
+	}
+
+	return equalElement(groupIDs, poll.groups), nil
+}
+
+// meetingUserGroups returns the group ids of a meeting user.
+//
+// If the default group entitlement is enabled, a meeting user without groups
+// is in the default group of the meeting.
+func (v *Vote) meetingUserGroups(ctx context.Context, ds *dsfetch.Fetch, meetingID, meetingUserID int) ([]int, error) {
+	groupIDs, err := ds.MeetingUser_GroupIDs(meetingUserID).Value(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fetching groups: %w", err)
+	}
+
+	if len(groupIDs) == 0 && v.entitleDefaultGroup {
+		// A user without groups is implicitly in the default group.
+		defaultGroupID, err := ds.Meeting_DefaultGroupID(meetingID).Value(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("fetching default group of meeting %d: %w", meetingID, err)
+		}
+		groupIDs = []int{defaultGroupID}
+	}
+
+	return groupIDs, nil
+}
+
+// delegatedUserIDs returns all user ids for which the user can vote. Each user
+// is returned only once and the user itself is not returned.
+func delegatedUserIDs(ctx context.Context, fetch *dsfetch.Fetch, userID int) ([]int, error) {
+	meetingUserIDs, err := fetch.User_MeetingUserIDs(userID).Value(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fetching meeting user: %w", err)
+	}
+
+	meetingUserDelegationsIDs := make([][]int, len(meetingUserIDs))
+	for i, muid := range meetingUserIDs {
+		fetch.MeetingUser_VoteDelegationsFromIDs(muid).Lazy(&meetingUserDelegationsIDs[i])
+	}
+
+	if err := fetch.Execute(ctx); err != nil {
+		return nil, fmt.Errorf("getting vote_delegation_from values: %w", err)
+	}
 
 	var delegatedMeetingUserIDs []int
 	for i := range meetingUserDelegationsIDs {
@@ -413,7 +1836,19 @@ func delegatedUserIDs(ctx context.Context, fetch *dsfetch.Fetch, userID int) ([]
 		return nil, fmt.Errorf("getting user_ids from meeting_user_ids: %w", err)
 	}
 
-	return userIDs, nil
+	// With a delegation cycle or delegations in many meetings, a user could be
+	// in the list more then once or the user could delegate to himself.
+	seen := map[int]struct{}{userID: {}}
+	unique := userIDs[:0]
+	for _, id := range userIDs {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		unique = append(unique, id)
+	}
+
+	return unique, nil
 }
 
 // Voted tells, on which the requestUser has already voted.
@@ -424,40 +1859,354 @@ func (v *Vote) Voted(ctx context.Context, pollIDs []int, requestUser int) (map[i
 		return nil, fmt.Errorf("getting all delegated users: %w", err)
 	}
 
-	requestedUserIDs := make(map[int]struct{}, len(userIDs)+1)
-	requestedUserIDs[requestUser] = struct{}{}
-	for _, uid := range userIDs {
-		requestedUserIDs[uid] = struct{}{}
+	requestedUserIDs := make(map[int]struct{}, len(userIDs)+1)
+	requestedUserIDs[requestUser] = struct{}{}
+	for _, uid := range userIDs {
+		requestedUserIDs[uid] = struct{}{}
+	}
+
+	requestedPollIDs := make(map[int]struct{}, len(pollIDs))
+	for _, pid := range pollIDs {
+		requestedPollIDs[pid] = struct{}{}
+	}
+
+	v.votedMu.Lock()
+	defer v.votedMu.Unlock()
+
+	out := make(map[int][]int, len(pollIDs))
+	for pid, userIDs := range v.voted {
+		if _, ok := requestedPollIDs[pid]; !ok {
+			continue
+		}
+
+		for _, uid := range userIDs {
+			if _, ok := requestedUserIDs[uid]; ok {
+				out[pid] = append(out[pid], uid)
+			}
+		}
+	}
+
+	for _, pid := range pollIDs {
+		if _, ok := out[pid]; !ok {
+			out[pid] = nil
+		}
+	}
+
+	return out, nil
+}
+
+// VotedHashes returns for the given polls the sha256 hash of the vote object
+// of the request user and all users the request user can vote for. It can be
+// used to verify, that a vote was saved, without revealing the vote.
+//
+// Hashes are only returned for named polls.
+func (v *Vote) VotedHashes(ctx context.Context, pollIDs []int, requestUser int) (map[int]map[int]string, error) {
+	voted, err := v.Voted(ctx, pollIDs, requestUser)
+	if err != nil {
+		return nil, fmt.Errorf("getting voted users: %w", err)
+	}
+
+	ds := dsfetch.New(v.flow)
+	out := make(map[int]map[int]string, len(voted))
+	for _, pollID := range pollIDs {
+		userIDs := voted[pollID]
+		out[pollID] = make(map[int]string, len(userIDs))
+		if len(userIDs) == 0 {
+			continue
+		}
+
+		poll, err := loadPoll(ctx, ds, pollID)
+		if err != nil {
+			return nil, fmt.Errorf("loading poll %d: %w", pollID, err)
+		}
+
+		if poll.ptype != "named" {
+			return nil, MessageError(ErrNotAllowed, "Poll %d is not a named poll. Hashes are only available for named polls", pollID)
+		}
+
+		for _, userID := range userIDs {
+			object, found, err := v.backend(poll).VotedObject(ctx, pollID, userID)
+			if err != nil {
+				var errNotExist interface{ DoesNotExist() }
+				if errors.As(err, &errNotExist) {
+					return nil, MessageError(ErrNotExists, "Poll %d does not exist in the backend", pollID)
+				}
+				return nil, fmt.Errorf("fetching vote object of user %d in poll %d: %w", userID, pollID, err)
+			}
+
+			if !found {
+				continue
+			}
+
+			out[pollID][userID] = fmt.Sprintf("%x", sha256.Sum256(object))
+		}
+	}
+
+	return out, nil
+}
+
+// GroupTurnout is the turnout of one group.
+type GroupTurnout struct {
+	Entitled int `json:"entitled"`
+	Voted    int `json:"voted"`
+}
+
+// TurnoutByGroup returns for each entitled group of a poll, how many members
+// the group has and how many of them have voted.
+//
+// A vote of a delegate is counted for the group of the represented user. Users
+// in more then one entitled group are counted in each group.
+func (v *Vote) TurnoutByGroup(ctx context.Context, pollID int) (map[int]GroupTurnout, error) {
+	ds := dsfetch.New(v.flow)
+	poll, err := loadPoll(ctx, ds, pollID)
+	if err != nil {
+		return nil, fmt.Errorf("loading poll: %w", err)
+	}
+
+	if !v.liveResultsAllowed(poll) {
+		return nil, MessageError(ErrNotAllowed, "Poll %d does not show results before it is stopped", pollID)
+	}
+
+	userIDs, err := groupUserIDs(ctx, ds, poll.groups)
+	if err != nil {
+		return nil, err
+	}
+
+	v.votedMu.Lock()
+	voted := make(map[int]struct{}, len(v.voted[pollID]))
+	for _, userID := range v.voted[pollID] {
+		voted[userID] = struct{}{}
+	}
+	v.votedMu.Unlock()
+
+	out := make(map[int]GroupTurnout, len(poll.groups))
+	for i, groupID := range poll.groups {
+		var turnout GroupTurnout
+		for _, userID := range userIDs[i] {
+			turnout.Entitled++
+			if _, ok := voted[userID]; ok {
+				turnout.Voted++
+			}
+		}
+		out[groupID] = turnout
+	}
+
+	return out, nil
+}
+
+// EntitledCount returns the number of users, that are entitled to vote on a
+// poll. A user in many entitled groups is only counted once.
+//
+// If the poll was started with an explicit list of entitled users, the length
+// of this list is returned.
+func (v *Vote) EntitledCount(ctx context.Context, pollID int) (int, error) {
+	v.entitledMu.Lock()
+	explicit, ok := v.entitled[pollID]
+	v.entitledMu.Unlock()
+	if ok {
+		return len(explicit), nil
+	}
+
+	ds := dsfetch.New(v.flow)
+	poll, err := loadPoll(ctx, ds, pollID)
+	if err != nil {
+		return 0, fmt.Errorf("loading poll: %w", err)
+	}
+
+	userIDs, err := groupUserIDs(ctx, ds, poll.groups)
+	if err != nil {
+		return 0, err
+	}
+
+	entitled := make(map[int]struct{})
+	for _, ids := range userIDs {
+		for _, id := range ids {
+			entitled[id] = struct{}{}
+		}
+	}
+
+	return len(entitled), nil
+}
+
+// PollTurnout is the turnout of one poll.
+type PollTurnout struct {
+	Votes    int `json:"votes"`
+	Entitled int `json:"entitled"`
+}
+
+// MeetingTurnout returns for each poll of a meeting, how many users have voted
+// and how many users are entitled to vote. Polls, that are not started, have
+// no votes.
+//
+// The entitled users of all polls are loaded together, so the number of
+// datastore requests does not grow with the number of polls.
+func (v *Vote) MeetingTurnout(ctx context.Context, meetingID int) (map[int]PollTurnout, error) {
+	ds := dsfetch.New(v.flow)
+	pollIDs, err := ds.Meeting_PollIDs(meetingID).Value(ctx)
+	if err != nil {
+		var errDoesNotExist dsfetch.DoesNotExistError
+		if errors.As(err, &errDoesNotExist) {
+			return nil, MessageError(ErrNotExists, "Meeting %d does not exist", meetingID)
+		}
+		return nil, fmt.Errorf("fetching polls of meeting %d: %w", meetingID, err)
+	}
+
+	pollGroups := make([][]int, len(pollIDs))
+	for i, pollID := range pollIDs {
+		ds.Poll_EntitledGroupIDs(pollID).Lazy(&pollGroups[i])
+	}
+
+	if err := ds.Execute(ctx); err != nil {
+		return nil, fmt.Errorf("fetching entitled groups: %w", err)
+	}
+
+	groupIndex := make(map[int]int)
+	var groupIDs []int
+	for _, groups := range pollGroups {
+		for _, groupID := range groups {
+			if _, ok := groupIndex[groupID]; !ok {
+				groupIndex[groupID] = len(groupIDs)
+				groupIDs = append(groupIDs, groupID)
+			}
+		}
+	}
+
+	userIDs, err := groupUserIDs(ctx, ds, groupIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	voteCount := v.VoteCount(ctx)
+
+	v.entitledMu.Lock()
+	defer v.entitledMu.Unlock()
+
+	out := make(map[int]PollTurnout, len(pollIDs))
+	for i, pollID := range pollIDs {
+		turnout := PollTurnout{Votes: voteCount[pollID]}
+
+		if explicit, ok := v.entitled[pollID]; ok {
+			turnout.Entitled = len(explicit)
+			out[pollID] = turnout
+			continue
+		}
+
+		entitled := make(map[int]struct{})
+		for _, groupID := range pollGroups[i] {
+			for _, userID := range userIDs[groupIndex[groupID]] {
+				entitled[userID] = struct{}{}
+			}
+		}
+		turnout.Entitled = len(entitled)
+		out[pollID] = turnout
+	}
+
+	return out, nil
+}
+
+// groupUserIDs returns for each group the user ids of its members.
+func groupUserIDs(ctx context.Context, ds *dsfetch.Fetch, groupIDs []int) ([][]int, error) {
+	meetingUserIDs := make([][]int, len(groupIDs))
+	for i, groupID := range groupIDs {
+		ds.Group_MeetingUserIDs(groupID).Lazy(&meetingUserIDs[i])
+	}
+
+	if err := ds.Execute(ctx); err != nil {
+		return nil, fmt.Errorf("fetching group members: %w", err)
+	}
+
+	userIDs := make([][]int, len(groupIDs))
+	for i := range meetingUserIDs {
+		userIDs[i] = make([]int, len(meetingUserIDs[i]))
+		for j, muID := range meetingUserIDs[i] {
+			ds.MeetingUser_UserID(muID).Lazy(&userIDs[i][j])
+		}
+	}
+
+	if err := ds.Execute(ctx); err != nil {
+		return nil, fmt.Errorf("fetching user ids of group members: %w", err)
+	}
+
+	return userIDs, nil
+}
+
+// liveResultsAllowed tells, if results of a poll can be read. This is always
+// true for polls, that are not started. A started poll only shows results, if
+// it is named and live results are enabled with WithLiveResults.
+func (v *Vote) liveResultsAllowed(poll pollConfig) bool {
+	if poll.state != "started" {
+		return true
 	}
 
-	requestedPollIDs := make(map[int]struct{}, len(pollIDs))
-	for _, pid := range pollIDs {
-		requestedPollIDs[pid] = struct{}{}
+	return poll.ptype == "named" && v.liveResults
+}
+
+// BackendHealth pings the fast and the long backend. It returns the errors
+// with the keys `fast` and `long`. The value is nil, if the backend is
+// reachable.
+func (v *Vote) BackendHealth(ctx context.Context) map[string]error {
+	return map[string]error{
+		"fast": v.fastBackend.Ping(ctx),
+		"long": v.longBackend.Ping(ctx),
 	}
+}
 
-	v.votedMu.Lock()
-	defer v.votedMu.Unlock()
+// BackendUsage is the number of polls and votes in a backend.
+type BackendUsage struct {
+	Polls int `json:"polls"`
+	Votes int `json:"votes"`
+}
 
-	out := make(map[int][]int, len(pollIDs))
-	for pid, userIDs := range v.voted {
-		if _, ok := requestedPollIDs[pid]; !ok {
-			continue
+// BackendLoad returns for the fast and the long backend how many polls and
+// votes they hold. The keys are `fast` and `long`.
+//
+// The data is read from the backends and not from the memory of the instance.
+// Polls, that were stopped but not cleared, are included. Some backends do not
+// report polls without votes.
+func (v *Vote) BackendLoad(ctx context.Context) (map[string]BackendUsage, error) {
+	load := make(map[string]BackendUsage, 2)
+	for name, backend := range map[string]Backend{"fast": v.fastBackend, "long": v.longBackend} {
+		voted, err := v.backendVoted(ctx, backend)
+		if err != nil {
+			return nil, fmt.Errorf("fetching voted from %s backend: %w", name, err)
 		}
 
-		for _, uid := range userIDs {
-			if _, ok := requestedUserIDs[uid]; ok {
-				out[pid] = append(out[pid], uid)
-			}
+		usage := BackendUsage{Polls: len(voted)}
+		for _, userIDs := range voted {
+			usage.Votes += len(userIDs)
 		}
+		load[name] = usage
 	}
+	return load, nil
+}
 
-	for _, pid := range pollIDs {
-		if _, ok := out[pid]; !ok {
-			out[pid] = nil
+// PollsVotedByUser returns the ids of all polls in the backends, on which the
+// user has voted. Votes, that were given by a delegate for the user, are
+// included.
+func (v *Vote) PollsVotedByUser(ctx context.Context, userID int) ([]int, error) {
+	pollIDs := make(map[int]struct{})
+	for name, backend := range map[string]Backend{"fast": v.fastBackend, "long": v.longBackend} {
+		voted, err := v.backendVoted(ctx, backend)
+		if err != nil {
+			return nil, fmt.Errorf("fetching voted from %s backend: %w", name, err)
+		}
+
+		for pollID, userIDs := range voted {
+			for _, id := range userIDs {
+				if id == userID {
+					pollIDs[pollID] = struct{}{}
+					break
+				}
+			}
 		}
 	}
 
-	return out, nil
+	sorted := make([]int, 0, len(pollIDs))
+	for pollID := range pollIDs {
+		sorted = append(sorted, pollID)
+	}
+	sort.Ints(sorted)
+	return sorted, nil
 }
 
 // VoteCount returns how many users have voted for all polls.
@@ -473,28 +2222,198 @@ func (v *Vote) VoteCount(ctx context.Context) map[int]int {
 	return count
 }
 
-// loadVoted creates the value for v.voted by the backends.
-func (v *Vote) loadVoted(ctx context.Context) error {
-	fastData, err := v.fastBackend.Voted(ctx)
+// PollStates returns all polls, that exist in the backends. The value is true,
+// if the poll is stopped.
+//
+// The polls are read from the backends, so polls of other instances are also
+// found.
+func (v *Vote) PollStates(ctx context.Context) (map[int]bool, error) {
+	states := make(map[int]bool)
+	for _, named := range v.namedBackends() {
+		var polls map[int]bool
+		err := v.withBackendTimeout(ctx, "polls", func(ctx context.Context) error {
+			var err error
+			polls, err = named.backend.Polls(ctx)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("fetching polls from %s backend: %w", named.name, err)
+		}
+
+		for pollID, stopped := range polls {
+			states[pollID] = stopped
+		}
+	}
+	return states, nil
+}
+
+// ActiveMeetings returns the ids of all meetings with a started poll.
+//
+// The started polls are read from the backends, so polls of other instances are
+// also found. The meeting ids are fetched from the datastore in one request.
+// Polls, that do not exist in the datastore, are skipped.
+func (v *Vote) ActiveMeetings(ctx context.Context) ([]int, error) {
+	states, err := v.PollStates(ctx)
 	if err != nil {
-		return fmt.Errorf("fetching data from fast backend: %w", err)
+		return nil, err
+	}
+
+	var keys []dskey.Key
+	for pollID, stopped := range states {
+		if stopped {
+			continue
+		}
+
+		key, err := dskey.FromParts("poll", pollID, "meeting_id")
+		if err != nil {
+			return nil, fmt.Errorf("building key for poll %d: %w", pollID, err)
+		}
+		keys = append(keys, key)
 	}
 
-	longData, err := v.longBackend.Voted(ctx)
+	meetingIDs := []int{}
+	if len(keys) == 0 {
+		return meetingIDs, nil
+	}
+
+	data, err := dsfetch.New(v.flow).Get(ctx, keys...)
 	if err != nil {
-		return fmt.Errorf("fetching data from long backend: %w", err)
+		return nil, fmt.Errorf("fetching meeting ids: %w", err)
+	}
+
+	active := make(map[int]struct{})
+	for _, key := range keys {
+		if data[key] == nil {
+			continue
+		}
+
+		var meetingID int
+		if err := json.Unmarshal(data[key], &meetingID); err != nil {
+			return nil, fmt.Errorf("decoding %s: %w", key, err)
+		}
+		active[meetingID] = struct{}{}
+	}
+
+	for meetingID := range active {
+		meetingIDs = append(meetingIDs, meetingID)
+	}
+	sort.Ints(meetingIDs)
+
+	return meetingIDs, nil
+}
+
+// loadVoted creates the value for v.voted by the backends.
+//
+// The map is replaced as a whole. Polls that are stopped are still known by the
+// backends and are kept. Polls that were cleared, for example by another
+// instance, are dropped, so the map does not grow on long running instances.
+//
+// The backends are asked at the same time, so a slow long backend does not
+// delay the fast backend. In development, the backend for ForceBackend is also
+// asked.
+func (v *Vote) loadVoted(ctx context.Context) error {
+	var fastData, longData, memoryData map[int][]int
+	eg, ctx := errgroup.WithContext(ctx)
+
+	eg.Go(func() error {
+		data, err := v.backendVoted(ctx, v.fastBackend)
+		if err != nil {
+			return fmt.Errorf("fetching data from fast backend: %w", err)
+		}
+		fastData = data
+		return nil
+	})
+
+	eg.Go(func() error {
+		data, err := v.backendVoted(ctx, v.longBackend)
+		if err != nil {
+			return fmt.Errorf("fetching data from long backend: %w", err)
+		}
+		longData = data
+		return nil
+	})
+
+	if v.memoryBackend != nil {
+		eg.Go(func() error {
+			data, err := v.backendVoted(ctx, v.memoryBackend)
+			if err != nil {
+				return fmt.Errorf("fetching data from memory backend: %w", err)
+			}
+			memoryData = data
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+
+	if fastData == nil {
+		fastData = make(map[int][]int, len(longData)+len(memoryData))
 	}
 
 	for pid, userIDs := range longData {
 		fastData[pid] = userIDs
 	}
 
+	for pid, userIDs := range memoryData {
+		fastData[pid] = userIDs
+	}
+
 	v.votedMu.Lock()
 	v.voted = fastData
 	v.votedMu.Unlock()
 	return nil
 }
 
+// RefreshVoted reloads the users, that have voted, and the deadlines of the
+// polls from the backends.
+//
+// On a single instance, this is only done at startup. RefreshVoted can be used
+// after the backends were changed from outside of the service.
+func (v *Vote) RefreshVoted(ctx context.Context) error {
+	if err := v.loadVoted(ctx); err != nil {
+		return fmt.Errorf("loading voted: %w", err)
+	}
+
+	if err := v.loadDeadlines(ctx); err != nil {
+		return fmt.Errorf("loading deadlines: %w", err)
+	}
+	return nil
+}
+
+// withBackendTimeout calls f with a context, that is canceled after the
+// backend timeout. If the backend does not answer in time, an ErrTemporary is
+// returned, so the client can try again later.
+//
+// f is called synchronously, so the backend has to return, when the context is
+// canceled.
+func (v *Vote) withBackendTimeout(ctx context.Context, call string, f func(context.Context) error) error {
+	if v.backendTimeout <= 0 {
+		return f(ctx)
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, v.backendTimeout)
+	defer cancel()
+
+	err := f(callCtx)
+	if err != nil && ctx.Err() == nil && errors.Is(callCtx.Err(), context.DeadlineExceeded) {
+		return MessageError(ErrTemporary, "Backend did not answer to %s in %s", call, v.backendTimeout)
+	}
+	return err
+}
+
+// backendVoted calls Voted on the backend with the backend timeout.
+func (v *Vote) backendVoted(ctx context.Context, backend Backend) (map[int][]int, error) {
+	var voted map[int][]int
+	err := v.withBackendTimeout(ctx, "voted", func(ctx context.Context) error {
+		var err error
+		voted, err = backend.Voted(ctx)
+		return err
+	})
+	return voted, err
+}
+
 // Backend is a storage for the poll options.
 type Backend interface {
 	// Start opens the poll for votes. To start a poll that is already started
@@ -512,11 +2431,39 @@ type Backend interface {
 	// The return value is the number of already voted objects.
 	Vote(ctx context.Context, pollID int, userID int, object []byte) error
 
+	// VoteWithNonce saves vote data like Vote. In the same atomic step, the
+	// backend has to check, that the nonce was not used before on the poll,
+	// and save it. If it was used, an error with the method `NonceUsed()` has
+	// to be returned and the vote is not saved. An empty nonce is not saved.
+	//
+	// The nonces of a poll are removed by Clear and ClearAll.
+	VoteWithNonce(ctx context.Context, pollID int, userID int, nonce string, object []byte) error
+
 	// Stop ends a poll and returns all poll objects and all userIDs from users
 	// that have voted. It is ok to call Stop() on a stopped poll. On a unknown
 	// poll `DoesNotExist()` has to be returned.
 	Stop(ctx context.Context, pollID int) ([][]byte, []int, error)
 
+	// Result returns the same data as Stop for a stopped poll, but does not
+	// change anything. On a unknown poll `DoesNotExist()` has to be returned.
+	// On a poll, that is not stopped, it has to be `Started()`.
+	Result(ctx context.Context, pollID int) ([][]byte, []int, error)
+
+	// Reopen starts a stopped poll again. The votes are not removed, so users
+	// that have voted can not vote again. It is ok to call Reopen() on a
+	// started poll. On a unknown poll `DoesNotExist()` has to be returned.
+	Reopen(ctx context.Context, pollID int) error
+
+	// RetractVote removes the vote of a user from a started poll, so the user
+	// can vote again. The vote object is not returned by Stop anymore, but
+	// VotesSince can still return it. If the user has not voted, it is a noop.
+	//
+	// On a unknown poll `DoesNotExist()` has to be returned. On a stopped poll
+	// it has to be `Stopped()`. A backend, that can not find the vote object of
+	// the user, has to return an error with the method `NotRetractable()` and
+	// must not change the poll.
+	RetractVote(ctx context.Context, pollID int, userID int) error
+
 	// Clear has to remove all data. It can be called on a started or stopped or
 	// non existing poll.
 	Clear(ctx context.Context, pollID int) error
@@ -527,6 +2474,36 @@ type Backend interface {
 	// Voted returns for all polls the userIDs, that have voted.
 	Voted(ctx context.Context) (map[int][]int, error)
 
+	// Polls returns all polls, that are started or stopped in the backend. The
+	// value is true, if the poll is stopped.
+	Polls(ctx context.Context) (map[int]bool, error)
+
+	// SetTime saves a time with a name for a poll, for example the deadline of
+	// the poll. If overwrite is false, a time, that is already saved with the
+	// name, is not replaced. The zero time removes the time. The times of a
+	// poll are removed by Clear and ClearAll.
+	//
+	// On a unknown poll `DoesNotExist()` has to be returned.
+	SetTime(ctx context.Context, pollID int, name string, t time.Time, overwrite bool) error
+
+	// Times returns for all polls the time with the name. Polls without the
+	// time are not returned.
+	Times(ctx context.Context, name string) (map[int]time.Time, error)
+
+	// VotesSince returns all vote objects of a poll with a sequence number
+	// greater then afterSeq and the latest sequence number of the poll. The
+	// sequence numbers of a poll have to increase with each vote. On a unknown
+	// poll `DoesNotExist()` has to be returned.
+	VotesSince(ctx context.Context, pollID int, afterSeq int) ([][]byte, int, error)
+
+	// VotedObject returns the vote object a user has voted with. If the user
+	// has not voted or the backend can not associate the object with the user,
+	// found is false. On a unknown poll `DoesNotExist()` has to be returned.
+	VotedObject(ctx context.Context, pollID int, userID int) (object []byte, found bool, err error)
+
+	// Ping checks, that the backend is reachable.
+	Ping(ctx context.Context) error
+
 	fmt.Stringer
 }
 
@@ -545,10 +2522,47 @@ type pollConfig struct {
 	maxVotesPerOption int
 	options           []int
 	state             string
+
+	// maxTextLength is the maximum length in bytes of a ballot on a poll with
+	// method TEXT. It is not part of the datastore.
+	maxTextLength int
 }
 
 func loadPoll(ctx context.Context, ds *dsfetch.Fetch, pollID int) (pollConfig, error) {
 	p := pollConfig{id: pollID}
+	p.lazy(ds)
+
+	if err := ds.Execute(ctx); err != nil {
+		var errDoesNotExist dsfetch.DoesNotExistError
+		if errors.As(err, &errDoesNotExist) && dskey.Key(errDoesNotExist).Collection() == "poll" && dskey.Key(errDoesNotExist).ID() == pollID {
+			return pollConfig{}, ErrNotExists
+		}
+
+		if pollWithoutMeeting(ctx, ds, pollID) {
+			return pollConfig{}, MessageError(ErrInvalid, "Poll %d has no meeting", pollID)
+		}
+		return pollConfig{}, fmt.Errorf("loading polldata from datastore: %w", err)
+	}
+
+	return p, nil
+}
+
+// pollWithoutMeeting returns true, if the field poll/meeting_id is empty. This
+// is a corrupted poll, that makes dsfetch fail, since the field is required.
+func pollWithoutMeeting(ctx context.Context, ds *dsfetch.Fetch, pollID int) bool {
+	key, err := dskey.FromParts("poll", pollID, "meeting_id")
+	if err != nil {
+		return false
+	}
+
+	data, err := ds.Get(ctx, key)
+	return err == nil && data[key] == nil
+}
+
+// lazy registers all fields of the poll with the id p.id. They are set on the
+// next ds.Execute.
+func (p *pollConfig) lazy(ds *dsfetch.Fetch) {
+	pollID := p.id
 	ds.Poll_MeetingID(pollID).Lazy(&p.meetingID)
 	ds.Poll_Backend(pollID).Lazy(&p.backend)
 	ds.Poll_Type(pollID).Lazy(&p.ptype)
@@ -562,33 +2576,80 @@ func loadPoll(ctx context.Context, ds *dsfetch.Fetch, pollID int) (pollConfig, e
 	ds.Poll_MaxVotesPerOption(pollID).Lazy(&p.maxVotesPerOption)
 	ds.Poll_OptionIDs(pollID).Lazy(&p.options)
 	ds.Poll_State(pollID).Lazy(&p.state)
+}
 
-	if err := ds.Execute(ctx); err != nil {
-		var errDoesNotExist dsfetch.DoesNotExistError
-		if errors.As(err, &errDoesNotExist) && dskey.Key(errDoesNotExist).Collection() == "poll" && dskey.Key(errDoesNotExist).ID() == pollID {
-			return pollConfig{}, ErrNotExists
-		}
-		return pollConfig{}, fmt.Errorf("loading polldata from datastore: %w", err)
+// checkStartable returns ErrWrongPollType, if the poll can not be started
+// electronically. This is the case for analog polls and for polls, that are
+// finished or published.
+func (p pollConfig) checkStartable() error {
+	if p.ptype == "analog" {
+		return MessageError(ErrWrongPollType, "Analog poll can not be started")
 	}
 
-	return p, nil
+	if p.state == "finished" || p.state == "published" {
+		return MessageError(ErrWrongPollType, "Poll %d is %s and can not be started", p.id, p.state)
+	}
+
+	return nil
+}
+
+// checkAmounts returns an error, if the amounts of a poll with the method Y
+// or N can never be fulfilled by a ballot. Zero values are treated as 1 like in
+// validate.
+func (p pollConfig) checkAmounts() error {
+	if p.method != "Y" && p.method != "N" {
+		return nil
+	}
+
+	minAmount, maxAmount, maxPerOption := p.minAmount, p.maxAmount, p.maxVotesPerOption
+	if minAmount == 0 {
+		minAmount = 1
+	}
+
+	if maxAmount == 0 {
+		maxAmount = 1
+	}
+
+	if maxPerOption == 0 {
+		maxPerOption = 1
+	}
+
+	if minAmount > maxAmount {
+		return MessageError(ErrInvalid, "Poll %d has min_votes_amount %d, which is bigger then max_votes_amount %d", p.id, minAmount, maxAmount)
+	}
+
+	if maxPerOption < 1 {
+		return MessageError(ErrInvalid, "Poll %d has max_votes_per_option %d, expected at least 1", p.id, maxPerOption)
+	}
+
+	return nil
 }
 
 // preload loads all data in the cache, that is needed later for the vote
 // requests.
 func (p pollConfig) preload(ctx context.Context, ds *dsfetch.Fetch) error {
-	ds.Meeting_UsersEnableVoteWeight(p.meetingID).Preload()
-	ds.Meeting_UsersEnableVoteDelegations(p.meetingID).Preload()
+	return preloadPolls(ctx, ds, []pollConfig{p})
+}
 
-	meetingUserIDsList := make([][]int, len(p.groups))
-	for i, groupID := range p.groups {
+// preloadPolls preloads the data of many polls. It needs the same number of
+// datastore requests as the preload of one poll.
+func preloadPolls(ctx context.Context, ds *dsfetch.Fetch, polls []pollConfig) error {
+	var groups []int
+	for _, p := range polls {
+		ds.Meeting_UsersEnableVoteWeight(p.meetingID).Preload()
+		ds.Meeting_UsersEnableVoteDelegations(p.meetingID).Preload()
+		groups = append(groups, p.groups...)
+	}
+
+	meetingUserIDsList := make([][]int, len(groups))
+	for i, groupID := range groups {
 		ds.Group_MeetingUserIDs(groupID).Lazy(&meetingUserIDsList[i])
 	}
 
 	// First database request to get meeting/enable_vote_weight and all
 	// meeting_users from all entitled groups.
 	if err := ds.Execute(ctx); err != nil {
-		return fmt.Errorf("fetching users: %w", err)
+		return preloadError("fetching users", err)
 	}
 
 	var userIDs []*int
@@ -606,7 +2667,7 @@ func (p pollConfig) preload(ctx context.Context, ds *dsfetch.Fetch) error {
 
 	// Second database request to get all user ids and meeting_user_data.
 	if err := ds.Execute(ctx); err != nil {
-		return fmt.Errorf("preload meeting user data: %w", err)
+		return preloadError("preload meeting user data", err)
 	}
 
 	var delegatedMeetingUserIDs []int
@@ -634,7 +2695,7 @@ func (p pollConfig) preload(ctx context.Context, ds *dsfetch.Fetch) error {
 	// Third database request to get all delegated user ids. Only fetches data
 	// if there are delegates.
 	if err := ds.Execute(ctx); err != nil {
-		return fmt.Errorf("preloading delegate user ids: %w", err)
+		return preloadError("preloading delegate user ids", err)
 	}
 
 	for _, uID := range userIDs {
@@ -649,12 +2710,31 @@ func (p pollConfig) preload(ctx context.Context, ds *dsfetch.Fetch) error {
 
 	// Thrid or forth database request to get is present_in_meeting for all users and delegates.
 	if err := ds.Execute(ctx); err != nil {
-		return fmt.Errorf("preloading user data: %w", err)
+		return preloadError("preloading user data", err)
 	}
 
 	return nil
 }
 
+// idsOfPolls returns the ids of the polls.
+func idsOfPolls(polls []pollConfig) []int {
+	ids := make([]int, len(polls))
+	for i, p := range polls {
+		ids[i] = p.id
+	}
+	return ids
+}
+
+// preloadError wraps an error from a preload phase. If the datastore did not
+// answer in time, an ErrTemporary is returned, so the client knows, that it can
+// try again later.
+func preloadError(phase string, err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return MessageError(ErrTemporary, "Datastore timeout in preload phase `%s`", phase)
+	}
+	return fmt.Errorf("%s: %w", phase, err)
+}
+
 type maybeInt struct {
 	unmarshalled bool
 	value        int
@@ -675,6 +2755,45 @@ func (m *maybeInt) Value() (int, bool) {
 type ballot struct {
 	UserID maybeInt    `json:"user_id"`
 	Value  ballotValue `json:"value"`
+
+	// Nonce is an optional random string. A second ballot with the same nonce
+	// is rejected, so a ballot can not be replayed.
+	Nonce string `json:"nonce,omitempty"`
+}
+
+// UnmarshalJSON decodes a ballot. A ballot with option ids next to the value
+// like {"value":"A","1":"Y"} is rejected, since it is not clear, what the user
+// wanted to vote.
+func (v *ballot) UnmarshalJSON(b []byte) error {
+	if err := checkAmbiguous(b, "value"); err != nil {
+		return err
+	}
+
+	type plainBallot ballot
+	return json.Unmarshal(b, (*plainBallot)(v))
+}
+
+// canonicalJSON encodes a json value with sorted keys and without whitespace.
+// Two values, that only differ in the key order or the whitespace, get the
+// same encoding.
+func canonicalJSON(raw json.RawMessage) (json.RawMessage, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("decoding value: %w", err)
+	}
+
+	// json.Marshal would escape html characters in text ballots.
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, fmt.Errorf("encoding value: %w", err)
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
 }
 
 func (v ballot) String() string {
@@ -698,6 +2817,10 @@ func validate(poll pollConfig, v ballotValue) string {
 		poll.maxVotesPerOption = 1
 	}
 
+	if poll.maxTextLength == 0 {
+		poll.maxTextLength = defaultMaxTextLength
+	}
+
 	allowedOptions := make(map[int]bool, len(poll.options))
 	for _, o := range poll.options {
 		allowedOptions[o] = true
@@ -775,20 +2898,125 @@ func validate(poll pollConfig, v ballotValue) string {
 			return fmt.Sprintf("Your vote has a wrong format")
 		}
 
+	case "TEXT":
+		if v.Type() != ballotValueText {
+			return fmt.Sprintf("Your vote has a wrong format")
+		}
+
+		if strings.TrimSpace(v.text) == "" {
+			return fmt.Sprintf("Your vote can not be empty")
+		}
+
+		if len(v.text) > poll.maxTextLength {
+			return fmt.Sprintf("Your vote can not be longer then %d bytes", poll.maxTextLength)
+		}
+		return voteIsValid
+
 	default:
 		return fmt.Sprintf("Your vote has a wrong format")
 	}
 }
 
+// foreignOptionsPoll returns the id of another poll, if all options of the
+// ballot belong to this other poll and none to the given poll. This happens,
+// when a client sends a ballot to the wrong poll. In any other case, 0 is
+// returned.
+func foreignOptionsPoll(ctx context.Context, ds *dsfetch.Fetch, poll pollConfig, v ballotValue) int {
+	optionIDs := v.optionIDs()
+	if len(optionIDs) == 0 {
+		return 0
+	}
+
+	for _, optionID := range optionIDs {
+		for _, pollOption := range poll.options {
+			if optionID == pollOption {
+				return 0
+			}
+		}
+	}
+
+	pollIDs := make([]int, len(optionIDs))
+	for i, optionID := range optionIDs {
+		ds.Option_PollID(optionID).Lazy(&pollIDs[i])
+	}
+
+	if err := ds.Execute(ctx); err != nil {
+		log.Debug("Fetching poll ids of foreign options: %v", err)
+		return 0
+	}
+
+	otherPoll := pollIDs[0]
+	for _, pollID := range pollIDs {
+		if pollID != otherPoll {
+			return 0
+		}
+	}
+
+	if otherPoll == poll.id {
+		return 0
+	}
+	return otherPoll
+}
+
 // voteData is the data a user sends as his vote.
 type ballotValue struct {
 	str          string
 	optionAmount map[int]int
 	optionYNA    map[int]string
 
+	// text is the answer on a TEXT poll. It is send as {"text": "..."} to
+	// distinguish it from a global answer like "Y".
+	text   string
+	isText bool
+
 	original json.RawMessage
 }
 
+// globalValue is the saved value of a global answer with WithNormalizeGlobal.
+type globalValue struct {
+	Global string `json:"global"`
+	Weight string `json:"weight"`
+}
+
+// storedValue is the value of a saved vote object. In addition to the values
+// of a ballot, it understands the globalValue.
+//
+// It must only be used for saved vote objects. A ballot from a client is
+// decoded as ballotValue, so a client can not send its own weight.
+type storedValue struct {
+	ballotValue
+}
+
+func (v *storedValue) UnmarshalJSON(b []byte) error {
+	var global struct {
+		Global *string `json:"global"`
+	}
+	if err := json.Unmarshal(b, &global); err == nil && global.Global != nil {
+		// voteData is a normalized global answer
+		if err := checkAmbiguous(b, "global"); err != nil {
+			return err
+		}
+
+		v.original = b
+		v.str = *global.Global
+		return nil
+	}
+
+	return v.ballotValue.UnmarshalJSON(b)
+}
+
+// optionIDs returns the option ids of a ballot, that votes on options.
+func (v ballotValue) optionIDs() []int {
+	var ids []int
+	for id := range v.optionAmount {
+		ids = append(ids, id)
+	}
+	for id := range v.optionYNA {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
 func (v ballotValue) MarshalJSON() ([]byte, error) {
 	return v.original, nil
 }
@@ -796,11 +3024,25 @@ func (v ballotValue) MarshalJSON() ([]byte, error) {
 func (v *ballotValue) UnmarshalJSON(b []byte) error {
 	v.original = b
 
+	if err := checkAmbiguous(b, "value", "text"); err != nil {
+		return err
+	}
+
 	if err := json.Unmarshal(b, &v.str); err == nil {
 		// voteData is a string
 		return nil
 	}
 
+	var text struct {
+		Text *string `json:"text"`
+	}
+	if err := json.Unmarshal(b, &text); err == nil && text.Text != nil {
+		// voteData is a text answer
+		v.text = *text.Text
+		v.isText = true
+		return nil
+	}
+
 	if err := json.Unmarshal(b, &v.optionAmount); err == nil {
 		// voteData is option_id to amount
 		return nil
@@ -815,14 +3057,48 @@ func (v *ballotValue) UnmarshalJSON(b []byte) error {
 	return fmt.Errorf("unknown vote value: `%s`", b)
 }
 
+// checkAmbiguous returns an error, if b is a json object, that contains one of
+// the reserved keys and also option ids as keys.
+func checkAmbiguous(b []byte, reserved ...string) error {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(b, &object); err != nil {
+		// Not an object. The caller has to handle it.
+		return nil
+	}
+
+	var reservedKey string
+	for _, key := range reserved {
+		if _, ok := object[key]; ok {
+			reservedKey = key
+			break
+		}
+	}
+
+	if reservedKey == "" {
+		return nil
+	}
+
+	for key := range object {
+		if _, err := strconv.Atoi(key); err == nil {
+			return fmt.Errorf("ambiguous ballot: key `%s` can not be used together with the option %s", reservedKey, key)
+		}
+	}
+	return nil
+}
+
 const (
 	ballotValueUnknown = iota
 	ballotValueString
 	ballotValueOptionAmount
 	ballotValueOptionString
+	ballotValueText
 )
 
 func (v *ballotValue) Type() int {
+	if v.isText {
+		return ballotValueText
+	}
+
 	if v.str != "" {
 		return ballotValueString
 	}
@@ -0,0 +1,20 @@
+package vote
+
+import "testing"
+
+func TestAuditLogBounded(t *testing.T) {
+	var a auditLog
+
+	a.record(1, AuditStart)
+	for i := 0; i < maxAuditEvents; i++ {
+		a.record(2, AuditStart)
+	}
+
+	if got := len(a.events); got != maxAuditEvents {
+		t.Errorf("Log has %d events, expected %d", got, maxAuditEvents)
+	}
+
+	if events := a.get(1); len(events) != 0 {
+		t.Errorf("The oldest event was not removed: %v", events)
+	}
+}
@@ -0,0 +1,224 @@
+package vote
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/openslides-autoupdate-service/pkg/datastore/dsmock"
+	"github.com/OpenSlides/openslides-vote-service/backend/memory"
+)
+
+// fakeClock is a clock, that only moves when Advance is called.
+type fakeClock struct {
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	at        time.Time
+	f         func()
+	cancelled bool
+	fired     bool
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) func() bool {
+	timer := &fakeTimer{at: c.now.Add(d), f: f}
+	c.timers = append(c.timers, timer)
+	return func() bool {
+		active := !timer.cancelled && !timer.fired
+		timer.cancelled = true
+		return active
+	}
+}
+
+// Advance moves the clock and calls all timers, that are due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+	for _, timer := range c.timers {
+		if !timer.cancelled && !timer.fired && !timer.at.After(c.now) {
+			timer.fired = true
+			timer.f()
+		}
+	}
+}
+
+func TestStopAt(t *testing.T) {
+	ctx := context.Background()
+
+	newVote := func(t *testing.T) (*Vote, *memory.Backend, *fakeClock) {
+		t.Helper()
+
+		backend := memory.New()
+		ds := dsmock.NewFlow(dsmock.YAMLData(`
+		poll/1:
+			meeting_id: 1
+			backend: fast
+			type: pseudoanonymous
+			pollmethod: Y
+			entitled_group_ids: []
+
+		meeting/1/users_enable_vote_weight: false
+		`))
+
+		v, _, err := New(ctx, backend, backend, ds, true)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+
+		clock := &fakeClock{now: time.Unix(1000, 0)}
+		v.now = clock.Now
+		v.afterFunc = clock.AfterFunc
+
+		if err := v.Start(ctx, 1); err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+
+		return v, backend, clock
+	}
+
+	t.Run("stops at deadline", func(t *testing.T) {
+		v, backend, clock := newVote(t)
+		if err := v.StopAt(ctx, 1, time.Unix(1060, 0)); err != nil {
+			t.Fatalf("StopAt: %v", err)
+		}
+
+		clock.Advance(59 * time.Second)
+		if _, stopped := backend.IsStopped(1); stopped {
+			t.Fatalf("Poll was stopped before the deadline")
+		}
+
+		clock.Advance(time.Second)
+		if _, stopped := backend.IsStopped(1); !stopped {
+			t.Errorf("Poll was not stopped at the deadline")
+		}
+	})
+
+	t.Run("replaced deadline", func(t *testing.T) {
+		v, backend, clock := newVote(t)
+		if err := v.StopAt(ctx, 1, time.Unix(1060, 0)); err != nil {
+			t.Fatalf("StopAt: %v", err)
+		}
+		if err := v.StopAt(ctx, 1, time.Unix(1120, 0)); err != nil {
+			t.Fatalf("StopAt: %v", err)
+		}
+
+		clock.Advance(time.Minute)
+		if _, stopped := backend.IsStopped(1); stopped {
+			t.Errorf("Poll was stopped at the replaced deadline")
+		}
+	})
+
+	t.Run("cancelled by manual stop", func(t *testing.T) {
+		v, _, clock := newVote(t)
+		if err := v.StopAt(ctx, 1, time.Unix(1060, 0)); err != nil {
+			t.Fatalf("StopAt: %v", err)
+		}
+
+		if _, err := v.Stop(ctx, 1); err != nil {
+			t.Fatalf("Stop: %v", err)
+		}
+
+		if !clock.timers[0].cancelled {
+			t.Errorf("Deadline was not cancelled by Stop")
+		}
+	})
+
+	t.Run("cancelled by clear", func(t *testing.T) {
+		v, _, clock := newVote(t)
+		if err := v.StopAt(ctx, 1, time.Unix(1060, 0)); err != nil {
+			t.Fatalf("StopAt: %v", err)
+		}
+
+		if err := v.Clear(ctx, 1); err != nil {
+			t.Fatalf("Clear: %v", err)
+		}
+
+		if !clock.timers[0].cancelled {
+			t.Errorf("Deadline was not cancelled by Clear")
+		}
+	})
+
+	t.Run("survives restart", func(t *testing.T) {
+		v, backend, _ := newVote(t)
+		if err := v.StopAt(ctx, 1, time.Unix(1060, 0)); err != nil {
+			t.Fatalf("StopAt: %v", err)
+		}
+
+		clock := &fakeClock{now: time.Unix(1000, 0)}
+		useClock := func(v *Vote) {
+			v.now = clock.Now
+			v.afterFunc = clock.AfterFunc
+		}
+
+		ds := dsmock.NewFlow(dsmock.YAMLData(`
+		poll/1:
+			meeting_id: 1
+			backend: fast
+			type: pseudoanonymous
+			pollmethod: Y
+			entitled_group_ids: []
+
+		meeting/1/users_enable_vote_weight: false
+		`))
+
+		if _, _, err := New(ctx, backend, backend, ds, true, useClock); err != nil {
+			t.Fatalf("New after restart: %v", err)
+		}
+
+		clock.Advance(59 * time.Second)
+		if _, stopped := backend.IsStopped(1); stopped {
+			t.Fatalf("Poll was stopped before the deadline")
+		}
+
+		clock.Advance(time.Second)
+		if _, stopped := backend.IsStopped(1); !stopped {
+			t.Errorf("Poll was not stopped at the deadline after a restart")
+		}
+	})
+
+	t.Run("removed deadline after restart", func(t *testing.T) {
+		v, backend, _ := newVote(t)
+		if err := v.StopAt(ctx, 1, time.Unix(1060, 0)); err != nil {
+			t.Fatalf("StopAt: %v", err)
+		}
+
+		if err := v.StopAt(ctx, 1, time.Time{}); err != nil {
+			t.Fatalf("StopAt with zero time: %v", err)
+		}
+
+		clock := &fakeClock{now: time.Unix(1000, 0)}
+		useClock := func(v *Vote) {
+			v.now = clock.Now
+			v.afterFunc = clock.AfterFunc
+		}
+
+		if _, _, err := New(ctx, backend, backend, dsmock.NewFlow(nil), true, useClock); err != nil {
+			t.Fatalf("New after restart: %v", err)
+		}
+
+		if len(clock.timers) != 0 {
+			t.Errorf("Removed deadline was restored")
+		}
+	})
+
+	t.Run("survives refresh", func(t *testing.T) {
+		v, backend, clock := newVote(t)
+		if err := v.StopAt(ctx, 1, time.Unix(1060, 0)); err != nil {
+			t.Fatalf("StopAt: %v", err)
+		}
+
+		if err := v.RefreshVoted(ctx); err != nil {
+			t.Fatalf("RefreshVoted: %v", err)
+		}
+
+		clock.Advance(time.Minute)
+		if _, stopped := backend.IsStopped(1); !stopped {
+			t.Errorf("Poll was not stopped at the deadline")
+		}
+	})
+}
@@ -0,0 +1,34 @@
+package vote
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyCacheExpires(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	cache := idempotencyCache{ttl: time.Minute}
+
+	_, found, release, err := cache.reserve(ctx, clock.Now, 1, 1, "key")
+	if err != nil || found {
+		t.Fatalf("First reserve returned (%t, %v), expected (false, nil)", found, err)
+	}
+	release(VoteResult{VoteUserID: 1}, true)
+
+	clock.Advance(59 * time.Second)
+	result, found, _, err := cache.reserve(ctx, clock.Now, 1, 1, "key")
+	if err != nil || !found || result.VoteUserID != 1 {
+		t.Fatalf("Reserve before the ttl returned (%v, %t, %v), expected the first result", result, found, err)
+	}
+
+	clock.Advance(2 * time.Second)
+	if _, found, _, _ := cache.reserve(ctx, clock.Now, 2, 1, "other"); found {
+		t.Fatalf("Reserve of another key returned a result")
+	}
+
+	if _, ok := cache.entries[idempotencyID{1, 1, "key"}]; ok {
+		t.Errorf("Expired entry was not removed")
+	}
+}
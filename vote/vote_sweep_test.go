@@ -0,0 +1,128 @@
+package vote
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/openslides-autoupdate-service/pkg/datastore/dsmock"
+	"github.com/OpenSlides/openslides-vote-service/backend/memory"
+)
+
+func TestSweepStopped(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	poll:
+		1:
+			meeting_id: 1
+			backend: fast
+			type: pseudoanonymous
+			pollmethod: Y
+			state: finished
+		2:
+			meeting_id: 1
+			backend: fast
+			type: pseudoanonymous
+			pollmethod: Y
+			state: finished
+		3:
+			meeting_id: 1
+			backend: fast
+			type: pseudoanonymous
+			pollmethod: Y
+			state: started
+	`))
+
+	v, _, err := New(ctx, backend, backend, ds, true, WithStoppedRetention(time.Minute))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	now := time.Unix(10000, 0)
+	for _, pollID := range []int{1, 2, 3} {
+		// Poll 1 and 3 were stopped a long time ago. Poll 3 was reopened in
+		// the datastore.
+		v.now = func() time.Time { return now.Add(-time.Hour) }
+		if pollID == 2 {
+			v.now = func() time.Time { return now }
+		}
+
+		backend.Start(ctx, pollID)
+		backend.Vote(ctx, pollID, 5, []byte("vote"))
+		if _, err := v.Stop(ctx, pollID); err != nil {
+			t.Fatalf("Stop poll %d: %v", pollID, err)
+		}
+	}
+
+	// The stop times are read from the backend, so they also work after a
+	// restart.
+	v, _, err = New(ctx, backend, backend, ds, true, WithStoppedRetention(time.Minute))
+	if err != nil {
+		t.Fatalf("New after restart: %v", err)
+	}
+
+	if err := v.sweepStopped(ctx, now); err != nil {
+		t.Fatalf("sweepStopped: %v", err)
+	}
+
+	var errDoesNotExist interface{ DoesNotExist() }
+	if _, _, err := backend.VotesSince(ctx, 1, 0); !errors.As(err, &errDoesNotExist) {
+		t.Errorf("Poll 1 was not cleared, VotesSince returned: %v", err)
+	}
+
+	for _, pollID := range []int{2, 3} {
+		if _, _, err := backend.VotesSince(ctx, pollID, 0); err != nil {
+			t.Errorf("Poll %d was cleared, VotesSince returned: %v", pollID, err)
+		}
+	}
+
+	if err := v.sweepStopped(ctx, now.Add(2*time.Minute)); err != nil {
+		t.Fatalf("second sweepStopped: %v", err)
+	}
+
+	if _, _, err := backend.VotesSince(ctx, 2, 0); !errors.As(err, &errDoesNotExist) {
+		t.Errorf("Poll 2 was not cleared after the retention, VotesSince returned: %v", err)
+	}
+}
+
+func TestSweepStoppedByOtherInstance(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	poll/1:
+		meeting_id: 1
+		backend: fast
+		type: pseudoanonymous
+		pollmethod: Y
+		state: finished
+	`))
+
+	v, _, err := New(ctx, backend, backend, ds, false, WithStoppedRetention(time.Minute))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	// The poll is stopped by another instance.
+	backend.Start(ctx, 1)
+	backend.Stop(ctx, 1)
+
+	now := time.Now()
+	if err := v.sweepStopped(ctx, now); err != nil {
+		t.Fatalf("sweepStopped: %v", err)
+	}
+
+	if _, _, err := backend.VotesSince(ctx, 1, 0); err != nil {
+		t.Fatalf("Poll was cleared on the first sweep, VotesSince returned: %v", err)
+	}
+
+	if err := v.sweepStopped(ctx, now.Add(time.Hour)); err != nil {
+		t.Fatalf("second sweepStopped: %v", err)
+	}
+
+	var errDoesNotExist interface{ DoesNotExist() }
+	if _, _, err := backend.VotesSince(ctx, 1, 0); !errors.As(err, &errDoesNotExist) {
+		t.Errorf("Poll was not cleared, VotesSince returned: %v", err)
+	}
+}
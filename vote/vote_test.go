@@ -1,14 +1,22 @@
 package vote_test
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/OpenSlides/openslides-autoupdate-service/pkg/datastore/cache"
+	"github.com/OpenSlides/openslides-autoupdate-service/pkg/datastore/dskey"
 	"github.com/OpenSlides/openslides-autoupdate-service/pkg/datastore/dsmock"
 	"github.com/OpenSlides/openslides-vote-service/backend/memory"
 	"github.com/OpenSlides/openslides-vote-service/vote"
@@ -111,6 +119,57 @@ func TestVoteStart(t *testing.T) {
 		}
 	})
 
+	t.Run("Min amount bigger then max amount", func(t *testing.T) {
+		backend := memory.New()
+		ds := &StubGetter{data: dsmock.YAMLData(`
+		poll:
+			1:
+				meeting_id: 5
+				type: named
+				state: started
+				backend: fast
+				pollmethod: Y
+				min_votes_amount: 3
+				max_votes_amount: 2
+
+		meeting/5/id: 5
+		`)}
+		v, _, _ := vote.New(ctx, backend, backend, ds, true)
+
+		err := v.Start(ctx, 1)
+
+		if !errors.Is(err, vote.ErrInvalid) {
+			t.Errorf("Start returned %v, expected ErrInvalid", err)
+		}
+
+		if _, _, err := backend.VotesSince(ctx, 1, 0); err == nil {
+			t.Errorf("Poll was started in the backend")
+		}
+	})
+
+	t.Run("Zero max votes per option", func(t *testing.T) {
+		backend := memory.New()
+		ds := &StubGetter{data: dsmock.YAMLData(`
+		poll:
+			1:
+				meeting_id: 5
+				type: named
+				state: started
+				backend: fast
+				pollmethod: Y
+				min_votes_amount: 1
+				max_votes_amount: 2
+				max_votes_per_option: 0
+
+		meeting/5/id: 5
+		`)}
+		v, _, _ := vote.New(ctx, backend, backend, ds, true)
+
+		if err := v.Start(ctx, 1); err != nil {
+			t.Errorf("Start returned unexpected error: %v", err)
+		}
+	})
+
 	t.Run("Start an anolog poll", func(t *testing.T) {
 		backend := memory.New()
 		ds := &StubGetter{data: dsmock.YAMLData(`
@@ -212,6 +271,68 @@ func TestVoteStartDSError(t *testing.T) {
 	}
 }
 
+func TestVoteStartPreloadRetry(t *testing.T) {
+	ctx := context.Background()
+	data := dsmock.YAMLData(`
+	poll/1:
+		meeting_id: 5
+		entitled_group_ids: [30]
+		backend: fast
+		type: pseudoanonymous
+		pollmethod: Y
+
+	meeting/5/id: 5
+	group/30/meeting_user_ids: []
+	`)
+
+	t.Run("transient error", func(t *testing.T) {
+		backend := memory.New()
+		ds := &flakyGetter{StubGetter: StubGetter{data: data}, failures: 1}
+		v, _, _ := vote.New(ctx, backend, backend, ds, true, vote.WithPreloadRetry(2, time.Millisecond))
+
+		if err := v.Start(ctx, 1); err != nil {
+			t.Fatalf("Start returned unexpected error: %v", err)
+		}
+
+		if err := backend.Vote(ctx, 1, 1, []byte("something")); err != nil {
+			t.Errorf("Vote after start returned unexpected error: %v", err)
+		}
+	})
+
+	t.Run("more errors then retries", func(t *testing.T) {
+		backend := memory.New()
+		ds := &flakyGetter{StubGetter: StubGetter{data: data}, failures: 3}
+		v, _, _ := vote.New(ctx, backend, backend, ds, true, vote.WithPreloadRetry(2, time.Millisecond))
+
+		if err := v.Start(ctx, 1); err == nil {
+			t.Fatalf("Start returned no error")
+		}
+
+		if ds.failures != 0 {
+			t.Errorf("Start tried %d times, expected 3", 3-ds.failures)
+		}
+	})
+
+	t.Run("not found error", func(t *testing.T) {
+		backend := memory.New()
+		ds := &flakyGetter{StubGetter: StubGetter{data: dsmock.YAMLData(`
+		poll/1:
+			meeting_id: 5
+			entitled_group_ids: [30]
+			backend: fast
+			type: pseudoanonymous
+			pollmethod: Y
+		`)}}
+		v, _, _ := vote.New(ctx, backend, backend, ds, true, vote.WithPreloadRetry(2, time.Hour))
+
+		// The retry would wait for an hour, so the test would time out, if
+		// the not found error would be retried.
+		if err := v.Start(ctx, 1); err == nil {
+			t.Fatalf("Start returned no error")
+		}
+	})
+}
+
 func TestVoteStop(t *testing.T) {
 	ctx := context.Background()
 	backend := memory.New()
@@ -300,790 +421,5250 @@ func TestVoteStop(t *testing.T) {
 	})
 }
 
-func TestVoteClear(t *testing.T) {
+func TestVoteStopMeta(t *testing.T) {
 	ctx := context.Background()
 	backend := memory.New()
-	v, _, _ := vote.New(ctx, backend, backend, &StubGetter{}, true)
 
-	if err := v.Clear(ctx, 1); err != nil {
-		t.Fatalf("Clear returned unexpected error: %v", err)
+	ds := &StubGetter{data: dsmock.YAMLData(`
+	poll:
+		1:
+			meeting_id: 1
+			backend: fast
+			type: pseudoanonymous
+			pollmethod: Y
+			sequential_number: 2
+			content_object_id: assignment/4
+		2:
+			meeting_id: 1
+			backend: fast
+			type: pseudoanonymous
+			pollmethod: Y
+	`)}
+
+	v, _, _ := vote.New(ctx, backend, backend, ds, true)
+
+	for _, tt := range []struct {
+		name   string
+		pollID int
+		expect vote.StopMeta
+	}{
+		{"With meta fields", 1, vote.StopMeta{SequentialNumber: 2, ContentObjectID: "assignment/4"}},
+		{"Without meta fields", 2, vote.StopMeta{}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := backend.Start(ctx, tt.pollID); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+
+			result, err := v.Stop(ctx, tt.pollID)
+			if err != nil {
+				t.Fatalf("Stop: %v", err)
+			}
+
+			if result.Meta != tt.expect {
+				t.Errorf("Got meta %+v, expected %+v", result.Meta, tt.expect)
+			}
+		})
 	}
 }
 
-func TestVoteClearAll(t *testing.T) {
+func TestVoteStopWithValidity(t *testing.T) {
 	ctx := context.Background()
 	backend := memory.New()
-	v, _, _ := vote.New(ctx, backend, backend, &StubGetter{}, true)
 
-	if err := v.ClearAll(ctx); err != nil {
-		t.Fatalf("ClearAll returned unexpected error: %v", err)
+	ds := &StubGetter{data: dsmock.YAMLData(`
+	poll:
+		1:
+			meeting_id: 1
+			backend: fast
+			type: named
+			pollmethod: YN
+			global_yes: true
+			option_ids: [1]
+	option:
+		1:
+			meeting_id: 1
+	`)}
+
+	v, _, _ := vote.New(ctx, backend, backend, ds, true)
+
+	if err := backend.Start(ctx, 1); err != nil {
+		t.Fatalf("Start returned an unexpected error: %v", err)
+	}
+
+	backend.Vote(ctx, 1, 1, []byte(`{"vote_user_id":1,"value":"Y","weight":"1.000000"}`))
+	// A vote with the value `A` is not allowed for the pollmethod YN.
+	backend.Vote(ctx, 1, 2, []byte(`{"vote_user_id":2,"value":{"1":"A"},"weight":"1.000000"}`))
+
+	result, err := v.StopWithValidity(ctx, 1)
+	if err != nil {
+		t.Fatalf("StopWithValidity returned unexpected error: %v", err)
+	}
+
+	if expect := []bool{true, false}; !reflect.DeepEqual(result.Valid, expect) {
+		t.Errorf("Got validity %v, expected %v", result.Valid, expect)
+	}
+
+	result, err = v.Stop(ctx, 1)
+	if err != nil {
+		t.Fatalf("Stop returned unexpected error: %v", err)
+	}
+
+	if result.Valid != nil {
+		t.Errorf("Stop returned validity %v, expected none", result.Valid)
 	}
 }
 
-func TestVoteVote(t *testing.T) {
+func TestVoteResultHash(t *testing.T) {
 	ctx := context.Background()
-	backend := memory.New()
-	ds := &StubGetter{
-		data: dsmock.YAMLData(`
-		poll/1:
+
+	data := dsmock.YAMLData(`
+	poll:
+		1:
 			meeting_id: 1
-			entitled_group_ids: [1]
+			backend: fast
+			type: pseudoanonymous
 			pollmethod: Y
-			global_yes: true
+			state: stopped
+		2:
+			meeting_id: 1
 			backend: fast
 			type: pseudoanonymous
-		
-		meeting/1/id: 1
+			pollmethod: Y
+			state: started
+	`)
 
-		user/1:
-			is_present_in_meeting_ids: [1]
-			meeting_user_ids: [10]
+	resultHash := func(t *testing.T, votes ...string) string {
+		t.Helper()
 
-		meeting_user/10:
-			user_id: 1
-			group_ids: [1]
-			meeting_id: 1
-		`),
+		backend := memory.New()
+		backend.Start(ctx, 1)
+		for i, v := range votes {
+			backend.Vote(ctx, 1, i+1, []byte(v))
+		}
+		backend.Stop(ctx, 1)
+
+		v, _, _ := vote.New(ctx, backend, backend, &StubGetter{data: data}, true)
+		hash, err := v.ResultHash(ctx, 1)
+		if err != nil {
+			t.Fatalf("ResultHash: %v", err)
+		}
+		return hash
 	}
-	v, _, _ := vote.New(ctx, backend, backend, ds, true)
 
-	t.Run("Poll does not exist in DS", func(t *testing.T) {
-		err := v.Vote(ctx, 404, 1, strings.NewReader(`{"value":"Y"}`))
+	t.Run("identical data", func(t *testing.T) {
+		hash1 := resultHash(t, `"Y"`, `"N"`)
+		hash2 := resultHash(t, `"Y"`, `"N"`)
 
-		if !errors.Is(err, vote.ErrNotExists) {
-			t.Errorf("Expected ErrNotExists, got: %v", err)
+		if hash1 != hash2 {
+			t.Errorf("Got different hashes for the same data: %s and %s", hash1, hash2)
 		}
 	})
 
-	t.Run("Unknown poll", func(t *testing.T) {
-		err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`))
+	t.Run("different ballot", func(t *testing.T) {
+		hash1 := resultHash(t, `"Y"`, `"N"`)
+		hash2 := resultHash(t, `"Y"`, `"A"`)
 
-		if !errors.Is(err, vote.ErrNotExists) {
-			t.Errorf("Expected ErrNotExists, got: %v", err)
+		if hash1 == hash2 {
+			t.Errorf("Got the same hash for different data: %s", hash1)
 		}
 	})
 
-	if err := backend.Start(ctx, 1); err != nil {
-		t.Fatalf("Starting poll returned unexpected error: %v", err)
-	}
-
-	t.Run("Invalid json", func(t *testing.T) {
-		err := v.Vote(ctx, 1, 1, strings.NewReader(`{123`))
-
-		var errTyped vote.TypeError
-		if !errors.As(err, &errTyped) {
-			t.Fatalf("Vote() did not return an TypeError, got: %v", err)
-		}
+	t.Run("started poll", func(t *testing.T) {
+		backend := memory.New()
+		backend.Start(ctx, 2)
+		v, _, _ := vote.New(ctx, backend, backend, &StubGetter{data: data}, true)
 
-		if errTyped != vote.ErrInvalid {
-			t.Errorf("Got error type `%s`, expected `%s`", errTyped.Type(), vote.ErrInvalid.Type())
+		if _, err := v.ResultHash(ctx, 2); !errors.Is(err, vote.ErrNotAllowed) {
+			t.Errorf("ResultHash returned error %v, expected %v", err, vote.ErrNotAllowed)
 		}
 	})
 
-	t.Run("Invalid format", func(t *testing.T) {
-		err := v.Vote(ctx, 1, 1, strings.NewReader(`{}`))
+	t.Run("not stopped in backend", func(t *testing.T) {
+		backend := memory.New()
+		backend.Start(ctx, 1)
+		v, _, _ := vote.New(ctx, backend, backend, &StubGetter{data: data}, true)
 
-		var errTyped vote.TypeError
-		if !errors.As(err, &errTyped) {
-			t.Fatalf("Vote() did not return an TypeError, got: %v", err)
+		if _, err := v.ResultHash(ctx, 1); !errors.Is(err, vote.ErrNotAllowed) {
+			t.Errorf("ResultHash returned error %v, expected %v", err, vote.ErrNotAllowed)
 		}
 
-		if errTyped != vote.ErrInvalid {
-			t.Errorf("Got error type `%s`, expected `%s`", errTyped.Type(), vote.ErrInvalid.Type())
+		if err := backend.Vote(ctx, 1, 1, []byte(`"Y"`)); err != nil {
+			t.Errorf("Vote after ResultHash returned: %v", err)
 		}
-	})
 
-	t.Run("Valid data", func(t *testing.T) {
-		err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`))
-		if err != nil {
-			t.Fatalf("Vote returned unexpected error: %v", err)
+		if events := v.Audit(1); len(events) != 0 {
+			t.Errorf("ResultHash created audit events: %v", events)
 		}
 	})
+}
 
-	t.Run("User has voted", func(t *testing.T) {
-		err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`))
-		if err == nil {
-			t.Fatalf("Vote returned no error")
-		}
+func TestVoteTallyWithCaps(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	ds := &StubGetter{data: dsmock.YAMLData(`
+	poll:
+		1:
+			meeting_id: 1
+			backend: fast
+			type: pseudoanonymous
+			pollmethod: Y
+			option_ids: [1, 2, 3]
+			state: finished
+		2:
+			meeting_id: 1
+			backend: fast
+			type: pseudoanonymous
+			pollmethod: Y
+			state: started
+	`)}
 
-		var errTyped vote.TypeError
-		if !errors.As(err, &errTyped) {
-			t.Fatalf("Vote() did not return an TypeError, got: %v", err)
+	backend.Start(ctx, 1)
+	backend.Vote(ctx, 1, 1, []byte(`{"value":{"1":1},"weight":"3.500000"}`))
+	backend.Vote(ctx, 1, 2, []byte(`{"value":{"2":1},"weight":"1.000000"}`))
+	backend.Vote(ctx, 1, 3, []byte(`{"value":{"2":1},"weight":"1.000000"}`))
+	backend.Vote(ctx, 1, 4, []byte(`{"value":"A","weight":"1.500000"}`))
+	backend.Stop(ctx, 1)
+
+	v, _, _ := vote.New(ctx, backend, backend, ds, true)
+
+	t.Run("option exceeds cap", func(t *testing.T) {
+		got, err := v.TallyWithCaps(ctx, 1, 0.4)
+		if err != nil {
+			t.Fatalf("TallyWithCaps: %v", err)
 		}
 
-		if errTyped != vote.ErrDoubleVote {
-			t.Errorf("Got error type `%s`, expected `%s`", errTyped.Type(), vote.ErrDoubleVote.Type())
+		expect := vote.Tally{
+			TotalWeight: "7.000000",
+			Options: map[int]vote.OptionTally{
+				1: {Weight: "3.500000", Fraction: 0.5, ExceedsCap: true},
+				2: {Weight: "2.000000", Fraction: 2.0 / 7.0, ExceedsCap: false},
+				3: {Weight: "0.000000", Fraction: 0, ExceedsCap: false},
+			},
+		}
+		if !reflect.DeepEqual(got, expect) {
+			t.Errorf("Got %v, expected %v", got, expect)
 		}
 	})
 
-	t.Run("Poll is stopped", func(t *testing.T) {
-		backend.Stop(ctx, 1)
+	t.Run("invalid fraction", func(t *testing.T) {
+		if _, err := v.TallyWithCaps(ctx, 1, 1.5); !errors.Is(err, vote.ErrInvalid) {
+			t.Errorf("TallyWithCaps returned error %v, expected %v", err, vote.ErrInvalid)
+		}
+	})
 
-		err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`))
-		if err == nil {
-			t.Fatalf("Vote returned no error")
+	t.Run("started poll", func(t *testing.T) {
+		backend.Start(ctx, 2)
+		if _, err := v.TallyWithCaps(ctx, 2, 0.4); !errors.Is(err, vote.ErrNotAllowed) {
+			t.Errorf("TallyWithCaps returned error %v, expected %v", err, vote.ErrNotAllowed)
 		}
+	})
 
-		var errTyped vote.TypeError
-		if !errors.As(err, &errTyped) {
-			t.Fatalf("Vote() did not return an TypeError, got: %v", err)
+	t.Run("does not change the poll", func(t *testing.T) {
+		if _, err := v.TallyWithCaps(ctx, 1, 0.4); err != nil {
+			t.Fatalf("TallyWithCaps: %v", err)
 		}
 
-		if errTyped != vote.ErrStopped {
-			t.Errorf("Got error type `%s`, expected `%s`", errTyped.Type(), vote.ErrStopped.Type())
+		if events := v.Audit(1); len(events) != 0 {
+			t.Errorf("TallyWithCaps created audit events: %v", events)
 		}
 	})
 }
 
-func TestVoteNoRequests(t *testing.T) {
-	// This tests makes sure, that a request to vote does not do any reading
-	// from the database. All values have to be in the cache from pollpreload.
+func TestVoteReopen(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
 
-	for _, tt := range []struct {
-		name              string
-		data              string
-		vote              string
-		expectVotedUserID int
-	}{
-		{
-			"normal vote",
-			`---
-			poll/1:
-				meeting_id: 50
-				entitled_group_ids: [5]
-				pollmethod: Y
-				global_yes: true
-				state: started
-				backend: fast
-				type: pseudoanonymous
+	ds := &StubGetter{data: dsmock.YAMLData(`
+	poll:
+		1:
+			meeting_id: 1
+			backend: fast
+			type: pseudoanonymous
+			pollmethod: Y
+	`)}
 
-			meeting/50/users_enable_vote_delegations: true
+	v, _, _ := vote.New(ctx, backend, backend, ds, true)
 
-			user/1:
-				is_present_in_meeting_ids: [50]
-				meeting_user_ids: [10]
-			meeting_user/10:
-				meeting_id: 50
-				group_ids: [5]
-				user_id: 1
+	t.Run("Unknown poll", func(t *testing.T) {
+		if err := v.Reopen(ctx, 1); !errors.Is(err, vote.ErrNotExists) {
+			t.Errorf("Reopen an unknown poll has to return an ErrNotExists, got: %v", err)
+		}
+	})
 
-			group/5/meeting_user_ids: [10]
-			`,
-			`{"value":"Y"}`,
-			1,
-		},
-		{
-			"delegation vote",
-			`---
-			poll/1:
-				meeting_id: 50
-				entitled_group_ids: [5]
-				pollmethod: Y
-				global_yes: true
-				state: started
-				backend: fast
-				type: pseudoanonymous
+	t.Run("Stopped poll", func(t *testing.T) {
+		backend.Start(ctx, 1)
+		backend.Vote(ctx, 1, 1, []byte(`"polldata1"`))
+		if _, err := v.Stop(ctx, 1); err != nil {
+			t.Fatalf("Stop returned unexpected error: %v", err)
+		}
 
-			meeting/50/users_enable_vote_delegations: true
+		if err := v.Reopen(ctx, 1); err != nil {
+			t.Fatalf("Reopen returned unexpected error: %v", err)
+		}
 
-			user:
-				1:
-					is_present_in_meeting_ids: [50]
-					meeting_user_ids: [10]
-				2:
-					meeting_user_ids: [20]
+		if err := backend.Vote(ctx, 1, 2, []byte(`"polldata2"`)); err != nil {
+			t.Errorf("Vote after reopen returned unexpected error: %v", err)
+		}
 
-			meeting_user:
-				10:
-					user_id: 1
-					vote_delegations_from_ids: [20]
-					meeting_id: 50
-				20:
-					meeting_id: 50
-					vote_delegated_to_id: 10
-					group_ids: [5]
-					user_id: 2
+		result, err := v.Stop(ctx, 1)
+		if err != nil {
+			t.Fatalf("Stop returned unexpected error: %v", err)
+		}
 
-			group/5/meeting_user_ids: [20]
-			`,
-			`{"user_id":2,"value":"Y"}`,
-			2,
-		},
-		{
-			"vote weight enabled",
-			`---
-			poll/1:
-				meeting_id: 50
-				entitled_group_ids: [5]
-				pollmethod: Y
-				global_yes: true
-				state: started
-				backend: fast
-				type: pseudoanonymous
+		if !reflect.DeepEqual(result.UserIDs, []int{1, 2}) {
+			t.Errorf("Got users %v, expected [1 2]", result.UserIDs)
+		}
+	})
+}
 
-			meeting/50:
-				users_enable_vote_weight: true
-				users_enable_vote_delegations: true
+func TestVoteClear(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	v, _, _ := vote.New(ctx, backend, backend, &StubGetter{}, true)
 
-			user/1:
-				is_present_in_meeting_ids: [50]
-				meeting_user_ids: [10]
+	if err := v.Clear(ctx, 1); err != nil {
+		t.Fatalf("Clear returned unexpected error: %v", err)
+	}
+}
 
-			meeting_user:
-				10:
-					group_ids: [5]
-					user_id: 1
-					meeting_id: 50
+func TestVoteClearAll(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	v, _, _ := vote.New(ctx, backend, backend, &StubGetter{}, true)
 
-			group/5/meeting_user_ids: [10]
-			`,
-			`{"value":"Y"}`,
-			1,
-		},
-		{
-			"vote weight enabled and delegated",
-			`---
-			poll/1:
-				meeting_id: 50
-				entitled_group_ids: [5]
-				pollmethod: Y
-				global_yes: true
-				state: started
-				backend: fast
-				type: pseudoanonymous
-			
-			meeting/50:
-				users_enable_vote_weight: true
-				users_enable_vote_delegations: true
+	if _, err := v.ClearAll(ctx); err != nil {
+		t.Fatalf("ClearAll returned unexpected error: %v", err)
+	}
+}
 
-			user:
-				1:
-					is_present_in_meeting_ids: [50]
-					meeting_user_ids: [10]
-				2:
-					meeting_user_ids: [20]
+// invalidateFlow is a flow, that can not be reset, but can invalidate single
+// keys.
+type invalidateFlow struct {
+	*dsmock.Flow
+	invalidated []dskey.Key
+}
 
-			meeting_user:
-				10:
-					meeting_id: 50
-					user_id: 1
+func (f *invalidateFlow) Invalidate(keys ...dskey.Key) {
+	f.invalidated = append(f.invalidated, keys...)
+}
 
-				20:
-					group_ids: [5]
-					meeting_id: 50
-					user_id: 2
-					vote_delegated_to_id: 10
+func TestVoteClearAllCacheReset(t *testing.T) {
+	ctx := context.Background()
+	data := dsmock.YAMLData(`
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		pollmethod: Y
+		backend: fast
+		type: pseudoanonymous
+		state: started
 
-			group/5/meeting_user_ids: [20]
-			`,
-			`{"user_id":2,"value":"Y"}`,
-			2,
-		},
+	meeting/1/users_enable_vote_weight: false
+	group/1/meeting_user_ids: [10]
+
+	meeting_user/10:
+		user_id: 1
+		meeting_id: 1
+
+	user/1/is_present_in_meeting_ids: [1]
+	`)
+
+	t.Run("flow without reset", func(t *testing.T) {
+		backend := memory.New()
+		v, _, _ := vote.New(ctx, backend, backend, &StubGetter{}, true)
+
+		cacheReset, err := v.ClearAll(ctx)
+		if err != nil {
+			t.Fatalf("ClearAll: %v", err)
+		}
+
+		if cacheReset {
+			t.Errorf("ClearAll returned cacheReset true, expected false")
+		}
+	})
+
+	t.Run("flow with reset", func(t *testing.T) {
+		backend := memory.New()
+		v, _, _ := vote.New(ctx, backend, backend, cache.New(dsmock.NewFlow(data)), true)
+
+		cacheReset, err := v.ClearAll(ctx)
+		if err != nil {
+			t.Fatalf("ClearAll: %v", err)
+		}
+
+		if !cacheReset {
+			t.Errorf("ClearAll returned cacheReset false, expected true")
+		}
+	})
+
+	t.Run("flow with invalidate", func(t *testing.T) {
+		backend := memory.New()
+		flow := &invalidateFlow{Flow: dsmock.NewFlow(data)}
+		v, _, _ := vote.New(ctx, backend, backend, flow, true)
+
+		if err := v.Start(ctx, 1); err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+
+		cacheReset, err := v.ClearAll(ctx)
+		if err != nil {
+			t.Fatalf("ClearAll: %v", err)
+		}
+
+		if cacheReset {
+			t.Errorf("ClearAll returned cacheReset true, expected false")
+		}
+
+		found := false
+		for _, key := range flow.invalidated {
+			if key.String() == "group/1/meeting_user_ids" {
+				found = true
+			}
+		}
+
+		if !found {
+			t.Errorf("Preloaded key group/1/meeting_user_ids was not invalidated. Invalidated keys: %v", flow.invalidated)
+		}
+	})
+}
+
+// failingClearBackend is a backend, that fails to clear one poll.
+type failingClearBackend struct {
+	*memory.Backend
+	pollID int
+}
+
+func (b *failingClearBackend) Clear(ctx context.Context, pollID int) error {
+	if pollID == b.pollID {
+		return errors.New("backend is broken")
+	}
+	return b.Backend.Clear(ctx, pollID)
+}
+
+func TestVoteClearMany(t *testing.T) {
+	ctx := context.Background()
+	backend := &failingClearBackend{Backend: memory.New(), pollID: 2}
+	backend.Start(ctx, 1)
+	backend.Vote(ctx, 1, 1, []byte("vote"))
+	backend.Start(ctx, 2)
+	backend.Vote(ctx, 2, 1, []byte("vote"))
+
+	v, _, _ := vote.New(ctx, backend, backend, &StubGetter{}, true)
+
+	// Poll 3 is unknown.
+	err := v.ClearMany(ctx, []int{1, 2, 3})
+
+	var errFailed vote.ClearManyError
+	if !errors.As(err, &errFailed) {
+		t.Fatalf("ClearMany returned %v, expected a ClearManyError", err)
+	}
+
+	if len(errFailed) != 1 || errFailed[2] == nil {
+		t.Errorf("Got failed polls %v, expected only poll 2", errFailed)
+	}
+
+	expect := map[int]int{2: 1}
+	if got := v.VoteCount(ctx); !reflect.DeepEqual(got, expect) {
+		t.Errorf("Got vote count %v, expected %v", got, expect)
+	}
+}
+
+func TestVoteStartMany(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	poll:
+		1:
+			meeting_id: 5
+			type: named
+			state: started
+			backend: fast
+			pollmethod: Y
+		2:
+			meeting_id: 5
+			type: analog
+			state: started
+			backend: fast
+			pollmethod: Y
+		3:
+			meeting_id: 5
+			type: named
+			state: finished
+			backend: fast
+			pollmethod: Y
+		4:
+			meeting_id: 5
+			type: pseudoanonymous
+			state: created
+			backend: fast
+			pollmethod: Y
+
+	meeting/5/id: 5
+	`))
+
+	v, _, _ := vote.New(ctx, backend, backend, ds, true)
+
+	for _, tt := range []struct {
+		name         string
+		pollIDs      []int
+		expectFailed []int
+	}{
+		{"all exist", []int{1, 2, 3, 4}, []int{2, 3}},
+		{"unknown poll", []int{1, 4, 404}, []int{404}},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
-			ctx := context.Background()
-			ds := dsmock.NewFlow(
-				dsmock.YAMLData(tt.data),
-				dsmock.NewCounter,
-			)
-			counter := ds.Middlewares()[0].(*dsmock.Counter)
-			cachedDS := cache.New(ds)
-			backend := memory.New()
-			v, _, _ := vote.New(ctx, backend, backend, cachedDS, true)
+			err := v.StartMany(ctx, tt.pollIDs)
+
+			var errFailed vote.StartManyError
+			if !errors.As(err, &errFailed) {
+				t.Fatalf("StartMany returned %v, expected a StartManyError", err)
+			}
+
+			failed := make([]int, 0, len(errFailed))
+			for pollID := range errFailed {
+				failed = append(failed, pollID)
+			}
+			sort.Ints(failed)
+
+			if !reflect.DeepEqual(failed, tt.expectFailed) {
+				t.Errorf("Got failed polls %v, expected %v", failed, tt.expectFailed)
+			}
+
+			for _, pollID := range []int{1, 4} {
+				if exists, _ := backend.IsStopped(pollID); !exists {
+					t.Errorf("Poll %d was not started", pollID)
+				}
+			}
+
+			for _, pollID := range []int{2, 3} {
+				if exists, _ := backend.IsStopped(pollID); exists {
+					t.Errorf("Poll %d was started", pollID)
+				}
+			}
+		})
+	}
+}
+
+func TestVoteStartManyPreload(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	poll:
+		1:
+			meeting_id: 1
+			type: named
+			state: started
+			backend: fast
+			pollmethod: Y
+			entitled_group_ids: [1]
+		2:
+			meeting_id: 1
+			type: named
+			state: started
+			backend: fast
+			pollmethod: Y
+			entitled_group_ids: [2]
+
+	group/1/meeting_user_ids: [10]
+	group/2/meeting_user_ids: [20]
+
+	meeting_user:
+		10:
+			user_id: 1
+			meeting_id: 1
+		20:
+			user_id: 2
+			meeting_id: 1
+
+	meeting/1/id: 1
+	user/1/id: 1
+	user/2/id: 2
+	`), dsmock.NewCounter)
+	counter := ds.Middlewares()[0].(*dsmock.Counter)
+
+	v, _, _ := vote.New(ctx, backend, backend, cache.New(ds), true)
+
+	if err := v.StartMany(ctx, []int{1, 2}); err != nil {
+		t.Fatalf("StartMany: %v", err)
+	}
+
+	for _, pollID := range []int{1, 2} {
+		if exists, _ := backend.IsStopped(pollID); !exists {
+			t.Errorf("Poll %d was not started", pollID)
+		}
+	}
+
+	// One request to load the polls and three to preload the data of both
+	// polls.
+	if got := counter.Count(); got != 4 {
+		t.Errorf("StartMany needed %d datastore requests, expected 4: %v", got, counter.Requests())
+	}
+}
+
+func TestVoteVote(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	ds := &StubGetter{
+		data: dsmock.YAMLData(`
+		poll/1:
+			meeting_id: 1
+			entitled_group_ids: [1]
+			pollmethod: Y
+			global_yes: true
+			backend: fast
+			type: pseudoanonymous
+		
+		meeting/1/id: 1
+
+		user/1:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [10]
+
+		meeting_user/10:
+			user_id: 1
+			group_ids: [1]
+			meeting_id: 1
+		`),
+	}
+	v, _, _ := vote.New(ctx, backend, backend, ds, true)
+
+	t.Run("Poll does not exist in DS", func(t *testing.T) {
+		err := v.Vote(ctx, 404, 1, strings.NewReader(`{"value":"Y"}`))
+
+		if !errors.Is(err, vote.ErrNotExists) {
+			t.Errorf("Expected ErrNotExists, got: %v", err)
+		}
+	})
+
+	t.Run("Poll not started in backend", func(t *testing.T) {
+		err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`))
+
+		if !errors.Is(err, vote.ErrNotStarted) {
+			t.Errorf("Expected ErrNotStarted, got: %v", err)
+		}
+
+		if errors.Is(err, vote.ErrNotExists) {
+			t.Errorf("Got ErrNotExists for a poll, that exists in the datastore")
+		}
+	})
+
+	if err := backend.Start(ctx, 1); err != nil {
+		t.Fatalf("Starting poll returned unexpected error: %v", err)
+	}
+
+	t.Run("Invalid json", func(t *testing.T) {
+		err := v.Vote(ctx, 1, 1, strings.NewReader(`{123`))
+
+		var errTyped vote.TypeError
+		if !errors.As(err, &errTyped) {
+			t.Fatalf("Vote() did not return an TypeError, got: %v", err)
+		}
+
+		if errTyped != vote.ErrInvalid {
+			t.Errorf("Got error type `%s`, expected `%s`", errTyped.Type(), vote.ErrInvalid.Type())
+		}
+	})
+
+	t.Run("Invalid format", func(t *testing.T) {
+		err := v.Vote(ctx, 1, 1, strings.NewReader(`{}`))
+
+		var errTyped vote.TypeError
+		if !errors.As(err, &errTyped) {
+			t.Fatalf("Vote() did not return an TypeError, got: %v", err)
+		}
+
+		if errTyped != vote.ErrInvalid {
+			t.Errorf("Got error type `%s`, expected `%s`", errTyped.Type(), vote.ErrInvalid.Type())
+		}
+	})
+
+	t.Run("Ambiguous ballot", func(t *testing.T) {
+		err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"A","1":"Y"}`))
+
+		if !errors.Is(err, vote.ErrInvalid) {
+			t.Fatalf("Vote returned %v, expected ErrInvalid", err)
+		}
+
+		if !strings.Contains(err.Error(), "ambiguous ballot") {
+			t.Errorf("Got error `%v`, expected an ambiguous ballot message", err)
+		}
+	})
+
+	t.Run("Valid data", func(t *testing.T) {
+		err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`))
+		if err != nil {
+			t.Fatalf("Vote returned unexpected error: %v", err)
+		}
+	})
+
+	t.Run("User has voted", func(t *testing.T) {
+		err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`))
+		if err == nil {
+			t.Fatalf("Vote returned no error")
+		}
+
+		var errTyped vote.TypeError
+		if !errors.As(err, &errTyped) {
+			t.Fatalf("Vote() did not return an TypeError, got: %v", err)
+		}
+
+		if errTyped != vote.ErrDoubleVote {
+			t.Errorf("Got error type `%s`, expected `%s`", errTyped.Type(), vote.ErrDoubleVote.Type())
+		}
+	})
+
+	t.Run("Poll is stopped", func(t *testing.T) {
+		backend.Stop(ctx, 1)
+
+		err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`))
+		if err == nil {
+			t.Fatalf("Vote returned no error")
+		}
+
+		var errTyped vote.TypeError
+		if !errors.As(err, &errTyped) {
+			t.Fatalf("Vote() did not return an TypeError, got: %v", err)
+		}
+
+		if errTyped != vote.ErrStopped {
+			t.Errorf("Got error type `%s`, expected `%s`", errTyped.Type(), vote.ErrStopped.Type())
+		}
+	})
+}
+
+func TestVoteClientTime(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		pollType   string
+		expectTime int64
+	}{
+		{"named", "named", 1700000000},
+		{"pseudoanonymous", "pseudoanonymous", 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			backend := memory.New()
+			ds := &StubGetter{
+				data: dsmock.YAMLData(fmt.Sprintf(`
+				poll/1:
+					meeting_id: 1
+					entitled_group_ids: [1]
+					pollmethod: Y
+					global_yes: true
+					backend: fast
+					type: %s
+
+				meeting/1/id: 1
+
+				user/1:
+					is_present_in_meeting_ids: [1]
+					meeting_user_ids: [10]
+
+				meeting_user/10:
+					user_id: 1
+					group_ids: [1]
+					meeting_id: 1
+				`, tt.pollType)),
+			}
+			v, _, _ := vote.New(ctx, backend, backend, ds, true)
+
+			if err := backend.Start(ctx, 1); err != nil {
+				t.Fatalf("backend.Start: %v", err)
+			}
+
+			voteCtx := vote.WithClientTime(ctx, time.Unix(1700000000, 0))
+			if err := v.Vote(voteCtx, 1, 1, strings.NewReader(`{"value":"Y"}`)); err != nil {
+				t.Fatalf("Vote returned unexpected error: %v", err)
+			}
+
+			data, _, _ := backend.Stop(ctx, 1)
+			if len(data) != 1 {
+				t.Fatalf("got %d vote objects, expected one", len(data))
+			}
+
+			var decoded struct {
+				ClientTime int64 `json:"client_time"`
+			}
+			if err := json.Unmarshal(data[0], &decoded); err != nil {
+				t.Fatalf("decoding voteobject returned unexpected error: %v", err)
+			}
+
+			if decoded.ClientTime != tt.expectTime {
+				t.Errorf("got client time %d, expected %d", decoded.ClientTime, tt.expectTime)
+			}
+		})
+	}
+}
+
+func TestVoteNoRequests(t *testing.T) {
+	// This tests makes sure, that a request to vote does not do any reading
+	// from the database. All values have to be in the cache from pollpreload.
+
+	for _, tt := range []struct {
+		name              string
+		data              string
+		vote              string
+		expectVotedUserID int
+	}{
+		{
+			"normal vote",
+			`---
+			poll/1:
+				meeting_id: 50
+				entitled_group_ids: [5]
+				pollmethod: Y
+				global_yes: true
+				state: started
+				backend: fast
+				type: pseudoanonymous
+
+			meeting/50/users_enable_vote_delegations: true
+
+			user/1:
+				is_present_in_meeting_ids: [50]
+				meeting_user_ids: [10]
+			meeting_user/10:
+				meeting_id: 50
+				group_ids: [5]
+				user_id: 1
+
+			group/5/meeting_user_ids: [10]
+			`,
+			`{"value":"Y"}`,
+			1,
+		},
+		{
+			"delegation vote",
+			`---
+			poll/1:
+				meeting_id: 50
+				entitled_group_ids: [5]
+				pollmethod: Y
+				global_yes: true
+				state: started
+				backend: fast
+				type: pseudoanonymous
+
+			meeting/50/users_enable_vote_delegations: true
+
+			user:
+				1:
+					is_present_in_meeting_ids: [50]
+					meeting_user_ids: [10]
+				2:
+					meeting_user_ids: [20]
+
+			meeting_user:
+				10:
+					user_id: 1
+					vote_delegations_from_ids: [20]
+					meeting_id: 50
+				20:
+					meeting_id: 50
+					vote_delegated_to_id: 10
+					group_ids: [5]
+					user_id: 2
+
+			group/5/meeting_user_ids: [20]
+			`,
+			`{"user_id":2,"value":"Y"}`,
+			2,
+		},
+		{
+			"vote weight enabled",
+			`---
+			poll/1:
+				meeting_id: 50
+				entitled_group_ids: [5]
+				pollmethod: Y
+				global_yes: true
+				state: started
+				backend: fast
+				type: pseudoanonymous
+
+			meeting/50:
+				users_enable_vote_weight: true
+				users_enable_vote_delegations: true
+
+			user/1:
+				is_present_in_meeting_ids: [50]
+				meeting_user_ids: [10]
+
+			meeting_user:
+				10:
+					group_ids: [5]
+					user_id: 1
+					meeting_id: 50
+
+			group/5/meeting_user_ids: [10]
+			`,
+			`{"value":"Y"}`,
+			1,
+		},
+		{
+			"vote weight enabled and delegated",
+			`---
+			poll/1:
+				meeting_id: 50
+				entitled_group_ids: [5]
+				pollmethod: Y
+				global_yes: true
+				state: started
+				backend: fast
+				type: pseudoanonymous
+			
+			meeting/50:
+				users_enable_vote_weight: true
+				users_enable_vote_delegations: true
+
+			user:
+				1:
+					is_present_in_meeting_ids: [50]
+					meeting_user_ids: [10]
+				2:
+					meeting_user_ids: [20]
+
+			meeting_user:
+				10:
+					meeting_id: 50
+					user_id: 1
+
+				20:
+					group_ids: [5]
+					meeting_id: 50
+					user_id: 2
+					vote_delegated_to_id: 10
+
+			group/5/meeting_user_ids: [20]
+			`,
+			`{"user_id":2,"value":"Y"}`,
+			2,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			ds := dsmock.NewFlow(
+				dsmock.YAMLData(tt.data),
+				dsmock.NewCounter,
+			)
+			counter := ds.Middlewares()[0].(*dsmock.Counter)
+			cachedDS := cache.New(ds)
+			backend := memory.New()
+			v, _, _ := vote.New(ctx, backend, backend, cachedDS, true)
+
+			if err := v.Start(ctx, 1); err != nil {
+				t.Fatalf("Can not start poll: %v", err)
+			}
+
+			counter.Reset()
+
+			if err := v.Vote(ctx, 1, 1, strings.NewReader(tt.vote)); err != nil {
+				t.Errorf("Vote returned unexpected error: %v", err)
+			}
+
+			if counter.Count() != 0 {
+				t.Errorf("Vote send %d requests to the datastore: %v", counter.Count(), counter.Requests())
+			}
+
+			backend.AssertUserHasVoted(t, 1, tt.expectVotedUserID)
+		})
+	}
+}
+
+func TestVoteDelegationAndGroup(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		data string
+		vote string
+
+		expectVotedUserID int
+	}{
+		{
+			"Not delegated",
+			`
+			poll/1:
+				meeting_id: 1
+				entitled_group_ids: [1]
+				pollmethod: Y
+				global_yes: true
+				backend: fast
+				type: pseudoanonymous
+
+			meeting/1/users_enable_vote_delegations: true
+
+			user/1:
+				is_present_in_meeting_ids: [1]
+				meeting_user_ids: [10]
+
+			meeting_user/10:
+				group_ids: [1]
+				meeting_id: 1
+			`,
+			`{"value":"Y"}`,
+
+			1,
+		},
+
+		{
+			"Not delegated not present",
+			`
+			poll/1:
+				meeting_id: 1
+				entitled_group_ids: [1]
+				pollmethod: Y
+				global_yes: true
+				backend: fast
+				type: pseudoanonymous
+
+			meeting/1/users_enable_vote_delegations: true
+
+			user/1:
+				meeting_user_ids: [10]
+
+			meeting_user/10:
+				group_ids: [1]
+				meeting_id: 1
+			`,
+			`{"value":"Y"}`,
+
+			0,
+		},
+
+		{
+			"Not delegated not in group",
+			`
+			poll/1:
+				meeting_id: 1
+				entitled_group_ids: [1]
+				pollmethod: Y
+				global_yes: true
+				backend: fast
+				type: pseudoanonymous
+
+			meeting/1/users_enable_vote_delegations: true
+
+			user/1:
+				is_present_in_meeting_ids: [1]
+				meeting_user_ids: [10]
+
+			meeting_user/10:
+				group_ids: []
+				meeting_id: 1
+			`,
+			`{"value":"Y"}`,
+
+			0,
+		},
+
+		{
+			"Vote for self",
+			`
+			poll/1:
+				meeting_id: 1
+				entitled_group_ids: [1]
+				pollmethod: Y
+				global_yes: true
+				backend: fast
+				type: pseudoanonymous
+
+			meeting/1/users_enable_vote_delegations: true
+
+			user/1:
+				is_present_in_meeting_ids: [1]
+				meeting_user_ids: [10]
+
+			meeting_user/10:
+				group_ids: [1]
+				meeting_id: 1
+			`,
+			`{"user_id": 1, "value":"Y"}`,
+
+			1,
+		},
+
+		{
+			"Vote for self not activated",
+			`
+			poll/1:
+				meeting_id: 1
+				entitled_group_ids: [1]
+				pollmethod: Y
+				global_yes: true
+				backend: fast
+				type: pseudoanonymous
+
+			meeting/1/users_enable_vote_delegations: false
+
+			user/1:
+				is_present_in_meeting_ids: [1]
+				meeting_user_ids: [10]
+
+			meeting_user/10:
+				group_ids: [1]
+				meeting_id: 1
+			`,
+			`{"user_id": 1, "value":"Y"}`,
+
+			1,
+		},
+
+		{
+			"Vote for anonymous",
+			`
+			poll/1:
+				meeting_id: 1
+				entitled_group_ids: [1]
+				pollmethod: Y
+				global_yes: true
+				backend: fast
+				type: pseudoanonymous
+			
+			meeting/1/users_enable_vote_delegations: true
+
+			user/1:
+				is_present_in_meeting_ids: [1]
+				meeting_user_ids: [10]
+
+			meeting_user/10:
+				group_ids: [1]
+				meeting_id: 1	
+			`,
+			`{"user_id": 0, "value":"Y"}`,
+
+			0,
+		},
+
+		{
+			"Vote for other without delegation",
+			`
+			poll/1:
+				meeting_id: 1
+				entitled_group_ids: [1]
+				pollmethod: Y
+				global_yes: true
+				backend: fast
+				type: pseudoanonymous
+
+			meeting/1/users_enable_vote_delegations: true
+
+			user/1/is_present_in_meeting_ids: [1]
+			user/2/meeting_user_ids: [20]
+
+			user:
+				1:
+					is_present_in_meeting_ids: [1]
+					meeting_user_ids: [10]
+					
+				2:
+					meeting_user_ids: [20]
+
+			meeting_user:
+				10:
+					meeting_id: 1
+					user_id: 1
+				20:
+					group_ids: [1]
+					meeting_id: 1	
+			`,
+			`{"user_id": 2, "value":"Y"}`,
+
+			0,
+		},
+
+		{
+			"Vote for other with delegation",
+			`
+			poll/1:
+				meeting_id: 1
+				entitled_group_ids: [1]
+				pollmethod: Y
+				global_yes: true
+				backend: fast
+				type: pseudoanonymous
+
+			meeting/1/users_enable_vote_delegations: true
+
+			user:
+				1:
+					is_present_in_meeting_ids: [1]
+					meeting_user_ids: [10]
+					
+				2:
+					meeting_user_ids: [20]
+
+			meeting_user:
+				10:
+					meeting_id: 1
+					user_id: 1
+
+				20:
+					group_ids: [1]
+					meeting_id: 1	
+					vote_delegated_to_id: 10
+			`,
+			`{"user_id": 2, "value":"Y"}`,
+
+			2,
+		},
+
+		{
+			"Vote for other with delegation not activated",
+			`
+			poll/1:
+				meeting_id: 1
+				entitled_group_ids: [1]
+				pollmethod: Y
+				global_yes: true
+				backend: fast
+				type: pseudoanonymous
+
+			meeting/1/users_enable_vote_delegations: false
+
+			user:
+				1:
+					is_present_in_meeting_ids: [1]
+					meeting_user_ids: [10]
+					
+				2:
+					meeting_user_ids: [20]
+
+			meeting_user:
+				10:
+					meeting_id: 1
+					user_id: 1
+
+				20:
+					group_ids: [1]
+					meeting_id: 1	
+					vote_delegated_to_id: 10
+			`,
+			`{"user_id": 2, "value":"Y"}`,
+
+			0,
+		},
+
+		{
+			"Vote for other with delegation not in group",
+			`
+			poll/1:
+				meeting_id: 1
+				entitled_group_ids: [1]
+				pollmethod: Y
+				global_yes: true
+				backend: fast
+				type: pseudoanonymous
+
+			meeting/1/users_enable_vote_delegations: true
+
+			user:
+				1:
+					is_present_in_meeting_ids: [1]
+					meeting_user_ids: [10]
+					
+				2:
+					meeting_user_ids: [20]
+
+			meeting_user:
+				10:
+					meeting_id: 1
+					user_id: 1
+
+				20:
+					group_ids: []
+					meeting_id: 1	
+					vote_delegated_to_id: 10
+			`,
+			`{"user_id": 2, "value":"Y"}`,
+
+			0,
+		},
+
+		{
+			"Vote for other with self not in group",
+			`
+			poll/1:
+				meeting_id: 1
+				entitled_group_ids: [1]
+				pollmethod: Y
+				global_yes: true
+				backend: fast
+				type: pseudoanonymous
+
+			meeting/1/users_enable_vote_delegations: true
+
+			user:
+				1:
+					is_present_in_meeting_ids: [1]
+					meeting_user_ids: [10]
+					
+				2:
+					meeting_user_ids: [20]
+
+			meeting_user:
+				10:
+					meeting_id: 1
+					user_id: 1
+
+				20:
+					group_ids: [1]
+					meeting_id: 1	
+					vote_delegated_to_id: 10
+			`,
+			`{"user_id": 2, "value":"Y"}`,
+
+			2,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			backend := memory.New()
+			ds := &StubGetter{data: dsmock.YAMLData(tt.data)}
+
+			v, _, _ := vote.New(ctx, backend, backend, ds, true)
+
+			if err := backend.Start(ctx, 1); err != nil {
+				t.Fatalf("backend.Start(): %v", err)
+			}
+
+			err := v.Vote(ctx, 1, 1, strings.NewReader(tt.vote))
+
+			if tt.expectVotedUserID != 0 {
+				if err != nil {
+					t.Fatalf("Vote returned unexpected error: %v", err)
+				}
+
+				backend.AssertUserHasVoted(t, 1, tt.expectVotedUserID)
+				return
+			}
+
+			if !errors.Is(err, vote.ErrNotAllowed) {
+				t.Fatalf("Expected NotAllowedError, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestVoteDefaultGroup(t *testing.T) {
+	data := `
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		pollmethod: Y
+		global_yes: true
+		backend: fast
+		type: pseudoanonymous
+
+	meeting/1/default_group_id: 1
+
+	user/1:
+		is_present_in_meeting_ids: [1]
+		meeting_user_ids: [10]
+
+	meeting_user/10:
+		group_ids: []
+		meeting_id: 1
+	`
+
+	for _, tt := range []struct {
+		name          string
+		data          string
+		entitled      bool
+		expectAllowed bool
+	}{
+		{"disabled", data, false, false},
+		{"enabled", data, true, true},
+		{
+			"enabled default group not entitled",
+			strings.Replace(data, "default_group_id: 1", "default_group_id: 2", 1),
+			true,
+			false,
+		},
+		{
+			"enabled user with other group",
+			strings.Replace(data, "group_ids: []", "group_ids: [3]", 1),
+			true,
+			false,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			backend := memory.New()
+			ds := &StubGetter{data: dsmock.YAMLData(tt.data)}
+
+			v, _, _ := vote.New(ctx, backend, backend, ds, true, vote.WithDefaultGroupEntitlement(tt.entitled))
+
+			if err := backend.Start(ctx, 1); err != nil {
+				t.Fatalf("backend.Start(): %v", err)
+			}
+
+			err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`))
+
+			if tt.expectAllowed {
+				if err != nil {
+					t.Fatalf("Vote returned unexpected error: %v", err)
+				}
+
+				backend.AssertUserHasVoted(t, 1, 1)
+				return
+			}
+
+			if !errors.Is(err, vote.ErrNotAllowed) {
+				t.Fatalf("Expected NotAllowedError, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestVoteStartWithEntitled(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		pollmethod: Y
+		global_yes: true
+		backend: fast
+		type: pseudoanonymous
+		state: started
+
+	meeting/1/users_enable_vote_weight: false
+	group/1/meeting_user_ids: [10]
+
+	user:
+		1:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [10]
+		2:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [20]
+
+	meeting_user:
+		10:
+			user_id: 1
+			group_ids: [1]
+			meeting_id: 1
+		20:
+			user_id: 2
+			group_ids: [2]
+			meeting_id: 1
+	`))
+	v, _, _ := vote.New(ctx, backend, backend, ds, true)
+
+	if err := v.StartWithEntitled(ctx, 1, []int{2}); err != nil {
+		t.Fatalf("StartWithEntitled: %v", err)
+	}
+
+	t.Run("user in entitled group but not in the list", func(t *testing.T) {
+		err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`))
+		if !errors.Is(err, vote.ErrNotAllowed) {
+			t.Errorf("Vote returned %v, expected %v", err, vote.ErrNotAllowed)
+		}
+	})
+
+	t.Run("user in the list", func(t *testing.T) {
+		if err := v.Vote(ctx, 1, 2, strings.NewReader(`{"value":"Y"}`)); err != nil {
+			t.Errorf("Vote returned unexpected error: %v", err)
+		}
+	})
+
+	t.Run("groups after clear", func(t *testing.T) {
+		if err := v.Clear(ctx, 1); err != nil {
+			t.Fatalf("Clear: %v", err)
+		}
+
+		if err := v.Start(ctx, 1); err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+
+		if err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`)); err != nil {
+			t.Errorf("Vote returned unexpected error: %v", err)
+		}
+	})
+}
+
+func TestVoteStartWithEntitledMultiInstance(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		pollmethod: Y
+		global_yes: true
+		backend: fast
+		type: pseudoanonymous
+		state: started
+
+	meeting/1/users_enable_vote_weight: false
+	group/1/meeting_user_ids: []
+	`))
+	v, _, _ := vote.New(ctx, backend, backend, ds, false)
+
+	err := v.StartWithEntitled(ctx, 1, []int{2})
+	if !errors.Is(err, vote.ErrNotAllowed) {
+		t.Fatalf("StartWithEntitled returned %v, expected %v", err, vote.ErrNotAllowed)
+	}
+
+	if err := backend.Vote(ctx, 1, 1, []byte("vote")); err == nil {
+		t.Errorf("Poll was started in the backend")
+	}
+}
+
+func TestVoteDelegateMustBeEntitled(t *testing.T) {
+	data := `
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		pollmethod: Y
+		global_yes: true
+		backend: fast
+		type: pseudoanonymous
+
+	meeting/1/users_enable_vote_delegations: true
+
+	user/1:
+		is_present_in_meeting_ids: [1]
+		meeting_user_ids: [10]
+
+	meeting_user/10:
+		group_ids: [2]
+		meeting_id: 1
+
+	user/2:
+		meeting_user_ids: [20]
+
+	meeting_user/20:
+		group_ids: [1]
+		meeting_id: 1
+		vote_delegated_to_id: 10
+	`
+
+	for _, tt := range []struct {
+		name                   string
+		data                   string
+		delegateMustBeEntitled bool
+		expectAllowed          bool
+	}{
+		{"disabled", data, false, true},
+		{"enabled delegate not entitled", data, true, false},
+		{
+			"enabled delegate entitled",
+			strings.Replace(data, "group_ids: [2]", "group_ids: [1]", 1),
+			true,
+			true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			backend := memory.New()
+			ds := &StubGetter{data: dsmock.YAMLData(tt.data)}
+
+			v, _, _ := vote.New(ctx, backend, backend, ds, true, vote.WithDelegateMustBeEntitled(tt.delegateMustBeEntitled))
+
+			if err := backend.Start(ctx, 1); err != nil {
+				t.Fatalf("backend.Start(): %v", err)
+			}
+
+			err := v.Vote(ctx, 1, 1, strings.NewReader(`{"user_id":2,"value":"Y"}`))
+
+			if tt.expectAllowed {
+				if err != nil {
+					t.Fatalf("Vote returned unexpected error: %v", err)
+				}
+
+				backend.AssertUserHasVoted(t, 1, 2)
+				return
+			}
+
+			if !errors.Is(err, vote.ErrNotAllowed) {
+				t.Fatalf("Expected NotAllowedError, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestVoteAbsentDelegates(t *testing.T) {
+	data := `
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		pollmethod: Y
+		global_yes: true
+		backend: fast
+		type: pseudoanonymous
+
+	meeting/1/users_enable_vote_delegations: true
+
+	user/1:
+		is_present_in_meeting_ids: [1]
+		meeting_user_ids: [10]
+
+	meeting_user/10:
+		group_ids: [1]
+		meeting_id: 1
+
+	user/2:
+		meeting_user_ids: [20]
+
+	meeting_user/20:
+		group_ids: [1]
+		meeting_id: 1
+		vote_delegated_to_id: 10
+	`
+
+	for _, tt := range []struct {
+		name          string
+		data          string
+		allowAbsent   bool
+		expectAllowed bool
+	}{
+		{"allowed, principal absent", data, true, true},
+		{"not allowed, principal absent", data, false, false},
+		{
+			"not allowed, principal present",
+			strings.Replace(data, "user/2:\n", "user/2:\n\t\tis_present_in_meeting_ids: [1]\n", 1),
+			false,
+			true,
+		},
+		{
+			"allowed, delegate absent",
+			strings.Replace(data, "is_present_in_meeting_ids: [1]", "is_present_in_meeting_ids: []", 1),
+			true,
+			false,
+		},
+		{
+			"not allowed, delegate absent",
+			strings.Replace(data, "is_present_in_meeting_ids: [1]", "is_present_in_meeting_ids: []", 1),
+			false,
+			false,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			backend := memory.New()
+			ds := &StubGetter{data: dsmock.YAMLData(tt.data)}
+
+			v, _, _ := vote.New(ctx, backend, backend, ds, true, vote.WithAbsentDelegates(tt.allowAbsent))
+
+			if err := backend.Start(ctx, 1); err != nil {
+				t.Fatalf("backend.Start(): %v", err)
+			}
+
+			err := v.Vote(ctx, 1, 1, strings.NewReader(`{"user_id":2,"value":"Y"}`))
+
+			if tt.expectAllowed {
+				if err != nil {
+					t.Fatalf("Vote returned unexpected error: %v", err)
+				}
+
+				backend.AssertUserHasVoted(t, 1, 2)
+				return
+			}
+
+			if !errors.Is(err, vote.ErrNotAllowed) {
+				t.Fatalf("Expected NotAllowedError, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestVoteWeight(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		data string
+
+		expectWeight string
+	}{
+		{
+			"No weight",
+			`
+			poll/1:
+				meeting_id: 1
+				entitled_group_ids: [1]
+				pollmethod: Y
+				global_yes: true
+				backend: fast
+				type: pseudoanonymous
+
+			meeting/1/id: 1
+
+			user/1:
+				is_present_in_meeting_ids: [1]
+				meeting_user_ids: [10]
+			meeting_user/10:
+				group_ids: [1]
+				meeting_id: 1
+			`,
+			"1.000000",
+		},
+		{
+			"Weight enabled, user has no weight",
+			`
+			poll/1:
+				meeting_id: 1
+				entitled_group_ids: [1]
+				pollmethod: Y
+				global_yes: true
+				backend: fast
+				type: pseudoanonymous
+
+			meeting/1/users_enable_vote_weight: true
+
+			user/1:
+				is_present_in_meeting_ids: [1]
+				meeting_user_ids: [10]
+			meeting_user/10:
+				group_ids: [1]
+				meeting_id: 1
+			`,
+			"1.000000",
+		},
+		{
+			"Weight enabled, user has default weight",
+			`
+			poll/1:
+				meeting_id: 1
+				entitled_group_ids: [1]
+				pollmethod: Y
+				global_yes: true
+				backend: fast
+				type: pseudoanonymous
+
+			meeting/1/users_enable_vote_weight: true
+
+			user/1:
+				is_present_in_meeting_ids: [1]
+				meeting_user_ids: [10]
+				default_vote_weight: "2.000000"
+			meeting_user/10:
+				group_ids: [1]
+				meeting_id: 1
+			`,
+			"2.000000",
+		},
+		{
+			"Weight enabled, user has default weight and meeting weight",
+			`
+			poll/1:
+				meeting_id: 1
+				entitled_group_ids: [1]
+				pollmethod: Y
+				global_yes: true
+				backend: fast
+				type: pseudoanonymous
+
+			meeting/1/users_enable_vote_weight: true
+
+			user/1:
+				is_present_in_meeting_ids: [1]
+				meeting_user_ids: [10]
+				default_vote_weight: "2.000000"
+			meeting_user/10:
+				group_ids: [1]
+				meeting_id: 1
+				vote_weight: "3.000000"
+			`,
+			"3.000000",
+		},
+		{
+			"Weight enabled, user has default weight and meeting weight in other meeting",
+			`
+			poll/1:
+				meeting_id: 1
+				entitled_group_ids: [1]
+				pollmethod: Y
+				global_yes: true
+				backend: fast
+				type: pseudoanonymous
+
+			meeting/1/users_enable_vote_weight: true
+
+			user/1:
+				is_present_in_meeting_ids: [1]
+				meeting_user_ids: [10,11]
+				default_vote_weight: "2.000000"
+			meeting_user/10:
+				group_ids: [1]
+				meeting_id: 1
+			meeting_user/11:
+				group_ids: [1]
+				meeting_id: 2
+				vote_weight: "3.000000"
+			`,
+			"2.000000",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			backend := memory.New()
+			ds := &StubGetter{data: dsmock.YAMLData(tt.data)}
+			v, _, _ := vote.New(ctx, backend, backend, ds, true)
+
+			if err := backend.Start(ctx, 1); err != nil {
+				t.Fatalf("bakckend.Start: %v", err)
+			}
+
+			if err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`)); err != nil {
+				t.Fatalf("vote returned unexpected error: %v", err)
+			}
+
+			data, _, _ := backend.Stop(ctx, 1)
+
+			if len(data) != 1 {
+				t.Fatalf("got %d vote objects, expected one", len(data))
+			}
+
+			var decoded struct {
+				Weight string `json:"weight"`
+			}
+			if err := json.Unmarshal(data[0], &decoded); err != nil {
+				t.Fatalf("decoding voteobject returned unexpected error: %v", err)
+			}
+
+			if decoded.Weight != tt.expectWeight {
+				t.Errorf("got weight %q, expected %q", decoded.Weight, tt.expectWeight)
+			}
+		})
+	}
+}
+
+func TestItLikeBackend(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+
+	ds := dsmock.NewFlow(dsmock.YAMLData(`---
+	organization/1/enable_electronic_voting: true
+	meeting/1:
+		name: my meeting
+		poll_couple_countdown: true
+		poll_countdown_id: 11
+		is_active_in_organization_id: 1
+		group_ids: [1]
+		meeting_user_ids: [11]
+	
+	projector_countdown/11:
+		default_time: 60
+		running: false
+		countdown_time: 60
+		meeting_id: 1
+
+	group/1/meeting_user_ids: [11]
+
+	option:
+		1:
+			meeting_id: 1
+			poll_id: 1
+		2:
+			meeting_id: 1
+			poll_id: 1
+	
+	user/1:
+		is_present_in_meeting_ids: [1]
+		meeting_user_ids: [11]
+
+	meeting_user/11:
+		meeting_id: 1
+		user_id: 1
+		group_ids: [1]
+	
+	assignment/1:
+		title: test_assignment_tcLT59bmXrXif424Qw7K
+		open_posts: 1
+		meeting_id: 1
+	
+	poll/1:
+		content_object_id: assignment/1
+		title: test_title_04k0y4TwPLpJKaSvIGm1
+		state: started
+		meeting_id: 1
+		option_ids: [1, 2]
+		entitled_group_ids: [1]
+		votesinvalid: "0.000000"
+		votesvalid: "0.000000"
+		votescast: "0.000000"
+		backend: fast
+		pollmethod: YNA
+		type: named
+		
+	`))
+
+	v, _, _ := vote.New(ctx, backend, backend, ds, true)
+	if err := backend.Start(ctx, 1); err != nil {
+		t.Fatalf("bakckend.Start: %v", err)
+	}
+
+	if err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value": {"1": "Y"}}`)); err != nil {
+		t.Fatalf("vote returned unexpected error: %v", err)
+	}
+
+	backend.AssertUserHasVoted(t, 1, 1)
+}
+
+func TestVotedPolls(t *testing.T) {
+	ctx := context.Background()
+
+	backend := memory.New()
+	ds := dsmock.NewFlow(dsmock.YAMLData(`---
+	poll/1:
+		backend: memory
+		meeting_id: 1
+		type: pseudoanonymous
+		pollmethod: Y
+
+	user/5/id: 5
+	`))
+
+	backend.Start(ctx, 1)
+	backend.Vote(ctx, 1, 5, []byte(`"Y"`))
+
+	v, _, _ := vote.New(ctx, backend, backend, ds, true)
+
+	got, err := v.Voted(ctx, []int{1, 2}, 5)
+	if err != nil {
+		t.Fatalf("VotedPolls() returned unexected error: %v", err)
+	}
+
+	expect := map[int][]int{1: {5}, 2: nil}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("Voted() == `%v`, expected `%v`", got, expect)
+	}
+}
+
+func TestVotedPollsWithDelegation(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	ds := dsmock.NewFlow(dsmock.YAMLData(`---
+	poll/1:
+		backend: memory
+		type: named
+		meeting_id: 40
+		pollmethod: Y
+
+	user/5:
+		meeting_user_ids: [10]
+	meeting_user:
+		10:
+			meeting_id: 8
+			vote_delegations_from_ids: [11]
+		11:
+			user_id: 6
+		12:
+			user_id: 7
+		
+	`))
+
+	backend.Start(ctx, 1)
+	backend.Vote(ctx, 1, 5, []byte(`"Y"`))
+	backend.Vote(ctx, 1, 6, []byte(`"Y"`))
+	backend.Vote(ctx, 1, 7, []byte(`"Y"`))
+	v, _, _ := vote.New(ctx, backend, backend, ds, true)
+
+	got, err := v.Voted(ctx, []int{1, 2}, 5)
+	if err != nil {
+		t.Fatalf("Voted() returned unexected error: %v", err)
+	}
+
+	expect := map[int][]int{1: {5, 6}, 2: nil}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("Voted() == `%v`, expected `%v`", got, expect)
+	}
+}
+
+func TestVoteDelegationCycle(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		pollmethod: Y
+		global_yes: true
+		backend: fast
+		type: named
+
+	meeting/1/users_enable_vote_delegations: true
+
+	user:
+		1:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [10]
+		2:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [20]
+
+	meeting_user:
+		10:
+			user_id: 1
+			meeting_id: 1
+			group_ids: [1]
+			vote_delegated_to_id: 20
+			vote_delegations_from_ids: [20, 10]
+		20:
+			user_id: 2
+			meeting_id: 1
+			group_ids: [1]
+			vote_delegated_to_id: 10
+			vote_delegations_from_ids: [10]
+	`))
+	v, _, _ := vote.New(ctx, backend, backend, ds, true)
+	backend.Start(ctx, 1)
+
+	if err := v.Vote(ctx, 1, 1, strings.NewReader(`{"user_id":2,"value":"Y"}`)); err != nil {
+		t.Fatalf("Vote for the delegator returned unexpected error: %v", err)
+	}
+
+	if err := v.Vote(ctx, 1, 2, strings.NewReader(`{"value":"Y"}`)); !errors.Is(err, vote.ErrDoubleVote) {
+		t.Errorf("Vote of the delegator returned %v, expected ErrDoubleVote", err)
+	}
+
+	if err := v.Vote(ctx, 1, 1, strings.NewReader(`{"user_id":2,"value":"Y"}`)); !errors.Is(err, vote.ErrDoubleVote) {
+		t.Errorf("Second vote for the delegator returned %v, expected ErrDoubleVote", err)
+	}
+
+	voted, err := v.Voted(ctx, []int{1}, 1)
+	if err != nil {
+		t.Fatalf("Voted returned unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(voted[1], []int{2}) {
+		t.Errorf("Voted returned %v, expected [2]", voted[1])
+	}
+
+	result, err := v.Stop(ctx, 1)
+	if err != nil {
+		t.Fatalf("Stop returned unexpected error: %v", err)
+	}
+
+	if len(result.Votes) != 1 || !reflect.DeepEqual(result.UserIDs, []int{2}) {
+		t.Errorf("Got %d votes from users %v, expected one vote from user 2", len(result.Votes), result.UserIDs)
+	}
+}
+
+func TestVoteDelegationDepth(t *testing.T) {
+	ctx := context.Background()
+
+	// User 1 delegated to 2, 2 to 3 and 3 to 4. User 5 and 6 delegated to
+	// each other.
+	data := dsmock.YAMLData(`
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		pollmethod: Y
+		global_yes: true
+		backend: fast
+		type: named
+
+	meeting/1:
+		users_enable_vote_delegations: true
+		users_enable_vote_weight: false
+
+	user:
+		1:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [10]
+		2:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [20]
+		3:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [30]
+		4:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [40]
+		5:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [50]
+		6:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [60]
+		7:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [70]
+
+	meeting_user:
+		10:
+			user_id: 1
+			meeting_id: 1
+			group_ids: [1]
+			vote_delegated_to_id: 20
+		20:
+			user_id: 2
+			meeting_id: 1
+			group_ids: [1]
+			vote_delegated_to_id: 30
+		30:
+			user_id: 3
+			meeting_id: 1
+			group_ids: [1]
+			vote_delegated_to_id: 40
+		40:
+			user_id: 4
+			meeting_id: 1
+			group_ids: [1]
+		50:
+			user_id: 5
+			meeting_id: 1
+			group_ids: [1]
+			vote_delegated_to_id: 60
+		60:
+			user_id: 6
+			meeting_id: 1
+			group_ids: [1]
+			vote_delegated_to_id: 50
+		70:
+			user_id: 7
+			meeting_id: 1
+			group_ids: [1]
+	`)
+
+	for _, tt := range []struct {
+		name        string
+		depth       int
+		requestUser int
+		voteUser    int
+		expectErr   error
+	}{
+		{"direct delegation", 0, 2, 1, nil},
+		{"depth 2 with default", 0, 3, 1, vote.ErrNotAllowed},
+		{"depth 2", 2, 3, 1, nil},
+		{"depth 3 with limit 2", 2, 4, 1, vote.ErrNotAllowed},
+		{"depth 3", 3, 4, 1, nil},
+		{"cycle", 5, 7, 5, vote.ErrNotAllowed},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := memory.New()
+			backend.Start(ctx, 1)
+
+			var options []vote.Option
+			if tt.depth != 0 {
+				options = append(options, vote.WithMaxDelegationDepth(tt.depth))
+			}
+
+			v, _, _ := vote.New(ctx, backend, backend, dsmock.NewFlow(data), true, options...)
+
+			err := v.Vote(ctx, 1, tt.requestUser, strings.NewReader(fmt.Sprintf(`{"user_id":%d,"value":"Y"}`, tt.voteUser)))
+
+			if tt.expectErr == nil {
+				if err != nil {
+					t.Fatalf("Vote returned unexpected error: %v", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, tt.expectErr) {
+				t.Errorf("Vote returned error %v, expected %v", err, tt.expectErr)
+			}
+		})
+	}
+}
+
+func TestVoteWithResult(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		pollmethod: Y
+		global_yes: true
+		backend: fast
+		type: pseudoanonymous
+
+	meeting/1:
+		users_enable_vote_weight: true
+
+	user/1:
+		is_present_in_meeting_ids: [1]
+		meeting_user_ids: [10]
+
+	meeting_user/10:
+		user_id: 1
+		group_ids: [1]
+		meeting_id: 1
+		vote_weight: "2.000000"
+	`))
+	v, _, _ := vote.New(ctx, backend, backend, ds, true)
+	backend.Start(ctx, 1)
+
+	result, err := v.VoteWithResult(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`))
+	if err != nil {
+		t.Fatalf("VoteWithResult returned unexpected error: %v", err)
+	}
+
+	expect := vote.VoteResult{VoteUserID: 1, Weight: "2.000000", AlreadyVotedCount: 1}
+	if result != expect {
+		t.Errorf("Got %+v, expected %+v", result, expect)
+	}
+}
+
+func TestVoteOptionsOfOtherPoll(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	poll:
+		1:
+			meeting_id: 1
+			entitled_group_ids: [1]
+			option_ids: [1, 2]
+			pollmethod: YNA
+			backend: fast
+			type: pseudoanonymous
+		2:
+			meeting_id: 1
+			option_ids: [3, 4]
+
+	option:
+		1:
+			poll_id: 1
+		2:
+			poll_id: 1
+		3:
+			poll_id: 2
+		4:
+			poll_id: 2
+
+	user/1:
+		is_present_in_meeting_ids: [1]
+		meeting_user_ids: [10]
+
+	meeting_user/10:
+		user_id: 1
+		group_ids: [1]
+		meeting_id: 1
+	`))
+	v, _, _ := vote.New(ctx, backend, backend, ds, true)
+	backend.Start(ctx, 1)
+
+	for _, tt := range []struct {
+		name   string
+		ballot string
+		expect string
+	}{
+		{
+			"options of other poll",
+			`{"value":{"3":"Y","4":"N"}}`,
+			"The options belong to poll 2 and not to poll 1",
+		},
+		{
+			"mixed options",
+			`{"value":{"1":"Y","3":"N"}}`,
+			"Option_id 3 does not belong to the poll",
+		},
+		{
+			"unknown option",
+			`{"value":{"5":"Y"}}`,
+			"Option_id 5 does not belong to the poll",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Vote(ctx, 1, 1, strings.NewReader(tt.ballot))
+
+			if !errors.Is(err, vote.ErrInvalid) {
+				t.Fatalf("Vote returned error %v, expected %v", err, vote.ErrInvalid)
+			}
+
+			if got := err.Error(); got != tt.expect {
+				t.Errorf("Got error message `%s`, expected `%s`", got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestVoteCanonicalBallots(t *testing.T) {
+	ctx := context.Background()
+	data := dsmock.YAMLData(`
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		option_ids: [1, 2]
+		pollmethod: YN
+		backend: fast
+		type: pseudoanonymous
+
+	meeting/1/users_enable_vote_weight: false
+
+	user:
+		1:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [10]
+		2:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [20]
+
+	meeting_user:
+		10:
+			user_id: 1
+			group_ids: [1]
+			meeting_id: 1
+		20:
+			user_id: 2
+			group_ids: [1]
+			meeting_id: 1
+	`)
+
+	for _, tt := range []struct {
+		name      string
+		canonical bool
+		expectEq  bool
+	}{
+		{"disabled", false, false},
+		{"enabled", true, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := memory.New()
+			backend.Start(ctx, 1)
+			v, _, _ := vote.New(ctx, backend, backend, dsmock.NewFlow(data), true, vote.WithCanonicalBallots(tt.canonical))
+
+			if err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":{"1":"Y","2":"N"}}`)); err != nil {
+				t.Fatalf("Vote of user 1: %v", err)
+			}
+
+			if err := v.Vote(ctx, 1, 2, strings.NewReader(`{"value":{ "2" : "N",
+				"1" : "Y" }}`)); err != nil {
+				t.Fatalf("Vote of user 2: %v", err)
+			}
+
+			result, err := v.Stop(ctx, 1)
+			if err != nil {
+				t.Fatalf("Stop: %v", err)
+			}
+
+			if len(result.Votes) != 2 {
+				t.Fatalf("Got %d votes, expected 2", len(result.Votes))
+			}
+
+			if got := string(result.Votes[0]) == string(result.Votes[1]); got != tt.expectEq {
+				t.Errorf("Saved votes `%s` and `%s`, expected equal: %v", result.Votes[0], result.Votes[1], tt.expectEq)
+			}
+
+			if tt.canonical {
+				expect := `{"value":{"1":"Y","2":"N"},"weight":"1.000000","method":"YN"}`
+				if got := string(result.Votes[0]); got != expect {
+					t.Errorf("Got saved vote `%s`, expected `%s`", got, expect)
+				}
+			}
+		})
+	}
+}
+
+// blockingBackend is a backend, that blocks votes of user 1 until release is
+// closed.
+type blockingBackend struct {
+	*memory.Backend
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingBackend) Vote(ctx context.Context, pollID int, userID int, object []byte) error {
+	if userID == 1 {
+		close(b.started)
+		<-b.release
+	}
+	return b.Backend.Vote(ctx, pollID, userID, object)
+}
+
+func TestVoteDrain(t *testing.T) {
+	ctx := context.Background()
+	backend := &blockingBackend{
+		Backend: memory.New(),
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		pollmethod: Y
+		global_yes: true
+		backend: fast
+		type: pseudoanonymous
+
+	meeting/1/users_enable_vote_weight: false
+
+	user/1:
+		is_present_in_meeting_ids: [1]
+		meeting_user_ids: [10]
+
+	user/2:
+		is_present_in_meeting_ids: [1]
+		meeting_user_ids: [20]
+
+	meeting_user/10:
+		user_id: 1
+		group_ids: [1]
+		meeting_id: 1
+
+	meeting_user/20:
+		user_id: 2
+		group_ids: [1]
+		meeting_id: 1
+	`))
+	v, _, _ := vote.New(ctx, backend, backend, ds, true)
+	backend.Start(ctx, 1)
+
+	voteErr := make(chan error, 1)
+	go func() {
+		voteErr <- v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`))
+	}()
+
+	select {
+	case <-backend.started:
+	case err := <-voteErr:
+		t.Fatalf("Vote returned before it was saved: %v", err)
+	}
+
+	shortCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := v.Drain(shortCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Drain with a running vote returned %v, expected %v", err, context.DeadlineExceeded)
+	}
+
+	if err := v.Vote(ctx, 1, 2, strings.NewReader(`{"value":"Y"}`)); !errors.Is(err, vote.ErrTemporary) {
+		t.Errorf("Vote while draining returned %v, expected %v", err, vote.ErrTemporary)
+	}
+
+	close(backend.release)
+
+	if err := v.Drain(ctx); err != nil {
+		t.Errorf("Drain returned unexpected error: %v", err)
+	}
+
+	if err := <-voteErr; err != nil {
+		t.Errorf("The running vote returned unexpected error: %v", err)
+	}
+
+	backend.AssertUserHasVoted(t, 1, 1)
+}
+
+func TestVoteMaintenance(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		pollmethod: Y
+		global_yes: true
+		backend: fast
+		type: pseudoanonymous
+		state: started
+
+	meeting/1/users_enable_vote_weight: false
+
+	user/1:
+		is_present_in_meeting_ids: [1]
+		meeting_user_ids: [10]
+
+	group/1/meeting_user_ids: [10]
+
+	meeting_user/10:
+		user_id: 1
+		group_ids: [1]
+		meeting_id: 1
+	`))
+	v, _, _ := vote.New(ctx, backend, backend, ds, true, vote.WithMaintenanceMessage("default message"))
+
+	if err := v.Start(ctx, 1); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	v.SetMaintenance(true, "")
+	if _, msg := v.Maintenance(); msg != "default message" {
+		t.Errorf("Got message %q, expected the default message", msg)
+	}
+
+	v.SetMaintenance(true, "back at 10:00")
+
+	t.Run("vote is rejected", func(t *testing.T) {
+		err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`))
+		if !errors.Is(err, vote.ErrMaintenance) {
+			t.Fatalf("Vote returned %v, expected %v", err, vote.ErrMaintenance)
+		}
+
+		if err.Error() != "back at 10:00" {
+			t.Errorf("Got message %q, expected %q", err.Error(), "back at 10:00")
+		}
+	})
+
+	t.Run("start is rejected", func(t *testing.T) {
+		if err := v.Start(ctx, 1); !errors.Is(err, vote.ErrMaintenance) {
+			t.Errorf("Start returned %v, expected %v", err, vote.ErrMaintenance)
+		}
+	})
+
+	t.Run("reads work", func(t *testing.T) {
+		if _, err := v.Voted(ctx, []int{1}, 1); err != nil {
+			t.Errorf("Voted returned unexpected error: %v", err)
+		}
+
+		if _, err := v.TurnoutByGroup(ctx, 2); errors.Is(err, vote.ErrMaintenance) {
+			t.Errorf("TurnoutByGroup returned %v", err)
+		}
+	})
+
+	t.Run("vote after maintenance", func(t *testing.T) {
+		v.SetMaintenance(false, "")
+
+		if err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`)); err != nil {
+			t.Errorf("Vote returned unexpected error: %v", err)
+		}
+	})
+}
+
+func TestVoteTrace(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		pollmethod: Y
+		global_yes: true
+		backend: fast
+		type: pseudoanonymous
+
+	meeting/1:
+		users_enable_vote_weight: true
+
+	user/1:
+		is_present_in_meeting_ids: [1]
+		meeting_user_ids: [10]
+
+	meeting_user/10:
+		user_id: 1
+		group_ids: [1]
+		meeting_id: 1
+	`))
+	v, _, _ := vote.New(ctx, backend, backend, ds, true)
+	backend.Start(ctx, 1)
+
+	ctx, trace := vote.WithTrace(ctx)
+	start := time.Now()
+	if _, err := v.VoteWithResult(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`)); err != nil {
+		t.Fatalf("VoteWithResult returned unexpected error: %v", err)
+	}
+	total := time.Since(start)
+
+	if trace.Load <= 0 || trace.Validation <= 0 || trace.Weight <= 0 || trace.Backend <= 0 {
+		t.Errorf("Not all phases were traced: %+v", trace)
+	}
+
+	if sum := trace.Load + trace.Validation + trace.Weight + trace.Backend; sum > total {
+		t.Errorf("Sum of phases is %s, more then the total time %s", sum, total)
+	}
+}
+
+func TestVoteHiddenNamedIdentity(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		pollmethod: Y
+		global_yes: true
+		backend: fast
+		type: named
+
+	meeting/1:
+		users_enable_vote_weight: false
+
+	user/1:
+		is_present_in_meeting_ids: [1]
+		meeting_user_ids: [10]
+
+	meeting_user/10:
+		user_id: 1
+		group_ids: [1]
+		meeting_id: 1
+	`))
+	v, _, _ := vote.New(ctx, backend, backend, ds, true, vote.WithHiddenNamedIdentity(true))
+	backend.Start(ctx, 1)
+
+	if err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`)); err != nil {
+		t.Fatalf("Vote returned unexpected error: %v", err)
+	}
+
+	if err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`)); !errors.Is(err, vote.ErrDoubleVote) {
+		t.Errorf("Second vote returned %v, expected ErrDoubleVote", err)
+	}
+
+	result, err := v.Stop(ctx, 1)
+	if err != nil {
+		t.Fatalf("Stop returned unexpected error: %v", err)
+	}
+
+	if len(result.Votes) != 1 {
+		t.Fatalf("Got %d votes, expected 1", len(result.Votes))
+	}
+
+	expect := `{"value":"Y","weight":"1.000000","method":"Y"}`
+	if string(result.Votes[0]) != expect {
+		t.Errorf("Got vote %s, expected %s", result.Votes[0], expect)
+	}
+
+	if !reflect.DeepEqual(result.UserIDs, []int{1}) {
+		t.Errorf("Got user ids %v, expected [1]", result.UserIDs)
+	}
+}
+
+func TestVoteSimulate(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		pollmethod: Y
+		global_yes: true
+		backend: fast
+		type: pseudoanonymous
+
+	meeting/1:
+		users_enable_vote_weight: true
+
+	user/1:
+		is_present_in_meeting_ids: [1]
+		meeting_user_ids: [10]
+
+	meeting_user/10:
+		user_id: 1
+		group_ids: [1]
+		meeting_id: 1
+		vote_weight: "2.000000"
+	`))
+	v, _, _ := vote.New(ctx, backend, backend, ds, true)
+	backend.Start(ctx, 1)
+
+	t.Run("valid vote", func(t *testing.T) {
+		result, err := v.Simulate(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`))
+		if err != nil {
+			t.Fatalf("Simulate returned unexpected error: %v", err)
+		}
+
+		if result.VoteUserID != 1 || result.Weight != "2.000000" {
+			t.Errorf("Got %+v, expected vote user 1 with weight 2.000000", result)
+		}
+
+		if result.Duration <= 0 {
+			t.Errorf("Got duration %s, expected a positive duration", result.Duration)
+		}
+
+		voted, err := backend.Voted(ctx)
+		if err != nil {
+			t.Fatalf("backend.Voted: %v", err)
+		}
+
+		if len(voted[1]) != 0 {
+			t.Errorf("Simulate saved a vote for users %v", voted[1])
+		}
+
+		if count := v.VoteCount(ctx)[1]; count != 0 {
+			t.Errorf("VoteCount returned %d after simulate, expected 0", count)
+		}
+	})
+
+	t.Run("invalid vote", func(t *testing.T) {
+		_, err := v.Simulate(ctx, 1, 1, strings.NewReader(`{"value":"N"}`))
+		if !errors.Is(err, vote.ErrInvalid) {
+			t.Errorf("Simulate with invalid vote returned %v, expected ErrInvalid", err)
+		}
+	})
+}
+
+func TestVoteEligible(t *testing.T) {
+	data := `
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		pollmethod: Y
+		global_yes: true
+		backend: fast
+		type: pseudoanonymous
+		state: started
+
+	meeting/1/users_enable_vote_delegations: true
+
+	user:
+		1:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [10]
+		2:
+			meeting_user_ids: [20]
+		3:
+			meeting_user_ids: [30]
+		4:
+			meeting_user_ids: [40]
+		5:
+			is_present_in_meeting_ids: [1]
+		6:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [60]
+		7:
+			meeting_user_ids: [70]
+
+	meeting_user:
+		10:
+			group_ids: [1]
+			meeting_id: 1
+		20:
+			group_ids: [1]
+			meeting_id: 1
+			vote_delegated_to_id: 10
+		30:
+			group_ids: [1]
+			meeting_id: 1
+		40:
+			group_ids: [2]
+			meeting_id: 1
+			vote_delegated_to_id: 10
+		60:
+			group_ids: [2]
+			meeting_id: 1
+		70:
+			group_ids: [1]
+			meeting_id: 1
+			vote_delegated_to_id: 60
+	`
+
+	for _, tt := range []struct {
+		name         string
+		data         string
+		options      []vote.Option
+		requestUser  int
+		voteUser     int
+		alreadyVoted bool
+		expectReason string
+	}{
+		{"for himself", data, nil, 1, 1, false, ""},
+		{"for delegated user", data, nil, 1, 2, false, ""},
+		{"already voted", data, nil, 1, 1, true, "already-voted"},
+		{"not started", strings.Replace(data, "state: started", "state: finished", 1), nil, 1, 1, false, "not-started"},
+		{"not present", data, nil, 2, 2, false, "not-present"},
+		{"anonymous", data, nil, 1, 0, false, "anonymous"},
+		{"not in meeting", data, nil, 5, 5, false, "not-in-meeting"},
+		{"not in group", data, nil, 1, 4, false, "not-in-group"},
+		{"not delegated", data, nil, 1, 3, false, "not-delegated"},
+		{
+			"delegation disabled",
+			strings.Replace(data, "users_enable_vote_delegations: true", "users_enable_vote_delegations: false", 1),
+			nil,
+			1,
+			2,
+			false,
+			"delegation-disabled",
+		},
+		{"delegate not in group", data, []vote.Option{vote.WithDelegateMustBeEntitled(true)}, 6, 7, false, "delegate-not-in-group"},
+		{"principal not present", data, []vote.Option{vote.WithAbsentDelegates(false)}, 1, 2, false, "principal-not-present"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			backend := memory.New()
+			ds := &StubGetter{data: dsmock.YAMLData(tt.data)}
+
+			v, _, _ := vote.New(ctx, backend, backend, ds, true, tt.options...)
+
+			if err := backend.Start(ctx, 1); err != nil {
+				t.Fatalf("backend.Start(): %v", err)
+			}
+
+			if tt.alreadyVoted {
+				backend.Vote(ctx, 1, tt.voteUser, []byte("vote"))
+				if err := v.RefreshVoted(ctx); err != nil {
+					t.Fatalf("RefreshVoted: %v", err)
+				}
+			}
+
+			got, err := v.Eligible(ctx, 1, tt.requestUser, tt.voteUser)
+			if err != nil {
+				t.Fatalf("Eligible returned unexpected error: %v", err)
+			}
+
+			expect := vote.Eligibility{Eligible: tt.expectReason == "", Reason: tt.expectReason}
+			if got != expect {
+				t.Errorf("Got %v, expected %v", got, expect)
+			}
+		})
+	}
+}
+
+func TestVoteEligibilityChecks(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		pollmethod: Y
+		global_yes: true
+		backend: fast
+		type: pseudoanonymous
+		state: started
+
+	meeting/1/users_enable_vote_delegations: true
+
+	user:
+		1:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [10]
+		2:
+			meeting_user_ids: [20]
+		3:
+			meeting_user_ids: [30]
+
+	meeting_user:
+		10:
+			group_ids: [1]
+			meeting_id: 1
+		20:
+			group_ids: [1]
+			meeting_id: 1
+		30:
+			group_ids: [1]
+			meeting_id: 1
+			vote_delegated_to_id: 10
+	`))
+
+	v, _, _ := vote.New(ctx, backend, backend, ds, true)
+	backend.Start(ctx, 1)
+
+	for _, tt := range []struct {
+		name        string
+		requestUser int
+		voteUser    int
+		expect      []vote.EligibilityCheck
+	}{
+		{
+			"all passed",
+			1,
+			1,
+			[]vote.EligibilityCheck{
+				{Check: "started", Passed: true},
+				{Check: "present", Passed: true},
+				{Check: "in-meeting", Passed: true},
+				{Check: "in-group", Passed: true},
+				{Check: "delegation", Passed: true},
+				{Check: "not-voted", Passed: true},
+			},
+		},
+		{
+			"not present",
+			2,
+			2,
+			[]vote.EligibilityCheck{
+				{Check: "started", Passed: true},
+				{Check: "present", Passed: false},
+				{Check: "in-meeting", Passed: true},
+				{Check: "in-group", Passed: true},
+				{Check: "delegation", Passed: true},
+				{Check: "not-voted", Passed: true},
+			},
+		},
+		{
+			"for delegated user",
+			1,
+			3,
+			[]vote.EligibilityCheck{
+				{Check: "started", Passed: true},
+				{Check: "present", Passed: true},
+				{Check: "in-meeting", Passed: true},
+				{Check: "in-group", Passed: true},
+				{Check: "delegation", Passed: true},
+				{Check: "not-voted", Passed: true},
+			},
+		},
+		{
+			"not delegated",
+			1,
+			2,
+			[]vote.EligibilityCheck{
+				{Check: "started", Passed: true},
+				{Check: "present", Passed: true},
+				{Check: "delegation", Passed: false},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := v.EligibilityChecks(ctx, 1, tt.requestUser, tt.voteUser)
+			if err != nil {
+				t.Fatalf("EligibilityChecks: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.expect) {
+				t.Errorf("Got %v, expected %v", got, tt.expect)
+			}
+		})
+	}
+
+	t.Run("already voted", func(t *testing.T) {
+		if err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`)); err != nil {
+			t.Fatalf("Vote: %v", err)
+		}
+
+		got, err := v.EligibilityChecks(ctx, 1, 1, 1)
+		if err != nil {
+			t.Fatalf("EligibilityChecks: %v", err)
+		}
+
+		if last := got[len(got)-1]; last.Check != "not-voted" || last.Passed {
+			t.Errorf("Got %v, expected a failed not-voted check", last)
+		}
+	})
+
+	t.Run("no information about other users", func(t *testing.T) {
+		// User 1 has voted in the subtest before.
+		got, err := v.EligibilityChecks(ctx, 1, 2, 1)
+		if err != nil {
+			t.Fatalf("EligibilityChecks: %v", err)
+		}
+
+		for _, check := range got {
+			switch check.Check {
+			case "not-voted", "in-group", "in-meeting":
+				t.Errorf("Got check %v for a user, that is not delegated", check)
+			}
+		}
+	})
+}
+
+func TestVotedHashes(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		pollType  string
+		expectErr error
+	}{
+		{"named", "named", nil},
+		{"pseudoanonymous", "pseudoanonymous", vote.ErrNotAllowed},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			backend := memory.New()
+			ds := dsmock.NewFlow(dsmock.YAMLData(fmt.Sprintf(`
+			poll/1:
+				meeting_id: 1
+				entitled_group_ids: [1]
+				pollmethod: Y
+				global_yes: true
+				backend: fast
+				type: %s
+
+			meeting/1/id: 1
+
+			user/1:
+				is_present_in_meeting_ids: [1]
+				meeting_user_ids: [10]
+
+			meeting_user/10:
+				user_id: 1
+				group_ids: [1]
+				meeting_id: 1
+			`, tt.pollType)))
+			v, _, _ := vote.New(ctx, backend, backend, ds, true)
+			backend.Start(ctx, 1)
+
+			if err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`)); err != nil {
+				t.Fatalf("Vote returned unexpected error: %v", err)
+			}
+
+			hashes, err := v.VotedHashes(ctx, []int{1}, 1)
+			if tt.expectErr != nil {
+				if !errors.Is(err, tt.expectErr) {
+					t.Fatalf("Got error %v, expected %v", err, tt.expectErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("VotedHashes returned unexpected error: %v", err)
+			}
+
+			object, _, _ := backend.VotedObject(ctx, 1, 1)
+			expect := fmt.Sprintf("%x", sha256.Sum256(object))
+			if got := hashes[1][1]; got != expect {
+				t.Errorf("Got hash %s, expected %s", got, expect)
+			}
+		})
+	}
+}
+
+func TestVoteIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	poll:
+		1:
+			meeting_id: 1
+			entitled_group_ids: [1]
+			pollmethod: Y
+			global_yes: true
+			backend: fast
+			type: pseudoanonymous
+		2:
+			meeting_id: 1
+			entitled_group_ids: [1]
+			pollmethod: Y
+			global_yes: true
+			backend: fast
+			type: pseudoanonymous
+
+	meeting/1/id: 1
+
+	user/1:
+		is_present_in_meeting_ids: [1]
+		meeting_user_ids: [10]
+
+	meeting_user/10:
+		user_id: 1
+		group_ids: [1]
+		meeting_id: 1
+	`))
+	v, _, _ := vote.New(ctx, backend, backend, ds, true, vote.WithIdempotencyTTL(time.Minute))
+	backend.Start(ctx, 1)
+	backend.Start(ctx, 2)
+
+	keyCtx := vote.WithIdempotencyKey(ctx, "my-key")
+
+	if err := v.Vote(keyCtx, 1, 1, strings.NewReader(`{"value":"Y"}`)); err != nil {
+		t.Fatalf("First vote returned unexpected error: %v", err)
+	}
+
+	t.Run("same key", func(t *testing.T) {
+		result, err := v.VoteWithResult(keyCtx, 1, 1, strings.NewReader(`{"value":"Y"}`))
+		if err != nil {
+			t.Fatalf("Vote with same key returned unexpected error: %v", err)
+		}
+
+		if result.VoteUserID != 1 {
+			t.Errorf("Got vote user %d, expected 1", result.VoteUserID)
+		}
+	})
+
+	t.Run("other key", func(t *testing.T) {
+		otherCtx := vote.WithIdempotencyKey(ctx, "other-key")
+		err := v.Vote(otherCtx, 1, 1, strings.NewReader(`{"value":"Y"}`))
+		if !errors.Is(err, vote.ErrDoubleVote) {
+			t.Errorf("Got error %v, expected ErrDoubleVote", err)
+		}
+	})
+
+	t.Run("no key", func(t *testing.T) {
+		err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`))
+		if !errors.Is(err, vote.ErrDoubleVote) {
+			t.Errorf("Got error %v, expected ErrDoubleVote", err)
+		}
+	})
+
+	t.Run("same key at the same time", func(t *testing.T) {
+		parallelCtx := vote.WithIdempotencyKey(ctx, "parallel-key")
+
+		var wg sync.WaitGroup
+		errs := make([]error, 10)
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, errs[i] = v.VoteWithResult(parallelCtx, 2, 1, strings.NewReader(`{"value":"Y"}`))
+			}(i)
+		}
+		wg.Wait()
+
+		for i, err := range errs {
+			if err != nil {
+				t.Errorf("Vote %d returned unexpected error: %v", i, err)
+			}
+		}
+	})
+
+	t.Run("after clear", func(t *testing.T) {
+		if err := v.Clear(ctx, 1); err != nil {
+			t.Fatalf("Clear: %v", err)
+		}
+
+		err := v.Vote(keyCtx, 1, 1, strings.NewReader(`{"value":"Y"}`))
+		if !errors.Is(err, vote.ErrNotStarted) {
+			t.Errorf("Got error %v, expected ErrNotStarted", err)
+		}
+	})
+}
+
+func TestVoteMaxVoters(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+
+	voterCount := 10
+	data := `
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		pollmethod: Y
+		global_yes: true
+		backend: fast
+		type: pseudoanonymous
+
+	meeting/1/id: 1
+	`
+	for i := 1; i <= voterCount; i++ {
+		data += fmt.Sprintf(`
+	user/%d:
+		is_present_in_meeting_ids: [1]
+		meeting_user_ids: [%d]
+
+	meeting_user/%d:
+		user_id: %d
+		group_ids: [1]
+		meeting_id: 1
+	`, i, i*10, i*10, i)
+	}
+
+	ds := dsmock.NewFlow(dsmock.YAMLData(data))
+	v, _, _ := vote.New(ctx, backend, backend, ds, true, vote.WithMaxVoters(1))
+
+	if err := backend.Start(ctx, 1); err != nil {
+		t.Fatalf("backend.Start returned unexpected error: %v", err)
+	}
+
+	errs := make(chan error, voterCount)
+	for i := 1; i <= voterCount; i++ {
+		go func(userID int) {
+			errs <- v.Vote(ctx, 1, userID, strings.NewReader(`{"value":"Y"}`))
+		}(i)
+	}
+
+	var success, full int
+	for i := 0; i < voterCount; i++ {
+		err := <-errs
+		switch {
+		case err == nil:
+			success++
+		case errors.Is(err, vote.ErrPollFull):
+			full++
+		default:
+			t.Errorf("Vote returned unexpected error: %v", err)
+		}
+	}
+
+	if success != 1 {
+		t.Errorf("Got %d successful votes, expected 1", success)
+	}
+
+	if full != voterCount-1 {
+		t.Errorf("Got %d poll full errors, expected %d", full, voterCount-1)
+	}
+
+	if count := v.VoteCount(ctx)[1]; count != 1 {
+		t.Errorf("VoteCount is %d, expected 1", count)
+	}
+}
+
+func TestTurnoutByGroup(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1, 2]
+		pollmethod: Y
+		global_yes: true
+		backend: fast
+		type: pseudoanonymous
+
+	group/1/meeting_user_ids: [10, 20, 30]
+	group/2/meeting_user_ids: [30, 40]
+
+	meeting_user:
+		10:
+			user_id: 1
+		20:
+			user_id: 2
+		30:
+			user_id: 3
+		40:
+			user_id: 4
+	`))
+
+	backend.Start(ctx, 1)
+	backend.Vote(ctx, 1, 1, []byte("vote"))
+	backend.Vote(ctx, 1, 3, []byte("vote"))
+
+	v, _, _ := vote.New(ctx, backend, backend, ds, true)
+
+	got, err := v.TurnoutByGroup(ctx, 1)
+	if err != nil {
+		t.Fatalf("TurnoutByGroup returned unexpected error: %v", err)
+	}
+
+	expect := map[int]vote.GroupTurnout{
+		1: {Entitled: 3, Voted: 2},
+		2: {Entitled: 2, Voted: 1},
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("Got %v, expected %v", got, expect)
+	}
+}
+
+func TestEntitledCount(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1, 2]
+		pollmethod: Y
+		global_yes: true
+		backend: fast
+		type: pseudoanonymous
+
+	group/1/meeting_user_ids: [10, 20, 30]
+	group/2/meeting_user_ids: [30, 40]
+
+	meeting_user:
+		10:
+			user_id: 1
+		20:
+			user_id: 2
+		30:
+			user_id: 3
+		40:
+			user_id: 4
+	`))
+
+	v, _, _ := vote.New(ctx, backend, backend, ds, true)
+
+	got, err := v.EntitledCount(ctx, 1)
+	if err != nil {
+		t.Fatalf("EntitledCount returned unexpected error: %v", err)
+	}
+
+	if got != 4 {
+		t.Errorf("Got %d entitled users, expected 4", got)
+	}
+}
+
+func TestBackendLoad(t *testing.T) {
+	ctx := context.Background()
+	fast := memory.New()
+	long := memory.New()
+
+	fast.Start(ctx, 1)
+	fast.Vote(ctx, 1, 1, []byte("vote"))
+	fast.Vote(ctx, 1, 2, []byte("vote"))
+	fast.Start(ctx, 2)
+	fast.Vote(ctx, 2, 1, []byte("vote"))
+	long.Start(ctx, 3)
+	long.Vote(ctx, 3, 1, []byte("vote"))
+
+	v, _, _ := vote.New(ctx, fast, long, dsmock.NewFlow(nil), true)
+
+	got, err := v.BackendLoad(ctx)
+	if err != nil {
+		t.Fatalf("BackendLoad: %v", err)
+	}
+
+	expect := map[string]vote.BackendUsage{
+		"fast": {Polls: 2, Votes: 3},
+		"long": {Polls: 1, Votes: 1},
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("Got %v, expected %v", got, expect)
+	}
+}
+
+func TestPollsVotedByUser(t *testing.T) {
+	ctx := context.Background()
+	fast := memory.New()
+	long := memory.New()
+
+	fast.Start(ctx, 1)
+	fast.Vote(ctx, 1, 5, []byte("vote"))
+	fast.Vote(ctx, 1, 6, []byte("vote"))
+	fast.Start(ctx, 2)
+	fast.Vote(ctx, 2, 6, []byte("vote"))
+	long.Start(ctx, 3)
+	long.Vote(ctx, 3, 5, []byte("vote"))
+	long.Start(ctx, 4)
+
+	v, _, _ := vote.New(ctx, fast, long, dsmock.NewFlow(nil), true)
+
+	got, err := v.PollsVotedByUser(ctx, 5)
+	if err != nil {
+		t.Fatalf("PollsVotedByUser: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, []int{1, 3}) {
+		t.Errorf("Got polls %v, expected [1 3]", got)
+	}
+}
+
+func TestRefreshVoted(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	backend.Start(ctx, 1)
+	backend.Vote(ctx, 1, 1, []byte("vote"))
+
+	v, _, _ := vote.New(ctx, backend, backend, dsmock.NewFlow(nil), true)
+
+	// Change the backend from outside of the service.
+	backend.Vote(ctx, 1, 2, []byte("vote"))
+	backend.Start(ctx, 2)
+	backend.Vote(ctx, 2, 1, []byte("vote"))
+
+	if got := v.VoteCount(ctx); !reflect.DeepEqual(got, map[int]int{1: 1}) {
+		t.Errorf("Before refresh, got vote count %v, expected %v", got, map[int]int{1: 1})
+	}
+
+	if err := v.RefreshVoted(ctx); err != nil {
+		t.Fatalf("RefreshVoted: %v", err)
+	}
+
+	expect := map[int]int{1: 2, 2: 1}
+	if got := v.VoteCount(ctx); !reflect.DeepEqual(got, expect) {
+		t.Errorf("After refresh, got vote count %v, expected %v", got, expect)
+	}
+}
+
+func TestTurnoutByGroupLiveResults(t *testing.T) {
+	data := `
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		pollmethod: Y
+		global_yes: true
+		backend: fast
+		type: pseudoanonymous
+		state: started
+
+	group/1/meeting_user_ids: [10]
+	meeting_user/10/user_id: 1
+	`
+
+	for _, tt := range []struct {
+		name          string
+		data          string
+		liveResults   bool
+		expectAllowed bool
+	}{
+		{"pseudoanonymous", data, false, false},
+		{"pseudoanonymous with live results", data, true, false},
+		{"named", strings.Replace(data, "pseudoanonymous", "named", 1), false, false},
+		{"named with live results", strings.Replace(data, "pseudoanonymous", "named", 1), true, true},
+		{"stopped pseudoanonymous", strings.Replace(data, "state: started", "state: finished", 1), false, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			backend := memory.New()
+			ds := dsmock.NewFlow(dsmock.YAMLData(tt.data))
+			v, _, _ := vote.New(ctx, backend, backend, ds, true, vote.WithLiveResults(tt.liveResults))
+
+			_, err := v.TurnoutByGroup(ctx, 1)
+
+			if tt.expectAllowed {
+				if err != nil {
+					t.Fatalf("TurnoutByGroup returned unexpected error: %v", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, vote.ErrNotAllowed) {
+				t.Errorf("Got error %v, expected ErrNotAllowed", err)
+			}
+		})
+	}
+}
+
+func TestActiveMeetings(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	poll:
+		1:
+			meeting_id: 1
+			backend: fast
+			type: pseudoanonymous
+			pollmethod: Y
+			state: started
+		2:
+			meeting_id: 2
+			backend: fast
+			type: pseudoanonymous
+			pollmethod: Y
+			state: started
+		3:
+			meeting_id: 2
+			backend: fast
+			type: pseudoanonymous
+			pollmethod: Y
+			state: started
+		4:
+			meeting_id: 3
+			backend: fast
+			type: pseudoanonymous
+			pollmethod: Y
+			state: started
+		5:
+			meeting_id: 4
+			backend: fast
+			type: pseudoanonymous
+			pollmethod: Y
+			state: started
+
+	meeting/1/id: 1
+	meeting/2/id: 2
+	meeting/3/id: 3
+	meeting/4/id: 4
+	`))
+	v, _, _ := vote.New(ctx, backend, backend, ds, true)
+
+	for _, pollID := range []int{1, 2, 3, 4} {
+		if err := v.Start(ctx, pollID); err != nil {
+			t.Fatalf("Start poll %d: %v", pollID, err)
+		}
+	}
+
+	if _, err := v.Stop(ctx, 4); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	// Poll 5 is started by another instance. Poll 6 does not exist in the
+	// datastore.
+	backend.Start(ctx, 5)
+	backend.Start(ctx, 6)
+
+	got, err := v.ActiveMeetings(ctx)
+	if err != nil {
+		t.Fatalf("ActiveMeetings: %v", err)
+	}
+
+	if expect := []int{1, 2, 4}; !reflect.DeepEqual(got, expect) {
+		t.Errorf("Got meetings %v, expected %v", got, expect)
+	}
+}
+
+func TestVoteCount(t *testing.T) {
+	ctx := context.Background()
+	backend1 := memory.New()
+	backend1.Start(ctx, 23)
+	backend1.Vote(ctx, 23, 1, []byte("vote"))
+	backend2 := memory.New()
+	backend2.Start(ctx, 42)
+	backend2.Vote(ctx, 42, 1, []byte("vote"))
+	backend2.Vote(ctx, 42, 2, []byte("vote"))
+	ds := dsmock.NewFlow(dsmock.YAMLData(``))
+
+	v, _, _ := vote.New(ctx, backend1, backend2, ds, true)
+
+	count := v.VoteCount(ctx)
+
+	expect := map[int]int{23: 1, 42: 2}
+	if !reflect.DeepEqual(count, expect) {
+		t.Errorf("Got %v, expected %v", count, expect)
+	}
+}
+
+// lossyBackend is a backend, that loses all votes.
+type lossyBackend struct {
+	*memory.Backend
+}
+
+func (b *lossyBackend) Stop(ctx context.Context, pollID int) ([][]byte, []int, error) {
+	_, userIDs, err := b.Backend.Stop(ctx, pollID)
+	return nil, userIDs, err
+}
+
+func TestStartupSelfcheck(t *testing.T) {
+	ctx := context.Background()
+	ds := dsmock.NewFlow(nil)
+
+	t.Run("working backend", func(t *testing.T) {
+		backend := memory.New()
+
+		if _, _, err := vote.New(ctx, backend, backend, ds, true, vote.WithStartupSelfcheck(true)); err != nil {
+			t.Fatalf("New returned unexpected error: %v", err)
+		}
+
+		voted, err := backend.Voted(ctx)
+		if err != nil {
+			t.Fatalf("Voted: %v", err)
+		}
+
+		if len(voted) != 0 {
+			t.Errorf("Selfcheck left data in the backend: %v", voted)
+		}
+	})
+
+	t.Run("broken backend", func(t *testing.T) {
+		backend := &lossyBackend{memory.New()}
+
+		_, _, err := vote.New(ctx, memory.New(), backend, ds, true, vote.WithStartupSelfcheck(true))
+		if err == nil {
+			t.Fatalf("New did not return an error")
+		}
+
+		if !strings.Contains(err.Error(), "stop returned the objects") {
+			t.Errorf("Got error `%v`, expected a message about the missing objects", err)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		backend := &lossyBackend{memory.New()}
+
+		if _, _, err := vote.New(ctx, backend, backend, ds, true); err != nil {
+			t.Fatalf("New returned unexpected error: %v", err)
+		}
+	})
+}
+
+func TestVotePublishVoterList(t *testing.T) {
+	ctx := context.Background()
+	data := dsmock.YAMLData(`
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		pollmethod: Y
+		global_yes: true
+		backend: fast
+		type: pseudoanonymous
+
+	meeting/1/users_enable_vote_weight: false
+
+	user:
+		1:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [10]
+		2:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [20]
+
+	meeting_user:
+		10:
+			user_id: 1
+			group_ids: [1]
+			meeting_id: 1
+		20:
+			user_id: 2
+			group_ids: [1]
+			meeting_id: 1
+	`)
+
+	for _, publish := range []bool{false, true} {
+		t.Run(fmt.Sprintf("publish %v", publish), func(t *testing.T) {
+			backend := memory.New()
+			backend.Start(ctx, 1)
+			v, _, _ := vote.New(ctx, backend, backend, dsmock.NewFlow(data), true, vote.WithPublishVoterList(publish))
+
+			for _, userID := range []int{1, 2} {
+				if err := v.Vote(ctx, 1, userID, strings.NewReader(`{"value":"Y"}`)); err != nil {
+					t.Fatalf("Vote of user %d: %v", userID, err)
+				}
+			}
+
+			if err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`)); !errors.Is(err, vote.ErrDoubleVote) {
+				t.Errorf("Second vote of user 1 returned %v, expected ErrDoubleVote", err)
+			}
+
+			result, err := v.Stop(ctx, 1)
+			if err != nil {
+				t.Fatalf("Stop: %v", err)
+			}
+
+			for _, ballot := range result.Votes {
+				if strings.Contains(string(ballot), "user_id") {
+					t.Errorf("Ballot %s is not anonymous", ballot)
+				}
+			}
+
+			sort.Ints(result.UserIDs)
+			if !reflect.DeepEqual(result.UserIDs, []int{1, 2}) {
+				t.Errorf("Got user ids %v, expected [1 2]", result.UserIDs)
+			}
+
+			if result.Meta.VoterListPublic != publish {
+				t.Errorf("Got voter_list_public %v, expected %v", result.Meta.VoterListPublic, publish)
+			}
+		})
+	}
+}
+
+// hangingBackend is a backend, that does not answer votes, stops and reopens
+// until the context is done.
+type hangingBackend struct {
+	*memory.Backend
+}
+
+func (b *hangingBackend) Vote(ctx context.Context, pollID int, userID int, object []byte) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (b *hangingBackend) Stop(ctx context.Context, pollID int) ([][]byte, []int, error) {
+	<-ctx.Done()
+	return nil, nil, ctx.Err()
+}
+
+func (b *hangingBackend) Reopen(ctx context.Context, pollID int) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestVoteBackendTimeout(t *testing.T) {
+	ctx := context.Background()
+	backend := &hangingBackend{memory.New()}
+	backend.Start(ctx, 1)
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		pollmethod: Y
+		global_yes: true
+		backend: fast
+		type: pseudoanonymous
+
+	meeting/1/users_enable_vote_weight: false
+
+	user/1:
+		is_present_in_meeting_ids: [1]
+		meeting_user_ids: [10]
+
+	meeting_user/10:
+		user_id: 1
+		group_ids: [1]
+		meeting_id: 1
+	`))
+
+	v, _, err := vote.New(ctx, backend, backend, ds, true, vote.WithBackendTimeout(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	t.Run("Vote", func(t *testing.T) {
+		err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`))
+		if !errors.Is(err, vote.ErrTemporary) {
+			t.Errorf("Vote returned %v, expected ErrTemporary", err)
+		}
+	})
+
+	t.Run("Stop", func(t *testing.T) {
+		_, err := v.Stop(ctx, 1)
+		if !errors.Is(err, vote.ErrTemporary) {
+			t.Errorf("Stop returned %v, expected ErrTemporary", err)
+		}
+	})
+
+	t.Run("Reopen", func(t *testing.T) {
+		err := v.Reopen(ctx, 1)
+		if !errors.Is(err, vote.ErrTemporary) {
+			t.Errorf("Reopen returned %v, expected ErrTemporary", err)
+		}
+	})
+
+	t.Run("Canceled request", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := v.Stop(ctx, 1)
+		if errors.Is(err, vote.ErrTemporary) || !errors.Is(err, context.Canceled) {
+			t.Errorf("Stop returned %v, expected context.Canceled", err)
+		}
+	})
+}
+
+func TestVoteRejectExplicitUser(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		name       string
+		reject     bool
+		delegation bool
+		ballot     string
+		expectErr  error
+	}{
+		{"lenient with user_id", false, false, `{"user_id":1,"value":"Y"}`, nil},
+		{"strict with user_id", true, false, `{"user_id":1,"value":"Y"}`, vote.ErrInvalid},
+		{"strict without user_id", true, false, `{"value":"Y"}`, nil},
+		{"strict with delegation", true, true, `{"user_id":1,"value":"Y"}`, nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := memory.New()
+			backend.Start(ctx, 1)
+			ds := dsmock.NewFlow(dsmock.YAMLData(fmt.Sprintf(`
+			poll/1:
+				meeting_id: 1
+				entitled_group_ids: [1]
+				pollmethod: Y
+				global_yes: true
+				backend: fast
+				type: pseudoanonymous
+
+			meeting/1:
+				users_enable_vote_weight: false
+				users_enable_vote_delegations: %v
+
+			user/1:
+				is_present_in_meeting_ids: [1]
+				meeting_user_ids: [10]
+
+			meeting_user/10:
+				user_id: 1
+				group_ids: [1]
+				meeting_id: 1
+			`, tt.delegation)))
+
+			v, _, _ := vote.New(ctx, backend, backend, ds, true, vote.WithRejectExplicitUser(tt.reject))
+
+			err := v.Vote(ctx, 1, 1, strings.NewReader(tt.ballot))
+
+			if tt.expectErr == nil {
+				if err != nil {
+					t.Errorf("Vote returned unexpected error: %v", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, tt.expectErr) {
+				t.Errorf("Vote returned %v, expected %v", err, tt.expectErr)
+			}
+		})
+	}
+}
+
+func TestVotePreloadKeys(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		pollmethod: Y
+		backend: fast
+		type: pseudoanonymous
+		state: started
+
+	meeting/1/users_enable_vote_weight: false
+	group/1/meeting_user_ids: [10]
+
+	user/1:
+		is_present_in_meeting_ids: [1]
+		meeting_user_ids: [10]
+
+	meeting_user/10:
+		user_id: 1
+		group_ids: [1]
+		meeting_id: 1
+	`))
+
+	v, _, _ := vote.New(ctx, backend, backend, ds, true)
+
+	keys, err := v.PreloadKeys(ctx, 1)
+	if err != nil {
+		t.Fatalf("PreloadKeys: %v", err)
+	}
+
+	got := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		got[key] = true
+	}
+
+	for _, expect := range []string{
+		"poll/1/meeting_id",
+		"meeting/1/users_enable_vote_weight",
+		"group/1/meeting_user_ids",
+		"meeting_user/10/user_id",
+		"user/1/is_present_in_meeting_ids",
+	} {
+		if !got[expect] {
+			t.Errorf("Key %s is missing in %v", expect, keys)
+		}
+	}
+
+	if exists, _ := backend.IsStopped(1); exists {
+		t.Errorf("The poll was started in the backend")
+	}
+}
+
+func TestVoteCountAfterRestore(t *testing.T) {
+	ctx := context.Background()
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		pollmethod: Y
+		global_yes: true
+		backend: fast
+		type: pseudoanonymous
+
+	meeting/1/users_enable_vote_weight: false
+
+	user:
+		1:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [10]
+		2:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [20]
+
+	meeting_user:
+		10:
+			user_id: 1
+			group_ids: [1]
+			meeting_id: 1
+		20:
+			user_id: 2
+			group_ids: [1]
+			meeting_id: 1
+	`))
+
+	backend := memory.New()
+	backend.Start(ctx, 1)
+	backend.Start(ctx, 2)
+	v, _, _ := vote.New(ctx, backend, backend, ds, true)
+
+	for _, userID := range []int{1, 2} {
+		if err := v.Vote(ctx, 1, userID, strings.NewReader(`{"value":"Y"}`)); err != nil {
+			t.Fatalf("Vote of user %d: %v", userID, err)
+		}
+	}
+
+	var snapshot bytes.Buffer
+	if err := backend.Snapshot(&snapshot); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	// Restart the service with a new backend from the snapshot.
+	restored := memory.New()
+	if err := restored.Restore(&snapshot); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	restarted, _, err := vote.New(ctx, restored, restored, ds, true)
+	if err != nil {
+		t.Fatalf("New after restore: %v", err)
+	}
+
+	if got, expect := restarted.VoteCount(ctx), v.VoteCount(ctx); !reflect.DeepEqual(got, expect) {
+		t.Errorf("Got vote count %v after restart, expected %v", got, expect)
+	}
+
+	if err := restarted.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`)); !errors.Is(err, vote.ErrDoubleVote) {
+		t.Errorf("Vote after restart returned %v, expected ErrDoubleVote", err)
+	}
+}
+
+func TestVoteBallotAck(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	backend.Start(ctx, 1)
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		pollmethod: Y
+		global_yes: true
+		backend: fast
+		type: pseudoanonymous
+
+	meeting/1/users_enable_vote_weight: false
+
+	user:
+		1:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [10]
+		2:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [20]
+
+	meeting_user:
+		10:
+			user_id: 1
+			group_ids: [1]
+			meeting_id: 1
+		20:
+			user_id: 2
+			group_ids: [1]
+			meeting_id: 1
+	`))
+
+	v, _, _ := vote.New(ctx, backend, backend, ds, true)
+
+	ballotIDs := make(map[string]bool)
+	for _, userID := range []int{1, 2} {
+		result, err := v.VoteWithResult(vote.WithBallotAck(ctx), 1, userID, strings.NewReader(`{"value":"Y"}`))
+		if err != nil {
+			t.Fatalf("Vote of user %d: %v", userID, err)
+		}
+
+		if result.BallotID == "" {
+			t.Fatalf("Vote of user %d returned no ballot id", userID)
+		}
+
+		if ballotIDs[result.BallotID] {
+			t.Errorf("Ballot id %s was returned twice", result.BallotID)
+		}
+		ballotIDs[result.BallotID] = true
+	}
+
+	stopResult, err := v.Stop(ctx, 1)
+	if err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	for _, object := range stopResult.Votes {
+		var saved struct {
+			BallotID string `json:"ballot_id"`
+		}
+		if err := json.Unmarshal(object, &saved); err != nil {
+			t.Fatalf("decoding vote: %v", err)
+		}
+
+		if !ballotIDs[saved.BallotID] {
+			t.Errorf("Vote %s has an unknown ballot id", object)
+		}
+	}
+}
+
+func TestVoteStopWithAggregation(t *testing.T) {
+	ctx := context.Background()
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	poll:
+		1:
+			meeting_id: 1
+			pollmethod: YNA
+			backend: fast
+			type: pseudoanonymous
+		2:
+			meeting_id: 1
+			pollmethod: Y
+			backend: fast
+			type: pseudoanonymous
+		3:
+			meeting_id: 1
+			pollmethod: TEXT
+			backend: fast
+			type: pseudoanonymous
+	`))
+
+	backend := memory.New()
+	v, _, _ := vote.New(ctx, backend, backend, ds, true)
+
+	t.Run("YNA", func(t *testing.T) {
+		backend.Start(ctx, 1)
+		for userID, object := range []string{
+			`{"value":{"1":"Y","2":"N"},"weight":"1.500000"}`,
+			`{"value":{"1":"Y","2":"A"},"weight":"2.000000"}`,
+			`{"value":{"1":"N"},"weight":"1.000000"}`,
+			`{"value":"A","weight":"3.000000"}`,
+			`broken`,
+		} {
+			backend.Vote(ctx, 1, userID+1, []byte(object))
+		}
+
+		result, err := v.StopWithAggregation(ctx, 1)
+		if err != nil {
+			t.Fatalf("StopWithAggregation: %v", err)
+		}
+
+		expect := &vote.Aggregation{
+			Options: map[int]map[string]string{
+				1: {"Y": "3.500000", "N": "1.000000"},
+				2: {"N": "1.500000", "A": "2.000000"},
+			},
+			Global:  map[string]string{"A": "3.000000"},
+			Invalid: 1,
+		}
+
+		if !reflect.DeepEqual(result.Aggregation, expect) {
+			t.Errorf("Got aggregation %v, expected %v", result.Aggregation, expect)
+		}
+
+		if len(result.Votes) != 5 {
+			t.Errorf("Got %d votes, expected 5", len(result.Votes))
+		}
+	})
+
+	t.Run("Y with amounts", func(t *testing.T) {
+		backend.Start(ctx, 2)
+		backend.Vote(ctx, 2, 1, []byte(`{"value":{"1":2,"2":1},"weight":"1.500000"}`))
+		backend.Vote(ctx, 2, 2, []byte(`{"value":{"1":1},"weight":"1.000000"}`))
+
+		result, err := v.StopWithAggregation(ctx, 2)
+		if err != nil {
+			t.Fatalf("StopWithAggregation: %v", err)
+		}
+
+		expect := map[int]map[string]string{
+			1: {"Y": "4.000000"},
+			2: {"Y": "1.500000"},
+		}
+
+		if !reflect.DeepEqual(result.Aggregation.Options, expect) {
+			t.Errorf("Got options %v, expected %v", result.Aggregation.Options, expect)
+		}
+	})
+
+	t.Run("TEXT", func(t *testing.T) {
+		backend.Start(ctx, 3)
+
+		if _, err := v.StopWithAggregation(ctx, 3); !errors.Is(err, vote.ErrInvalid) {
+			t.Errorf("StopWithAggregation returned %v, expected ErrInvalid", err)
+		}
+
+		if _, stopped := backend.IsStopped(3); stopped {
+			t.Errorf("The poll was stopped")
+		}
+	})
+}
+
+func TestVoteNonce(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		pollmethod: Y
+		global_yes: true
+		backend: fast
+		type: pseudoanonymous
+		state: started
+
+	meeting/1/users_enable_vote_weight: false
+
+	user:
+		1:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [10]
+		2:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [20]
+		3:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [30]
+
+	meeting_user:
+		10:
+			user_id: 1
+			group_ids: [1]
+			meeting_id: 1
+		20:
+			user_id: 2
+			group_ids: [1]
+			meeting_id: 1
+		30:
+			user_id: 3
+			group_ids: [1]
+			meeting_id: 1
+
+	group/1/meeting_user_ids: [10, 20, 30]
+	`))
+
+	v, _, err := vote.New(ctx, backend, backend, ds, true)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if err := v.Start(ctx, 1); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	if err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y","nonce":"abc"}`)); err != nil {
+		t.Fatalf("First vote: %v", err)
+	}
+
+	t.Run("same nonce", func(t *testing.T) {
+		err := v.Vote(ctx, 1, 2, strings.NewReader(`{"value":"Y","nonce":"abc"}`))
+		if !errors.Is(err, vote.ErrInvalid) {
+			t.Errorf("Vote with used nonce returned %v, expected ErrInvalid", err)
+		}
+	})
+
+	t.Run("other nonce", func(t *testing.T) {
+		if err := v.Vote(ctx, 1, 2, strings.NewReader(`{"value":"Y","nonce":"def"}`)); err != nil {
+			t.Errorf("Vote with new nonce: %v", err)
+		}
+	})
+
+	t.Run("other instance", func(t *testing.T) {
+		other, _, err := vote.New(ctx, backend, backend, ds, false)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+
+		err = other.Vote(ctx, 1, 3, strings.NewReader(`{"value":"Y","nonce":"abc"}`))
+		if !errors.Is(err, vote.ErrInvalid) {
+			t.Errorf("Vote with used nonce on other instance returned %v, expected ErrInvalid", err)
+		}
+	})
+
+	t.Run("after clear", func(t *testing.T) {
+		if err := v.Clear(ctx, 1); err != nil {
+			t.Fatalf("Clear: %v", err)
+		}
+
+		if err := v.Start(ctx, 1); err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+
+		if err := v.Vote(ctx, 1, 3, strings.NewReader(`{"value":"Y","nonce":"abc"}`)); err != nil {
+			t.Errorf("Vote with nonce after clear: %v", err)
+		}
+	})
+}
+
+func TestVoteRetractVote(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		pollmethod: YNA
+		backend: fast
+		type: named
+		state: started
+		global_yes: true
+		global_no: true
+
+	meeting/1/users_enable_vote_weight: false
+
+	user/1:
+		is_present_in_meeting_ids: [1]
+		meeting_user_ids: [10]
+
+	meeting_user/10:
+		user_id: 1
+		group_ids: [1]
+		meeting_id: 1
+
+	group/1/meeting_user_ids: [10]
+	`))
+
+	v, _, err := vote.New(ctx, backend, backend, ds, true)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if err := v.Start(ctx, 1); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	if err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`)); err != nil {
+		t.Fatalf("Vote: %v", err)
+	}
+
+	if err := v.RetractVote(ctx, 1, 1); err != nil {
+		t.Fatalf("RetractVote: %v", err)
+	}
+
+	if count := v.VoteCount(ctx)[1]; count != 0 {
+		t.Errorf("VoteCount after retract is %d, expected 0", count)
+	}
+
+	if err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"N"}`)); err != nil {
+		t.Fatalf("Vote after retract: %v", err)
+	}
+
+	result, err := v.Stop(ctx, 1)
+	if err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	if len(result.Votes) != 1 || !strings.Contains(string(result.Votes[0]), `"value":"N"`) {
+		t.Errorf("Stop returned votes %s, expected only the second vote", result.Votes)
+	}
+
+	if err := v.RetractVote(ctx, 1, 1); !errors.Is(err, vote.ErrNotExists) {
+		t.Errorf("RetractVote on a stopped poll returned %v, expected ErrNotExists", err)
+	}
+}
+
+func TestVoteAudit(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		pollmethod: Y
+		global_yes: true
+		backend: fast
+		type: pseudoanonymous
+		state: started
+
+	meeting/1/users_enable_vote_weight: false
+
+	user/1:
+		is_present_in_meeting_ids: [1]
+		meeting_user_ids: [10]
+
+	meeting_user/10:
+		user_id: 1
+		group_ids: [1]
+		meeting_id: 1
+
+	group/1/meeting_user_ids: [10]
+	`))
+
+	v, _, err := vote.New(ctx, backend, backend, ds, true)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if err := v.Start(ctx, 1); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	// The second start does not change anything, but is recorded.
+	if err := v.Start(ctx, 1); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	if err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`)); err != nil {
+		t.Fatalf("Vote: %v", err)
+	}
+
+	if _, err := v.Stop(ctx, 1); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	auditEvents := func(t *testing.T) []string {
+		t.Helper()
+
+		events := v.Audit(1)
+		got := make([]string, len(events))
+		for i, event := range events {
+			got[i] = event.Event
+			if event.PollID != 1 {
+				t.Errorf("Event %d has poll id %d, expected 1", i, event.PollID)
+			}
+
+			if i > 0 && event.Time.Before(events[i-1].Time) {
+				t.Errorf("Event %d is older then event %d", i, i-1)
+			}
+		}
+		return got
+	}
+
+	expect := []string{vote.AuditStart, vote.AuditStart, vote.AuditStop}
+	if got := auditEvents(t); !reflect.DeepEqual(got, expect) {
+		t.Errorf("Got events %v, expected %v", got, expect)
+	}
+
+	if err := v.Reopen(ctx, 1); err != nil {
+		t.Fatalf("Reopen: %v", err)
+	}
+
+	if _, err := v.Stop(ctx, 1); err != nil {
+		t.Fatalf("Stop after reopen: %v", err)
+	}
+
+	expect = []string{vote.AuditStart, vote.AuditStart, vote.AuditStop, vote.AuditReopen, vote.AuditStop}
+	if got := auditEvents(t); !reflect.DeepEqual(got, expect) {
+		t.Errorf("Got events after reopen %v, expected %v", got, expect)
+	}
 
-			if err := v.Start(ctx, 1); err != nil {
-				t.Fatalf("Can not start poll: %v", err)
-			}
+	if err := v.Clear(ctx, 1); err != nil {
+		t.Fatalf("Clear: %v", err)
+	}
 
-			counter.Reset()
+	expect = []string{vote.AuditStart, vote.AuditStart, vote.AuditStop, vote.AuditReopen, vote.AuditStop, vote.AuditClear}
+	if got := auditEvents(t); !reflect.DeepEqual(got, expect) {
+		t.Errorf("Got events after clear %v, expected %v", got, expect)
+	}
 
-			if err := v.Vote(ctx, 1, 1, strings.NewReader(tt.vote)); err != nil {
-				t.Errorf("Vote returned unexpected error: %v", err)
-			}
+	if _, err := v.ClearAll(ctx); err != nil {
+		t.Fatalf("ClearAll: %v", err)
+	}
 
-			if counter.Count() != 0 {
-				t.Errorf("Vote send %d requests to the datastore: %v", counter.Count(), counter.Requests())
-			}
+	expect = []string{vote.AuditStart, vote.AuditStart, vote.AuditStop, vote.AuditReopen, vote.AuditStop, vote.AuditClear, vote.AuditClearAll}
+	if got := auditEvents(t); !reflect.DeepEqual(got, expect) {
+		t.Errorf("Got events after clear all %v, expected %v", got, expect)
+	}
 
-			backend.AssertUserHasVoted(t, 1, tt.expectVotedUserID)
-		})
+	if events := v.Audit(2); len(events) != 0 {
+		t.Errorf("Got events %v for an unknown poll, expected none", events)
 	}
 }
 
-func TestVoteDelegationAndGroup(t *testing.T) {
-	for _, tt := range []struct {
-		name string
-		data string
-		vote string
+func TestVoteNormalizeGlobal(t *testing.T) {
+	ctx := context.Background()
 
-		expectVotedUserID int
+	for _, tt := range []struct {
+		name      string
+		normalize bool
+		ballot    string
+		expect    string
 	}{
-		{
-			"Not delegated",
-			`
+		{"disabled", false, `{"value":"Y"}`, `{"value":"Y","weight":"2.000000","method":"Y"}`},
+		{"enabled", true, `{"value":"Y"}`, `{"value":{"global":"Y","weight":"2.000000"},"weight":"2.000000","method":"Y"}`},
+		{"enabled with option", true, `{"value":{"5":1}}`, `{"value":{"5":1},"weight":"2.000000","method":"Y"}`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := memory.New()
+			ds := dsmock.NewFlow(dsmock.YAMLData(`
 			poll/1:
 				meeting_id: 1
 				entitled_group_ids: [1]
 				pollmethod: Y
 				global_yes: true
+				option_ids: [5]
 				backend: fast
 				type: pseudoanonymous
+				state: started
 
-			meeting/1/users_enable_vote_delegations: true
+			meeting/1/users_enable_vote_weight: true
 
 			user/1:
 				is_present_in_meeting_ids: [1]
 				meeting_user_ids: [10]
+				default_vote_weight: "2.000000"
 
 			meeting_user/10:
+				user_id: 1
 				group_ids: [1]
 				meeting_id: 1
-			`,
-			`{"value":"Y"}`,
 
-			1,
-		},
+			group/1/meeting_user_ids: [10]
+			option/5/poll_id: 1
+			`))
 
-		{
-			"Not delegated not present",
-			`
-			poll/1:
-				meeting_id: 1
-				entitled_group_ids: [1]
-				pollmethod: Y
-				global_yes: true
-				backend: fast
-				type: pseudoanonymous
+			v, _, err := vote.New(ctx, backend, backend, ds, true, vote.WithNormalizeGlobal(tt.normalize))
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
 
-			meeting/1/users_enable_vote_delegations: true
+			if err := v.Start(ctx, 1); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
 
-			user/1:
-				meeting_user_ids: [10]
+			if err := v.Vote(ctx, 1, 1, strings.NewReader(tt.ballot)); err != nil {
+				t.Fatalf("Vote: %v", err)
+			}
 
-			meeting_user/10:
-				group_ids: [1]
-				meeting_id: 1
-			`,
-			`{"value":"Y"}`,
+			result, err := v.Stop(ctx, 1)
+			if err != nil {
+				t.Fatalf("Stop: %v", err)
+			}
 
-			0,
-		},
+			if len(result.Votes) != 1 {
+				t.Fatalf("Stop returned %d votes, expected 1", len(result.Votes))
+			}
 
-		{
-			"Not delegated not in group",
-			`
+			if got := string(result.Votes[0]); got != tt.expect {
+				t.Errorf("Got vote `%s`, expected `%s`", got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestVoteNormalizeGlobalFromClient(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		pollmethod: Y
+		global_yes: true
+		backend: fast
+		type: pseudoanonymous
+		state: started
+
+	meeting/1/users_enable_vote_weight: true
+
+	user/1:
+		is_present_in_meeting_ids: [1]
+		meeting_user_ids: [10]
+
+	meeting_user/10:
+		user_id: 1
+		group_ids: [1]
+		meeting_id: 1
+
+	group/1/meeting_user_ids: [10]
+	`))
+
+	v, _, err := vote.New(ctx, backend, backend, ds, true, vote.WithNormalizeGlobal(true))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if err := v.Start(ctx, 1); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	err = v.Vote(ctx, 1, 1, strings.NewReader(`{"value":{"global":"Y","weight":"100.000000"}}`))
+	if !errors.Is(err, vote.ErrInvalid) {
+		t.Errorf("Vote returned %v, expected %v", err, vote.ErrInvalid)
+	}
+}
+
+// concurrencyBackend is a backend, that records the maximum number of
+// concurrent calls to Stop.
+type concurrencyBackend struct {
+	*memory.Backend
+
+	mu      sync.Mutex
+	current int
+	max     int
+}
+
+func (b *concurrencyBackend) Stop(ctx context.Context, pollID int) ([][]byte, []int, error) {
+	b.mu.Lock()
+	b.current++
+	if b.current > b.max {
+		b.max = b.current
+	}
+	b.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	b.mu.Lock()
+	b.current--
+	b.mu.Unlock()
+
+	return b.Backend.Stop(ctx, pollID)
+}
+
+func TestVoteMaxConcurrentStops(t *testing.T) {
+	ctx := context.Background()
+	const pollCount = 20
+	const limit = 3
+
+	var yaml strings.Builder
+	for i := 1; i <= pollCount; i++ {
+		fmt.Fprintf(&yaml, `
+	poll/%d:
+		meeting_id: 1
+		backend: fast
+		type: pseudoanonymous
+		pollmethod: Y
+`, i)
+	}
+
+	backend := &concurrencyBackend{Backend: memory.New()}
+	ds := dsmock.NewFlow(dsmock.YAMLData(yaml.String()))
+
+	v, _, err := vote.New(ctx, backend, backend, ds, true, vote.WithMaxConcurrentStops(limit))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	for i := 1; i <= pollCount; i++ {
+		backend.Start(ctx, i)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, pollCount)
+	for i := 1; i <= pollCount; i++ {
+		wg.Add(1)
+		go func(pollID int) {
+			defer wg.Done()
+			if _, err := v.Stop(ctx, pollID); err != nil {
+				errs <- fmt.Errorf("stop poll %d: %w", pollID, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if backend.max > limit {
+		t.Errorf("Got %d concurrent stops, expected at most %d", backend.max, limit)
+	}
+
+	t.Run("waiting stop respects the context", func(t *testing.T) {
+		hanging := &hangingBackend{memory.New()}
+		hanging.Start(ctx, 1)
+		hanging.Start(ctx, 2)
+
+		v, _, err := vote.New(ctx, hanging, hanging, ds, true, vote.WithMaxConcurrentStops(1))
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+
+		// The first stop hangs in the backend and holds the only slot.
+		firstCtx, cancelFirst := context.WithCancel(ctx)
+		defer cancelFirst()
+		go v.Stop(firstCtx, 1)
+		time.Sleep(10 * time.Millisecond)
+
+		secondCtx, cancelSecond := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancelSecond()
+
+		if _, err := v.Stop(secondCtx, 2); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Second stop returned %v, expected a deadline exceeded error", err)
+		}
+	})
+}
+
+func TestVoteForceBackend(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		name        string
+		development bool
+		expectFast  bool
+	}{
+		{"development", true, false},
+		{"production", false, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			fast := memory.New()
+			long := memory.New()
+			ds := dsmock.NewFlow(dsmock.YAMLData(`
 			poll/1:
 				meeting_id: 1
-				entitled_group_ids: [1]
-				pollmethod: Y
-				global_yes: true
 				backend: fast
 				type: pseudoanonymous
+				pollmethod: Y
+				entitled_group_ids: []
 
-			meeting/1/users_enable_vote_delegations: true
+			meeting/1/users_enable_vote_weight: false
+			`))
+
+			v, _, err := vote.New(ctx, fast, long, ds, true, vote.WithDevelopment(tt.development))
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+
+			if err := v.ForceBackend(1, "memory"); err != nil {
+				t.Fatalf("ForceBackend: %v", err)
+			}
+
+			if err := v.Start(ctx, 1); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+
+			if exists, _ := fast.IsStopped(1); exists != tt.expectFast {
+				t.Errorf("Poll exists in the fast backend: %v, expected %v", exists, tt.expectFast)
+			}
+
+			if exists, _ := long.IsStopped(1); exists {
+				t.Errorf("Poll exists in the long backend")
+			}
+
+			// Stop has to use the same backend as Start.
+			if _, err := v.Stop(ctx, 1); err != nil {
+				t.Errorf("Stop: %v", err)
+			}
+
+			if err := v.Clear(ctx, 1); err != nil {
+				t.Errorf("Clear: %v", err)
+			}
+		})
+	}
+
+	t.Run("refresh voted", func(t *testing.T) {
+		backend := memory.New()
+		ds := dsmock.NewFlow(dsmock.YAMLData(`
+		poll/1:
+			meeting_id: 1
+			backend: fast
+			type: pseudoanonymous
+			pollmethod: Y
+			entitled_group_ids: [1]
+			global_yes: true
+
+		group/1/meeting_user_ids: [10]
+		meeting_user/10:
+			user_id: 1
+			meeting_id: 1
+			group_ids: [1]
+		user/1:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [10]
+		meeting/1/users_enable_vote_weight: false
+		`))
+
+		v, _, _ := vote.New(ctx, backend, backend, ds, true, vote.WithDevelopment(true))
+
+		if err := v.ForceBackend(1, "memory"); err != nil {
+			t.Fatalf("ForceBackend: %v", err)
+		}
+
+		if err := v.Start(ctx, 1); err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+
+		if err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`)); err != nil {
+			t.Fatalf("Vote: %v", err)
+		}
+
+		if err := v.RefreshVoted(ctx); err != nil {
+			t.Fatalf("RefreshVoted: %v", err)
+		}
+
+		voted, err := v.Voted(ctx, []int{1}, 1)
+		if err != nil {
+			t.Fatalf("Voted: %v", err)
+		}
+
+		if !reflect.DeepEqual(voted[1], []int{1}) {
+			t.Errorf("Voted returned %v after refresh, expected user 1 for poll 1", voted)
+		}
+	})
+
+	t.Run("remove forced backend", func(t *testing.T) {
+		fast := memory.New()
+		ds := dsmock.NewFlow(dsmock.YAMLData(`
+		poll/1:
+			meeting_id: 1
+			backend: fast
+			type: pseudoanonymous
+			pollmethod: Y
+			entitled_group_ids: []
+
+		meeting/1/users_enable_vote_weight: false
+		`))
+
+		v, _, _ := vote.New(ctx, fast, fast, ds, true, vote.WithDevelopment(true))
+
+		if err := v.ForceBackend(1, "memory"); err != nil {
+			t.Fatalf("ForceBackend: %v", err)
+		}
+
+		if err := v.ForceBackend(1, ""); err != nil {
+			t.Fatalf("ForceBackend with empty name: %v", err)
+		}
+
+		if err := v.Start(ctx, 1); err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+
+		if exists, _ := fast.IsStopped(1); !exists {
+			t.Errorf("Poll was not started in the fast backend")
+		}
+	})
+
+	t.Run("unknown backend", func(t *testing.T) {
+		backend := memory.New()
+		v, _, _ := vote.New(ctx, backend, backend, dsmock.NewFlow(nil), true, vote.WithDevelopment(true))
+
+		if err := v.ForceBackend(1, "sqlite"); !errors.Is(err, vote.ErrInvalid) {
+			t.Errorf("ForceBackend with unknown backend returned %v, expected ErrInvalid", err)
+		}
+	})
+}
+
+// recordingFlow remembers all keys, that were requested, and how often they
+// were requested.
+type recordingFlow struct {
+	*dsmock.Flow
+
+	mu   sync.Mutex
+	keys map[dskey.Key]int
+}
+
+func (f *recordingFlow) Get(ctx context.Context, keys ...dskey.Key) (map[dskey.Key][]byte, error) {
+	f.mu.Lock()
+	if f.keys == nil {
+		f.keys = make(map[dskey.Key]int)
+	}
+	for _, key := range keys {
+		f.keys[key]++
+	}
+	f.mu.Unlock()
+
+	return f.Flow.Get(ctx, keys...)
+}
+
+func TestVotePreloadExtension(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	flow := &recordingFlow{Flow: dsmock.NewFlow(dsmock.YAMLData(`
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: []
+		pollmethod: Y
+		backend: fast
+		type: pseudoanonymous
+
+	meeting/1:
+		users_enable_vote_weight: false
+		name: my meeting
+	`))}
+
+	extraKey := dskey.MustKey("meeting/1/name")
+	var calledWith [2]int
+	extension := func(pollID, meetingID int) []dskey.Key {
+		calledWith = [2]int{pollID, meetingID}
+		return []dskey.Key{extraKey}
+	}
+
+	v, _, err := vote.New(ctx, backend, backend, flow, true, vote.WithPreloadExtension(extension))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if err := v.Start(ctx, 1); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
 
-			user/1:
-				is_present_in_meeting_ids: [1]
-				meeting_user_ids: [10]
+	if calledWith != [2]int{1, 1} {
+		t.Errorf("Extension was called with poll and meeting %v, expected [1 1]", calledWith)
+	}
 
-			meeting_user/10:
-				group_ids: []
-				meeting_id: 1
-			`,
-			`{"value":"Y"}`,
+	if _, ok := flow.keys[extraKey]; !ok {
+		t.Errorf("Key %s was not requested", extraKey)
+	}
+}
 
-			0,
-		},
+func TestVoteClearMeeting(t *testing.T) {
+	ctx := context.Background()
+	fast := memory.New()
+	long := memory.New()
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	meeting/1/poll_ids: [1, 2]
+	meeting/2/poll_ids: [3]
+	`))
 
-		{
-			"Vote for self",
-			`
-			poll/1:
-				meeting_id: 1
-				entitled_group_ids: [1]
-				pollmethod: Y
-				global_yes: true
-				backend: fast
-				type: pseudoanonymous
+	v, _, err := vote.New(ctx, fast, long, ds, true)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
 
-			meeting/1/users_enable_vote_delegations: true
+	fast.Start(ctx, 1)
+	long.Start(ctx, 2)
+	fast.Start(ctx, 3)
 
-			user/1:
-				is_present_in_meeting_ids: [1]
-				meeting_user_ids: [10]
+	if err := v.ClearMeeting(ctx, 1); err != nil {
+		t.Fatalf("ClearMeeting: %v", err)
+	}
 
-			meeting_user/10:
-				group_ids: [1]
-				meeting_id: 1
-			`,
-			`{"user_id": 1, "value":"Y"}`,
+	for _, tt := range []struct {
+		backend *memory.Backend
+		pollID  int
+		expect  bool
+	}{
+		{fast, 1, false},
+		{long, 2, false},
+		{fast, 3, true},
+	} {
+		if exists, _ := tt.backend.IsStopped(tt.pollID); exists != tt.expect {
+			t.Errorf("Poll %d exists: %v, expected %v", tt.pollID, exists, tt.expect)
+		}
+	}
 
-			1,
-		},
+	if err := v.ClearMeeting(ctx, 404); !errors.Is(err, vote.ErrNotExists) {
+		t.Errorf("ClearMeeting of unknown meeting returned %v, expected ErrNotExists", err)
+	}
+}
 
-		{
-			"Vote for self not activated",
-			`
+func TestVotePollWithoutMeeting(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		meeting string
+	}{
+		{"missing meeting_id", ""},
+		{"meeting_id zero", "meeting_id: 0"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			backend := memory.New()
+			ds := dsmock.NewFlow(dsmock.YAMLData(fmt.Sprintf(`
 			poll/1:
-				meeting_id: 1
+				%s
 				entitled_group_ids: [1]
 				pollmethod: Y
 				global_yes: true
 				backend: fast
 				type: pseudoanonymous
 
-			meeting/1/users_enable_vote_delegations: false
-
 			user/1:
 				is_present_in_meeting_ids: [1]
 				meeting_user_ids: [10]
 
 			meeting_user/10:
+				user_id: 1
 				group_ids: [1]
 				meeting_id: 1
-			`,
-			`{"user_id": 1, "value":"Y"}`,
+			`, tt.meeting)))
+			v, _, _ := vote.New(ctx, backend, backend, ds, true)
+			backend.Start(ctx, 1)
 
-			1,
-		},
+			err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`))
 
-		{
-			"Vote for anonymous",
-			`
-			poll/1:
-				meeting_id: 1
-				entitled_group_ids: [1]
-				pollmethod: Y
-				global_yes: true
-				backend: fast
-				type: pseudoanonymous
-			
-			meeting/1/users_enable_vote_delegations: true
+			if !errors.Is(err, vote.ErrInvalid) {
+				t.Fatalf("Vote returned %v, expected ErrInvalid", err)
+			}
 
-			user/1:
-				is_present_in_meeting_ids: [1]
-				meeting_user_ids: [10]
+			if !strings.Contains(err.Error(), "has no meeting") {
+				t.Errorf("Got error `%v`, expected a message about the missing meeting", err)
+			}
+		})
+	}
+}
 
-			meeting_user/10:
-				group_ids: [1]
-				meeting_id: 1	
-			`,
-			`{"user_id": 0, "value":"Y"}`,
+func TestVoteExportImport(t *testing.T) {
+	ctx := context.Background()
+	fast := memory.New()
+	long := memory.New()
+	ds := dsmock.NewFlow(dsmock.YAMLData(`
+	poll:
+		1:
+			meeting_id: 1
+			entitled_group_ids: [1]
+			pollmethod: Y
+			global_yes: true
+			backend: fast
+			type: pseudoanonymous
+			state: started
+		2:
+			meeting_id: 1
+			entitled_group_ids: [1]
+			pollmethod: Y
+			global_yes: true
+			backend: long
+			type: named
+			state: started
 
-			0,
-		},
+	meeting/1/users_enable_vote_weight: false
+	group/1/meeting_user_ids: [10, 20]
 
-		{
-			"Vote for other without delegation",
-			`
-			poll/1:
-				meeting_id: 1
-				entitled_group_ids: [1]
-				pollmethod: Y
-				global_yes: true
-				backend: fast
-				type: pseudoanonymous
+	user:
+		1:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [10]
+		2:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [20]
 
-			meeting/1/users_enable_vote_delegations: true
+	meeting_user:
+		10:
+			user_id: 1
+			group_ids: [1]
+			meeting_id: 1
+		20:
+			user_id: 2
+			group_ids: [1]
+			meeting_id: 1
+	`))
 
-			user/1/is_present_in_meeting_ids: [1]
-			user/2/meeting_user_ids: [20]
+	v, _, err := vote.New(ctx, fast, long, ds, true)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
 
-			user:
-				1:
-					is_present_in_meeting_ids: [1]
-					meeting_user_ids: [10]
-					
-				2:
-					meeting_user_ids: [20]
+	for _, pollID := range []int{1, 2} {
+		if err := v.Start(ctx, pollID); err != nil {
+			t.Fatalf("Start poll %d: %v", pollID, err)
+		}
+	}
 
-			meeting_user:
-				10:
-					meeting_id: 1
-					user_id: 1
-				20:
-					group_ids: [1]
-					meeting_id: 1	
-			`,
-			`{"user_id": 2, "value":"Y"}`,
+	for _, userID := range []int{1, 2} {
+		if err := v.Vote(ctx, 1, userID, strings.NewReader(`{"value":"Y"}`)); err != nil {
+			t.Fatalf("Vote of user %d: %v", userID, err)
+		}
+	}
 
-			0,
-		},
+	if err := v.Vote(ctx, 2, 2, strings.NewReader(`{"value":"Y"}`)); err != nil {
+		t.Fatalf("Vote on poll 2: %v", err)
+	}
 
-		{
-			"Vote for other with delegation",
-			`
-			poll/1:
-				meeting_id: 1
-				entitled_group_ids: [1]
-				pollmethod: Y
-				global_yes: true
-				backend: fast
-				type: pseudoanonymous
+	expectCount := v.VoteCount(ctx)
 
-			meeting/1/users_enable_vote_delegations: true
+	var buf bytes.Buffer
+	if err := v.Export(ctx, &buf); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	exported := buf.String()
 
-			user:
-				1:
-					is_present_in_meeting_ids: [1]
-					meeting_user_ids: [10]
-					
-				2:
-					meeting_user_ids: [20]
+	if _, err := v.ClearAll(ctx); err != nil {
+		t.Fatalf("ClearAll: %v", err)
+	}
 
-			meeting_user:
-				10:
-					meeting_id: 1
-					user_id: 1
+	if count := v.VoteCount(ctx); len(count) != 0 {
+		t.Fatalf("VoteCount after ClearAll: %v", count)
+	}
 
-				20:
-					group_ids: [1]
-					meeting_id: 1	
-					vote_delegated_to_id: 10
-			`,
-			`{"user_id": 2, "value":"Y"}`,
+	if err := v.Import(ctx, &buf); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
 
-			2,
-		},
+	if got := v.VoteCount(ctx); !reflect.DeepEqual(got, expectCount) {
+		t.Errorf("VoteCount after import is %v, expected %v", got, expectCount)
+	}
 
-		{
-			"Vote for other with delegation not activated",
-			`
-			poll/1:
-				meeting_id: 1
-				entitled_group_ids: [1]
-				pollmethod: Y
-				global_yes: true
-				backend: fast
-				type: pseudoanonymous
+	// A second export has to be the same.
+	buf.Reset()
+	if err := v.Export(ctx, &buf); err != nil {
+		t.Fatalf("Second export: %v", err)
+	}
 
-			meeting/1/users_enable_vote_delegations: false
+	if buf.String() != exported {
+		t.Errorf("Export after import:\n%s\nexpected:\n%s", buf.String(), exported)
+	}
+}
 
-			user:
-				1:
-					is_present_in_meeting_ids: [1]
-					meeting_user_ids: [10]
-					
-				2:
-					meeting_user_ids: [20]
+func TestVoteExportStateFromBackend(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
 
-			meeting_user:
-				10:
-					meeting_id: 1
-					user_id: 1
+	// Both polls are changed in the backend directly, like from another
+	// instance.
+	backend.Start(ctx, 1)
+	backend.Start(ctx, 2)
+	backend.Vote(ctx, 2, 1, []byte(`"Y"`))
+	backend.Stop(ctx, 2)
 
-				20:
-					group_ids: [1]
-					meeting_id: 1	
-					vote_delegated_to_id: 10
-			`,
-			`{"user_id": 2, "value":"Y"}`,
+	v, _, err := vote.New(ctx, backend, backend, &StubGetter{}, false)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := v.Export(ctx, &buf); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	expect := `{"poll_id":1,"backend":"fast","stopped":false,"user_ids":[],"objects":[]}
+{"poll_id":2,"backend":"fast","stopped":true,"user_ids":[1],"objects":["Ilki"]}
+`
+	if got := buf.String(); got != expect {
+		t.Errorf("Export returned:\n%s\nexpected:\n%s", got, expect)
+	}
+}
+
+func TestVotePresenceCache(t *testing.T) {
+	ctx := context.Background()
+	presentKey := dskey.MustKey("user/1/is_present_in_meeting_ids")
+
+	ds := &recordingFlow{Flow: dsmock.NewFlow(dsmock.YAMLData(`
+	poll:
+		1:
+			meeting_id: 1
+			entitled_group_ids: [1]
+			pollmethod: Y
+			global_yes: true
+			backend: fast
+			type: pseudoanonymous
+		2:
+			meeting_id: 1
+			entitled_group_ids: [1]
+			pollmethod: Y
+			global_yes: true
+			backend: fast
+			type: pseudoanonymous
+		3:
+			meeting_id: 1
+			entitled_group_ids: [1]
+			pollmethod: Y
+			global_yes: true
+			backend: fast
+			type: pseudoanonymous
 
-			0,
-		},
+	meeting/1/users_enable_vote_weight: false
 
-		{
-			"Vote for other with delegation not in group",
-			`
-			poll/1:
-				meeting_id: 1
-				entitled_group_ids: [1]
-				pollmethod: Y
-				global_yes: true
-				backend: fast
-				type: pseudoanonymous
+	user/1:
+		is_present_in_meeting_ids: [1]
+		meeting_user_ids: [10]
 
-			meeting/1/users_enable_vote_delegations: true
+	meeting_user/10:
+		user_id: 1
+		group_ids: [1]
+		meeting_id: 1
+	`))}
 
-			user:
-				1:
-					is_present_in_meeting_ids: [1]
-					meeting_user_ids: [10]
-					
-				2:
-					meeting_user_ids: [20]
+	presentRequests := func() int {
+		ds.mu.Lock()
+		defer ds.mu.Unlock()
+		return ds.keys[presentKey]
+	}
 
-			meeting_user:
-				10:
-					meeting_id: 1
-					user_id: 1
+	backend := memory.New()
+	v, _, _ := vote.New(ctx, backend, backend, ds, true, vote.WithPresenceCacheTTL(time.Minute))
+	for _, pollID := range []int{1, 2, 3} {
+		backend.Start(ctx, pollID)
+	}
 
-				20:
-					group_ids: []
-					meeting_id: 1	
-					vote_delegated_to_id: 10
-			`,
-			`{"user_id": 2, "value":"Y"}`,
+	if err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`)); err != nil {
+		t.Fatalf("Vote on poll 1: %v", err)
+	}
 
-			0,
-		},
+	firstVote := presentRequests()
+	if firstVote == 0 {
+		t.Fatalf("Presence was not fetched")
+	}
 
-		{
-			"Vote for other with self not in group",
-			`
-			poll/1:
-				meeting_id: 1
-				entitled_group_ids: [1]
-				pollmethod: Y
-				global_yes: true
-				backend: fast
-				type: pseudoanonymous
+	if err := v.Vote(ctx, 2, 1, strings.NewReader(`{"value":"Y"}`)); err != nil {
+		t.Fatalf("Vote on poll 2: %v", err)
+	}
 
-			meeting/1/users_enable_vote_delegations: true
+	if got := presentRequests(); got != firstVote {
+		t.Errorf("Second vote fetched the presence again")
+	}
 
-			user:
-				1:
-					is_present_in_meeting_ids: [1]
-					meeting_user_ids: [10]
-					
-				2:
-					meeting_user_ids: [20]
+	if err := v.Clear(ctx, 2); err != nil {
+		t.Fatalf("Clear: %v", err)
+	}
 
-			meeting_user:
-				10:
-					meeting_id: 1
-					user_id: 1
+	if err := v.Vote(ctx, 3, 1, strings.NewReader(`{"value":"Y"}`)); err != nil {
+		t.Fatalf("Vote on poll 3: %v", err)
+	}
 
-				20:
-					group_ids: [1]
-					meeting_id: 1	
-					vote_delegated_to_id: 10
-			`,
-			`{"user_id": 2, "value":"Y"}`,
+	if got := presentRequests(); got == firstVote {
+		t.Errorf("Presence was not fetched again after Clear")
+	}
+}
 
-			2,
-		},
+func TestErrorTypes(t *testing.T) {
+	for _, tt := range []struct {
+		err        vote.TypeError
+		expectType string
+	}{
+		{vote.ErrInternal, "internal"},
+		{vote.ErrExists, "exist"},
+		{vote.ErrNotExists, "not-exist"},
+		{vote.ErrInvalid, "invalid"},
+		{vote.ErrDoubleVote, "double-vote"},
+		{vote.ErrNotAllowed, "not-allowed"},
+		{vote.ErrStopped, "stopped"},
+		{vote.ErrTemporary, "temporary"},
+		{vote.ErrPollFull, "poll-full"},
+		{vote.ErrMaintenance, "maintenance"},
+		{vote.ErrNotStarted, "not-started"},
+		{vote.ErrWrongPollType, "wrong-poll-type"},
 	} {
-		t.Run(tt.name, func(t *testing.T) {
-			ctx := context.Background()
-			backend := memory.New()
-			ds := &StubGetter{data: dsmock.YAMLData(tt.data)}
-
-			v, _, _ := vote.New(ctx, backend, backend, ds, true)
-
-			if err := backend.Start(ctx, 1); err != nil {
-				t.Fatalf("backend.Start(): %v", err)
+		t.Run(tt.expectType, func(t *testing.T) {
+			if got := tt.err.Type(); got != tt.expectType {
+				t.Errorf("Got type %s, expected %s", got, tt.expectType)
 			}
 
-			err := v.Vote(ctx, 1, 1, strings.NewReader(tt.vote))
-
-			if tt.expectVotedUserID != 0 {
-				if err != nil {
-					t.Fatalf("Vote returned unexpected error: %v", err)
-				}
-
-				backend.AssertUserHasVoted(t, 1, tt.expectVotedUserID)
-				return
+			var body struct {
+				Error string `json:"error"`
+			}
+			if err := json.Unmarshal([]byte(tt.err.Error()), &body); err != nil {
+				t.Fatalf("decoding error: %v", err)
 			}
 
-			if !errors.Is(err, vote.ErrNotAllowed) {
-				t.Fatalf("Expected NotAllowedError, got: %v", err)
+			if body.Error != tt.expectType {
+				t.Errorf("Error() has type %s, expected %s", body.Error, tt.expectType)
 			}
 		})
 	}
+
+	if got := vote.TypeError(vote.ErrWrongPollType + 1).Type(); got != vote.TypeInternal {
+		t.Errorf("Unknown error has type %s, expected %s. Add new errors to this test", got, vote.TypeInternal)
+	}
 }
 
-func TestVoteWeight(t *testing.T) {
-	for _, tt := range []struct {
-		name string
-		data string
+func TestVoteMethodN(t *testing.T) {
+	ctx := context.Background()
 
-		expectWeight string
-	}{
-		{
-			"No weight",
-			`
+	for _, method := range []string{"Y", "N"} {
+		t.Run(method, func(t *testing.T) {
+			backend := memory.New()
+			backend.Start(ctx, 1)
+			v, _, _ := vote.New(ctx, backend, backend, dsmock.NewFlow(dsmock.YAMLData(fmt.Sprintf(`---
 			poll/1:
 				meeting_id: 1
 				entitled_group_ids: [1]
-				pollmethod: Y
-				global_yes: true
+				pollmethod: %s
+				option_ids: [1, 2]
+				min_votes_amount: 1
+				max_votes_amount: 1
+				max_votes_per_option: 1
+				state: started
 				backend: fast
 				type: pseudoanonymous
 
-			meeting/1/id: 1
+			meeting/1/users_enable_vote_weight: false
 
 			user/1:
 				is_present_in_meeting_ids: [1]
 				meeting_user_ids: [10]
+
 			meeting_user/10:
 				group_ids: [1]
+				user_id: 1
 				meeting_id: 1
-			`,
-			"1.000000",
-		},
-		{
-			"Weight enabled, user has no weight",
-			`
-			poll/1:
-				meeting_id: 1
-				entitled_group_ids: [1]
-				pollmethod: Y
-				global_yes: true
-				backend: fast
-				type: pseudoanonymous
 
-			meeting/1/users_enable_vote_weight: true
+			group/1/meeting_user_ids: [10]
+			option/1/meeting_id: 1
+			option/2/meeting_id: 1
+			`, method))), true)
 
-			user/1:
-				is_present_in_meeting_ids: [1]
-				meeting_user_ids: [10]
-			meeting_user/10:
-				group_ids: [1]
-				meeting_id: 1
-			`,
-			"1.000000",
-		},
-		{
-			"Weight enabled, user has default weight",
-			`
-			poll/1:
-				meeting_id: 1
-				entitled_group_ids: [1]
-				pollmethod: Y
-				global_yes: true
-				backend: fast
-				type: pseudoanonymous
+			if err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":{"1":1}}`)); err != nil {
+				t.Fatalf("Vote: %v", err)
+			}
 
-			meeting/1/users_enable_vote_weight: true
+			result, err := v.Stop(ctx, 1)
+			if err != nil {
+				t.Fatalf("Stop: %v", err)
+			}
 
-			user/1:
-				is_present_in_meeting_ids: [1]
-				meeting_user_ids: [10]
-				default_vote_weight: "2.000000"
-			meeting_user/10:
-				group_ids: [1]
-				meeting_id: 1
-			`,
-			"2.000000",
-		},
-		{
-			"Weight enabled, user has default weight and meeting weight",
-			`
-			poll/1:
-				meeting_id: 1
-				entitled_group_ids: [1]
-				pollmethod: Y
-				global_yes: true
-				backend: fast
-				type: pseudoanonymous
+			if len(result.Votes) != 1 {
+				t.Fatalf("Got %d votes, expected 1", len(result.Votes))
+			}
+
+			expect := fmt.Sprintf(`{"value":{"1":1},"weight":"1.000000","method":"%s"}`, method)
+			if got := string(result.Votes[0]); got != expect {
+				t.Errorf("Got saved vote `%s`, expected `%s`", got, expect)
+			}
+		})
+	}
+}
+
+// staleCacheFlow is a flow with a cache, that does not receive updates. A key
+// is only loaded again, after it was invalidated.
+type staleCacheFlow struct {
+	*dsmock.Flow
+
+	mu    sync.Mutex
+	cache map[dskey.Key][]byte
+}
+
+func (f *staleCacheFlow) Get(ctx context.Context, keys ...dskey.Key) (map[dskey.Key][]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.cache == nil {
+		f.cache = make(map[dskey.Key][]byte)
+	}
+
+	var missing []dskey.Key
+	for _, key := range keys {
+		if _, ok := f.cache[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+
+	if len(missing) > 0 {
+		data, err := f.Flow.Get(ctx, missing...)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, key := range missing {
+			f.cache[key] = data[key]
+		}
+	}
+
+	out := make(map[dskey.Key][]byte, len(keys))
+	for _, key := range keys {
+		out[key] = f.cache[key]
+	}
+	return out, nil
+}
+
+func (f *staleCacheFlow) Invalidate(keys ...dskey.Key) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, key := range keys {
+		delete(f.cache, key)
+	}
+}
+
+func TestVoteReloadPoll(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ds := dsmock.NewFlow(dsmock.YAMLData(`---
+	poll/1:
+		meeting_id: 1
+		entitled_group_ids: [1]
+		pollmethod: Y
+		global_yes: true
+		state: started
+		backend: fast
+		type: pseudoanonymous
+
+	meeting/1/users_enable_vote_weight: false
+
+	user:
+		1:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [10]
+		2:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [20]
+
+	meeting_user:
+		10:
+			group_ids: [1]
+			user_id: 1
+			meeting_id: 1
+		20:
+			group_ids: [2]
+			user_id: 2
+			meeting_id: 1
+
+	group/1/meeting_user_ids: [10]
+	group/2/meeting_user_ids: [20]
+	`))
+	go ds.Update(ctx, nil)
+
+	flow := &staleCacheFlow{Flow: ds}
+	backend := memory.New()
+	v, _, _ := vote.New(ctx, backend, backend, flow, true)
+
+	if err := v.Start(ctx, 1); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	if err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`)); err != nil {
+		t.Fatalf("Vote of user 1: %v", err)
+	}
+
+	ds.Send(dsmock.YAMLData(`poll/1/entitled_group_ids: [1, 2]`))
+
+	if err := v.Vote(ctx, 1, 2, strings.NewReader(`{"value":"Y"}`)); !errors.Is(err, vote.ErrNotAllowed) {
+		t.Fatalf("Vote of user 2 with outdated cache returned %v, expected %v", err, vote.ErrNotAllowed)
+	}
+
+	if err := v.ReloadPoll(ctx, 1); err != nil {
+		t.Fatalf("ReloadPoll: %v", err)
+	}
+
+	if err := v.Vote(ctx, 1, 2, strings.NewReader(`{"value":"Y"}`)); err != nil {
+		t.Fatalf("Vote of user 2 after reload: %v", err)
+	}
+
+	result, err := v.Stop(ctx, 1)
+	if err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	if !reflect.DeepEqual(result.UserIDs, []int{1, 2}) {
+		t.Errorf("Got voted users %v, expected [1 2]", result.UserIDs)
+	}
+
+	t.Run("finished poll", func(t *testing.T) {
+		ds.Send(dsmock.YAMLData(`poll/1/state: finished`))
+
+		if err := v.ReloadPoll(ctx, 1); !errors.Is(err, vote.ErrWrongPollType) {
+			t.Errorf("ReloadPoll returned %v, expected %v", err, vote.ErrWrongPollType)
+		}
+	})
+}
 
-			meeting/1/users_enable_vote_weight: true
+func TestVoteWeightDecimals(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		options       []vote.Option
+		defaultWeight string
+		meetingWeight string
+		expectWeight  string
+		expectErr     error
+	}{
+		{"default weight", nil, "", "", "1.000000", nil},
+		{"user default weight", nil, "1", "", "1.000000", nil},
+		{"meeting weight", nil, "1", "2.5", "2.500000", nil},
+		{"two decimals", []vote.Option{vote.WithWeightDecimals(2)}, "", "2.555", "2.56", nil},
+		{"no decimals", []vote.Option{vote.WithWeightDecimals(0)}, "", "2.5", "3", nil},
+		{"malformed meeting weight", nil, "", "2,5", "", vote.ErrInvalid},
+		{"negative default weight", nil, "-1", "", "", vote.ErrInvalid},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			backend := memory.New()
+			backend.Start(ctx, 1)
 
-			user/1:
-				is_present_in_meeting_ids: [1]
-				meeting_user_ids: [10]
-				default_vote_weight: "2.000000"
-			meeting_user/10:
-				group_ids: [1]
-				meeting_id: 1
-				vote_weight: "3.000000"
-			`,
-			"3.000000",
-		},
-		{
-			"Weight enabled, user has default weight and meeting weight in other meeting",
-			`
+			data := dsmock.YAMLData(`
 			poll/1:
 				meeting_id: 1
 				entitled_group_ids: [1]
@@ -1096,212 +5677,225 @@ func TestVoteWeight(t *testing.T) {
 
 			user/1:
 				is_present_in_meeting_ids: [1]
-				meeting_user_ids: [10,11]
-				default_vote_weight: "2.000000"
+				meeting_user_ids: [10]
 			meeting_user/10:
 				group_ids: [1]
 				meeting_id: 1
-			meeting_user/11:
-				group_ids: [1]
-				meeting_id: 2
-				vote_weight: "3.000000"
-			`,
-			"2.000000",
-		},
-	} {
-		t.Run(tt.name, func(t *testing.T) {
-			ctx := context.Background()
-			backend := memory.New()
-			ds := &StubGetter{data: dsmock.YAMLData(tt.data)}
-			v, _, _ := vote.New(ctx, backend, backend, ds, true)
-
-			if err := backend.Start(ctx, 1); err != nil {
-				t.Fatalf("bakckend.Start: %v", err)
+			`)
+			if tt.defaultWeight != "" {
+				data[dskey.MustKey("user/1/default_vote_weight")] = []byte(strconv.Quote(tt.defaultWeight))
 			}
-
-			if err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`)); err != nil {
-				t.Fatalf("vote returned unexpected error: %v", err)
+			if tt.meetingWeight != "" {
+				data[dskey.MustKey("meeting_user/10/vote_weight")] = []byte(strconv.Quote(tt.meetingWeight))
 			}
 
-			data, _, _ := backend.Stop(ctx, 1)
+			v, _, _ := vote.New(ctx, backend, backend, dsmock.NewFlow(data), true, tt.options...)
 
-			if len(data) != 1 {
-				t.Fatalf("got %d vote objects, expected one", len(data))
+			result, err := v.VoteWithResult(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`))
+			if tt.expectErr != nil {
+				if !errors.Is(err, tt.expectErr) {
+					t.Fatalf("Got error %v, expected %v", err, tt.expectErr)
+				}
+				return
 			}
 
-			var decoded struct {
-				Weight string `json:"weight"`
-			}
-			if err := json.Unmarshal(data[0], &decoded); err != nil {
-				t.Fatalf("decoding voteobject returned unexpected error: %v", err)
+			if err != nil {
+				t.Fatalf("Vote: %v", err)
 			}
 
-			if decoded.Weight != tt.expectWeight {
-				t.Errorf("got weight %q, expected %q", decoded.Weight, tt.expectWeight)
+			if result.Weight != tt.expectWeight {
+				t.Errorf("Got weight %s, expected %s", result.Weight, tt.expectWeight)
 			}
 		})
 	}
 }
 
-func TestItLikeBackend(t *testing.T) {
+func TestVoteMeetingTurnout(t *testing.T) {
 	ctx := context.Background()
 	backend := memory.New()
+	flow := dsmock.NewFlow(dsmock.YAMLData(`---
+	meeting/1/poll_ids: [1, 2]
+	meeting/1/users_enable_vote_weight: false
 
-	ds := dsmock.NewFlow(dsmock.YAMLData(`---
-	organization/1/enable_electronic_voting: true
-	meeting/1:
-		name: my meeting
-		poll_couple_countdown: true
-		poll_countdown_id: 11
-		is_active_in_organization_id: 1
-		group_ids: [1]
-		meeting_user_ids: [11]
-	
-	projector_countdown/11:
-		default_time: 60
-		running: false
-		countdown_time: 60
-		meeting_id: 1
-
-	group/1/meeting_user_ids: [11]
-
-	option:
+	poll:
 		1:
 			meeting_id: 1
-			poll_id: 1
+			entitled_group_ids: [1]
+			pollmethod: Y
+			global_yes: true
+			state: started
+			backend: fast
+			type: pseudoanonymous
 		2:
 			meeting_id: 1
-			poll_id: 1
-	
-	user/1:
-		is_present_in_meeting_ids: [1]
-		meeting_user_ids: [11]
+			entitled_group_ids: [1, 2]
+			pollmethod: Y
+			global_yes: true
+			state: created
+			backend: fast
+			type: pseudoanonymous
 
-	meeting_user/11:
-		meeting_id: 1
-		user_id: 1
-		group_ids: [1]
-	
-	assignment/1:
-		title: test_assignment_tcLT59bmXrXif424Qw7K
-		open_posts: 1
-		meeting_id: 1
-	
-	poll/1:
-		content_object_id: assignment/1
-		title: test_title_04k0y4TwPLpJKaSvIGm1
-		state: started
-		meeting_id: 1
-		option_ids: [1, 2]
-		entitled_group_ids: [1]
-		votesinvalid: "0.000000"
-		votesvalid: "0.000000"
-		votescast: "0.000000"
-		backend: fast
-		pollmethod: YNA
-		type: named
-		
-	`))
+	user:
+		1:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [10]
+		2:
+			is_present_in_meeting_ids: [1]
+			meeting_user_ids: [20]
+		3:
+			meeting_user_ids: [30]
 
-	v, _, _ := vote.New(ctx, backend, backend, ds, true)
-	if err := backend.Start(ctx, 1); err != nil {
-		t.Fatalf("bakckend.Start: %v", err)
+	meeting_user:
+		10:
+			group_ids: [1]
+			user_id: 1
+			meeting_id: 1
+		20:
+			group_ids: [1, 2]
+			user_id: 2
+			meeting_id: 1
+		30:
+			group_ids: [2]
+			user_id: 3
+			meeting_id: 1
+
+	group/1/meeting_user_ids: [10, 20]
+	group/2/meeting_user_ids: [20, 30]
+	`), dsmock.NewCounter)
+	counter := flow.Middlewares()[0].(*dsmock.Counter)
+
+	v, _, _ := vote.New(ctx, backend, backend, flow, true)
+
+	if err := v.Start(ctx, 1); err != nil {
+		t.Fatalf("Start: %v", err)
 	}
 
-	if err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value": {"1": "Y"}}`)); err != nil {
-		t.Fatalf("vote returned unexpected error: %v", err)
+	if err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`)); err != nil {
+		t.Fatalf("Vote: %v", err)
 	}
 
-	backend.AssertUserHasVoted(t, 1, 1)
+	counter.Reset()
+	turnout, err := v.MeetingTurnout(ctx, 1)
+	if err != nil {
+		t.Fatalf("MeetingTurnout: %v", err)
+	}
+
+	expect := map[int]vote.PollTurnout{
+		1: {Votes: 1, Entitled: 2},
+		2: {Votes: 0, Entitled: 3},
+	}
+	if !reflect.DeepEqual(turnout, expect) {
+		t.Errorf("Got %v, expected %v", turnout, expect)
+	}
+
+	if got := counter.Count(); got > 4 {
+		t.Errorf("MeetingTurnout sent %d datastore requests, expected at most 4", got)
+	}
+
+	t.Run("unknown meeting", func(t *testing.T) {
+		if _, err := v.MeetingTurnout(ctx, 404); !errors.Is(err, vote.ErrNotExists) {
+			t.Errorf("Got error %v, expected %v", err, vote.ErrNotExists)
+		}
+	})
 }
 
-func TestVotedPolls(t *testing.T) {
+func TestVoteBallotTransformer(t *testing.T) {
 	ctx := context.Background()
-
-	backend := memory.New()
-	ds := dsmock.NewFlow(dsmock.YAMLData(`---
+	data := dsmock.YAMLData(`---
 	poll/1:
-		backend: memory
 		meeting_id: 1
-		type: pseudoanonymous
+		entitled_group_ids: [1]
 		pollmethod: Y
+		global_yes: true
+		state: started
+		backend: fast
+		type: pseudoanonymous
 
-	user/5/id: 5
-	`))
+	meeting/1/users_enable_vote_weight: false
 
-	backend.Start(ctx, 1)
-	backend.Vote(ctx, 1, 5, []byte(`"Y"`))
+	user/1:
+		is_present_in_meeting_ids: [1]
+		meeting_user_ids: [10]
 
-	v, _, _ := vote.New(ctx, backend, backend, ds, true)
+	meeting_user/10:
+		group_ids: [1]
+		user_id: 1
+		meeting_id: 1
 
-	got, err := v.Voted(ctx, []int{1, 2}, 5)
-	if err != nil {
-		t.Fatalf("VotedPolls() returned unexected error: %v", err)
-	}
+	group/1/meeting_user_ids: [10]
+	`)
 
-	expect := map[int][]int{1: {5}, 2: nil}
-	if !reflect.DeepEqual(got, expect) {
-		t.Errorf("Voted() == `%v`, expected `%v`", got, expect)
-	}
-}
+	t.Run("append field", func(t *testing.T) {
+		backend := memory.New()
+		backend.Start(ctx, 1)
 
-func TestVotedPollsWithDelegation(t *testing.T) {
-	ctx := context.Background()
-	backend := memory.New()
-	ds := dsmock.NewFlow(dsmock.YAMLData(`---
-	poll/1:
-		backend: memory
-		type: named
-		meeting_id: 40
-		pollmethod: Y
+		var transformedPoll int
+		transformer := func(pollID int, raw []byte) ([]byte, error) {
+			transformedPoll = pollID
 
-	user/5:
-		meeting_user_ids: [10]
-	meeting_user:
-		10:
-			meeting_id: 8
-			vote_delegations_from_ids: [11]
-		11:
-			user_id: 6
-		12:
-			user_id: 7
-		
-	`))
+			var ballot map[string]json.RawMessage
+			if err := json.Unmarshal(raw, &ballot); err != nil {
+				return nil, err
+			}
+			ballot["server_time"] = json.RawMessage(`1700000000`)
+			return json.Marshal(ballot)
+		}
 
-	backend.Start(ctx, 1)
-	backend.Vote(ctx, 1, 5, []byte(`"Y"`))
-	backend.Vote(ctx, 1, 6, []byte(`"Y"`))
-	backend.Vote(ctx, 1, 7, []byte(`"Y"`))
-	v, _, _ := vote.New(ctx, backend, backend, ds, true)
+		v, _, _ := vote.New(ctx, backend, backend, dsmock.NewFlow(data), true, vote.WithBallotTransformer(transformer))
 
-	got, err := v.Voted(ctx, []int{1, 2}, 5)
-	if err != nil {
-		t.Fatalf("Voted() returned unexected error: %v", err)
-	}
+		if err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`)); err != nil {
+			t.Fatalf("Vote: %v", err)
+		}
 
-	expect := map[int][]int{1: {5, 6}, 2: nil}
-	if !reflect.DeepEqual(got, expect) {
-		t.Errorf("Voted() == `%v`, expected `%v`", got, expect)
-	}
-}
+		if transformedPoll != 1 {
+			t.Errorf("Transformer was called with poll %d, expected 1", transformedPoll)
+		}
 
-func TestVoteCount(t *testing.T) {
-	ctx := context.Background()
-	backend1 := memory.New()
-	backend1.Start(ctx, 23)
-	backend1.Vote(ctx, 23, 1, []byte("vote"))
-	backend2 := memory.New()
-	backend2.Start(ctx, 42)
-	backend2.Vote(ctx, 42, 1, []byte("vote"))
-	backend2.Vote(ctx, 42, 2, []byte("vote"))
-	ds := dsmock.NewFlow(dsmock.YAMLData(``))
+		result, err := v.Stop(ctx, 1)
+		if err != nil {
+			t.Fatalf("Stop: %v", err)
+		}
 
-	v, _, _ := vote.New(ctx, backend1, backend2, ds, true)
+		if len(result.Votes) != 1 {
+			t.Fatalf("Got %d votes, expected 1", len(result.Votes))
+		}
 
-	count := v.VoteCount(ctx)
+		var saved struct {
+			Value      string `json:"value"`
+			ServerTime int    `json:"server_time"`
+		}
+		if err := json.Unmarshal(result.Votes[0], &saved); err != nil {
+			t.Fatalf("decoding saved vote: %v", err)
+		}
 
-	expect := map[int]int{23: 1, 42: 2}
-	if !reflect.DeepEqual(count, expect) {
-		t.Errorf("Got %v, expected %v", count, expect)
-	}
+		if saved.Value != "Y" || saved.ServerTime != 1700000000 {
+			t.Errorf("Got saved vote %s, expected value Y and server_time 1700000000", result.Votes[0])
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		backend := memory.New()
+		backend.Start(ctx, 1)
+
+		transformer := func(pollID int, raw []byte) ([]byte, error) {
+			return nil, errors.New("signing failed")
+		}
+
+		v, _, _ := vote.New(ctx, backend, backend, dsmock.NewFlow(data), true, vote.WithBallotTransformer(transformer))
+
+		err := v.Vote(ctx, 1, 1, strings.NewReader(`{"value":"Y"}`))
+		if err == nil {
+			t.Fatalf("Vote did not return an error")
+		}
+
+		var errTyped interface{ Type() string }
+		if errors.As(err, &errTyped) {
+			t.Errorf("Got error of type %s, expected an internal error", errTyped.Type())
+		}
+
+		votes, _, _ := backend.Stop(ctx, 1)
+		if len(votes) != 0 {
+			t.Errorf("Got %d saved votes, expected 0", len(votes))
+		}
+	})
 }
@@ -2,6 +2,7 @@ package vote
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 )
 
@@ -181,6 +182,42 @@ func TestVoteValidate(t *testing.T) {
 			`{"1":2,"2":2}`,
 			false,
 		},
+		{
+			"Method N, Vote Option",
+			pollConfig{
+				method:  "N",
+				options: []int{1, 2},
+			},
+			`{"1":1}`,
+			true,
+		},
+		{
+			"Method N, Vote on to many Options",
+			pollConfig{
+				method:  "N",
+				options: []int{1, 2},
+			},
+			`{"1":1,"2":1}`,
+			false,
+		},
+		{
+			"Method N, Vote wrong option",
+			pollConfig{
+				method:  "N",
+				options: []int{1, 2},
+			},
+			`{"5":1}`,
+			false,
+		},
+		{
+			"Method N, Vote Option with YNA",
+			pollConfig{
+				method:  "N",
+				options: []int{1, 2},
+			},
+			`{"1":"N"}`,
+			false,
+		},
 
 		// Test Method YN and YNA
 		{
@@ -283,6 +320,76 @@ func TestVoteValidate(t *testing.T) {
 			false,
 		},
 
+		// Test Method TEXT.
+		{
+			"Method TEXT, Vote Text",
+			pollConfig{
+				method: "TEXT",
+			},
+			`{"text":"Jane Doe"}`,
+			true,
+		},
+		{
+			"Method TEXT, Vote empty Text",
+			pollConfig{
+				method: "TEXT",
+			},
+			`{"text":""}`,
+			false,
+		},
+		{
+			"Method TEXT, Vote whitespace Text",
+			pollConfig{
+				method: "TEXT",
+			},
+			`{"text":"  "}`,
+			false,
+		},
+		{
+			"Method TEXT, Vote oversized Text",
+			pollConfig{
+				method: "TEXT",
+			},
+			`{"text":"` + strings.Repeat("a", 257) + `"}`,
+			false,
+		},
+		{
+			"Method TEXT, Vote Text with configured max length",
+			pollConfig{
+				method:        "TEXT",
+				maxTextLength: 4,
+			},
+			`{"text":"Jane Doe"}`,
+			false,
+		},
+		{
+			"Method TEXT, Vote Y",
+			pollConfig{
+				method:    "TEXT",
+				globalYes: true,
+			},
+			`"Y"`,
+			false,
+		},
+		{
+			"Method TEXT, Vote Option",
+			pollConfig{
+				method:  "TEXT",
+				options: []int{1, 2},
+			},
+			`{"1":1}`,
+			false,
+		},
+		{
+			"Method Y, Vote Text",
+			pollConfig{
+				method:    "Y",
+				globalYes: true,
+			},
+			`{"text":"Y"}`,
+			false,
+		},
+
 		// Unknown method
 		{
 			"Method Unknown",
@@ -314,3 +421,72 @@ func TestVoteValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestBallotDecode(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		ballot      string
+		expectError bool
+	}{
+		{"Global value", `{"value":"Y"}`, false},
+		{"Option amount", `{"value":{"1":1,"2":0}}`, false},
+		{"Option string", `{"value":{"1":"Y","2":"N"}}`, false},
+		{"Text", `{"value":{"text":"my answer"}}`, false},
+		{"With user id", `{"user_id":5,"value":"A"}`, false},
+		{"Option next to value", `{"value":"A","1":"Y"}`, true},
+		{"Nested value with option", `{"value":{"value":"A","1":"Y"}}`, true},
+		{"Text with option", `{"value":{"text":"my answer","1":"Y"}}`, true},
+		{"Nested value", `{"value":{"value":"A"}}`, true},
+		{"Normalized global", `{"value":{"global":"Y","weight":"1.000000"}}`, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var b ballot
+			err := json.Unmarshal([]byte(tt.ballot), &b)
+
+			if !tt.expectError {
+				if err != nil {
+					t.Fatalf("Unmarshal returned unexpected error: %v", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("Got no error")
+			}
+		})
+	}
+}
+
+func TestStoredValueDecode(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		value       string
+		expectStr   string
+		expectError bool
+	}{
+		{"Global value", `"Y"`, "Y", false},
+		{"Normalized global", `{"global":"Y","weight":"1.000000"}`, "Y", false},
+		{"Normalized global with option", `{"global":"Y","1":"Y"}`, "", true},
+		{"Option string", `{"1":"Y"}`, "", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var v storedValue
+			err := json.Unmarshal([]byte(tt.value), &v)
+
+			if tt.expectError {
+				if err == nil {
+					t.Fatalf("Got no error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unmarshal returned unexpected error: %v", err)
+			}
+
+			if v.str != tt.expectStr {
+				t.Errorf("Got value %q, expected %q", v.str, tt.expectStr)
+			}
+		})
+	}
+}
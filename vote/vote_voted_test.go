@@ -0,0 +1,114 @@
+package vote
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/OpenSlides/openslides-autoupdate-service/pkg/datastore/dsmock"
+	"github.com/OpenSlides/openslides-vote-service/backend/memory"
+)
+
+func TestLoadVotedDropsClearedPolls(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	ds := dsmock.NewFlow(dsmock.YAMLData(``))
+
+	backend.Start(ctx, 1)
+	backend.Vote(ctx, 1, 5, []byte("vote"))
+	backend.Start(ctx, 2)
+	backend.Vote(ctx, 2, 5, []byte("vote"))
+
+	v, _, err := New(ctx, backend, backend, ds, true)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if _, _, err := backend.Stop(ctx, 1); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	// Clear poll 2 only in the backend, like another instance would do it.
+	if err := backend.Clear(ctx, 2); err != nil {
+		t.Fatalf("Clear: %v", err)
+	}
+
+	if err := v.loadVoted(ctx); err != nil {
+		t.Fatalf("loadVoted: %v", err)
+	}
+
+	if _, ok := v.voted[1]; !ok {
+		t.Errorf("Stopped poll 1 was removed from voted")
+	}
+
+	if _, ok := v.voted[2]; ok {
+		t.Errorf("Cleared poll 2 is still in voted")
+	}
+}
+
+func TestClearRemovesVotedEntry(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	ds := dsmock.NewFlow(dsmock.YAMLData(``))
+
+	backend.Start(ctx, 1)
+	backend.Vote(ctx, 1, 5, []byte("vote"))
+
+	v, _, err := New(ctx, backend, backend, ds, true)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if err := v.Clear(ctx, 1); err != nil {
+		t.Fatalf("Clear: %v", err)
+	}
+
+	if _, ok := v.voted[1]; ok {
+		t.Errorf("Cleared poll 1 is still in voted")
+	}
+}
+
+// slowVotedBackend is a backend, that waits before it returns the voted users.
+type slowVotedBackend struct {
+	*memory.Backend
+	delay time.Duration
+	voted map[int][]int
+	err   error
+}
+
+func (b *slowVotedBackend) Voted(ctx context.Context) (map[int][]int, error) {
+	time.Sleep(b.delay)
+	return b.voted, b.err
+}
+
+func TestLoadVotedParallel(t *testing.T) {
+	ctx := context.Background()
+	ds := dsmock.NewFlow(dsmock.YAMLData(``))
+	delay := 100 * time.Millisecond
+
+	fast := &slowVotedBackend{Backend: memory.New(), delay: delay, voted: map[int][]int{1: {5}, 2: {5}}}
+	long := &slowVotedBackend{Backend: memory.New(), delay: delay, voted: map[int][]int{2: {6, 7}}}
+
+	start := time.Now()
+	v, _, err := New(ctx, fast, long, ds, true)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if took := time.Since(start); took >= 2*delay {
+		t.Errorf("loading voted took %s, expected less then %s", took, 2*delay)
+	}
+
+	// The long backend overrides the fast backend.
+	expect := map[int][]int{1: {5}, 2: {6, 7}}
+	if !reflect.DeepEqual(v.voted, expect) {
+		t.Errorf("Got voted %v, expected %v", v.voted, expect)
+	}
+
+	long.err = errors.New("long backend error")
+	if err := v.loadVoted(ctx); !errors.Is(err, long.err) {
+		t.Errorf("loadVoted returned %v, expected the error from the long backend", err)
+	}
+}